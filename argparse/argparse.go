@@ -72,6 +72,24 @@ func (h singleTaskHandler) numberOfTasks() numTasks {
 	return oneTask
 }
 
+// Takes a single task if the first argument is not a parameter.
+type optionalTaskHandler struct{}
+
+func (h optionalTaskHandler) handleTasks(cmd *msg.Cmd, args []string) ([]string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], ParamIdentifierPrefix) {
+		return args, nil
+	}
+	return singleTaskHandler{}.handleTasks(cmd, args)
+}
+
+func (h optionalTaskHandler) description() string {
+	return "[task]"
+}
+
+func (h optionalTaskHandler) numberOfTasks() numTasks {
+	return oneTask
+}
+
 type multiTaskHandler struct{}
 
 func (h multiTaskHandler) handleTasks(cmd *msg.Cmd, args []string) ([]string, error) {
@@ -135,9 +153,15 @@ type Description struct {
 }
 
 type ParamDescription struct {
-	ParamName        string // Name of the parameter
-	ParamValues      string // Description of possible values
-	ParamExplanation string // Explanation of this parameter
+	ParamName        string   // Name of the parameter
+	ParamAliases     []string // Short names of the parameter, if any
+	ParamValues      string   // Description of possible values
+	ParamExplanation string   // Explanation of this parameter
+}
+
+// Names gives the name of the parameter followed by its aliases, if any.
+func (d ParamDescription) Names() string {
+	return strings.Join(append([]string{d.ParamName}, d.ParamAliases...), ", ")
 }
 
 // TODO: Move methods to builder?
@@ -145,6 +169,7 @@ type Parser struct {
 	command     string
 	taskHandler taskHandler
 	argHandler  ArgHandler
+	strict      bool
 }
 
 func CommandParser(command string) *Parser {
@@ -164,6 +189,11 @@ func (p *Parser) TaskDescription() string {
 	}
 }
 
+// TakesTasks returns whether the command accepts task names.
+func (p *Parser) TakesTasks() bool {
+	return p.taskHandler.numberOfTasks() != noTasks
+}
+
 func (p *Parser) ParamDescription() []ParamDescription {
 	return p.argHandler.DescribeParameters()
 }
@@ -186,6 +216,12 @@ func (p *Parser) WithSingleTask() *Parser {
 	return p
 }
 
+// WithOptionalTask accepts a single task name which may be left out.
+func (p *Parser) WithOptionalTask() *Parser {
+	p.taskHandler = new(optionalTaskHandler)
+	return p
+}
+
 func (p *Parser) WithMultipleTasks() *Parser {
 	p.taskHandler = new(multiTaskHandler)
 	return p
@@ -217,12 +253,56 @@ func (p *Parser) Parse(args []string) (msg.Cmd, error) {
 	unusedArgs, err := p.argHandler.HandleArgs(&cmd, restArgs)
 	if err != nil {
 		return cmd, err
+	} else if p.strict && len(unusedArgs) > 0 {
+		return cmd, errors.Errorf("Unused arguments: %v", unusedArgs)
 	} else {
 		WarnUnused(unusedArgs)
 		return cmd, nil
 	}
 }
 
+// ApplyDefaults merges parameters configured as defaults for the command into
+// it. Explicitly given parameters take precedence: a default option or flag is
+// only applied if not given, default quantities only if none are given.
+func (p *Parser) ApplyDefaults(cmd *msg.Cmd, defaults []string) error {
+	if len(defaults) == 0 {
+		return nil
+	}
+	def := msg.Cmd{Op: p.command}
+	unused, err := p.argHandler.HandleArgs(&def, defaults)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid defaults for %s", p.command)
+	} else if len(unused) > 0 {
+		return errors.Errorf("Invalid defaults for %s: %v", p.command, unused)
+	}
+	for name, value := range def.Flags {
+		if _, ok := cmd.Flags[name]; !ok {
+			if cmd.Flags == nil {
+				cmd.Flags = make(map[string]bool)
+			}
+			cmd.Flags[name] = value
+		}
+	}
+	for name, value := range def.Opts {
+		if _, ok := cmd.Opts[name]; !ok {
+			if cmd.Opts == nil {
+				cmd.Opts = make(map[string]string)
+			}
+			cmd.Opts[name] = value
+		}
+	}
+	if len(cmd.Quantities) == 0 {
+		cmd.Quantities = def.Quantities
+	}
+	return nil
+}
+
+// Strict makes unused arguments an error rather than a warning.
+func (p *Parser) Strict(strict bool) *Parser {
+	p.strict = strict
+	return p
+}
+
 // Warn the user about arguments being unevaluated.
 // If args is empty, no warning is issued.
 func WarnUnused(args []string) {
@@ -248,7 +328,9 @@ func GetTaskNames(taskField string) ([]string, error) {
 
 // Whether the given name is valid for a task.
 func validTaskName(name string) bool {
-	if isParamIdentifier(name) {
+	if name == "" {
+		return false
+	} else if isParamIdentifier(name) {
 		return false
 	} else if hasWhitespace(name) {
 		return false
@@ -273,60 +355,132 @@ type Quantifier interface {
 	DescribeUsage() string
 }
 
+// MultiTokenQuantifier is implemented by quantifiers whose argument may span
+// several command line arguments, e.g. :between 2024-01-01 2024-03-31.
+type MultiTokenQuantifier interface {
+	Quantifier
+	// Tokens gives the number of arguments needed, the first being given.
+	Tokens(first string) int
+	// Join combines the arguments into one to parse.
+	Join(tokens []string) string
+}
+
 type Param struct {
 	Name        string
+	Aliases     []string // Short names, e.g. t for today
 	RequiresArg bool
 	Quantifier  Quantifier
 	Description string
+	kind        paramKind // How the parameter modifies the command
+	usage       string    // Usage description in the absence of a quantifier
+}
+
+type paramKind int
+
+const (
+	quantityParam paramKind = iota // Adds quantities to the command
+	flagParam                      // Sets a flag in the command
+	optionParam                    // Sets an option in the command
+)
+
+// Flag creates a parameter without argument, setting the flag of the same name.
+func Flag(name string, description string) Param {
+	return Param{Name: name, RequiresArg: false, Description: description, kind: flagParam}
+}
+
+// Option creates a parameter whose argument is stored in the option of the
+// same name. The usage describes possible values.
+func Option(name string, usage string, description string) Param {
+	return Param{Name: name, RequiresArg: true, Description: description, kind: optionParam, usage: usage}
 }
 
 func (p Param) Describe() ParamDescription {
+	usage := p.usage
+	if p.Quantifier != nil {
+		usage = p.Quantifier.DescribeUsage()
+	}
+	var aliases []string
+	for _, alias := range p.Aliases {
+		aliases = append(aliases, ParamIdentifierPrefix+alias)
+	}
 	return ParamDescription{
 		ParamName:        ParamIdentifierPrefix + p.Name,
-		ParamValues:      p.Quantifier.DescribeUsage(),
+		ParamAliases:     aliases,
+		ParamValues:      usage,
 		ParamExplanation: p.Description,
 	}
 }
 
+// Apply the parameter with the given argument to the command.
+func (p Param) apply(cmd *msg.Cmd, pArg string) error {
+	switch p.kind {
+	case flagParam:
+		if cmd.Flags == nil {
+			cmd.Flags = make(map[string]bool)
+		}
+		cmd.Flags[p.Name] = true
+	case optionParam:
+		if cmd.Opts == nil {
+			cmd.Opts = make(map[string]string)
+		}
+		cmd.Opts[p.Name] = pArg
+	default:
+		q, err := p.Quantifier.Parse(pArg)
+		if err != nil {
+			return err
+		}
+		cmd.Quantities = append(cmd.Quantities, q...)
+	}
+	return nil
+}
+
 type paramHandler struct {
-	params map[string]Param
+	params  map[string]Param
+	aliases map[string]string // The parameter name for each alias
 }
 
 func (p paramHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
-	quant := []msg.Quantity{}
 	unused := []string{}
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if isParamIdentifier(arg) {
-			if param, ok := p.params[cleanParam(arg)]; ok {
-				pArg := ""
-				if param.RequiresArg {
-					if strings.Contains(arg, "=") {
-						// Quantity contained in argument.
-						pArg = strings.Split(arg, "=")[1]
-					} else {
-						// Quantity in next argument.
-						i++
-						if i == len(args) {
-							return args, errors.New("No argument for parameter " + param.Name)
-						}
-						pArg = args[i]
-					}
+			param, err := p.resolve(cleanParam(arg))
+			if err != nil {
+				return unused, err
+			}
+			pArg := ""
+			if param.RequiresArg {
+				if strings.Contains(arg, "=") {
+					// Quantity contained in argument.
+					pArg = strings.SplitN(arg, "=", 2)[1]
 				} else {
-					// If no arg is required, we can pass the empty string.
+					// Quantity in next argument.
+					i++
+					if i == len(args) {
+						return args, errors.New("No argument for parameter " + param.Name)
+					}
+					pArg = args[i]
 				}
-				// Parse and add to list.
-				q, err := param.Quantifier.Parse(pArg)
-				if err != nil {
-					return unused, err
+				if mq, ok := param.Quantifier.(MultiTokenQuantifier); ok {
+					// Quantity possibly continued in further arguments.
+					n := mq.Tokens(pArg) - 1
+					if i+n >= len(args) {
+						return args, errors.Errorf("Too few arguments for parameter %s", param.Name)
+					}
+					pArg = mq.Join(append([]string{pArg}, args[i+1:i+1+n]...))
+					i += n
 				}
-				quant = append(quant, q...)
+			} else {
+				// If no arg is required, we can pass the empty string.
+			}
+			// Parse and add to command.
+			if err := param.apply(cmd, pArg); err != nil {
+				return unused, err
 			}
 		} else {
 			unused = append(unused, arg)
 		}
 	}
-	cmd.Quantities = quant
 	return unused, nil
 }
 
@@ -350,14 +504,26 @@ func (h paramHandler) DescribeParameters() []ParamDescription {
 
 func HandlerForParams(params []Param) ArgHandler {
 	pmap := make(map[string]Param)
+	aliases := make(map[string]string)
 	for _, param := range params {
 		if _, ok := pmap[param.Name]; ok {
 			panic("Duplicate param name: " + param.Name)
 		}
 		pmap[param.Name] = param
+		for _, alias := range param.Aliases {
+			if _, ok := aliases[alias]; ok {
+				panic("Duplicate param alias: " + alias)
+			}
+			aliases[alias] = param.Name
+		}
+	}
+	for alias := range aliases {
+		if _, ok := pmap[alias]; ok {
+			panic("Param alias shadows param name: " + alias)
+		}
 	}
 
-	return paramHandler{params: pmap}
+	return paramHandler{params: pmap, aliases: aliases}
 }
 
 func isParamIdentifier(str string) bool {
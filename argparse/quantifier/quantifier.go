@@ -41,6 +41,22 @@ func (lq list) DescribeUsage() string {
 	return fmt.Sprintf("%s,...", lq.elem.DescribeUsage())
 }
 
+// The arguments needed to complete the last element of the list.
+func (lq list) Tokens(first string) int {
+	if mq, ok := lq.elem.(arg.MultiTokenQuantifier); ok {
+		parts := strings.Split(first, ",")
+		return mq.Tokens(parts[len(parts)-1])
+	}
+	return 1
+}
+
+func (lq list) Join(tokens []string) string {
+	if mq, ok := lq.elem.(arg.MultiTokenQuantifier); ok {
+		return mq.Join(tokens)
+	}
+	return strings.Join(tokens, "")
+}
+
 type pair struct {
 	tag  string
 	elem arg.Quantifier
@@ -73,11 +89,23 @@ func (p pair) DescribeUsage() string {
 	return fmt.Sprintf("%s:%[1]s", p.elem.DescribeUsage())
 }
 
+// Both elements can be given as separate arguments, e.g. 2024-01-01 2024-03-31.
+func (p pair) Tokens(first string) int {
+	if strings.Contains(first, ":") {
+		return 1
+	}
+	return 2
+}
+
+func (p pair) Join(tokens []string) string {
+	return strings.Join(tokens, ":")
+}
+
 type date struct{}
 
 func (dq date) Parse(str string) ([]msg.Quantity, error) {
 	_, err := time.Parse("2006-01-02", str)
-	return arg.SingleQuantity("day", str), err
+	return arg.SingleQuantity(TimeDay, str), err
 }
 
 func (dq date) DescribeUsage() string {
@@ -88,7 +116,7 @@ type month struct{}
 
 func (mq month) Parse(str string) ([]msg.Quantity, error) {
 	_, err := time.Parse("2006-01", str)
-	return arg.SingleQuantity("month", str), err
+	return arg.SingleQuantity(TimeMonth, str), err
 }
 
 func (mq month) DescribeUsage() string {
@@ -99,7 +127,7 @@ type year struct{}
 
 func (yq year) Parse(str string) ([]msg.Quantity, error) {
 	_, err := time.Parse("2006", str)
-	return arg.SingleQuantity("year", str), err
+	return arg.SingleQuantity(TimeYear, str), err
 }
 
 func (yq year) DescribeUsage() string {
@@ -267,16 +295,48 @@ func DynamicBetween() arg.Quantifier {
 	return TaggedPair(TimeBetween, SpecificDate())
 }
 
+// Range determines the time interval described by a quantity. The start is
+// inclusive, the end exclusive.
+func Range(q msg.Quantity) (time.Time, time.Time, error) {
+	var start, end time.Time
+	if len(q.Elems) == 0 {
+		return start, end, errors.Errorf("Invalid quantity: %v", q)
+	}
+	var err error
+	switch q.Type {
+	case TimeDay:
+		start, err = time.Parse("2006-01-02", q.Elems[0])
+		end = start.AddDate(0, 0, 1)
+	case TimeBetween:
+		if len(q.Elems) < 2 {
+			return start, end, errors.Errorf("Invalid quantity: %v", q)
+		}
+		start, err = time.Parse("2006-01-02", q.Elems[0])
+		if err == nil {
+			end, err = time.Parse("2006-01-02", q.Elems[1])
+		}
+	case TimeMonth:
+		start, err = time.Parse("2006-01", q.Elems[0])
+		end = start.AddDate(0, 1, 0)
+	case TimeYear:
+		start, err = time.Parse("2006", q.Elems[0])
+		end = start.AddDate(1, 0, 0)
+	default:
+		err = errors.Errorf("Unknown quantity type: %s", q.Type)
+	}
+	return start, end, err
+}
+
 // Quantity describing the week (Mon-Sun) a number of weeks before now.
 func weeksAgo(now time.Time, weeks int) []msg.Quantity {
 	daysSinceLastMonday := (int(now.Weekday()) + 6) % 7
 	// Monday in the target week
 	start := now.AddDate(0, 0, -(daysSinceLastMonday + 7*weeks))
-	// Sunday
-	end := start.AddDate(0, 0, 6)
-	// Avoid passing a future date.
-	if end.After(now) {
-		end = now
+	// The Monday after, as the end of a range is exclusive
+	end := start.AddDate(0, 0, 7)
+	// Avoid passing a future date beyond tomorrow, ending today's activity.
+	if tomorrow := now.AddDate(0, 0, 1); end.After(tomorrow) {
+		end = tomorrow
 	}
 
 	return arg.SingleQuantity(TimeBetween, isoDate(start), isoDate(end))
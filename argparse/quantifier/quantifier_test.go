@@ -0,0 +1,49 @@
+package quantifier_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+)
+
+// The dates the week quantity ranges over, the end being exclusive.
+func weekRange(t *testing.T, q argparse.Quantifier, arg string) (string, string) {
+	t.Helper()
+	quantities, err := q.Parse(arg)
+	if err != nil || len(quantities) != 1 {
+		t.Fatalf("unexpected quantities %v, error: %v", quantities, err)
+	}
+	start, end, err := quantifier.Range(quantities[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return start.Format("2006-01-02"), end.Format("2006-01-02")
+}
+
+func TestWeekBoundaries(t *testing.T) {
+	for _, c := range []struct {
+		now        time.Time
+		q          func(now time.Time) argparse.Quantifier
+		arg        string
+		start, end string
+	}{
+		// The current week includes today, but not the days after.
+		{time.Date(2020, 3, 4, 15, 0, 0, 0, time.UTC), thisWeek, "", "2020-03-02", "2020-03-05"},
+		{time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC), thisWeek, "", "2020-03-02", "2020-03-03"},
+		{time.Date(2020, 3, 8, 23, 59, 0, 0, time.UTC), thisWeek, "", "2020-03-02", "2020-03-09"},
+		// Past weeks end with Sunday, i.e. before the following Monday.
+		{time.Date(2020, 3, 4, 15, 0, 0, 0, time.UTC), quantifier.DynamicWeekOffset, "1", "2020-02-24", "2020-03-02"},
+		{time.Date(2020, 3, 8, 23, 59, 0, 0, time.UTC), quantifier.DynamicWeekOffset, "2", "2020-02-17", "2020-02-24"},
+	} {
+		start, end := weekRange(t, c.q(c.now), c.arg)
+		if start != c.start || end != c.end {
+			t.Errorf("at %v with %q: expected %s to %s, got %s to %s", c.now, c.arg, c.start, c.end, start, end)
+		}
+	}
+}
+
+func thisWeek(now time.Time) argparse.Quantifier {
+	return quantifier.FixedWeekOffset(now, 0)
+}
@@ -0,0 +1,105 @@
+package argparse
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Suggestions lists the candidates closest to the word, e.g. to point out
+// typos. Candidates too different from the word are never suggested.
+func Suggestions(word string, candidates []string) []string {
+	// Allow about one typo per four characters.
+	best := 1 + len(word)/4
+	var closest []string
+	for _, c := range candidates {
+		switch d := editDistance(word, c); {
+		case d < best:
+			best = d
+			closest = []string{c}
+		case d == best:
+			closest = append(closest, c)
+		}
+	}
+	sort.Strings(closest)
+	return closest
+}
+
+// DidYouMean phrases suggestions as a question to append to an error message,
+// empty if there are none.
+func DidYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return "; did you mean " + strings.Join(suggestions, " or ") + "?"
+}
+
+// The Levenshtein distance between a and b, swapping adjacent characters
+// counting as a single edit.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// Rows i-2, i-1 and i of the distance matrix.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minOf(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minOf(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func minOf(first int, rest ...int) int {
+	m := first
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
+
+// Find the parameter with the given name or alias, or the only one starting
+// with it. Otherwise, the error names the candidates.
+func (h paramHandler) resolve(name string) (Param, error) {
+	if param, ok := h.params[name]; ok {
+		return param, nil
+	}
+	if param, ok := h.params[h.aliases[name]]; ok {
+		return param, nil
+	}
+	var names, matching []string
+	for n := range h.params {
+		names = append(names, n)
+		if name != "" && strings.HasPrefix(n, name) {
+			matching = append(matching, n)
+		}
+	}
+	if len(matching) == 1 {
+		return h.params[matching[0]], nil
+	}
+	if len(matching) > 1 {
+		sort.Strings(matching)
+		return Param{}, errors.Errorf("Ambiguous parameter %s%s: could be %s",
+			ParamIdentifierPrefix, name, ParamIdentifierPrefix+strings.Join(matching, ", "+ParamIdentifierPrefix))
+	}
+	suggestions := Suggestions(name, names)
+	for i := range suggestions {
+		suggestions[i] = ParamIdentifierPrefix + suggestions[i]
+	}
+	return Param{}, errors.Errorf("Unknown parameter %s%s%s", ParamIdentifierPrefix, name, DidYouMean(suggestions))
+}
@@ -2,19 +2,24 @@
 package client
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/format"
 	"github.com/fgahr/tilo/msg"
 	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/transport"
 	"github.com/pkg/errors"
 )
 
@@ -53,11 +58,14 @@ func Dispatch(conf *config.Opts, args []string) bool {
 	command := args[0]
 	op, ok := operations[command]
 	if !ok {
-		showUsageAndDie(errors.Errorf("No such command: %s", command))
+		if success, found := runPlugin(conf, args); found {
+			return success
+		}
+		showUsageAndDie(unknownCommand(command))
 	}
 
 	cl := newClient(conf)
-	if cmd, err := op.Parser().Parse(args[1:]); err != nil {
+	if cmd, err := parse(conf, op, args); err != nil {
 		cl.PrintError(err)
 		cl.PrintShortDescription(op.DescribeShort())
 		return false
@@ -69,15 +77,92 @@ func Dispatch(conf *config.Opts, args []string) bool {
 	}
 }
 
+// The error for an unknown command, suggesting similar ones.
+func unknownCommand(command string) error {
+	return errors.Errorf("No such command: %s%s", command,
+		argparse.DidYouMean(argparse.Suggestions(command, CommandNames())))
+}
+
+// Parse the command given as arguments, including configured defaults.
+func parse(conf *config.Opts, op Operation, args []string) (msg.Cmd, error) {
+	if err := fixClock(conf); err != nil {
+		return msg.Cmd{}, err
+	}
+	parser := op.Parser().Strict(conf.Strict.Value == "true")
+	cmd, err := parser.Parse(args[1:])
+	if err != nil {
+		return cmd, err
+	}
+	switch conf.Condition.Value {
+	case "", config.IF_IDLE, config.IF_ACTIVE:
+		cmd.Condition = conf.Condition.Value
+	default:
+		return cmd, errors.Errorf("Invalid condition: %s. Possible values: %s, %s",
+			conf.Condition.Value, config.IF_IDLE, config.IF_ACTIVE)
+	}
+	if conf.Now.Value != "" {
+		// Data changed at a fixed time would be recorded at the wrong time.
+		if !server.IsReadOnly(op, cmd) {
+			return cmd, errors.Errorf("The %s command changes data and cannot run at a fixed time, see --now", args[0])
+		}
+		now := msg.Clock()
+		cmd.Now = &now
+	}
+	return cmd, parser.ApplyDefaults(&cmd, conf.CommandDefaults(args[0]))
+}
+
+// Layouts accepted for --now, in local time unless stating the zone.
+var nowLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// Replace the clock by the time given via --now, if any, so that relative
+// quantifiers like :this-week refer to it. The server is told the time along
+// with the command.
+func fixClock(conf *config.Opts) error {
+	if conf.Now.Value == "" {
+		return nil
+	}
+	for _, layout := range nowLayouts {
+		if now, err := time.ParseInLocation(layout, conf.Now.Value, time.Local); err == nil {
+			msg.Clock = func() time.Time { return now }
+			return nil
+		}
+	}
+	return errors.Errorf("Invalid time: %s. Expected e.g. 2020-03-01T23:59", conf.Now.Value)
+}
+
+// Execute runs a single command given as command line arguments, e.g.
+// "start foo", printing responses to out and messages to msgout. Unlike
+// Dispatch, it neither prints help nor runs plugins.
+func Execute(conf *config.Opts, args []string, out io.Writer, msgout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("No command given")
+	}
+	op, ok := operations[args[0]]
+	if !ok {
+		return unknownCommand(args[0])
+	}
+	cmd, err := parse(conf, op, args)
+	if err != nil {
+		return err
+	}
+	cl := &Client{conf: conf, out: out, msgout: msgout}
+	return op.ClientExec(cl, cmd)
+}
+
 // Client is a type bundling everything required for client-side operation.
 type Client struct {
 	conf   *config.Opts
 	conn   net.Conn
+	in     io.Reader // Messages from the server, decompressed if necessary
+	dec    *json.Decoder
+	rest   io.Reader // Remaining data after decoding, see Read
+	out    io.Writer // Where responses are printed
 	msgout io.Writer
 	err    error
 }
 
-// Read from the client's connection.
+// Read from the client's connection. Data already buffered while decoding
+// prior messages is read first.
 func (cl *Client) Read(p []byte) (n int, err error) {
 	if cl.Failed() {
 		return 0, errors.Wrap(cl.err, "cannot read from socket: preceding error")
@@ -85,11 +170,27 @@ func (cl *Client) Read(p []byte) (n int, err error) {
 	if cl.conn == nil {
 		panic("cannot read: connection not yet established")
 	}
-	return cl.conn.Read(p)
+	if cl.rest == nil {
+		in, err := cl.input()
+		if err != nil {
+			return 0, err
+		}
+		cl.rest = in
+		if cl.dec != nil {
+			cl.rest = io.MultiReader(cl.dec.Buffered(), in)
+		}
+	}
+	return cl.rest.Read(p)
 }
 
 func newClient(conf *config.Opts) *Client {
-	return &Client{conf: conf, msgout: os.Stderr}
+	return &Client{conf: conf, out: os.Stdout, msgout: os.Stderr}
+}
+
+// Fork gives another client with the same configuration and output, e.g. to
+// send requests while receiving notifications on this client's connection.
+func (c *Client) Fork() *Client {
+	return &Client{conf: c.conf, out: c.out, msgout: c.msgout}
 }
 
 // Failed returns whether the client has encountered an error.
@@ -117,6 +218,11 @@ func (c *Client) Error() error {
 	return c.err
 }
 
+// ClearError discards a preceding error, e.g. to retry an operation.
+func (c *Client) ClearError() {
+	c.err = nil
+}
+
 // SendReceivePrint executes a typical client lifecycle: a server round-trip.
 // This will establish a connection, send the command, receive a response, and
 // print it.
@@ -127,14 +233,85 @@ func (c *Client) SendReceivePrint(cmd msg.Cmd) {
 	c.PrintResponse(resp)
 }
 
+// Request sends a command and returns the response, for commands issuing
+// several requests. Unlike the other methods, failure does not persist.
+func (c *Client) Request(cmd msg.Cmd) (msg.Response, error) {
+	c.EstablishConnection()
+	c.SendToServer(cmd)
+	resp := c.ReceiveFromServer()
+	if c.Failed() {
+		err := c.Error()
+		c.ClearError()
+		return resp, err
+	}
+	return resp, resp.Err()
+}
+
+// CurrentTask asks the server for the active task, if any.
+func (c *Client) CurrentTask() (msg.Task, bool, error) {
+	resp, err := c.Request(msg.Cmd{Op: "current"})
+	if err != nil {
+		return msg.Task{}, false, errors.Wrap(err, "Unable to determine the active task")
+	}
+	for _, elem := range resp.Body {
+		if elem.Kind == msg.KindTaskEvent && elem.Event.Task.IsRunning() {
+			return elem.Event.Task, true, nil
+		}
+	}
+	return msg.Task{}, false, nil
+}
+
+// ConfigDir is the directory holding the configuration file.
+func (c *Client) ConfigDir() string {
+	return c.conf.ConfigDir()
+}
+
+// ConfigFile is the path of the configuration file, which may not exist yet.
+func (c *Client) ConfigFile() string {
+	return c.conf.ConfFile.Value
+}
+
+// ServerAddress is the configured protocol and socket of the server.
+func (c *Client) ServerAddress() (string, string) {
+	return c.conf.Protocol.Value, c.conf.Socket.Value
+}
+
+// CalDAVURL is the calendar from which planned entries are imported.
+func (c *Client) CalDAVURL() string {
+	return c.conf.CalDAVURL.Value
+}
+
+// DailyTarget is the configured time to be tracked per day, empty if unset.
+func (c *Client) DailyTarget() string {
+	return c.conf.DailyTarget.Value
+}
+
+// Jira is the site and token to which worklogs are posted.
+func (c *Client) Jira() (string, string) {
+	return c.conf.JiraURL.Value, c.conf.JiraToken.Value
+}
+
+// GitHubToken is the token with which issues are commented on.
+func (c *Client) GitHubToken() string {
+	return c.conf.GitHubToken.Value
+}
+
 // EstablishConnection ensures the server is up and the client is connected.
 func (c *Client) EstablishConnection() {
 	if c.Failed() {
 		return
 	}
-	c.EnsureServerIsRunning()
+	c.spawnServerIfAllowed()
+	if c.Failed() {
+		return
+	}
+	if c.conn != nil {
+		// Each request uses a fresh connection.
+		c.conn.Close()
+		c.conn, c.in, c.dec, c.rest = nil, nil, nil, nil
+	}
 	socket := c.conf.Socket.Value
-	if conn, err := net.Dial(c.conf.Protocol.Value, socket); err != nil {
+	if conn, err := transport.Dial(c.conf); err != nil {
 		c.err = errors.Wrap(err, "failed to connect to socket "+socket)
 	} else {
 		c.conn = conn
@@ -148,8 +325,25 @@ func (c *Client) SendToServer(cmd msg.Cmd) {
 	}
 	if !c.Connected() {
 		c.err = errors.New("cannot send to server: not connected")
+		return
+	}
+	if cmd.Source.IsEmpty() {
+		cmd.Source = msg.LocalSource()
+	}
+	if cmd.Client == nil {
+		cmd.Client = msg.CurrentBuild()
 	}
-	enc := json.NewEncoder(c.conn)
+	if cmd.Token == "" {
+		cmd.Token = c.conf.APIToken.Value
+	}
+	if cmd.Compression == "" && c.conf.Compression.Value == msg.CompressionGzip {
+		cmd.Compression = msg.CompressionGzip
+	}
+	var out io.Writer = c.conn
+	if c.tracing() {
+		out = io.MultiWriter(c.conn, &traceWriter{w: c.msgout, prefix: traceSent})
+	}
+	enc := json.NewEncoder(out)
 	c.err = errors.Wrap(enc.Encode(cmd), "failed to send command to server")
 }
 
@@ -162,12 +356,76 @@ func (c *Client) ReceiveFromServer() msg.Response {
 	}
 	if !c.Connected() {
 		c.err = errors.New("cannot receive from server: not connected")
+		resp.SetError(c.err)
+		return resp
+	}
+	dec, err := c.decoder()
+	if err != nil {
+		c.err = errors.Wrap(err, "failed to read response")
+		resp.SetError(c.err)
+		return resp
 	}
-	dec := json.NewDecoder(c.conn)
 	c.err = errors.Wrap(dec.Decode(&resp), "failed to decode response")
 	return resp
 }
 
+// ReceiveNext decodes the next value streamed by the server into v, typically
+// following a response. Returns false once the server has closed the
+// connection or an error occurred.
+func (c *Client) ReceiveNext(v interface{}) bool {
+	if c.Failed() {
+		return false
+	}
+	if !c.Connected() {
+		c.err = errors.New("cannot receive from server: not connected")
+		return false
+	}
+	dec, err := c.decoder()
+	if err == nil {
+		err = dec.Decode(v)
+	}
+	if err == io.EOF {
+		return false
+	} else if err != nil {
+		c.err = errors.Wrap(err, "failed to decode server message")
+		return false
+	}
+	return true
+}
+
+// The decoder for messages from the server.
+func (c *Client) decoder() (*json.Decoder, error) {
+	if c.dec == nil {
+		in, err := c.input()
+		if err != nil {
+			return nil, err
+		}
+		c.dec = json.NewDecoder(in)
+	}
+	return c.dec, nil
+}
+
+// The data received from the server, decompressed if necessary.
+func (c *Client) input() (io.Reader, error) {
+	if c.in == nil {
+		in, err := msg.Decompressor(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if c.tracing() {
+			in = io.TeeReader(in, &traceWriter{w: c.msgout, prefix: traceReceived})
+		}
+		c.in = in
+	}
+	return c.in, nil
+}
+
+// Output is where responses and other results are printed for the user,
+// typically standard output.
+func (c *Client) Output() io.Writer {
+	return c.out
+}
+
 // PrintResponse print a server response for the user to read.
 func (c *Client) PrintResponse(resp msg.Response) {
 	if c.Failed() {
@@ -177,24 +435,18 @@ func (c *Client) PrintResponse(resp msg.Response) {
 	// Response type might be rewritten.
 	if resp.Failed() {
 		c.err = resp.Err()
+	} else if f, err := format.Get(c.conf.Output.Value); err != nil {
+		c.err = err
 	} else {
-		w := tabwriter.NewWriter(os.Stdout, 0, 4, 1, ' ', 0)
-		for _, line := range resp.Body {
-			noTab := true
-			for _, word := range line {
-				if noTab {
-					noTab = false
-				} else {
-					fmt.Fprint(w, "\t")
-				}
-				fmt.Fprint(w, word)
-			}
-			fmt.Fprint(w, "\n")
-		}
-		c.err = w.Flush()
+		c.err = f.Format(c.out, resp)
 	}
 }
 
+// OutputFormat is the name of the configured output format.
+func (c *Client) OutputFormat() string {
+	return c.conf.Output.Value
+}
+
 // EnsureServerIsRunning will do nothing if the server is up, else it will start it.
 func (c *Client) EnsureServerIsRunning() {
 	// Query server status.
@@ -206,11 +458,15 @@ func (c *Client) EnsureServerIsRunning() {
 	}
 
 	// Start server if it isn't running.
+	c.resolveOrphanedTask()
 	if pid, err := server.StartInBackground(c.conf); err != nil {
 		c.err = errors.Wrap(err, "Could not start server")
 		return
 	} else {
 		fmt.Printf("Server started in background process: PID %d\n", pid)
+		if _, err := os.Stat(c.ConfigFile()); os.IsNotExist(err) {
+			fmt.Println("Using the default configuration; run `tilo init` to set up your own")
+		}
 	}
 
 	// Wait for server to become available
@@ -235,6 +491,53 @@ func (c *Client) EnsureServerIsRunning() {
 	}
 }
 
+// Start a server if none is running, according to the configured policy.
+func (c *Client) spawnServerIfAllowed() {
+	if c.ServerIsRunning() {
+		return
+	}
+	switch c.conf.Spawn.Value {
+	case config.SPAWN_ALWAYS:
+		c.EnsureServerIsRunning()
+	case config.SPAWN_ASK:
+		if c.AskYesNo("Server is not running. Start it now?") {
+			c.EnsureServerIsRunning()
+		} else {
+			c.err = errors.New("server is not running")
+		}
+	case config.SPAWN_NEVER:
+		c.err = errors.New("server is not running and automatic start is disabled; use `server start`")
+	default:
+		c.err = errors.Errorf("invalid spawn policy: %s (expected %s, %s, or %s)",
+			c.conf.Spawn.Value, config.SPAWN_ALWAYS, config.SPAWN_NEVER, config.SPAWN_ASK)
+	}
+}
+
+// AskYesNo asks the user a yes/no question. Anything but an explicit yes counts as no.
+// The answer is read from the terminal if possible, as standard input may
+// carry data for the command.
+func (c *Client) AskYesNo(question string) bool {
+	switch strings.ToLower(c.Ask(question + " [y/N]")) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// Ask the user a question, preferably on the terminal, returning the answer
+// without surrounding whitespace. Empty if there is no answer.
+func (c *Client) Ask(question string) string {
+	fmt.Fprintf(c.msgout, "%s ", question)
+	var in io.Reader = os.Stdin
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		in = tty
+	}
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
 // ServerIsRunning tries to determine whether the server is running.
 func (c *Client) ServerIsRunning() bool {
 	running, _ := server.IsRunning(c.conf)
@@ -243,9 +546,68 @@ func (c *Client) ServerIsRunning() bool {
 
 // RunServer will yield the current process to a freshly started server.
 func (c *Client) RunServer() {
+	if c.conf.Now.Value != "" {
+		c.err = errors.New("A server cannot run at a fixed time, see --now")
+		return
+	}
+	c.resolveOrphanedTask()
 	c.err = server.Run(c.conf)
 }
 
+// RunEphemeralServer runs a server on sample data, see server.RunEphemeral.
+func (c *Client) RunEphemeralServer() {
+	c.err = server.RunEphemeral(c.conf)
+}
+
+// Ask whether to continue a task left running by a server which stopped
+// unexpectedly, unless configured otherwise. The answer is passed on to the
+// server about to be started.
+func (c *Client) resolveOrphanedTask() {
+	if c.conf.OrphanedTask.Value != config.ORPHAN_ASK {
+		return
+	}
+	if running, _ := server.IsRunning(c.conf); running {
+		return
+	}
+	task, alive, ok := server.OrphanedTask(c.conf)
+	if !ok {
+		return
+	}
+	question := fmt.Sprintf("Task %s, started %s, was still running when the server stopped unexpectedly around %s.\n"+
+		"Continue it? Otherwise, it is saved as ended then.",
+		task.Name, task.Started.Format("2006-01-02 15:04"), alive.Format("2006-01-02 15:04"))
+	if c.AskYesNo(question) {
+		c.conf.OrphanedTask.Value = config.ORPHAN_ADOPT
+	} else {
+		c.conf.OrphanedTask.Value = config.ORPHAN_SAVE
+	}
+}
+
+// ReplayServerSession executes the commands recorded in the file, see
+// server.Replay.
+func (c *Client) ReplayServerSession(file string) {
+	count, took, err := server.Replay(c.conf, file)
+	if err != nil {
+		c.err = errors.Wrap(err, "Replay failed")
+		return
+	}
+	c.PrintMessage(fmt.Sprintf("Replayed %d commands in %v", count, took))
+}
+
+// OpenBackendReadOnly opens the configured backend directly for queries,
+// bypassing the server. The caller is responsible for closing it.
+func (c *Client) OpenBackendReadOnly() backend.Backend {
+	if c.Failed() {
+		return nil
+	}
+	b, err := backend.OpenReadOnly(c.conf)
+	if err != nil {
+		c.err = errors.Wrap(err, "unable to open backend")
+		return nil
+	}
+	return b
+}
+
 // PrintMessage prints the given message for the user.
 func (c *Client) PrintMessage(message string) {
 	fmt.Fprintln(c.msgout, message)
@@ -259,8 +621,10 @@ func (c *Client) PrintShortDescription(desc argparse.Description) {
 // Gather descriptions of operations in alphabetical order.
 func operationDescriptions() []argparse.Description {
 	var descriptions []argparse.Description
-	for _, op := range operations {
-		descriptions = append(descriptions, op.DescribeShort())
+	for name, op := range operations {
+		if !isHidden(name) {
+			descriptions = append(descriptions, op.DescribeShort())
+		}
 	}
 	byCmdAsc := func(i, j int) bool {
 		return descriptions[i].Cmd < descriptions[j].Cmd
@@ -270,6 +634,49 @@ func operationDescriptions() []argparse.Description {
 	return descriptions
 }
 
+// Commands for internal use, e.g. by shell completion, start with this prefix
+// and are not listed.
+const hiddenPrefix = "__"
+
+func isHidden(cmd string) bool {
+	return strings.HasPrefix(cmd, hiddenPrefix)
+}
+
+// CommandNames lists all commands available to the user in alphabetical order.
+func CommandNames() []string {
+	var names []string
+	for name := range operations {
+		if !isHidden(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AllCommandNames lists all commands, including hidden ones, in alphabetical
+// order.
+func AllCommandNames() []string {
+	var names []string
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CommandOperation gives the operation registered for the command.
+func CommandOperation(cmd string) (Operation, bool) {
+	op, ok := operations[cmd]
+	return op, ok
+}
+
+// CommandTakesTasks returns whether the command accepts task names.
+func CommandTakesTasks(cmd string) bool {
+	op, ok := operations[cmd]
+	return ok && op.Parser().TakesTasks()
+}
+
 // Whether a command with the given name exists.
 func (c *Client) CommandExists(cmd string) bool {
 	_, ok := operations[cmd]
@@ -295,7 +702,7 @@ func (c *Client) PrintSingleOperationHelp(cmd string) error {
 			w := tabwriter.NewWriter(c.msgout, 4, 4, 2, ' ', 0)
 			for _, par := range pdesc {
 				fmt.Fprintf(w, "    %s\t%s\t%s\n",
-					par.ParamName, par.ParamValues, par.ParamExplanation)
+					par.Names(), par.ParamValues, par.ParamExplanation)
 			}
 			w.Flush()
 		}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// SendConfirmed executes a command altering recorded data. The server is asked
+// for a preview of the affected data first, which is shown to the user for
+// confirmation. With assume_yes set (--yes), the command is executed without
+// asking; with dry_run set (--dry-run), only the preview is shown.
+//
+// The server-side operation needs to honour cmd.DryRun.
+func (c *Client) SendConfirmed(cmd msg.Cmd, question string) {
+	if c.conf.AssumeYes.Value == "true" && !c.DryRun() {
+		c.SendReceivePrint(cmd)
+		return
+	}
+	preview := cmd
+	preview.DryRun = true
+	c.SendReceivePrint(preview)
+	if c.Failed() || c.DryRun() {
+		return
+	}
+	if !c.AskYesNo(question) {
+		c.err = errors.New("Aborted")
+		return
+	}
+	c.SendReceivePrint(cmd)
+}
+
+// DryRun tells whether changes to recorded data are only to be previewed.
+func (c *Client) DryRun() bool {
+	return c.conf.DryRun.Value == "true"
+}
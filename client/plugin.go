@@ -0,0 +1,33 @@
+package client
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/fgahr/tilo/config"
+)
+
+// Prefix of executables providing additional commands, as in tilo-foo.
+const pluginPrefix = "tilo-"
+
+// Run the executable tilo-<command> from PATH, passing the remaining arguments
+// and the configuration in its environment. Returns whether the plugin
+// succeeded and whether one was found at all.
+func runPlugin(conf *config.Opts, args []string) (bool, bool) {
+	path, err := exec.LookPath(pluginPrefix + args[0])
+	if err != nil {
+		return false, false
+	}
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = conf.MergeIntoEnv(os.Environ())
+	if err := cmd.Run(); err != nil {
+		if _, exited := err.(*exec.ExitError); !exited {
+			printError(err, os.Stderr)
+		}
+		return false, true
+	}
+	return true, true
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"bytes"
+	"io"
+)
+
+// Prefixes marking the direction of traced messages.
+const (
+	traceSent     = "--> "
+	traceReceived = "<-- "
+)
+
+// Writes everything passing through to w, each line marked with a prefix.
+type traceWriter struct {
+	w       io.Writer
+	prefix  string
+	midLine bool
+}
+
+func (t *traceWriter) Write(p []byte) (int, error) {
+	buf := bytes.Buffer{}
+	for _, b := range p {
+		if !t.midLine {
+			buf.WriteString(t.prefix)
+			t.midLine = true
+		}
+		buf.WriteByte(b)
+		if b == '\n' {
+			t.midLine = false
+		}
+	}
+	if _, err := t.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Whether to print all messages exchanged with the server.
+func (c *Client) tracing() bool {
+	return c.conf.Trace.Value == "true"
+}
@@ -18,7 +18,7 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+	return argparse.CommandParser(op.Command()).WithOptionalTask().WithoutParams()
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -27,7 +27,8 @@ func (op operation) DescribeShort() argparse.Description {
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
 	header := "Abort the currently active task without logging the time"
-	footer := "Use the `stop` command to log the time of a task"
+	footer := "Use the `stop` command to log the time of a task\n" +
+		"With a task given, fails unless it is the active one, e.g. in scripts"
 	return header, footer
 }
 
@@ -39,9 +40,13 @@ func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
-	task, stopped := srv.StopCurrentTask()
-	if stopped {
-		resp.AddStoppedTask(task)
+	if err := srv.ExpectActiveTask(req.Cmd.TaskNames); err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	task, aborted := srv.AbortCurrentTask()
+	if aborted {
+		resp.AddAbortedTask(task)
 	} else {
 		resp.SetError(errors.New("No active task"))
 	}
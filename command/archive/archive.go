@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramUndo = "undo"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "archive-task"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Flag(paramUndo, "Restore an archived task"),
+	}
+	return argparse.CommandParser(op.Command()).WithSingleTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Hide a finished task")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Mark a task as archived so that it no longer appears in completion and :all queries"
+	footer := "Recorded entries are kept; query the task by name or use :include-archived to see them\n\n" +
+		"Examples\n" +
+		"    tilo archive-task oldproject        # Hide oldproject\n" +
+		"    tilo archive-task oldproject :undo  # Show it again"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrapf(cl.Error(), "Failed to archive task '%s'", cmd.TaskNames[0])
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	task := req.Cmd.TaskNames[0]
+	archived := !req.Cmd.Flags[paramUndo]
+	if err := srv.Backend.SetArchived(task, archived); err != nil {
+		resp.SetError(err)
+	} else if archived {
+		resp.AddMessage("Archived " + task)
+	} else {
+		resp.AddMessage("Restored " + task)
+	}
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
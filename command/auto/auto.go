@@ -0,0 +1,262 @@
+package auto
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramWatchDir = "watch-dir"
+	paramIdle     = "idle"
+)
+
+// The time without changes after which the task is stopped, by default.
+const defaultIdle = 5 * time.Minute
+
+// How often a heartbeat is sent while files of the same project change.
+const heartbeatInterval = time.Minute
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "auto"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramWatchDir, "<dir>", "The directory containing one subdirectory per project"),
+		argparse.Option(paramIdle, "<duration>", "Stop the task after this long without changes; 5m by default"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Track work on projects by watching their files")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Watch a directory of projects and track time on the project whose files change\n" +
+		"Each subdirectory of the watched directory is a task of the same name"
+	footer := "Runs until interrupted, stopping the task it started, if any\n" +
+		"Hidden files and directories, e.g. .git, are ignored\n\n" +
+		"Examples\n" +
+		"    tilo auto :watch-dir ~/src            # Track whichever project in ~/src is edited\n" +
+		"    tilo auto :watch-dir ~/src :idle=15m  # Allow for longer pauses"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	root, idle, err := autoOptions(cmd)
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "Unable to watch files")
+	}
+	defer watcher.Close()
+	if err := watchTree(watcher, root); err != nil {
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	t := tracker{cl: cl}
+	idleTimer := time.NewTimer(idle)
+	defer idleTimer.Stop()
+	fmt.Fprintln(cl.Output(), "Watching", root)
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return t.stop("watcher closed")
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				// Subdirectories need to be watched explicitly.
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() && !hidden(root, ev.Name) {
+					watchTree(watcher, ev.Name)
+				}
+			}
+			if project, ok := projectOf(root, ev.Name); ok {
+				t.activity(project, time.Now())
+				resetTimer(idleTimer, idle)
+			}
+		case err := <-watcher.Errors:
+			cl.PrintError(errors.Wrap(err, "Error watching files"))
+		case <-idleTimer.C:
+			if err := t.stop("idle"); err != nil {
+				cl.PrintError(err)
+			}
+		case <-sigChan:
+			return t.stop("interrupted")
+		}
+	}
+}
+
+func autoOptions(cmd msg.Cmd) (string, time.Duration, error) {
+	root, ok := cmd.Opts[paramWatchDir]
+	if !ok {
+		return "", 0, errors.New("Require a directory to watch, e.g. :watch-dir ~/src")
+	}
+	if strings.HasPrefix(root, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", 0, errors.Wrap(err, "Unable to expand "+root)
+		}
+		root = filepath.Join(home, root[2:])
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "Invalid directory")
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", 0, errors.Errorf("Not a directory: %s", root)
+	}
+
+	idle := defaultIdle
+	if i, ok := cmd.Opts[paramIdle]; ok {
+		if idle, err = time.ParseDuration(i); err != nil || idle <= 0 {
+			return "", 0, errors.Errorf("Invalid idle time: %s", i)
+		}
+	}
+	return root, idle, nil
+}
+
+// Add watches for a directory and all non-hidden directories beneath it.
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Possibly removed in the meantime.
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return errors.Wrap(watcher.Add(path), "Unable to watch "+path)
+	})
+}
+
+// The project a changed file belongs to, i.e. the name of the subdirectory
+// of root containing it. Files directly in root and hidden files belong to
+// no project.
+func projectOf(root string, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || hidden(root, path) {
+		return "", false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) < 2 {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// Whether any element of the path below root is hidden.
+func hidden(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return true
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// Keeps track of the task started by this client.
+type tracker struct {
+	cl        *client.Client
+	task      string    // The task started, empty if none
+	heartbeat time.Time // The time of the latest heartbeat sent
+}
+
+// Note activity on a project, starting its task if necessary.
+func (t *tracker) activity(project string, now time.Time) {
+	if project == t.task {
+		if now.Sub(t.heartbeat) >= heartbeatInterval {
+			t.send(msg.Cmd{Op: "heartbeat", Opts: map[string]string{"task": project}})
+			t.heartbeat = now
+		}
+		return
+	}
+	if _, err := argparse.GetTaskNames(project); err != nil {
+		t.cl.PrintError(errors.Wrap(err, "Not tracking "+project))
+		return
+	}
+	if t.send(msg.Cmd{Op: "start", TaskNames: []string{project}}) {
+		t.task = project
+		t.heartbeat = now
+		fmt.Fprintln(t.cl.Output(), now.Format("15:04:05"), "Started", project)
+	}
+}
+
+// Stop the task started, unless another one has been started since.
+func (t *tracker) stop(reason string) error {
+	if t.task == "" {
+		return nil
+	}
+	defer func() { t.task = "" }()
+	if current, running, err := t.cl.CurrentTask(); err != nil {
+		return err
+	} else if !running || current.Name != t.task {
+		return nil
+	}
+	if t.send(msg.Cmd{Op: "stop"}) {
+		fmt.Fprintln(t.cl.Output(), time.Now().Format("15:04:05"), "Stopped", t.task, "("+reason+")")
+		return nil
+	}
+	return errors.Errorf("Failed to stop %s", t.task)
+}
+
+// Send a command, reporting failure to the user.
+func (t *tracker) send(cmd msg.Cmd) bool {
+	if _, err := t.cl.Request(cmd); err != nil {
+		t.cl.PrintError(err)
+		return false
+	}
+	return true
+}
+
+// Reset a timer that may or may not have fired yet.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
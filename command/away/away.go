@@ -0,0 +1,134 @@
+package away
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramKeep    = "keep"
+	paramDiscard = "discard"
+	paramAssign  = "assign"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "away"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Flag(paramKeep, "Keep the time away on the running task"),
+		argparse.Flag(paramDiscard, "Remove the time away from the running task"),
+		argparse.Option(paramAssign, "<task>", "Move the time away to another task"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Decide on time spent away from the running task")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Show a period without activity on the running task and decide whether to keep it\n" +
+		"Periods are detected when a heartbeat arrives after `away_threshold` without one"
+	footer := "Without parameters, the decision is asked for interactively\n" +
+		"Listeners are notified of away periods, e.g. to ask the user in a tray icon\n\n" +
+		"Examples\n" +
+		"    tilo away                 # Show the period and decide\n" +
+		"    tilo away :discard        # Do not count it towards the running task\n" +
+		"    tilo away :assign=meeting # Move it to another task"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if !decided(cmd) {
+		// Show the period, then ask what to do with it.
+		cl.SendReceivePrint(cmd)
+		if cl.Failed() {
+			return cl.Error()
+		}
+		if cmd.Flags == nil {
+			cmd.Flags = make(map[string]bool)
+		}
+		if cmd.Opts == nil {
+			cmd.Opts = make(map[string]string)
+		}
+		switch answer := cl.Ask("Keep it (k), discard it (d), or move it to another task (task name)?"); answer {
+		case "":
+			return nil
+		case "k", "keep":
+			cmd.Flags[paramKeep] = true
+		case "d", "discard":
+			cmd.Flags[paramDiscard] = true
+		default:
+			if _, err := argparse.GetTaskNames(answer); err != nil {
+				return err
+			}
+			cmd.Opts[paramAssign] = answer
+		}
+	}
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to handle the time away")
+}
+
+// Whether the command says what to do with the time away.
+func decided(cmd msg.Cmd) bool {
+	return cmd.Flags[paramKeep] || cmd.Flags[paramDiscard] || cmd.Opts[paramAssign] != ""
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	away, ok := srv.PendingAway()
+	if !ok {
+		resp.SetError(errors.New("No away period pending"))
+		return srv.Answer(req, resp)
+	}
+
+	var err error
+	var done string
+	length := away.Duration().Truncate(time.Second)
+	assign := req.Cmd.Opts[paramAssign]
+	switch {
+	case req.Cmd.Flags[paramKeep]:
+		err = srv.KeepAway()
+		done = fmt.Sprintf("Kept %v on %s", length, away.Task)
+	case req.Cmd.Flags[paramDiscard]:
+		err = srv.ReassignAway("")
+		done = fmt.Sprintf("Discarded %v", length)
+	case assign != "":
+		if _, err = argparse.GetTaskNames(assign); err == nil {
+			err = srv.ReassignAway(assign)
+		}
+		done = fmt.Sprintf("Moved %v to %s", length, assign)
+	default:
+		resp.AddKeyValue("Task", away.Task)
+		resp.AddKeyValue("Away since", away.Since.Format("2006-01-02 15:04:05"))
+		resp.AddKeyValue("Back at", away.Until.Format("2006-01-02 15:04:05"))
+		resp.AddKeyValue("Duration", length.String())
+		return srv.Answer(req, resp)
+	}
+
+	if err != nil {
+		resp.SetError(err)
+	} else {
+		resp.AddMessage(done)
+		resp.AddCurrentTask(srv.CurrentTask)
+	}
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
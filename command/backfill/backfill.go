@@ -0,0 +1,181 @@
+package backfill
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramPerDay   = "per-day"
+	paramAt       = "at"
+	paramWeekdays = "weekdays"
+	// Time of day the entries start by default
+	defaultAt = "09:00"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "backfill"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Option(paramPerDay, "<duration>", "The time to record per day, e.g. 8h"),
+		argparse.Option(paramAt, "HH:MM", "The time of day the entries start, "+defaultAt+" by default"),
+		argparse.Flag(paramWeekdays, "Leave out Saturdays and Sundays"),
+	)
+	return argparse.CommandParser(op.Command()).WithSingleTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Record the same time on each day of a period")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Record one entry per day of a period, e.g. for leave, conferences or flat allocations"
+	footer := "With :between, both days given are included\n" +
+		"The entries are listed for confirmation first, see the README\n\n" +
+		"Examples\n" +
+		"    tilo backfill vacation :between=2024-07-01:2024-07-05 :per-day=8h\n" +
+		"    tilo backfill conference :last-week :per-day=6h :at=10:00 :weekdays"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	entries, err := entriesFor(cmd)
+	if err != nil {
+		return err
+	}
+	cmd.Body = nil
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			panic(err)
+		}
+		cmd.Body = append(cmd.Body, []string{string(data)})
+	}
+	cl.EstablishConnection()
+	cl.SendConfirmed(cmd, fmt.Sprintf("Record these %d entries?", len(entries)))
+	return errors.Wrap(cl.Error(), "Failed to backfill")
+}
+
+// The entries to record, computed by the client as days begin and end in its
+// time zone.
+func entriesFor(cmd msg.Cmd) ([]msg.Task, error) {
+	if len(cmd.Quantities) != 1 {
+		return nil, errors.New("Require a single period, e.g. :between=2024-07-01:2024-07-05")
+	}
+	perDay, err := time.ParseDuration(cmd.Opts[paramPerDay])
+	if err != nil || perDay <= 0 || perDay > 24*time.Hour {
+		return nil, errors.Errorf("Require the time per day, e.g. :per-day=8h, got '%s'", cmd.Opts[paramPerDay])
+	}
+	at := defaultAt
+	if a, ok := cmd.Opts[paramAt]; ok {
+		at = a
+	}
+	startOfDay, err := time.Parse("15:04", at)
+	if err != nil {
+		return nil, errors.Errorf("Invalid time of day: %s", at)
+	}
+	first, end, err := quantifier.Range(cmd.Quantities[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid period")
+	}
+	if cmd.Quantities[0].Type == quantifier.TimeBetween {
+		end = end.AddDate(0, 0, 1)
+	}
+	var entries []msg.Task
+	for day := first; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if cmd.Flags[paramWeekdays] && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		started := time.Date(day.Year(), day.Month(), day.Day(), startOfDay.Hour(), startOfDay.Minute(), 0, 0, time.Local)
+		entries = append(entries, msg.Task{Name: cmd.TaskNames[0], Started: started, Ended: started.Add(perDay), HasEnded: true})
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("No days to record in the period given")
+	}
+	return entries, nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	var entries []msg.Task
+	for _, line := range req.Cmd.Body {
+		entry := msg.Task{}
+		if len(line) != 1 {
+			resp.SetError(errors.New("Malformed entry"))
+		} else if err := json.Unmarshal([]byte(line[0]), &entry); err != nil {
+			resp.SetError(errors.Wrap(err, "Malformed entry"))
+		} else if err := entry.Validate(); err != nil {
+			resp.SetError(err)
+		}
+		if resp.Failed() {
+			return srv.Answer(req, resp)
+		}
+		entry.Source = req.Cmd.Source
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		resp.SetError(errors.New("No entries given"))
+		return srv.Answer(req, resp)
+	}
+
+	overlapping, err := countOverlapping(srv, entries)
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	}
+	if req.Cmd.DryRun {
+		resp.AddEntries(entries)
+	} else {
+		for _, entry := range entries {
+			if err := srv.SaveTask(entry); err != nil {
+				resp.SetError(err)
+				return srv.Answer(req, resp)
+			}
+		}
+		resp.AddKeyValue("Recorded entries", fmt.Sprint(len(entries)))
+	}
+	if overlapping > 0 {
+		resp.AddMessage(fmt.Sprintf("%d of them overlap recorded time", overlapping))
+	}
+	return srv.Answer(req, resp)
+}
+
+// The number of entries overlapping entries already recorded.
+func countOverlapping(srv *server.Server, entries []msg.Task) (int, error) {
+	var count int
+	for _, entry := range entries {
+		overlaps := false
+		err := srv.Backend.ForEachTaskBetween(nil, entry.Started, entry.Ended, func(task msg.Task) error {
+			overlaps = true
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if overlaps {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
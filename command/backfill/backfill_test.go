@@ -0,0 +1,29 @@
+package backfill_test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/fgahr/tilo/command/backfill"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestBackfillOneEntryPerDay(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.Conf.AssumeYes.Value = "true"
+	// Saturday to Wednesday, both included
+	out := srv.MustRun("backfill", "vacation", ":between=2024-07-06:2024-07-10", ":per-day=8h", ":weekdays")
+	if !strings.Contains(out, "Recorded entries 3") {
+		t.Errorf("expected three entries to be recorded, got:\n%s", out)
+	}
+	out = srv.MustRun("backfill", "vacation", ":day=2024-07-08", ":per-day=4h", ":at=13:00")
+	if !strings.Contains(out, "1 of them overlap recorded time") {
+		t.Errorf("expected an overlap to be reported, got:\n%s", out)
+	}
+
+	if _, err := srv.Run("backfill", "vacation", ":day=2024-07-08"); err == nil {
+		t.Error("expected an error without the time per day")
+	}
+}
@@ -0,0 +1,234 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/format"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTask = "task"
+	// Set from the first argument not being a parameter
+	optMonth = "month"
+	// Width of a single day in the grid
+	cellWidth = 7
+)
+
+// Takes the month as a plain argument, besides the usual parameters.
+type monthHandler struct {
+	params argparse.ArgHandler
+}
+
+func (h monthHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	unused, err := h.params.HandleArgs(cmd, args)
+	if err != nil || len(unused) == 0 {
+		return unused, err
+	}
+	if cmd.Opts == nil {
+		cmd.Opts = make(map[string]string)
+	}
+	cmd.Opts[optMonth] = unused[0]
+	return unused[1:], nil
+}
+
+func (h monthHandler) TakesParameters() bool {
+	return true
+}
+
+func (h monthHandler) DescribeParameters() []argparse.ParamDescription {
+	month := argparse.ParamDescription{
+		ParamName:        "",
+		ParamValues:      "YYYY-MM",
+		ParamExplanation: "The month to show, the current one by default",
+	}
+	return append([]argparse.ParamDescription{month}, h.params.DescribeParameters()...)
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "calendar"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramTask, "<task>", "Only show time spent on the given task"),
+	}
+	handler := monthHandler{params: argparse.HandlerForParams(params)}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(handler)
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:    op.Command(),
+		First:  "<month>",
+		Second: "[parameters]",
+		What:   "Show daily totals for a month",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Print a month as a calendar with the total time tracked on each day"
+	footer := "Examples\n" +
+		"    tilo calendar                    # This month across all tasks\n" +
+		"    tilo calendar 2024-05 :task=foo  # Time spent on foo in May 2024"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	month, err := parseMonth(cmd, msg.Clock())
+	if err != nil {
+		return err
+	}
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	if cl.OutputFormat() != format.Text {
+		cl.PrintResponse(resp)
+		return errors.Wrap(cl.Error(), "Failed to query the server")
+	}
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Failed to query the server")
+	}
+	if resp.Failed() {
+		return resp.Err()
+	}
+	_, err = fmt.Fprint(cl.Output(), render(month, dailyTotals(resp)))
+	return err
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	now := req.Cmd.CurrentTime()
+	month, err := parseMonth(req.Cmd, now)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	task := query.TskAllTasks
+	if t, ok := req.Cmd.Opts[paramTask]; ok {
+		task = t
+	}
+	if sum, err := srv.Backend.GetDailyTotals(task, month, month.AddDate(0, 1, 0)); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+	} else {
+		resp.AddQuerySummaries(withCurrentTask(sum, srv.CurrentTask, task, month, now))
+	}
+	return srv.Answer(req, resp)
+}
+
+// Add the time spent on the running task to the day it was started, if that
+// day is part of the month.
+func withCurrentTask(sum []msg.Summary, current msg.Task, task string, month time.Time, now time.Time) []msg.Summary {
+	if !current.IsRunning() || (task != query.TskAllTasks && task != current.Name) {
+		return sum
+	}
+	if current.Started.Before(month) || !current.Started.Before(month.AddDate(0, 1, 0)) {
+		return sum
+	}
+	day := current.Started.Format("2006-01-02")
+	running := now.Sub(current.Started)
+	for i := range sum {
+		if len(sum[i].Details.Elems) > 0 && sum[i].Details.Elems[0] == day {
+			sum[i].Total += running
+			sum[i].End = now
+			return sum
+		}
+	}
+	return append(sum, msg.Summary{
+		Task:    task,
+		Details: msg.Quantity{Type: quantifier.TimeDay, Elems: []string{day}},
+		Total:   running,
+		Start:   current.Started,
+		End:     now,
+	})
+}
+
+// The first instant of the requested month.
+func parseMonth(cmd msg.Cmd, now time.Time) (time.Time, error) {
+	m, ok := cmd.Opts[optMonth]
+	if !ok {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	}
+	month, err := time.ParseInLocation("2006-01", m, now.Location())
+	if err != nil {
+		return month, errors.Errorf("Invalid month: %s", m)
+	}
+	return month, nil
+}
+
+// The total per day of the month, indexed by day.
+func dailyTotals(resp msg.Response) map[int]time.Duration {
+	totals := make(map[int]time.Duration)
+	for _, elem := range resp.Body {
+		if elem.Kind != msg.KindSummaryRow || len(elem.Summary.Details.Elems) == 0 {
+			continue
+		}
+		if day, err := time.Parse("2006-01-02", elem.Summary.Details.Elems[0]); err == nil {
+			totals[day.Day()] += elem.Summary.Total
+		}
+	}
+	return totals
+}
+
+// Render the month as a grid, weeks starting on Monday. Each week takes two
+// lines: the days of the month and the time tracked on them.
+func render(month time.Time, totals map[int]time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", month.Format("January 2006"))
+	var header strings.Builder
+	for _, wd := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		fmt.Fprintf(&header, "%-*s", cellWidth, wd)
+	}
+	fmt.Fprintf(&b, "%s\n", strings.TrimRight(header.String(), " "))
+	days := month.AddDate(0, 1, -1).Day()
+	offset := (int(month.Weekday()) + 6) % 7
+	var total time.Duration
+	for first := 1 - offset; first <= days; first += 7 {
+		var dayLine, totalLine strings.Builder
+		for day := first; day < first+7; day++ {
+			if day < 1 || day > days {
+				fmt.Fprintf(&dayLine, "%-*s", cellWidth, "")
+				fmt.Fprintf(&totalLine, "%-*s", cellWidth, "")
+				continue
+			}
+			fmt.Fprintf(&dayLine, "%-*d", cellWidth, day)
+			fmt.Fprintf(&totalLine, "%-*s", cellWidth, formatTotal(totals[day]))
+			total += totals[day]
+		}
+		fmt.Fprintf(&b, "%s\n%s\n", strings.TrimRight(dayLine.String(), " "), strings.TrimRight(totalLine.String(), " "))
+	}
+	fmt.Fprintf(&b, "Total: %s\n", formatTotal(total))
+	return b.String()
+}
+
+// A duration in hours and minutes, e.g. 3:25; a dash if nothing is tracked.
+func formatTotal(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	return fmt.Sprintf("%d:%02d", minutes/60, minutes%60)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"encoding/json"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+// Everything known about the installed version's commands.
+type registry struct {
+	Version  string `json:"version"`
+	Commands []spec `json:"commands"`
+}
+
+// The capabilities of a single command.
+type spec struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Usage       []string    `json:"usage"`           // The argument classes, e.g. [task,..]
+	Hidden      bool        `json:"hidden"`          // Meant for internal use, not listed by help
+	Tasks       string      `json:"tasks,omitempty"` // Description of the task names taken, if any
+	Params      []paramSpec `json:"params"`
+}
+
+// A parameter of a command.
+type paramSpec struct {
+	Name        string   `json:"name"`              // Including the prefix, e.g. :today
+	Aliases     []string `json:"aliases,omitempty"` // Short names, e.g. :t
+	Values      string   `json:"values,omitempty"`  // Usage of the argument or quantifier, if any
+	Description string   `json:"description"`
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "__commands"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Describe all commands as JSON for external tools")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Describe all commands of this version, including their parameters, as JSON"
+	footer := "Meant for shell completions, graphical interfaces and documentation"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	listed := make(map[string]bool)
+	for _, name := range client.CommandNames() {
+		listed[name] = true
+	}
+	reg := registry{Version: msg.Version, Commands: []spec{}}
+	for _, name := range client.AllCommandNames() {
+		op, _ := client.CommandOperation(name)
+		s := describe(name, op)
+		s.Hidden = !listed[name]
+		reg.Commands = append(reg.Commands, s)
+	}
+	return errors.Wrap(json.NewEncoder(cl.Output()).Encode(reg), "Unable to describe commands")
+}
+
+// Describe a single command.
+func describe(name string, op client.Operation) spec {
+	desc := op.DescribeShort()
+	s := spec{
+		Name:        name,
+		Description: desc.What,
+		Usage:       []string{},
+		Tasks:       op.Parser().TaskDescription(),
+		Params:      []paramSpec{},
+	}
+	for _, arg := range []string{desc.First, desc.Second} {
+		if arg != "" {
+			s.Usage = append(s.Usage, arg)
+		}
+	}
+	for _, p := range op.Parser().ParamDescription() {
+		s.Params = append(s.Params, paramSpec{Name: p.ParamName, Aliases: p.ParamAliases, Values: p.ParamValues, Description: p.ParamExplanation})
+	}
+	return s
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,213 @@
+package compact
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramGap    = "gap"
+	paramDryRun = "dry-run"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "compact"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramGap, "<duration>", "The longest break between entries to merge; merge_gap by default"),
+		argparse.Flag(paramDryRun, "Only list the entries resulting from merging"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Merge adjacent entries of the same task")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Merge entries of the same task separated by a short break into a single entry\n" +
+		"Notes of the merged entries are kept"
+	footer := "The merged entries are listed for confirmation first, see the README\n" +
+		"Merges are synchronized to other devices, see the README\n\n" +
+		"Examples\n" +
+		"    tilo compact :gap=30s :dry-run  # List what would be merged\n" +
+		"    tilo compact                    # Merge using merge_gap from the configuration"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if gap, ok := cmd.Opts[paramGap]; ok {
+		if d, err := time.ParseDuration(gap); err != nil || d <= 0 {
+			return errors.Errorf("Invalid gap: %s", gap)
+		}
+	}
+	if cmd.Flags[paramDryRun] {
+		cmd.DryRun = true
+		cl.SendReceivePrint(cmd)
+	} else {
+		cl.SendConfirmed(cmd, "Merge these entries?")
+	}
+	return errors.Wrap(cl.Error(), "Failed to merge entries")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	if srv.AppendOnly() {
+		resp.SetError(errors.Errorf("The %s backend only appends entries, they cannot be merged", srv.Backend.Name()))
+		return srv.Answer(req, resp)
+	}
+	gap := srv.MergeGap()
+	if g, ok := req.Cmd.Opts[paramGap]; ok {
+		gap, _ = time.ParseDuration(g)
+	}
+	if gap <= 0 {
+		resp.SetError(errors.New("Require a gap, e.g. :gap=30s, or merge_gap in the configuration"))
+		return srv.Answer(req, resp)
+	}
+	runs, err := findRuns(srv.Backend, gap)
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	}
+	if d, ok := srv.SplitAt(); ok {
+		runs = withinDays(runs, d)
+	}
+	var merged []msg.Task
+	entries := 0
+	for _, run := range runs {
+		merged = append(merged, spanning(run))
+		entries += len(run)
+	}
+	if req.Cmd.DryRun {
+		resp.AddEntries(merged)
+		resp.AddKeyValue("Entries to merge", fmt.Sprint(entries))
+		resp.AddKeyValue("Resulting entries", fmt.Sprint(len(merged)))
+		return srv.Answer(req, resp)
+	}
+	for i, run := range runs {
+		var dups []backend.Duplicate
+		for _, task := range run {
+			dups = append(dups, backend.Duplicate{Entry: task, Of: merged[i]})
+		}
+		if err := srv.ReplaceEntries([]msg.Task{merged[i]}, dups); err != nil {
+			resp.SetError(err)
+			return srv.Answer(req, resp)
+		}
+	}
+	resp.AddKeyValue("Merged entries", fmt.Sprint(entries))
+	resp.AddKeyValue("Resulting entries", fmt.Sprint(len(merged)))
+	return srv.Answer(req, resp)
+}
+
+// Runs of at least two entries of the same task, each starting at most gap
+// after the previous ones ended.
+func findRuns(b backend.Backend, gap time.Duration) ([][]msg.Task, error) {
+	byTask := make(map[string][]msg.Task)
+	var names []string
+	err := b.ForEachTaskBetween(nil, time.Unix(0, 0), time.Now().AddDate(1, 0, 0), func(task msg.Task) error {
+		if _, ok := byTask[task.Name]; !ok {
+			names = append(names, task.Name)
+		}
+		byTask[task.Name] = append(byTask[task.Name], task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	var runs [][]msg.Task
+	for _, name := range names {
+		tasks := byTask[name]
+		sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Started.Before(tasks[j].Started) })
+		run := []msg.Task{tasks[0]}
+		end := tasks[0].Ended
+		for _, task := range tasks[1:] {
+			if task.Started.Sub(end) > gap {
+				if len(run) > 1 {
+					runs = append(runs, run)
+				}
+				run = nil
+				end = task.Ended
+			}
+			run = append(run, task)
+			if task.Ended.After(end) {
+				end = task.Ended
+			}
+		}
+		if len(run) > 1 {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// Divide the runs at the start of each day, as merged entries would be split
+// there again. Runs of a single entry are dropped.
+func withinDays(runs [][]msg.Task, d server.DayStart) [][]msg.Task {
+	var result [][]msg.Task
+	for _, run := range runs {
+		var part []msg.Task
+		var next time.Time
+		for _, task := range run {
+			if len(part) > 0 && !task.Started.Before(next) {
+				if len(part) > 1 {
+					result = append(result, part)
+				}
+				part = nil
+			}
+			if len(part) == 0 {
+				next = d.Next(task.Started.Local())
+			}
+			part = append(part, task)
+		}
+		if len(part) > 1 {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// The entry spanning a run of entries, with the notes of all of them.
+func spanning(run []msg.Task) msg.Task {
+	merged := msg.Task{Name: run[0].Name, Started: run[0].Started, Ended: run[0].Ended, HasEnded: true, Source: run[0].Source}
+	for _, task := range run {
+		if task.Ended.After(merged.Ended) {
+			merged.Ended = task.Ended
+		}
+		for _, note := range task.Notes {
+			if !containsNote(merged.Notes, note) {
+				merged.Notes = append(merged.Notes, note)
+			}
+		}
+	}
+	return merged
+}
+
+func containsNote(notes []string, note string) bool {
+	for _, n := range notes {
+		if n == note {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,86 @@
+package compact_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/compact"
+	_ "github.com/fgahr/tilo/command/note"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/sync"
+	"github.com/fgahr/tilo/msg"
+	_ "github.com/fgahr/tilo/server/backend/csvfile"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestCompactRecordsChanges(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.MustRun("note", "bar")
+	srv.At(started.Add(30 * time.Minute))
+	srv.MustRun("stop")
+	srv.At(started.Add(40 * time.Minute))
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	srv.MustRun("compact", ":gap=15m")
+	out := srv.MustRun("sync", ":export")
+	var merged []msg.Task
+	removed := 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var change msg.Change
+		if err := json.Unmarshal([]byte(line), &change); err != nil {
+			t.Fatalf("invalid change %q: %v", line, err)
+		}
+		switch {
+		case change.Kind == msg.ChangeRemoval:
+			removed++
+		case change.Kind == msg.ChangeEntry && change.Task.Ended.Equal(started.Add(time.Hour)) && change.Task.Started.Equal(started):
+			merged = append(merged, change.Task)
+		}
+	}
+	if len(merged) != 1 || len(merged[0].Notes) != 1 || merged[0].Notes[0] != "bar" {
+		t.Errorf("expected the merged entry with its note in the change log, got:\n%s", out)
+	}
+	if removed != 2 {
+		t.Errorf("expected the removal of both entries in the change log, got:\n%s", out)
+	}
+}
+
+func TestCompactRefusedWhenAppendOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tilo.csv")
+	srv := tilotest.StartServer(t, "--backend=csv", "--csv-file="+file)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 9, 0, 0, 0, time.Local)
+	for i := 0; i < 2; i++ {
+		srv.At(started.Add(time.Duration(i) * time.Hour))
+		srv.MustRun("start", "foo")
+		srv.At(started.Add(time.Duration(i)*time.Hour + 30*time.Minute))
+		srv.MustRun("stop")
+	}
+
+	srv.Conf.AssumeYes.Value = "true"
+	_, err := srv.Run("compact", ":gap=1h")
+	if err == nil || !strings.Contains(err.Error(), "only appends entries") {
+		t.Errorf("expected compacting to be refused, got: %v", err)
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 3 {
+		t.Errorf("expected the header and both entries to be kept, got:\n%s", content)
+	}
+}
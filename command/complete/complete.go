@@ -0,0 +1,150 @@
+package complete
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// The maximum number of suggested task names.
+const maxSuggestions = 50
+
+// Takes the command to complete for and the word to complete.
+type completionHandler struct{}
+
+func (h completionHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	if len(args) > 2 {
+		return args, errors.New("Expected a command and a prefix")
+	}
+	cmd.Body = [][]string{args}
+	return nil, nil
+}
+
+func (h completionHandler) TakesParameters() bool {
+	return true
+}
+
+func (h completionHandler) DescribeParameters() []argparse.ParamDescription {
+	return []argparse.ParamDescription{
+		argparse.ParamDescription{
+			ParamName:        "",
+			ParamValues:      "[command] [prefix]",
+			ParamExplanation: "The command being completed and the word to complete",
+		},
+	}
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "__complete"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(completionHandler{})
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:   op.Command(),
+		First: "[command] [prefix]",
+		What:  "List completions for shell completion scripts",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "List possible completions, one per line"
+	footer := "With a single argument, command names are completed\n" +
+		"Otherwise task names are completed, most recently used first"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	var args []string
+	if len(cmd.Body) > 0 {
+		args = cmd.Body[0]
+	}
+	if len(args) < 2 {
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		for _, name := range client.CommandNames() {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintln(cl.Output(), name)
+			}
+		}
+		return nil
+	}
+	if !client.CommandTakesTasks(args[0]) {
+		return nil
+	}
+
+	var resp msg.Response
+	if cl.ServerIsRunning() {
+		cl.EstablishConnection()
+		cl.SendToServer(cmd)
+		resp = cl.ReceiveFromServer()
+	} else if b := cl.OpenBackendReadOnly(); b != nil {
+		// Completion should never start a server.
+		defer b.Close()
+		resp = respond(b, args[1])
+	}
+	if cl.Failed() {
+		return cl.Error()
+	}
+	if resp.Failed() {
+		return resp.Err()
+	}
+	for _, elem := range resp.Body {
+		fmt.Fprintln(cl.Output(), elem.Message)
+	}
+	return nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	if len(req.Cmd.Body) == 0 || len(req.Cmd.Body[0]) < 2 {
+		resp := msg.Response{}
+		resp.SetError(errors.New("Nothing to complete"))
+		return srv.Answer(req, resp)
+	}
+	return srv.Answer(req, respond(srv.Backend, req.Cmd.Body[0][1]))
+}
+
+// Task names matching the prefix, one message each. Archived tasks are left
+// out.
+func respond(b backend.Backend, prefix string) msg.Response {
+	resp := msg.Response{Status: msg.RespSuccess}
+	archived, err := backend.ArchivedSet(b)
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return resp
+	}
+	names, err := b.TaskNames(prefix, maxSuggestions+len(archived))
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return resp
+	}
+	suggested := 0
+	for _, name := range names {
+		if !archived[name] && suggested < maxSuggestions {
+			resp.AddMessage(name)
+			suggested++
+		}
+	}
+	return resp
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
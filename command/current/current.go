@@ -1,6 +1,9 @@
 package current
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/client"
 	"github.com/fgahr/tilo/command"
@@ -27,26 +30,71 @@ func (op operation) DescribeShort() argparse.Description {
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
 	header := "Determine the currently active task, if any"
-	footer := "Exits with non-zero status if no task is active"
+	footer := "Also shows the length of the current or preceding break and today's sessions\n" +
+		"Exits with non-zero status if no task is active"
 	return header, footer
 }
 
 func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
-	cl.SendReceivePrint(cmd)
-	return errors.Wrap(cl.Error(), "failed to determine the current task")
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	cl.PrintResponse(resp)
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "failed to determine the current task")
+	}
+	for _, elem := range resp.Body {
+		if elem.Kind == msg.KindTaskEvent {
+			return nil
+		}
+	}
+	return errors.New("No active task")
 }
 
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
-	if srv.CurrentTask.IsRunning() {
-		resp.AddCurrentTask(srv.CurrentTask)
-	} else {
-		resp.SetError(errors.New("No active task"))
+	now := time.Now()
+	current := srv.CurrentTask
+	if current.IsRunning() {
+		resp.AddCurrentTask(current)
+		if seen, ok := srv.LastActivity(current.Name); ok && seen.After(current.Started) {
+			resp.AddKeyValue("Last activity", seen.Format("2006-01-02 15:04:05"))
+		}
+		if away, ok := srv.PendingAway(); ok {
+			resp.AddKeyValue("Away", away.Duration().Truncate(time.Second).String()+", see `tilo away`")
+		}
+	}
+
+	// The break lasts from the end of the latest entry until now or until the
+	// current task was started.
+	if recent, err := srv.Backend.RecentTasks(1); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+	} else if len(recent) > 0 {
+		lastStop := recent[0].End
+		if current.IsRunning() {
+			resp.AddKeyValue("Preceding break", current.Started.Sub(lastStop).Truncate(time.Second).String())
+		} else {
+			resp.AddKeyValue("Idle since", lastStop.Format("2006-01-02 15:04:05"))
+			resp.AddKeyValue("Break", now.Sub(lastStop).Truncate(time.Second).String())
+		}
 	}
+
+	count, total, err := srv.TodaysSessions(now)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Sessions today", strconv.Itoa(count))
+	resp.AddKeyValue("Total today", total.Truncate(time.Second).String())
 	return srv.Answer(req, resp)
 }
 
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
 func init() {
 	command.RegisterOperation(operation{})
 }
@@ -0,0 +1,137 @@
+package dedupe
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramDryRun = "dry-run"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "dedupe"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Flag(paramDryRun, "Only list the duplicates"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Remove duplicate entries")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Find entries identical to or contained in another entry of the same task and remove them\n" +
+		"Notes of removed entries are attached to the entry kept"
+	footer := "The duplicates are listed for confirmation first, see the README\n" +
+		"Removals are synchronized to other devices, see the README\n\n" +
+		"Examples\n" +
+		"    tilo dedupe :dry-run  # List duplicates only\n" +
+		"    tilo dedupe --yes     # Remove duplicates without asking"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if cmd.Flags[paramDryRun] {
+		cmd.DryRun = true
+		cl.SendReceivePrint(cmd)
+	} else {
+		cl.SendConfirmed(cmd, "Remove these duplicates?")
+	}
+	return errors.Wrap(cl.Error(), "Failed to remove duplicates")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	dups, err := findDuplicates(srv.Backend)
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	}
+	var entries []msg.Task
+	var total time.Duration
+	for _, dup := range dups {
+		entries = append(entries, dup.Entry)
+		total += dup.Entry.Ended.Sub(dup.Entry.Started)
+	}
+	if req.Cmd.DryRun {
+		resp.AddEntries(entries)
+		resp.AddKeyValue("Duplicates", fmt.Sprint(len(dups)))
+		resp.AddKeyValue("Time", total.String())
+		return srv.Answer(req, resp)
+	}
+	if err := srv.RemoveDuplicates(dups); err != nil {
+		resp.SetError(err)
+	} else {
+		resp.AddKeyValue("Removed duplicates", fmt.Sprint(len(dups)))
+		resp.AddKeyValue("Time", total.String())
+	}
+	return srv.Answer(req, resp)
+}
+
+// All entries identical to or contained in another entry of the same task.
+func findDuplicates(b backend.Backend) ([]backend.Duplicate, error) {
+	byTask := make(map[string][]msg.Task)
+	var names []string
+	err := b.ForEachTaskBetween(nil, time.Unix(0, 0), time.Now().AddDate(1, 0, 0), func(task msg.Task) error {
+		if _, ok := byTask[task.Name]; !ok {
+			names = append(names, task.Name)
+		}
+		byTask[task.Name] = append(byTask[task.Name], task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	var dups []backend.Duplicate
+	for _, name := range names {
+		dups = append(dups, duplicatesOf(byTask[name])...)
+	}
+	return dups, nil
+}
+
+// The duplicates among the entries of a single task. Containing entries come
+// first when sorted by start and, for equal starts, longest first, so each
+// entry only needs to be compared with the kept entry ending last.
+func duplicatesOf(tasks []msg.Task) []backend.Duplicate {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if !tasks[i].Started.Equal(tasks[j].Started) {
+			return tasks[i].Started.Before(tasks[j].Started)
+		}
+		return tasks[i].Ended.After(tasks[j].Ended)
+	})
+	var dups []backend.Duplicate
+	var latest *msg.Task
+	for i := range tasks {
+		task := tasks[i]
+		if latest != nil && !task.Ended.After(latest.Ended) {
+			dups = append(dups, backend.Duplicate{Entry: task, Of: *latest})
+		} else {
+			latest = &tasks[i]
+		}
+	}
+	return dups
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
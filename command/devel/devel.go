@@ -0,0 +1,150 @@
+package devel
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/sample"
+	"github.com/pkg/errors"
+)
+
+const (
+	SEED = "seed"
+)
+
+const (
+	// Set from the first argument
+	optAction  = "action"
+	paramDays  = "days"
+	paramTasks = "tasks"
+	// Generated by default
+	defaultDays  = 90
+	defaultTasks = 8
+)
+
+// Parameters of the seed command.
+var seedParams = []argparse.Param{
+	argparse.Option(paramDays, "<n>", fmt.Sprintf("The number of days up to today to fill, %d by default", defaultDays)),
+	argparse.Option(paramTasks, "<n>", fmt.Sprintf("The number of tasks worked on, %d by default", defaultTasks)),
+}
+
+// Takes the action as the first argument, followed by its parameters.
+type develHandler struct{}
+
+func (h develHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, errors.New("Require a command but none was given")
+	}
+	if cmd.Opts == nil {
+		cmd.Opts = make(map[string]string)
+	}
+	cmd.Opts[optAction] = args[0]
+	switch args[0] {
+	case SEED:
+		return argparse.HandlerForParams(seedParams).HandleArgs(cmd, args[1:])
+	default:
+		return args, errors.New("Not a known devel command: " + args[0])
+	}
+}
+
+func (h develHandler) TakesParameters() bool {
+	return true
+}
+
+func (h develHandler) DescribeParameters() []argparse.ParamDescription {
+	return []argparse.ParamDescription{
+		argparse.ParamDescription{
+			ParamName:        SEED,
+			ParamValues:      "[:days=<n>] [:tasks=<n>]",
+			ParamExplanation: "Fill an empty backend with a random history of entries",
+		},
+	}
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "devel"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(develHandler{})
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:   op.Command(),
+		First: "[seed]",
+		What:  "Support development and testing of tilo",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Support development and testing of tilo, e.g. of reports at a realistic scale"
+	footer := "Seeding requires an empty backend so as not to mix generated entries with\n" +
+		"recorded ones, e.g. --db-file=/tmp/seed.db\n\n" +
+		"Examples\n" +
+		"    tilo --db-file=/tmp/seed.db devel seed :days=365 :tasks=12"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	for _, param := range []string{paramDays, paramTasks} {
+		if v, ok := cmd.Opts[param]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+				return errors.Errorf("Invalid number of %s: %s", param, v)
+			}
+		}
+	}
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to seed the backend")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	if recent, err := srv.Backend.RecentTasks(1); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	} else if len(recent) > 0 || srv.CurrentTask.IsRunning() {
+		resp.SetError(errors.New("Seeding requires an empty backend, e.g. --db-file=/tmp/seed.db"))
+		return srv.Answer(req, resp)
+	}
+	days := number(req.Cmd.Opts[paramDays], defaultDays)
+	tasks := number(req.Cmd.Opts[paramTasks], defaultTasks)
+	now := time.Now()
+	entries := sample.Entries(rand.New(rand.NewSource(now.UnixNano())), now, days, tasks)
+	if !req.Cmd.DryRun {
+		for _, entry := range entries {
+			if err := srv.Backend.Save(entry); err != nil {
+				resp.SetError(errors.Wrap(err, "Failed to save generated entry"))
+				return srv.Answer(req, resp)
+			}
+		}
+	}
+	resp.AddKeyValue("Generated entries", fmt.Sprint(len(entries)))
+	resp.AddKeyValue("Tasks", fmt.Sprint(tasks))
+	resp.AddKeyValue("Days", fmt.Sprint(days))
+	return srv.Answer(req, resp)
+}
+
+// The number given, or the default if none is.
+func number(str string, def int) int {
+	if n, err := strconv.Atoi(str); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
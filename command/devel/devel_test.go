@@ -0,0 +1,23 @@
+package devel_test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/fgahr/tilo/command/devel"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestSeedEmptyBackendOnly(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	out := strings.Join(strings.Fields(srv.MustRun("devel", "seed", ":days=28", ":tasks=3")), " ")
+	if !strings.Contains(out, "Tasks 3") || strings.Contains(out, "Generated entries 0") {
+		t.Errorf("expected entries of three tasks to be generated, got:\n%s", out)
+	}
+
+	if _, err := srv.Run("devel", "seed"); err == nil {
+		t.Error("expected an error when seeding a backend holding data")
+	}
+}
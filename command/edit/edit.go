@@ -0,0 +1,238 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramID       = "id"
+	paramOn       = "on"
+	paramStart    = "start"
+	paramEnd      = "end"
+	paramDuration = "duration"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "edit"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramID, "<id>", "The entry to edit, as listed without changes given"),
+		argparse.Option(paramOn, "YYYY-MM-DD", "Edit the task's entry started on that day"),
+		argparse.Option(paramStart, "HH:MM", "The new start, on the day the entry started"),
+		argparse.Option(paramEnd, "HH:MM", "The new end, on the day the entry started"),
+		argparse.Option(paramDuration, "<duration>", "The new duration, e.g. 1h30m, keeping the start"),
+	}
+	return argparse.CommandParser(op.Command()).WithOptionalTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Change the start or end of a recorded entry")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Change the start or end of a recorded entry, e.g. one of a task left running\n" +
+		"The entry is the task's latest one unless given by :on or :id"
+	footer := "Without changes, the entry is shown with its ID; the changed entry is shown\n" +
+		"for confirmation, see the README\n" +
+		"Times may be given with a date as well, e.g. 2024-05-03T01:30\n" +
+		"Edits are synchronized to other devices, see the README\n\n" +
+		"Examples\n" +
+		"    tilo edit foo :end=17:30               # Fix foo's latest entry, left running\n" +
+		"    tilo edit foo :on=2024-05-02 :start=09:15\n" +
+		"    tilo edit :id=42 :duration=45m"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if len(cmd.TaskNames) == 0 && cmd.Opts[paramID] == "" {
+		return errors.New("Require a task or the ID of an entry")
+	}
+	if _, ok := cmd.Opts[paramOn]; ok && len(cmd.TaskNames) == 0 {
+		return errors.New("Require a task along with :on")
+	}
+	if changes(cmd) {
+		cl.SendConfirmed(cmd, "Change this entry?")
+	} else {
+		cl.SendReceivePrint(cmd)
+	}
+	return errors.Wrap(cl.Error(), "Failed to edit entry")
+}
+
+// Whether the command changes the entry rather than showing it.
+func changes(cmd msg.Cmd) bool {
+	for _, param := range []string{paramStart, paramEnd, paramDuration} {
+		if _, ok := cmd.Opts[param]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	entry, err := selectEntry(srv, req.Cmd)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if !changes(req.Cmd) {
+		resp.AddKeyValue(entry.ID, describe(entry.Task))
+		return srv.Answer(req, resp)
+	}
+	changed, err := adjust(entry.Task, req.Cmd.Opts, time.Now())
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if req.Cmd.DryRun {
+		resp.AddKeyValue("Before", describe(entry.Task))
+		resp.AddKeyValue("After", describe(changed))
+		return srv.Answer(req, resp)
+	}
+	if err := srv.UpdateEntry(entry, changed); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Changed", describe(changed))
+	return srv.Answer(req, resp)
+}
+
+// The entry given by ID, by task and day, or the task's latest one.
+func selectEntry(srv *server.Server, cmd msg.Cmd) (msg.Entry, error) {
+	if id, ok := cmd.Opts[paramID]; ok {
+		entry, err := srv.Backend.GetRecord(id)
+		if err == nil && len(cmd.TaskNames) > 0 && entry.Name != cmd.TaskNames[0] {
+			return msg.Entry{}, errors.Errorf("Entry %s is one of %s, not %s", id, entry.Name, cmd.TaskNames[0])
+		}
+		return entry, err
+	}
+	task := cmd.TaskNames[0]
+	if on, ok := cmd.Opts[paramOn]; ok {
+		day, err := time.ParseInLocation("2006-01-02", on, time.Local)
+		if err != nil {
+			return msg.Entry{}, errors.Errorf("Invalid day: %s", on)
+		}
+		return entryOn(srv, task, day)
+	}
+	recent, err := srv.Backend.RecentEntries([]string{task}, 1)
+	if err != nil {
+		return msg.Entry{}, errors.Wrap(err, "Error in database query")
+	}
+	if len(recent) == 0 {
+		return msg.Entry{}, errors.Errorf("No entries of %s", task)
+	}
+	return recent[0], nil
+}
+
+// The single entry of the task started on the day. Recent entries are
+// fetched in growing numbers until reaching back before the day.
+func entryOn(srv *server.Server, task string, day time.Time) (msg.Entry, error) {
+	next := day.AddDate(0, 0, 1)
+	for n := 16; ; n *= 4 {
+		recent, err := srv.Backend.RecentEntries([]string{task}, n)
+		if err != nil {
+			return msg.Entry{}, errors.Wrap(err, "Error in database query")
+		}
+		if len(recent) == n && !recent[n-1].Ended.Before(day) {
+			continue
+		}
+		var found []msg.Entry
+		for _, entry := range recent {
+			if !entry.Started.Before(day) && entry.Started.Before(next) {
+				found = append(found, entry)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return msg.Entry{}, errors.Errorf("No entry of %s started on %s", task, day.Format("2006-01-02"))
+		case 1:
+			return found[0], nil
+		default:
+			var ids []string
+			for i := len(found) - 1; i >= 0; i-- {
+				ids = append(ids, fmt.Sprintf("%s (%s-%s)", found[i].ID,
+					found[i].Started.Format("15:04"), found[i].Ended.Format("15:04")))
+			}
+			return msg.Entry{}, errors.Errorf("Several entries of %s started on %s, select one via :id: %s",
+				task, day.Format("2006-01-02"), strings.Join(ids, ", "))
+		}
+	}
+}
+
+// The entry with the changes given applied.
+func adjust(task msg.Task, opts map[string]string, now time.Time) (msg.Task, error) {
+	day := task.Started.Local()
+	if start, ok := opts[paramStart]; ok {
+		t, err := parseTime(start, day)
+		if err != nil {
+			return task, err
+		}
+		task.Started = t
+	}
+	if end, ok := opts[paramEnd]; ok {
+		t, err := parseTime(end, day)
+		if err != nil {
+			return task, err
+		}
+		task.Ended = t
+	}
+	if duration, ok := opts[paramDuration]; ok {
+		if _, given := opts[paramEnd]; given {
+			return task, errors.New("Require either :end or :duration, not both")
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return task, errors.Errorf("Invalid duration: %s", duration)
+		}
+		task.Ended = task.Started.Add(d)
+	}
+	if !task.Ended.After(task.Started) {
+		return task, errors.Errorf("The entry would end before it starts: %s", describe(task))
+	}
+	if task.Ended.After(now) {
+		return task, errors.Errorf("The entry would end in the future: %s", describe(task))
+	}
+	return task, nil
+}
+
+// A time of day on the day given, or a point in time including the date.
+func parseTime(str string, day time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02T15:04", str, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return time.Time{}, errors.Errorf("Invalid time: %s", str)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, time.Local), nil
+}
+
+// A single line describing the entry.
+func describe(task msg.Task) string {
+	started, ended := task.Started.Local(), task.Ended.Local()
+	end := ended.Format("15:04")
+	if ended.YearDay() != started.YearDay() || ended.Year() != started.Year() {
+		end = ended.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("%s %s - %s (%v)", task.Name, started.Format("2006-01-02 15:04"), end, ended.Sub(started))
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,75 @@
+package edit_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/edit"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/sync"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestEditEntryLeftRunning(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 2, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(26 * time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	out := srv.MustRun("edit", "foo", ":end=17:30")
+	if !strings.Contains(out, "foo 2020-03-02 09:00 - 17:30 (8h30m0s)") {
+		t.Errorf("expected the entry to end at 17:30, got:\n%s", out)
+	}
+	out = srv.MustRun("edit", "foo", ":on=2020-03-02")
+	if !strings.Contains(out, "foo 2020-03-02 09:00 - 17:30") {
+		t.Errorf("expected the changed entry to be shown, got:\n%s", out)
+	}
+
+	if _, err := srv.Run("edit", "foo", ":end=08:00"); err == nil {
+		t.Error("expected an error for an entry ending before it starts")
+	}
+	if _, err := srv.Run("edit", "foo", ":on=2020-03-03", ":start=10:00"); err == nil {
+		t.Error("expected an error for a day without entries")
+	}
+}
+
+func TestEditRecordsChanges(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 2, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	srv.MustRun("edit", "foo", ":end=10:30")
+	out := srv.MustRun("sync", ":export")
+	var changes []msg.Change
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var change msg.Change
+		if err := json.Unmarshal([]byte(line), &change); err != nil {
+			t.Fatalf("invalid change %q: %v", line, err)
+		}
+		changes = append(changes, change)
+	}
+	removed, saved := false, false
+	for _, change := range changes {
+		ended := change.Task.Ended
+		removed = removed || change.Kind == msg.ChangeRemoval && ended.Equal(started.Add(time.Hour))
+		saved = saved || change.Kind == msg.ChangeEntry && ended.Equal(started.Add(90*time.Minute))
+	}
+	if len(changes) != 3 || !removed || !saved {
+		t.Errorf("expected the entry to be recorded as removed and saved anew, got:\n%s", out)
+	}
+}
@@ -0,0 +1,310 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/ics"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramFormat  = "format"
+	paramColumns = "columns"
+	formatCSV    = "csv"
+	formatJSON   = "json"
+	formatICS    = "ics"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "export"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Option(paramFormat, formatCSV+"|"+formatJSON+"|"+formatICS, "The output format, csv by default"),
+		argparse.Option(paramColumns, "<column>,...", "The columns of CSV exports, see below"),
+	)
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Export recorded entries")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Write all recorded entries to standard output"
+	footer := "Without time parameters, the entire history is exported\n" +
+		"Entries are written as they are read, so exports of any size are possible\n" +
+		"CSV columns: " + strings.Join(columnNames(), ",") + "\n" +
+		"    by default " + strings.Join(defaultColumns, ",") + "\n" +
+		"    tags are the words starting with # in the notes\n\n" +
+		"Examples\n" +
+		"    tilo export :all > tilo.csv              # Everything, as CSV\n" +
+		"    tilo export :all :columns=task,iso_week,duration_s\n" +
+		"    tilo export foo :this-year :format=json  # This year's entries for foo, as JSON lines\n" +
+		"    tilo export :this-month :format=ics      # This month's entries, as a calendar"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	w, err := newWriter(cl.Output(), cmd.Opts[paramFormat], cmd.Opts[paramColumns])
+	if err != nil {
+		return err
+	}
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Export failed")
+	}
+	if resp.Failed() {
+		return resp.Err()
+	}
+	if err := w.begin(); err != nil {
+		return errors.Wrap(err, "Failed to write header")
+	}
+	task := msg.Task{}
+	for cl.ReceiveNext(&task) {
+		if err := w.write(task); err != nil {
+			return errors.Wrap(err, "Failed to write entry")
+		}
+		task = msg.Task{}
+	}
+	if err := w.flush(); err != nil {
+		return errors.Wrap(err, "Failed to write entries")
+	}
+	return errors.Wrap(cl.Error(), "Export failed")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	tasks := req.Cmd.TaskNames
+	if len(tasks) == 1 && tasks[0] == query.TskAllTasks {
+		tasks = nil
+	}
+	ranges, err := timeRanges(req.Cmd.Quantities)
+	if err != nil {
+		resp := msg.Response{}
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if err := srv.Answer(req, msg.Response{Status: msg.RespSuccess}); err != nil {
+		return err
+	}
+	// Entries are sent one by one to avoid holding the history in memory.
+	send := func(task msg.Task) error {
+		return srv.Stream(req, task)
+	}
+	for _, r := range ranges {
+		if err := srv.Backend.ForEachTaskBetween(tasks, r[0], r[1], send); err != nil {
+			return errors.Wrap(err, "Export aborted")
+		}
+	}
+	return nil
+}
+
+// The time ranges to export, covering all time if no quantities are given.
+func timeRanges(quantities []msg.Quantity) ([][2]time.Time, error) {
+	if len(quantities) == 0 {
+		return [][2]time.Time{{time.Unix(0, 0), time.Now().AddDate(1, 0, 0)}}, nil
+	}
+	var ranges [][2]time.Time
+	for _, quant := range quantities {
+		start, end, err := quantifier.Range(quant)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to construct query")
+		}
+		ranges = append(ranges, [2]time.Time{start, end})
+	}
+	return ranges, nil
+}
+
+// Writes exported entries in a particular format.
+type writer interface {
+	begin() error
+	write(task msg.Task) error
+	flush() error
+}
+
+func newWriter(out io.Writer, format string, columnList string) (writer, error) {
+	if columnList != "" && format != "" && format != formatCSV {
+		return nil, errors.Errorf("Columns can only be chosen for %s exports", formatCSV)
+	}
+	switch format {
+	case "", formatCSV:
+		cols, err := selectColumns(columnList)
+		if err != nil {
+			return nil, err
+		}
+		return csvWriter{csv.NewWriter(out), cols}, nil
+	case formatJSON:
+		return jsonWriter{json.NewEncoder(out)}, nil
+	case formatICS:
+		return icsWriter{ics.NewWriter(out)}, nil
+	default:
+		return nil, errors.Errorf("Unknown export format: %s", format)
+	}
+}
+
+// A column of CSV exports, computed from the entry.
+type column struct {
+	name  string
+	value func(task msg.Task) string
+}
+
+// All available columns. duration_seconds is the same as duration_s, kept
+// under its original name for the default columns.
+var columns = []column{
+	{"task", func(task msg.Task) string { return task.Name }},
+	{"start", func(task msg.Task) string { return task.Started.Format(time.RFC3339) }},
+	{"end", func(task msg.Task) string { return task.Ended.Format(time.RFC3339) }},
+	{"duration_s", durationSeconds},
+	{"duration_seconds", durationSeconds},
+	{"iso_week", func(task msg.Task) string {
+		year, week := task.Started.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}},
+	{"weekday", func(task msg.Task) string { return task.Started.Weekday().String() }},
+	{"notes", func(task msg.Task) string { return strings.Join(task.Notes, "\n") }},
+	{"tags", tags},
+	{"host", func(task msg.Task) string { return task.Source.Host }},
+	{"user", func(task msg.Task) string { return task.Source.User }},
+}
+
+var defaultColumns = []string{"task", "start", "end", "duration_seconds", "notes", "host", "user"}
+
+func columnNames() []string {
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.name)
+	}
+	return names
+}
+
+// The columns given as a comma-separated list, the default ones if empty.
+func selectColumns(list string) ([]column, error) {
+	names := defaultColumns
+	if list != "" {
+		names = strings.Split(list, ",")
+	}
+	var selected []column
+	for _, name := range names {
+		found := false
+		for _, col := range columns {
+			if col.name == name {
+				selected = append(selected, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("No such column: %s%s", name,
+				argparse.DidYouMean(argparse.Suggestions(name, columnNames())))
+		}
+	}
+	return selected, nil
+}
+
+func durationSeconds(task msg.Task) string {
+	return strconv.FormatInt(int64(task.Ended.Sub(task.Started)/time.Second), 10)
+}
+
+// The words starting with # in the notes, e.g. #billable, each given once.
+func tags(task msg.Task) string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, note := range task.Notes {
+		for _, word := range strings.Fields(note) {
+			word = strings.TrimRight(word, ".,;:!?")
+			if len(word) > 1 && strings.HasPrefix(word, "#") && !seen[word] {
+				seen[word] = true
+				found = append(found, word)
+			}
+		}
+	}
+	return strings.Join(found, " ")
+}
+
+type csvWriter struct {
+	out  *csv.Writer
+	cols []column
+}
+
+func (w csvWriter) begin() error {
+	var header []string
+	for _, col := range w.cols {
+		header = append(header, col.name)
+	}
+	return w.out.Write(header)
+}
+
+func (w csvWriter) write(task msg.Task) error {
+	var record []string
+	for _, col := range w.cols {
+		record = append(record, col.value(task))
+	}
+	return w.out.Write(record)
+}
+
+func (w csvWriter) flush() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w jsonWriter) begin() error {
+	return nil
+}
+
+func (w jsonWriter) write(task msg.Task) error {
+	return w.enc.Encode(task)
+}
+
+func (w jsonWriter) flush() error {
+	return nil
+}
+
+type icsWriter struct {
+	cal *ics.Writer
+}
+
+func (w icsWriter) begin() error {
+	return w.cal.Begin()
+}
+
+func (w icsWriter) write(task msg.Task) error {
+	return w.cal.Write(task)
+}
+
+func (w icsWriter) flush() error {
+	return w.cal.Flush()
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
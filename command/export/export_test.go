@@ -0,0 +1,39 @@
+package export_test
+
+import (
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/export"
+	_ "github.com/fgahr/tilo/command/note"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestExportColumns(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	// A Sunday in the ninth week of 2020.
+	started := time.Date(2020, 3, 1, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.MustRun("note", "fixed login #billable, see #123")
+	srv.At(started.Add(90 * time.Minute))
+	srv.MustRun("stop")
+
+	out := srv.MustRun("export", "foo", ":columns=task,duration_s,iso_week,weekday,tags")
+	expected := "task,duration_s,iso_week,weekday,tags\n" +
+		"foo,5400,2020-W09,Sunday,#billable #123\n"
+	if out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if _, err := srv.Run("export", "foo", ":columns=task,duraton_s"); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+	if _, err := srv.Run("export", "foo", ":format=json", ":columns=task"); err == nil {
+		t.Error("expected an error for columns of a JSON export")
+	}
+}
@@ -0,0 +1,182 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramDue    = "due"
+	paramBudget = "budget"
+	paramWindow = "window"
+	// Days of recent activity considered by default
+	defaultWindow = 14
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "forecast"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramBudget, "<duration>", "The total time planned for the task, e.g. 80h"),
+		argparse.Option(paramDue, "YYYY-MM-DD", "The day the task needs to be completed"),
+		argparse.Option(paramWindow, "N", fmt.Sprintf("Days of recent activity to base the forecast on, %d by default", defaultWindow)),
+	}
+	return argparse.CommandParser(op.Command()).WithSingleTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Project the completion of a task")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Estimate when the budget for a task is used up, based on recent daily activity"
+	footer := "With a due date, the daily time required to meet it is shown as well\n\n" +
+		"Examples\n" +
+		"    tilo forecast foo :budget=80h                   # When foo is likely finished\n" +
+		"    tilo forecast foo :budget=80h :due=2024-07-01   # Daily time needed to finish by July"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if _, err := parsePlan(cmd, msg.Clock()); err != nil {
+		return err
+	}
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to create forecast")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	now := req.Cmd.CurrentTime()
+	p, err := parsePlan(req.Cmd, now)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	task := req.Cmd.TaskNames[0]
+	var running time.Duration
+	// Not counted if started after the time assumed, see --now.
+	if srv.CurrentTask.IsRunning() && srv.CurrentTask.Name == task && now.After(srv.CurrentTask.Started) {
+		running = now.Sub(srv.CurrentTask.Started)
+	}
+	if spent, recent, err := timeSpent(srv.Backend, task, p.window, now); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+	} else {
+		p.forecast(&resp, spent+running, recent+running, now)
+	}
+	return srv.Answer(req, resp)
+}
+
+// The planned effort for a task.
+type plan struct {
+	budget time.Duration
+	due    time.Time // Zero if not given
+	window int       // In days
+}
+
+func parsePlan(cmd msg.Cmd, now time.Time) (plan, error) {
+	p := plan{window: defaultWindow}
+	budget, ok := cmd.Opts[paramBudget]
+	if !ok {
+		return p, errors.New("A budget is required, e.g. :budget=80h")
+	}
+	var err error
+	if p.budget, err = time.ParseDuration(budget); err != nil || p.budget <= 0 {
+		return p, errors.Errorf("Invalid budget: %s", budget)
+	}
+	if due, ok := cmd.Opts[paramDue]; ok {
+		if p.due, err = time.ParseInLocation("2006-01-02", due, now.Location()); err != nil {
+			return p, errors.Errorf("Invalid due date: %s", due)
+		}
+	}
+	if window, ok := cmd.Opts[paramWindow]; ok {
+		if p.window, err = strconv.Atoi(window); err != nil || p.window < 1 {
+			return p, errors.Errorf("Invalid window: %s", window)
+		}
+	}
+	return p, nil
+}
+
+// The total time spent on the task and the time spent within the window.
+func timeSpent(b backend.Backend, task string, window int, now time.Time) (time.Duration, time.Duration, error) {
+	end := now.Add(time.Second)
+	all, err := b.GetTaskBetween(task, time.Unix(0, 0), end, msg.Source{})
+	if err != nil {
+		return 0, 0, err
+	}
+	windowStart := startOfDay(now).AddDate(0, 0, 1-window)
+	recent, err := b.GetTaskBetween(task, windowStart, end, msg.Source{})
+	if err != nil {
+		return 0, 0, err
+	}
+	return total(all), total(recent), nil
+}
+
+func total(summaries []msg.Summary) time.Duration {
+	var result time.Duration
+	for _, s := range summaries {
+		result += s.Total
+	}
+	return result
+}
+
+// Add the forecast to the response.
+func (p plan) forecast(resp *msg.Response, spent time.Duration, recent time.Duration, now time.Time) {
+	remaining := p.budget - spent
+	velocity := recent / time.Duration(p.window)
+	resp.AddKeyValue("Budget", p.budget.String())
+	resp.AddKeyValue("Spent", spent.String())
+	if remaining <= 0 {
+		resp.AddKeyValue("Over budget by", (-remaining).String())
+		return
+	}
+	resp.AddKeyValue("Remaining", remaining.String())
+	resp.AddKeyValue("Daily average", fmt.Sprintf("%v (last %d days)", velocity.Truncate(time.Minute), p.window))
+	if velocity > 0 {
+		days := int(math.Ceil(float64(remaining) / float64(velocity)))
+		resp.AddKeyValue("Projected completion", startOfDay(now).AddDate(0, 0, days).Format("2006-01-02"))
+	} else {
+		resp.AddKeyValue("Projected completion", "unknown, no recent activity")
+	}
+	if p.due.IsZero() {
+		return
+	}
+	resp.AddKeyValue("Due", p.due.Format("2006-01-02"))
+	// Today counts as a working day.
+	days := int(p.due.Sub(startOfDay(now))/(24*time.Hour)) + 1
+	if days < 1 {
+		resp.AddKeyValue("Required daily", "overdue")
+	} else {
+		resp.AddKeyValue("Required daily", (remaining / time.Duration(days)).Truncate(time.Minute).String())
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
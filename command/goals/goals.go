@@ -0,0 +1,98 @@
+package goals
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+// The width of the progress bars.
+const barWidth = 20
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "goals"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Show progress towards this week's goals")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Show the time spent this week on each task with a weekly goal, and the time remaining"
+	footer := "Goals are configured as e.g. `weekly_goals = foo=12h,bar=4h`\n" +
+		"Weeks start on Monday; the active task is included"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to determine progress towards goals")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	goals := srv.WeeklyGoals()
+	if len(goals) == 0 {
+		resp.SetError(errors.New("No weekly goals set, use weekly_goals"))
+		return srv.Answer(req, resp)
+	}
+
+	tasks := make([]string, 0, len(goals))
+	for task := range goals {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+	now := req.Cmd.CurrentTime()
+	for _, task := range tasks {
+		spent, err := srv.SpentThisWeek(task, now)
+		if err != nil {
+			resp.SetError(err)
+			return srv.Answer(req, resp)
+		}
+		resp.AddKeyValue(task, progress(spent, goals[task]))
+	}
+	return srv.Answer(req, resp)
+}
+
+// A progress bar followed by the time spent and remaining, e.g.
+// [##########----------]   6h0m0s/12h0m0s    50%  6h0m0s left
+func progress(spent time.Duration, goal time.Duration) string {
+	spent = spent.Truncate(time.Minute)
+	filled := barWidth
+	if spent < goal {
+		filled = int(barWidth * spent / goal)
+	}
+	bar := "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]"
+	percent := int(100 * spent / goal)
+	status := "met"
+	if remaining := goal - spent; remaining > 0 {
+		status = remaining.String() + " left"
+	}
+	return fmt.Sprintf("%s %8v/%-8v %4d%%  %s", bar, spent, goal, percent, status)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
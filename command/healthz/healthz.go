@@ -0,0 +1,69 @@
+package healthz
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "healthz"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Check whether the server is healthy")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Check whether the server is running and its backend answers queries"
+	footer := "Exits with a non-zero status otherwise, e.g. for monitoring\n" +
+		"A server is never started by this command; with state_address set, the\n" +
+		"same check is available via HTTP at /healthz"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if !cl.ServerIsRunning() {
+		return errors.New("Server is not running")
+	}
+	cl.SendReceivePrint(cmd)
+	return cl.Error()
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	report := srv.Health(time.Now())
+	if report.Status != server.HealthOK {
+		resp.SetError(errors.New(report.Error))
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Status", report.Status)
+	resp.AddKeyValue("Version", report.Version)
+	resp.AddKeyValue("Uptime", (time.Duration(report.UptimeSeconds) * time.Second).String())
+	resp.AddKeyValue("Backend", fmt.Sprintf("%s (%.1fms)", report.Backend, report.BackendMillis))
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,82 @@
+package heartbeat
+
+import (
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTask = "task"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "heartbeat"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramTask, "<name>", "The task worked on; the active one by default"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Signal that work on a task is ongoing")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Tell the server that a task is being worked on right now"
+	footer := "Meant to be sent periodically by editors or status bars, it prints nothing on success\n" +
+		"The time of the latest heartbeat is shown by the `current` command\n" +
+		"Does not start a server; fails if none is running or no task is active\n\n" +
+		"Examples\n" +
+		"    tilo heartbeat             # Activity on the active task\n" +
+		"    tilo heartbeat :task=foo   # Activity on foo, whether active or not"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if !cl.ServerIsRunning() {
+		return errors.New("No server running")
+	}
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Failed to send heartbeat")
+	} else if resp.Failed() {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	task, ok := req.Cmd.Opts[paramTask]
+	if !ok {
+		task = srv.CurrentTask.Name
+		if !srv.CurrentTask.IsRunning() {
+			resp.SetError(errors.New("No active task"))
+			return srv.Answer(req, resp)
+		}
+	} else if names, err := argparse.GetTaskNames(task); err != nil || len(names) != 1 || names[0] == argparse.AllTasks {
+		resp.SetError(errors.Errorf("Invalid task name: %s", task))
+		return srv.Answer(req, resp)
+	}
+	srv.RecordActivity(task, msg.Clock())
+	resp.Status = msg.RespSuccess
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,82 @@
+package history
+
+import (
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "log"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := query.TimeParams(msg.Clock())
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("List task changes chronologically")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "List the history of started, stopped and aborted tasks"
+	footer := "Without parameters, today's events are listed\n\n" +
+		"Examples\n" +
+		"    tilo log :all                 # All of today's events\n" +
+		"    tilo log foo,bar :last-week   # Events concerning foo and bar during last week"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if len(cmd.Quantities) == 0 {
+		today, _ := quantifier.FixedDayOffset(msg.Clock(), 0).Parse("")
+		cmd.Quantities = today
+	}
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to retrieve the task history")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	tasks := req.Cmd.TaskNames
+	if len(tasks) == 1 && tasks[0] == query.TskAllTasks {
+		tasks = nil
+	}
+	for _, quant := range req.Cmd.Quantities {
+		start, end, err := quantifier.Range(quant)
+		if err != nil {
+			resp.SetError(errors.Wrap(err, "Unable to construct query"))
+			break
+		}
+		if entries, err := srv.Backend.GetEventsBetween(tasks, start, end); err != nil {
+			resp.SetError(errors.Wrap(err, "Error in database query"))
+			break
+		} else {
+			resp.AddLogEntries(entries)
+		}
+	}
+	if !resp.Failed() && len(resp.Body) == 0 {
+		resp.AddMessage("Nothing found")
+	}
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,67 @@
+package invoice
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Rules applied to each session before billing, e.g. at least 30 minutes,
+// rounded up to 15 minutes. Stored data is not affected.
+type billing struct {
+	Minimum string `json:"minimum"`  // The minimum time billed per session, e.g. 30m
+	RoundUp string `json:"round_up"` // The unit sessions are rounded up to, e.g. 15m
+}
+
+// Parsed billing rules.
+type billingRule struct {
+	minimum time.Duration
+	roundUp time.Duration
+}
+
+func (b billing) parse() (billingRule, error) {
+	rule := billingRule{}
+	var err error
+	if b.Minimum != "" {
+		if rule.minimum, err = time.ParseDuration(b.Minimum); err != nil || rule.minimum < 0 {
+			return rule, errors.Errorf("Invalid minimum: %s", b.Minimum)
+		}
+	}
+	if b.RoundUp != "" {
+		if rule.roundUp, err = time.ParseDuration(b.RoundUp); err != nil || rule.roundUp < 0 {
+			return rule, errors.Errorf("Invalid rounding: %s", b.RoundUp)
+		}
+	}
+	return rule, nil
+}
+
+// The time billed for a session of the given length.
+func (r billingRule) apply(d time.Duration) time.Duration {
+	if d < r.minimum {
+		d = r.minimum
+	}
+	if r.roundUp > 0 {
+		if rest := d % r.roundUp; rest > 0 {
+			d += r.roundUp - rest
+		}
+	}
+	return d
+}
+
+// The billing rules for each of the client's tasks. Rules for a task
+// replace those for the client.
+func (c *customer) billingRules() (map[string]billingRule, error) {
+	rules := make(map[string]billingRule)
+	for _, task := range c.Tasks {
+		b := c.Billing
+		if tb, ok := c.TaskBilling[task]; ok {
+			b = tb
+		}
+		rule, err := b.parse()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Billing rules for %s", task)
+		}
+		rules[task] = rule
+	}
+	return rules, nil
+}
@@ -0,0 +1,253 @@
+package invoice
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	optClient     = "client"
+	paramBy       = "by"
+	paramHTML     = "html"
+	paramTemplate = "template"
+	paramSettings = "settings"
+	byTask        = "task"
+	byDay         = "day"
+)
+
+// Takes the client to bill as the first argument not being a parameter.
+type clientHandler struct {
+	params argparse.ArgHandler
+}
+
+func (h clientHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	unused, err := h.params.HandleArgs(cmd, args)
+	if err != nil || len(unused) == 0 {
+		return unused, err
+	}
+	if cmd.Opts == nil {
+		cmd.Opts = make(map[string]string)
+	}
+	cmd.Opts[optClient] = unused[0]
+	return unused[1:], nil
+}
+
+func (h clientHandler) TakesParameters() bool {
+	return true
+}
+
+func (h clientHandler) DescribeParameters() []argparse.ParamDescription {
+	name := argparse.ParamDescription{
+		ParamName:        "",
+		ParamValues:      "<client>",
+		ParamExplanation: "The client to bill, as named in the invoice settings",
+	}
+	return append([]argparse.ParamDescription{name}, h.params.DescribeParameters()...)
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "invoice"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Option(paramBy, byTask+"|"+byDay, "One line per task or per task and day; per task by default"),
+		argparse.Flag(paramHTML, "Render as HTML instead of text"),
+		argparse.Option(paramTemplate, "<file>", "A template to render the invoice with"),
+		argparse.Option(paramSettings, "<file>", "The invoice settings; "+settingsFile+" in the configuration directory by default"),
+	)
+	handler := clientHandler{params: argparse.HandlerForParams(params)}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(handler)
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:    op.Command(),
+		First:  "<client>",
+		Second: "[parameters]",
+		What:   "Create an invoice for a client",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Create an itemized invoice for the time spent on a client's tasks in a period\n" +
+		"Clients, their tasks, rates and tax are read from " + settingsFile + " in the configuration directory"
+	footer := "Each invoice gets the next number, kept in " + numberFile + "; with --dry-run it is not used up\n" +
+		"Billing rules from the settings, e.g. a minimum per session, apply to each session\n" +
+		"Templates use Go's text/template, or html/template with :html, see the README for the fields\n\n" +
+		"Examples\n" +
+		"    tilo invoice client-a :last-month                  # Per task, as text\n" +
+		"    tilo invoice client-a :last-month :by=day :html    # Per day, as HTML"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	name := cmd.Opts[optClient]
+	if name == "" {
+		return errors.New("Require a client to bill")
+	}
+	if len(cmd.Quantities) == 0 {
+		return errors.New("Require a period, e.g. :last-month")
+	}
+	by := byTask
+	if b, ok := cmd.Opts[paramBy]; ok {
+		if b != byTask && b != byDay {
+			return errors.Errorf("Invalid value for :%s: %s", paramBy, b)
+		}
+		by = b
+	}
+	settingsPath := filepath.Join(cl.ConfigDir(), settingsFile)
+	if f, ok := cmd.Opts[paramSettings]; ok {
+		settingsPath = f
+	}
+	s, err := readSettings(settingsPath)
+	if err != nil {
+		return err
+	}
+	c, ok := s.Clients[name]
+	if !ok {
+		return errors.Errorf("No such client in %s: %s", settingsPath, name)
+	} else if len(c.Tasks) == 0 {
+		return errors.Errorf("No tasks given for client %s", name)
+	}
+	rules, err := c.billingRules()
+	if err != nil {
+		return err
+	}
+	tmpl, err := loadTemplate(cmd.Opts[paramTemplate], cmd.Flags[paramHTML])
+	if err != nil {
+		return err
+	}
+
+	cmd.TaskNames = c.Tasks
+	resp, err := cl.Request(cmd)
+	if err != nil {
+		return errors.Wrap(err, "Failed to determine the time spent")
+	}
+	number, err := lastNumber(cl.ConfigDir())
+	if err != nil {
+		return err
+	}
+	number++
+
+	inv := invoice{
+		Number:     fmt.Sprintf(s.NumberFormat, number),
+		Date:       msg.Clock(),
+		Period:     describePeriod(cmd.Quantities),
+		Issuer:     s.Issuer,
+		Client:     *c,
+		TaxPercent: c.TaxPercent,
+		Currency:   c.Currency,
+	}
+	for _, it := range items(resp, by, rules) {
+		inv.add(it.task, it.day, it.sessions, it.billed)
+	}
+	if len(inv.Items) == 0 {
+		return errors.New("Nothing to bill in this period")
+	}
+	if err := tmpl.Execute(cl.Output(), inv); err != nil {
+		return errors.Wrap(err, "Failed to render invoice")
+	}
+	if cl.DryRun() {
+		return nil
+	}
+	return saveNumber(cl.ConfigDir(), number)
+}
+
+// The time billed for a task, possibly on a single day.
+type billed struct {
+	task     string
+	day      string // Empty if billed per task
+	sessions int
+	billed   time.Duration
+}
+
+// The time billed per task, or per task and day, for the sessions in a
+// response. Sorted by task and day.
+func items(resp msg.Response, by string, rules map[string]billingRule) []billed {
+	var result []billed
+	index := make(map[string]int)
+	for _, elem := range resp.Body {
+		if elem.Kind != msg.KindEntry {
+			continue
+		}
+		task := elem.Task
+		day := ""
+		if by == byDay {
+			day = task.Started.Format("2006-01-02")
+		}
+		key := task.Name + " " + day
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, billed{task: task.Name, day: day})
+		}
+		result[i].sessions++
+		result[i].billed += rules[task.Name].apply(task.Ended.Sub(task.Started))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].task != result[j].task {
+			return result[i].task < result[j].task
+		}
+		return result[i].day < result[j].day
+	})
+	return result
+}
+
+// A description of the billed period, e.g. "month 2024-06".
+func describePeriod(quantities []msg.Quantity) string {
+	parts := make([]string, len(quantities))
+	for i, q := range quantities {
+		parts[i] = q.Type + " " + strings.Join(q.Elems, ":")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	var entries []msg.Task
+	for _, q := range req.Cmd.Quantities {
+		start, end, err := quantifier.Range(q)
+		if err != nil {
+			resp.SetError(err)
+			return srv.Answer(req, resp)
+		}
+		err = srv.Backend.ForEachTaskBetween(req.Cmd.TaskNames, start, end, func(task msg.Task) error {
+			entries = append(entries, task)
+			return nil
+		})
+		if err != nil {
+			resp.SetError(errors.Wrap(err, "Error in database query"))
+			return srv.Answer(req, resp)
+		}
+	}
+	resp.AddEntries(entries)
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
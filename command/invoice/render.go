@@ -0,0 +1,129 @@
+package invoice
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// An invoice as passed to the templates.
+type invoice struct {
+	Number     string
+	Date       time.Time
+	Period     string
+	Issuer     string
+	Client     customer
+	Items      []item
+	Hours      float64
+	Net        float64
+	TaxPercent float64
+	Tax        float64
+	Gross      float64
+	Currency   string
+}
+
+// A line of an invoice: the time billed for a task, possibly on a single
+// day, after applying the billing rules to each session.
+type item struct {
+	Task     string
+	Day      string // Empty if items are per task
+	Sessions int
+	Hours    float64
+	Rate     float64
+	Amount   float64
+}
+
+// Round an amount to cents.
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Add an item for the time billed, computing the totals.
+func (inv *invoice) add(task string, day string, sessions int, billed time.Duration) {
+	hours := math.Round(billed.Hours()*100) / 100
+	rate := inv.Client.rate(task)
+	it := item{Task: task, Day: day, Sessions: sessions, Hours: hours, Rate: rate, Amount: cents(hours * rate)}
+	inv.Items = append(inv.Items, it)
+	inv.Hours += it.Hours
+	inv.Net = cents(inv.Net + it.Amount)
+	inv.Tax = cents(inv.Net * inv.TaxPercent / 100)
+	inv.Gross = cents(inv.Net + inv.Tax)
+}
+
+var funcs = map[string]interface{}{
+	"lines": func(s string) []string { return strings.Split(s, "\n") },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+const textTemplate = `INVOICE {{.Number}}
+Date: {{date .Date}}
+
+{{.Issuer}}
+
+To:
+{{.Client.Name}}
+{{.Client.Address}}
+
+Period: {{.Period}}
+
+{{range .Items}}{{printf "%-24s %-10s %8.2f h x %8.2f %12.2f" .Task .Day .Hours .Rate .Amount}}
+{{end}}
+{{printf "%-35s %8.2f h" "Total" .Hours}}
+{{printf "%-57s %12.2f" "Net" .Net}}
+{{printf "%-57s %12.2f" (printf "Tax %g%%" .TaxPercent) .Tax}}
+{{printf "%-57s %12.2f %s" "Total due" .Gross .Currency}}
+`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Invoice {{.Number}}</title></head>
+<body>
+<p>{{range lines .Issuer}}{{.}}<br>{{end}}</p>
+<p>{{.Client.Name}}<br>{{range lines .Client.Address}}{{.}}<br>{{end}}</p>
+<h1>Invoice {{.Number}}</h1>
+<p>Date: {{date .Date}}<br>Period: {{.Period}}</p>
+<table>
+<tr><th>Task</th><th>Day</th><th>Hours</th><th>Rate</th><th>Amount</th></tr>
+{{range .Items}}<tr><td>{{.Task}}</td><td>{{.Day}}</td><td>{{printf "%.2f" .Hours}}</td><td>{{printf "%.2f" .Rate}}</td><td>{{printf "%.2f" .Amount}}</td></tr>
+{{end}}<tr><td>Net</td><td></td><td>{{printf "%.2f" .Hours}}</td><td></td><td>{{printf "%.2f" .Net}}</td></tr>
+<tr><td>Tax {{.TaxPercent}}%</td><td></td><td></td><td></td><td>{{printf "%.2f" .Tax}}</td></tr>
+<tr><td>Total due</td><td></td><td></td><td></td><td>{{printf "%.2f" .Gross}} {{.Currency}}</td></tr>
+</table>
+</body>
+</html>
+`
+
+// Something a template can be executed on, either text or HTML.
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// The template to render the invoice with: the given file or the built-in
+// template for the format. HTML templates escape their input.
+func loadTemplate(file string, html bool) (executor, error) {
+	text := textTemplate
+	if html {
+		text = htmlTemplate
+	}
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to read template")
+		}
+		text = string(data)
+	}
+	var tmpl executor
+	var err error
+	if html {
+		tmpl, err = htmltemplate.New("invoice").Funcs(funcs).Parse(text)
+	} else {
+		tmpl, err = template.New("invoice").Funcs(funcs).Parse(text)
+	}
+	return tmpl, errors.Wrap(err, "Invalid template")
+}
@@ -0,0 +1,98 @@
+package invoice
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The file holding the invoice settings, in the configuration directory.
+const settingsFile = "invoices.json"
+
+// The file holding the number of the latest invoice, in the configuration
+// directory.
+const numberFile = "invoice-number"
+
+// Settings for invoices, read from a JSON file, e.g.
+//
+//	{
+//	  "issuer": "Jane Doe\nMain Street 1\n12345 Town",
+//	  "number_format": "INV-%04d",
+//	  "clients": {
+//	    "client-a": {
+//	      "name": "Client A Ltd.",
+//	      "address": "Market Square 2\n54321 City",
+//	      "tasks": ["projectx", "support"],
+//	      "rate": 80,
+//	      "rates": {"support": 60},
+//	      "currency": "EUR",
+//	      "tax_percent": 19,
+//	      "billing": {"minimum": "30m", "round_up": "15m"},
+//	      "task_billing": {"support": {"round_up": "5m"}}
+//	    }
+//	  }
+//	}
+type settings struct {
+	Issuer       string               `json:"issuer"`
+	NumberFormat string               `json:"number_format"` // A format for the invoice number, e.g. INV-%04d
+	Clients      map[string]*customer `json:"clients"`
+}
+
+// A client to bill.
+type customer struct {
+	Name        string             `json:"name"`
+	Address     string             `json:"address"`
+	Tasks       []string           `json:"tasks"` // The tasks billed to this client
+	Rate        float64            `json:"rate"`  // The hourly rate
+	Rates       map[string]float64 `json:"rates"` // Hourly rates differing by task
+	Currency    string             `json:"currency"`
+	TaxPercent  float64            `json:"tax_percent"`  // Tax added to the net amount
+	Billing     billing            `json:"billing"`      // Rules applied to each session
+	TaskBilling map[string]billing `json:"task_billing"` // Rules differing by task
+}
+
+// The hourly rate for a task.
+func (c *customer) rate(task string) float64 {
+	if r, ok := c.Rates[task]; ok {
+		return r
+	}
+	return c.Rate
+}
+
+func readSettings(file string) (settings, error) {
+	s := settings{}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return s, errors.Wrap(err, "Unable to read invoice settings")
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, errors.Wrapf(err, "Invalid invoice settings in %s", file)
+	}
+	if s.NumberFormat == "" {
+		s.NumberFormat = "%d"
+	}
+	return s, nil
+}
+
+// The number of the latest invoice; zero if there is none yet.
+func lastNumber(dir string) (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, numberFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Wrap(err, "Unable to read the invoice number")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n, errors.Wrap(err, "Invalid invoice number")
+}
+
+// Remember the number of the latest invoice.
+func saveNumber(dir string, n int) error {
+	err := ioutil.WriteFile(filepath.Join(dir, numberFile), []byte(strconv.Itoa(n)+"\n"), 0600)
+	return errors.Wrap(err, "Unable to save the invoice number")
+}
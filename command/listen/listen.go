@@ -1,8 +1,15 @@
 package listen
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"strconv"
+	"text/template"
+	"time"
 
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/client"
@@ -12,6 +19,22 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	paramFormat   = "format"
+	paramTemplate = "template"
+	paramExec     = "exec"
+	paramSinceSeq = "since-seq"
+	paramName     = "name"
+)
+
+const (
+	formatJSON     = "json"
+	formatTemplate = "template"
+)
+
+// The template used with :format=template unless another one is given.
+const defaultTemplate = "{{.Event}}{{if .Task}} {{.Task}}{{end}}"
+
 type operation struct {
 	// No state required
 }
@@ -21,7 +44,14 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+	params := []argparse.Param{
+		argparse.Option(paramFormat, "json|template", "Print one JSON object or one line from the template per event"),
+		argparse.Option(paramTemplate, "<template>", "The template for :format=template, see the README"),
+		argparse.Option(paramExec, "<command>", "Run a shell command for each event"),
+		argparse.Option(paramName, "<name>", "The purpose of the listener, shown by `tilo server listeners`"),
+		argparse.Option(paramSinceSeq, "<N>", "Replay the events after sequence number N, if still available"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -29,12 +59,26 @@ func (op operation) DescribeShort() argparse.Description {
 }
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
-	header := "Connect to the server and listen for notifications. Print whatever is received"
-	footer := "Use this mode for scripting purposes or as sample output when developing listeners in other languages"
+	header := "Connect to the server and listen for notifications, one event at a time\n" +
+		"Events are start, idle, shutdown, budget, away and running, numbered by the field seq"
+	footer := "Use this mode for scripting purposes or as sample output when developing listeners in other languages\n" +
+		"With :exec, the command receives the event as JSON on stdin and in TILO_* variables;\n" +
+		"events are then only printed if a format is given\n\n" +
+		"Examples\n" +
+		"    tilo listen                                                   # JSON lines\n" +
+		"    tilo listen :name=bar :format=template :template='{{.Task}}'  # The running task, e.g. for lemonbar\n" +
+		"    tilo listen :exec='notify-send \"tilo: $TILO_EVENT\"'           # A desktop notification per event"
 	return header, footer
 }
 
 func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if _, _, err := sinceSeq(cmd); err != nil {
+		return err
+	}
+	h, err := newHandler(cl, cmd)
+	if err != nil {
+		return err
+	}
 	cl.EstablishConnection()
 	cl.SendToServer(cmd)
 	resp := cl.ReceiveFromServer()
@@ -44,22 +88,135 @@ func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
 	if cl.Failed() {
 		return errors.Wrap(cl.Error(), "Failed to establish listener connection")
 	}
-	_, err := io.Copy(os.Stdout, cl)
-	return err
+	dec := json.NewDecoder(cl)
+	for {
+		ntf := server.Notification{}
+		if err := dec.Decode(&ntf); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "Invalid notification")
+		}
+		if err := h.handle(ntf); err != nil {
+			return err
+		}
+	}
+}
+
+// The sequence number after which to replay events, if requested.
+func sinceSeq(cmd msg.Cmd) (uint64, bool, error) {
+	seq, ok := cmd.Opts[paramSinceSeq]
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false, errors.Errorf("Invalid sequence number: %s", seq)
+	}
+	return n, true, nil
+}
+
+// Deals with the events received according to the parameters.
+type handler struct {
+	out     io.Writer
+	format  string             // Empty if events are not printed
+	tmpl    *template.Template // Set for formatTemplate
+	command string             // The command to run per event, if any
+}
+
+func newHandler(cl *client.Client, cmd msg.Cmd) (*handler, error) {
+	h := handler{out: cl.Output(), format: cmd.Opts[paramFormat], command: cmd.Opts[paramExec]}
+	if h.format == "" && h.command == "" {
+		h.format = formatJSON
+	}
+	switch h.format {
+	case "", formatJSON:
+	case formatTemplate:
+		text := defaultTemplate
+		if t, ok := cmd.Opts[paramTemplate]; ok {
+			text = t
+		}
+		tmpl, err := template.New("event").Parse(text)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid template")
+		}
+		h.tmpl = tmpl
+	default:
+		return nil, errors.Errorf("Unknown format: %s", h.format)
+	}
+	return &h, nil
+}
+
+func (h *handler) handle(ntf server.Notification) error {
+	data, err := json.Marshal(ntf)
+	if err != nil {
+		return err
+	}
+	if ntf.Event == server.EventShutdown {
+		// Only a marker, not a task.
+		ntf.Task = ""
+	}
+	switch h.format {
+	case formatJSON:
+		fmt.Fprintln(h.out, string(data))
+	case formatTemplate:
+		var line bytes.Buffer
+		if err := h.tmpl.Execute(&line, ntf); err != nil {
+			return errors.Wrap(err, "Failed to apply template")
+		}
+		fmt.Fprintln(h.out, line.String())
+	}
+	if h.command != "" {
+		c := exec.Command("sh", "-c", h.command)
+		c.Env = append(os.Environ(), eventEnv(ntf)...)
+		c.Stdin = bytes.NewReader(data)
+		c.Stdout = h.out
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, "Command failed for event", ntf.Event+":", err)
+		}
+	}
+	return nil
+}
+
+// Environment variables describing the event.
+func eventEnv(ntf server.Notification) []string {
+	env := []string{
+		"TILO_EVENT=" + ntf.Event,
+		"TILO_TASK=" + ntf.Task,
+		"TILO_SINCE=" + ntf.Since.Format(time.RFC3339),
+	}
+	if ntf.Warning != nil {
+		env = append(env, fmt.Sprintf("TILO_PERCENT=%d", ntf.Warning.Percent))
+	}
+	if ntf.Event == server.EventRunning {
+		env = append(env, "TILO_ELAPSED="+ntf.Elapsed.String())
+	}
+	return env
 }
 
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	// NOTE: Connection has to be kept open!
 	resp := msg.Response{}
+	since, replay, err := sinceSeq(req.Cmd)
+	if err != nil {
+		resp.SetError(err)
+		defer req.Close()
+		return srv.Answer(req, resp)
+	}
 	if listener, err := srv.RegisterListener(req); err != nil {
 		resp.SetError(errors.Wrap(err, "Failed to add as listener"))
 	} else {
 		resp.SetListening()
-		defer listener.Notify(server.TaskNotification(srv.CurrentTask))
+		defer srv.Greet(listener, since, replay)
 	}
 	return srv.Answer(req, resp)
 }
 
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
 func init() {
 	command.RegisterOperation(operation{})
 }
@@ -0,0 +1,83 @@
+package listen_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/history"
+	_ "github.com/fgahr/tilo/command/listen"
+	_ "github.com/fgahr/tilo/command/start"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+// The longest elapsed time passed to :exec so far, zero if none.
+func longestElapsed(t *testing.T, file string) time.Duration {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	var longest time.Duration
+	for _, line := range strings.Fields(string(content)) {
+		d, err := time.ParseDuration(line)
+		if err != nil {
+			t.Fatalf("invalid TILO_ELAPSED %q: %v", line, err)
+		}
+		if d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+func TestRunningNotifications(t *testing.T) {
+	srv := tilotest.StartServer(t, "--running-interval=100ms")
+	srv.MustRun("start", "foo")
+
+	elapsedFile := filepath.Join(t.TempDir(), "elapsed")
+	done := make(chan string, 1)
+	go func() {
+		// Returns once the server shuts down.
+		out, _ := srv.Run("listen", ":format=json", ":exec=echo $TILO_ELAPSED >> '"+elapsedFile+"'")
+		done <- out
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for longestElapsed(t, elapsedFile) < time.Second {
+		if time.Now().After(deadline) {
+			srv.Stop()
+			t.Fatal("no running notification with a second elapsed in time")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	history := srv.MustRun("log", "foo")
+	srv.Stop()
+
+	var out string
+	select {
+	case out = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("listener did not stop with the server")
+	}
+	var longest time.Duration
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var ntf struct {
+			Event   string        `json:"event"`
+			Elapsed time.Duration `json:"elapsed"`
+		}
+		if err := json.Unmarshal([]byte(line), &ntf); err != nil {
+			t.Fatalf("invalid notification %q: %v", line, err)
+		}
+		if ntf.Event == "running" && ntf.Elapsed > longest {
+			longest = ntf.Elapsed
+		}
+	}
+	if longest < time.Second || longest > 10*time.Second {
+		t.Errorf("expected a running notification with about a second elapsed, got:\n%s", out)
+	}
+	if !strings.Contains(history, "running") {
+		t.Errorf("expected running markers in the history, got:\n%s", history)
+	}
+}
@@ -0,0 +1,114 @@
+package note
+
+import (
+	"strings"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTask = "task"
+)
+
+// Handles the note text in addition to the parameters.
+type noteHandler struct {
+	params argparse.ArgHandler
+}
+
+func (h noteHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	text, err := h.params.HandleArgs(cmd, args)
+	if err != nil {
+		return args, err
+	}
+	if len(text) == 0 {
+		return args, errors.New("Require a note but none is given")
+	}
+	cmd.Body = [][]string{text}
+	return nil, nil
+}
+
+func (h noteHandler) TakesParameters() bool {
+	return true
+}
+
+func (h noteHandler) DescribeParameters() []argparse.ParamDescription {
+	text := argparse.ParamDescription{
+		ParamName:        "",
+		ParamValues:      "<text>",
+		ParamExplanation: "The note to attach",
+	}
+	return append([]argparse.ParamDescription{text}, h.params.DescribeParameters()...)
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "note"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramTask, "TASK", "Annotate the latest saved entry of this task instead"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(noteHandler{argparse.HandlerForParams(params)})
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:    op.Command(),
+		First:  "<text>",
+		Second: "[parameters]",
+		What:   "Attach a note to the current task",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Attach a note to the currently active task or the latest entry of a given task"
+	footer := "Notes are shown by `query` with the :with-notes parameter\n\n" +
+		"Examples\n" +
+		"    tilo note \"debugged flaky test\"           # Annotate the current task\n" +
+		"    tilo note :task=foo \"forgot to mention\"   # Annotate the latest entry of foo"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to attach note")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	if len(req.Cmd.Body) == 0 {
+		resp.SetError(errors.New("No note given"))
+		return srv.Answer(req, resp)
+	}
+	text := strings.Join(req.Cmd.Body[0], " ")
+	task := req.Cmd.Opts[paramTask]
+	if task == "" || task == srv.CurrentTask.Name {
+		if srv.AnnotateCurrentTask(text) {
+			resp.AddMessage("Note added to the current task " + srv.CurrentTask.Name)
+		} else if task == "" {
+			resp.SetError(errors.New("No active task"))
+		}
+	}
+	if !resp.Failed() && len(resp.Body) == 0 {
+		if err := srv.AnnotateTask(task, text); err != nil {
+			resp.SetError(err)
+		} else {
+			resp.AddMessage("Note added to the latest entry of " + task)
+		}
+	}
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -3,6 +3,7 @@ package ping
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/fgahr/tilo/argparse"
@@ -10,6 +11,12 @@ import (
 	"github.com/fgahr/tilo/command"
 	"github.com/fgahr/tilo/msg"
 	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramCount    = "count"
+	paramInterval = "interval"
 )
 
 type operation struct {
@@ -21,7 +28,11 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+	params := []argparse.Param{
+		argparse.Option(paramCount, "N", "Number of pings to send, 1 by default"),
+		argparse.Option(paramInterval, "<duration>", "Time between pings, e.g. 500ms; 1s by default"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -30,25 +41,106 @@ func (op operation) DescribeShort() argparse.Description {
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
 	header := "Request a reply from the server, measure the time between sending and receiving"
-	footer := "Use this command to test server responsiveness"
+	footer := "Use this command to test server responsiveness\n\n" +
+		"Examples\n" +
+		"    tilo ping :count=10 :interval=200ms  # Latency statistics over ten pings"
 	return header, footer
 }
 
 func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	count, interval, err := pingOptions(cmd)
+	if err != nil {
+		return err
+	}
 	// TODO: Should ping start a server if none is running?
-	cl.EstablishConnection()
-	before := time.Now()
-	if _, err := fmt.Fprintln(os.Stderr, "Sending ping to server"); err != nil {
+	if count == 1 {
+		if _, err := fmt.Fprintln(os.Stderr, "Sending ping to server"); err != nil {
+			return err
+		}
+		latency, err := ping(cl, cmd)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stderr, "Received pong from server after %v\n", latency)
 		return err
 	}
+
+	stats := statistics{}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		if latency, err := ping(cl, cmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Ping %d failed: %v\n", i+1, err)
+			stats.lost++
+		} else {
+			fmt.Fprintf(os.Stderr, "Pong %d after %v\n", i+1, latency)
+			stats.add(latency)
+		}
+	}
+	_, err = fmt.Fprintln(os.Stderr, stats.String())
+	if stats.replies == 0 {
+		return errors.New("No reply from server")
+	}
+	return err
+}
+
+// Send a single ping and measure the time until the reply arrives.
+func ping(cl *client.Client, cmd msg.Cmd) (time.Duration, error) {
+	cl.EstablishConnection()
+	before := time.Now()
 	cl.SendToServer(cmd)
 	cl.ReceiveFromServer() // Ignoring response
 	after := time.Now()
 	if cl.Failed() {
-		return cl.Error()
+		err := cl.Error()
+		cl.ClearError()
+		return 0, err
 	}
-	_, err := fmt.Fprintf(os.Stderr, "Received pong from server after %v\n", after.Sub(before))
-	return err
+	return after.Sub(before), nil
+}
+
+func pingOptions(cmd msg.Cmd) (int, time.Duration, error) {
+	count, interval := 1, time.Second
+	var err error
+	if c, ok := cmd.Opts[paramCount]; ok {
+		if count, err = strconv.Atoi(c); err != nil || count < 1 {
+			return 0, 0, errors.Errorf("Invalid count: %s", c)
+		}
+	}
+	if i, ok := cmd.Opts[paramInterval]; ok {
+		if interval, err = time.ParseDuration(i); err != nil || interval < 0 {
+			return 0, 0, errors.Errorf("Invalid interval: %s", i)
+		}
+	}
+	return count, interval, nil
+}
+
+// Latency statistics over several pings.
+type statistics struct {
+	min, max, total time.Duration
+	replies, lost   int
+}
+
+func (s *statistics) add(latency time.Duration) {
+	if s.replies == 0 || latency < s.min {
+		s.min = latency
+	}
+	if latency > s.max {
+		s.max = latency
+	}
+	s.total += latency
+	s.replies++
+}
+
+func (s *statistics) String() string {
+	sent := s.replies + s.lost
+	summary := fmt.Sprintf("%d sent, %d received, %.0f%% loss", sent, s.replies, 100*float64(s.lost)/float64(sent))
+	if s.replies == 0 {
+		return summary
+	}
+	avg := s.total / time.Duration(s.replies)
+	return summary + fmt.Sprintf("\nmin/avg/max latency: %v/%v/%v", s.min, avg, s.max)
 }
 
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
@@ -59,6 +151,11 @@ func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	return srv.Answer(req, resp)
 }
 
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
 func init() {
 	command.RegisterOperation(operation{})
 }
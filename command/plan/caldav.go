@@ -0,0 +1,75 @@
+package plan
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/server/ics"
+	"github.com/pkg/errors"
+)
+
+// The format of times in CalDAV queries.
+const caldavTime = "20060102T150405Z"
+
+// The parts of a CalDAV multistatus response holding calendar data.
+type multistatus struct {
+	Responses []struct {
+		Propstats []struct {
+			CalendarData string `xml:"prop>calendar-data"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Fetch the events between start and end from a CalDAV calendar, with
+// recurring events expanded by the server.
+func fetchCalDAV(url string, start time.Time, end time.Time) ([]ics.Event, error) {
+	if url == "" {
+		return nil, errors.New("No calendar configured, see caldav_url")
+	}
+	rng := fmt.Sprintf(`start="%s" end="%s"`, start.UTC().Format(caldavTime), end.UTC().Format(caldavTime))
+	query := `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:calendar-data><C:expand ` + rng + `/></C:calendar-data></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT"><C:time-range ` + rng + `/></C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+	req, err := http.NewRequest("REPORT", url, strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, errors.Errorf("Unexpected response: %s", resp.Status)
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, errors.Wrap(err, "Malformed response")
+	}
+	var events []ics.Event
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstats {
+			if ps.CalendarData == "" {
+				continue
+			}
+			found, err := ics.ReadEvents(strings.NewReader(ps.CalendarData))
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, found...)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,348 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	LIST    = "list"
+	IMPORT  = "import"
+	CONFIRM = "confirm"
+	DISCARD = "discard"
+	REPORT  = "report"
+)
+
+const (
+	// Set from the first argument
+	optAction   = "action"
+	paramCalDAV = "caldav"
+	paramDays   = "days"
+	paramPast   = "past"
+	paramDaily  = "daily"
+	// Days ahead imported by default
+	defaultDays = 14
+)
+
+// Parameters of the import command.
+var importParams = []argparse.Param{
+	argparse.Flag(paramCalDAV, "Import from the calendar given as caldav_url"),
+	argparse.Option(paramDays, "<n>", "The number of days ahead to import, 14 by default"),
+}
+
+// Parameters of the confirm command.
+var confirmParams = []argparse.Param{
+	argparse.Flag(paramPast, "Confirm all planned entries which have ended"),
+}
+
+// Takes the action as the first argument, followed by its parameters.
+type planHandler struct{}
+
+func (h planHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	if cmd.Opts == nil {
+		cmd.Opts = make(map[string]string)
+	}
+	if len(args) == 0 {
+		cmd.Opts[optAction] = LIST
+		return args, nil
+	}
+	cmd.Opts[optAction] = args[0]
+	switch args[0] {
+	case LIST:
+		return args[1:], nil
+	case IMPORT:
+		return argparse.HandlerForParams(importParams).HandleArgs(cmd, args[1:])
+	case CONFIRM, DISCARD:
+		rest := args[1:]
+		if args[0] == CONFIRM {
+			var err error
+			if rest, err = argparse.HandlerForParams(confirmParams).HandleArgs(cmd, rest); err != nil {
+				return rest, err
+			}
+		}
+		if len(rest) == 0 && !cmd.Flags[paramPast] {
+			return rest, errors.New("Require the ID of a planned entry but none was given")
+		}
+		cmd.Body = [][]string{rest}
+		return nil, nil
+	case REPORT:
+		return argparse.HandlerForParams(reportParams(msg.Clock())).HandleArgs(cmd, args[1:])
+	default:
+		return args, errors.New("Not a known plan command: " + args[0])
+	}
+}
+
+func (h planHandler) TakesParameters() bool {
+	return true
+}
+
+func (h planHandler) DescribeParameters() []argparse.ParamDescription {
+	return []argparse.ParamDescription{
+		argparse.ParamDescription{
+			ParamName:        LIST,
+			ParamExplanation: "List planned entries not yet confirmed, the default",
+		},
+		argparse.ParamDescription{
+			ParamName:        IMPORT,
+			ParamValues:      ":caldav [:days=<n>]",
+			ParamExplanation: "Import upcoming events as planned entries",
+		},
+		argparse.ParamDescription{
+			ParamName:        CONFIRM,
+			ParamValues:      "<id>...|:past",
+			ParamExplanation: "Record planned entries as tracked time",
+		},
+		argparse.ParamDescription{
+			ParamName:        DISCARD,
+			ParamValues:      "<id>...",
+			ParamExplanation: "Remove planned entries, e.g. cancelled meetings",
+		},
+		argparse.ParamDescription{
+			ParamName:        REPORT,
+			ParamValues:      "[<time>] [:daily]",
+			ParamExplanation: "Compare planned and tracked time per task, this week by default",
+		},
+	}
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "plan"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(planHandler{})
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:   op.Command(),
+		First: "[list|import|confirm|discard|report]",
+		What:  "Plan entries ahead, e.g. from a calendar",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Keep planned entries, e.g. meetings, apart from tracked time until confirmed"
+	footer := "Imported events are assigned to the task preceding a colon in their title,\n" +
+		"e.g. \"foo: Sprint review\", or to plan_task otherwise\n" +
+		"Importing again updates entries, keeping them confirmed where they were\n" +
+		"Reports mark overruns, i.e. more time tracked than planned for a task\n\n" +
+		"Examples\n" +
+		"    tilo plan import :caldav          # The next two weeks of events\n" +
+		"    tilo plan confirm 3f9a0c2e        # Record a meeting which took place\n" +
+		"    tilo plan report :last-week       # Planned vs. tracked time\n" +
+		"    tilo plan report :daily           # The same per day, for this week"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if cmd.Opts[optAction] == IMPORT {
+		if !cmd.Flags[paramCalDAV] {
+			return errors.New("Require a source to import from, e.g. :caldav")
+		}
+		days := defaultDays
+		if d, ok := cmd.Opts[paramDays]; ok {
+			n, err := strconv.Atoi(d)
+			if err != nil || n <= 0 {
+				return errors.Errorf("Invalid number of days: %s", d)
+			}
+			days = n
+		}
+		start := time.Now()
+		events, err := fetchCalDAV(cl.CalDAVURL(), start, start.AddDate(0, 0, days))
+		if err != nil {
+			return errors.Wrap(err, "Failed to fetch events")
+		}
+		cmd.Body = nil
+		for _, ev := range events {
+			plan := msg.Plan{ID: msg.PlanID(ev.UID, ev.Started), Title: ev.Summary, Started: ev.Started, Ended: ev.Ended}
+			data, err := json.Marshal(plan)
+			if err != nil {
+				panic(err)
+			}
+			cmd.Body = append(cmd.Body, []string{string(data)})
+		}
+	}
+	cl.EstablishConnection()
+	cl.SendReceivePrint(cmd)
+	return cl.Error()
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	planner, err := srv.Planner()
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	// Planned entries are few, so they are simply gathered as a whole.
+	all, err := planner.GetPlansBetween(time.Unix(0, 0), time.Now().AddDate(10, 0, 0))
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Unable to read planned entries"))
+		return srv.Answer(req, resp)
+	}
+	switch req.Cmd.Opts[optAction] {
+	case IMPORT:
+		resp = importPlans(srv, planner, all, req.Cmd.Body)
+	case CONFIRM:
+		resp = confirm(srv, all, req.Cmd)
+	case DISCARD:
+		resp = discard(planner, req.Cmd)
+	case REPORT:
+		resp = report(srv, all, req.Cmd)
+	default:
+		resp = list(all)
+	}
+	return srv.Answer(req, resp)
+}
+
+// Save the imported entries, assigning them to tasks.
+func importPlans(srv *server.Server, planner backend.Planner, existing []msg.Plan, body [][]string) msg.Response {
+	resp := msg.Response{}
+	confirmed := make(map[string]bool)
+	for _, p := range existing {
+		confirmed[p.ID] = p.Confirmed
+	}
+	var plans []msg.Plan
+	for _, line := range body {
+		plan := msg.Plan{}
+		if len(line) != 1 {
+			resp.SetError(errors.New("Malformed planned entry"))
+		} else if err := json.Unmarshal([]byte(line[0]), &plan); err != nil {
+			resp.SetError(errors.Wrap(err, "Malformed planned entry"))
+		}
+		if resp.Failed() {
+			return resp
+		}
+		plan.Task, plan.Title = taskAndTitle(plan.Title, srv.DefaultPlanTask())
+		plan.Confirmed = confirmed[plan.ID]
+		plans = append(plans, plan)
+	}
+	if err := planner.SavePlans(plans); err != nil {
+		resp.SetError(errors.Wrap(err, "Unable to save planned entries"))
+		return resp
+	}
+	resp.AddKeyValue("Imported entries", fmt.Sprint(len(plans)))
+	return resp
+}
+
+// The task named before a colon in the title, if valid, and the rest of the
+// title. Otherwise, the default task and the entire title.
+func taskAndTitle(title string, def string) (string, string) {
+	parts := strings.SplitN(title, ":", 2)
+	if len(parts) == 2 {
+		task := strings.TrimSpace(parts[0])
+		if names, err := argparse.GetTaskNames(task); err == nil && len(names) == 1 && names[0] != argparse.AllTasks {
+			return task, strings.TrimSpace(parts[1])
+		}
+	}
+	return def, title
+}
+
+// Record the requested entries as tracked time.
+func confirm(srv *server.Server, all []msg.Plan, cmd msg.Cmd) msg.Response {
+	resp := msg.Response{}
+	var chosen []msg.Plan
+	if cmd.Flags[paramPast] {
+		now := time.Now()
+		for _, p := range all {
+			if !p.Confirmed && !p.Ended.After(now) {
+				chosen = append(chosen, p)
+			}
+		}
+	} else {
+		for _, id := range cmd.Body[0] {
+			p, ok := find(all, id)
+			if !ok {
+				resp.SetError(errors.Errorf("No such planned entry: %s", id))
+				return resp
+			}
+			chosen = append(chosen, p)
+		}
+	}
+	for _, p := range chosen {
+		if err := srv.ConfirmPlan(p); err != nil {
+			resp.SetError(err)
+			return resp
+		}
+		resp.AddKeyValue(p.ID, describe(p))
+	}
+	if len(chosen) == 0 {
+		resp.AddMessage("Nothing to confirm")
+	}
+	return resp
+}
+
+// Remove the requested entries.
+func discard(planner backend.Planner, cmd msg.Cmd) msg.Response {
+	resp := msg.Response{}
+	for _, id := range cmd.Body[0] {
+		if removed, err := planner.RemovePlan(id); err != nil {
+			resp.SetError(err)
+			return resp
+		} else if !removed {
+			resp.SetError(errors.Errorf("No such planned entry: %s", id))
+			return resp
+		}
+		resp.AddMessage("Discarded " + id)
+	}
+	return resp
+}
+
+// List the entries not yet confirmed.
+func list(all []msg.Plan) msg.Response {
+	resp := msg.Response{}
+	for _, p := range all {
+		if !p.Confirmed {
+			resp.AddKeyValue(p.ID, describe(p))
+		}
+	}
+	if len(resp.Body) == 0 {
+		resp.AddMessage("Nothing planned")
+	}
+	return resp
+}
+
+func find(all []msg.Plan, id string) (msg.Plan, bool) {
+	for _, p := range all {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return msg.Plan{}, false
+}
+
+// A single line describing the entry.
+func describe(p msg.Plan) string {
+	desc := fmt.Sprintf("%s-%s %s", p.Started.Local().Format("2006-01-02 15:04"), p.Ended.Local().Format("15:04"), p.Task)
+	if p.Title != "" {
+		desc += " (" + p.Title + ")"
+	}
+	return desc
+}
+
+// Listing and reporting are permitted with read-only API tokens.
+func (op operation) ReadOnlyCommand(cmd msg.Cmd) bool {
+	action := cmd.Opts[optAction]
+	return action == LIST || action == REPORT
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
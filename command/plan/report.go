@@ -0,0 +1,133 @@
+package plan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+// Parameters of the report command.
+func reportParams(now time.Time) []argparse.Param {
+	return append(query.TimeParams(now),
+		argparse.Flag(paramDaily, "Compare per day and task rather than per task"))
+}
+
+// Planned and tracked time of a task, possibly on a single day.
+type comparison struct {
+	day     string // Empty unless reporting per day
+	task    string
+	planned time.Duration
+	tracked time.Duration
+}
+
+// An overrun is tracked time exceeding planned time. Tasks without any plan
+// are merely unplanned.
+func (c comparison) overrun() time.Duration {
+	if c.planned == 0 || c.tracked <= c.planned {
+		return 0
+	}
+	return c.tracked - c.planned
+}
+
+func (c comparison) String() string {
+	str := fmt.Sprintf("planned %-8v tracked %-8v", c.planned.Truncate(time.Minute), c.tracked.Truncate(time.Minute))
+	if over := c.overrun(); over >= time.Minute {
+		str += " OVER by " + over.Truncate(time.Minute).String()
+	} else if c.planned == 0 {
+		str += " unplanned"
+	}
+	return strings.TrimRight(str, " ")
+}
+
+// Compare the planned and the tracked time per task, or per day and task,
+// highlighting overruns.
+func report(srv *server.Server, all []msg.Plan, cmd msg.Cmd) msg.Response {
+	resp := msg.Response{}
+	start, end, err := reportRange(cmd.Quantities, cmd.CurrentTime())
+	if err != nil {
+		resp.SetError(err)
+		return resp
+	}
+	daily := cmd.Flags[paramDaily]
+	byKey := make(map[[2]string]*comparison)
+	get := func(t time.Time, task string) *comparison {
+		key := [2]string{"", task}
+		if daily {
+			key[0] = t.Local().Format("2006-01-02")
+		}
+		if byKey[key] == nil {
+			byKey[key] = &comparison{day: key[0], task: task}
+		}
+		return byKey[key]
+	}
+	for _, p := range all {
+		if !p.Started.Before(start) && p.Started.Before(end) {
+			get(p.Started, p.Task).planned += p.Ended.Sub(p.Started)
+		}
+	}
+	err = srv.Backend.ForEachTaskBetween(nil, start, end, func(task msg.Task) error {
+		get(task.Started, task.Name).tracked += task.Ended.Sub(task.Started)
+		return nil
+	})
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Unable to read tracked time"))
+		return resp
+	}
+	if len(byKey) == 0 {
+		resp.AddMessage("Nothing planned or tracked")
+		return resp
+	}
+
+	var rows []*comparison
+	for _, c := range byKey {
+		rows = append(rows, c)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].day != rows[j].day {
+			return rows[i].day < rows[j].day
+		}
+		return rows[i].task < rows[j].task
+	})
+	total := comparison{}
+	var overruns int
+	for _, c := range rows {
+		key := c.task
+		if daily {
+			key = c.day + " " + c.task
+		}
+		resp.AddKeyValue(key, c.String())
+		total.planned += c.planned
+		total.tracked += c.tracked
+		if c.overrun() >= time.Minute {
+			overruns++
+		}
+	}
+	resp.AddKeyValue("Total", total.String())
+	if overruns > 0 {
+		resp.AddMessage(fmt.Sprintf("%d overrun(s)", overruns))
+	}
+	return resp
+}
+
+// The period to report on, the current week unless given.
+func reportRange(quantities []msg.Quantity, now time.Time) (time.Time, time.Time, error) {
+	switch len(quantities) {
+	case 0:
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		start := time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 0, 7), nil
+	case 1:
+		start, end, err := quantifier.Range(quantities[0])
+		return start, end, errors.Wrap(err, "Unable to construct query")
+	default:
+		return time.Time{}, time.Time{}, errors.New("Require at most one period")
+	}
+}
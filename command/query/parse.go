@@ -29,19 +29,57 @@ const (
 	paramLastYear  = "last-year"
 	paramSince     = "since"
 	paramBetween   = "between"
+	// Flags
+	paramWithNotes = "with-notes"
+	paramOffline   = "offline"
+	paramTotalOnly = "total-only"
+	paramCombine   = "combine"
+	// Include archived tasks in :all queries
+	paramIncludeArchived = "include-archived"
+	// Query all users of a shared server
+	paramTeam = "team"
+	// Options
+	paramHost = "host"
+	paramUser = "user"
 )
 
 func newQueryArgHandler(now time.Time) argparse.ArgHandler {
-	params := []argparse.Param{
+	params := append(TimeParams(now), SummaryParams()...)
+	params = append(params,
+		argparse.Flag(paramOffline, "Read the database directly if no server is running"),
+		argparse.Flag(paramIncludeArchived, "Include archived tasks in :all"),
+		argparse.Flag(paramTeam, "Query all users of a shared server; admins only"),
+	)
+	return argparse.HandlerForParams(params)
+}
+
+// SummaryParams are the parameters selecting entries by their source and
+// shaping the summaries, understood by Respond.
+func SummaryParams() []argparse.Param {
+	return []argparse.Param{
+		argparse.Flag(paramWithNotes, "Include notes attached to the entries"),
+		argparse.Flag(paramTotalOnly, "Print only the total time per task"),
+		argparse.Flag(paramCombine, "Print only the total time across all tasks"),
+		argparse.Option(paramHost, "<hostname>", "Only entries started on the given host"),
+		argparse.Option(paramUser, "<username>", "Only entries started by the given user"),
+	}
+}
+
+// TimeParams are the parameters describing time periods relative to now.
+// They are shared by all commands making enquiries about prior activity.
+func TimeParams(now time.Time) []argparse.Param {
+	return []argparse.Param{
 		// Fixed day
 		argparse.Param{
 			Name:        paramToday,
+			Aliases:     []string{"t"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedDayOffset(now, 0),
 			Description: "Today's activity",
 		},
 		argparse.Param{
 			Name:        paramYesterday,
+			Aliases:     []string{"y"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedDayOffset(now, -1),
 			Description: "Yesterday's activity",
@@ -50,12 +88,14 @@ func newQueryArgHandler(now time.Time) argparse.ArgHandler {
 		// Fixed week
 		argparse.Param{
 			Name:        paramThisWeek,
+			Aliases:     []string{"w"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedWeekOffset(now, 0),
 			Description: "This week's activity",
 		},
 		argparse.Param{
 			Name:        paramLastWeek,
+			Aliases:     []string{"lw"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedWeekOffset(now, -1),
 			Description: "Last week's activity",
@@ -64,12 +104,14 @@ func newQueryArgHandler(now time.Time) argparse.ArgHandler {
 		// Fixed month
 		argparse.Param{
 			Name:        paramThisMonth,
+			Aliases:     []string{"m"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedMonthOffset(now, 0),
 			Description: "This month's activity",
 		},
 		argparse.Param{
 			Name:        paramLastMonth,
+			Aliases:     []string{"lm"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedMonthOffset(now, -1),
 			Description: "Last month's activity",
@@ -78,12 +120,14 @@ func newQueryArgHandler(now time.Time) argparse.ArgHandler {
 		// Fixed year
 		argparse.Param{
 			Name:        paramThisYear,
+			Aliases:     []string{"yr"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedYearOffset(now, 0),
 			Description: "This year's activity",
 		},
 		argparse.Param{
 			Name:        paramLastYear,
+			Aliases:     []string{"ly"},
 			RequiresArg: false,
 			Quantifier:  quantifier.FixedYearOffset(now, -1),
 			Description: "Last year's activity",
@@ -149,6 +193,4 @@ func newQueryArgHandler(now time.Time) argparse.ArgHandler {
 			Description: "Activity between two dates",
 		},
 	}
-
-	return argparse.HandlerForParams(params)
 }
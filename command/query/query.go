@@ -22,7 +22,7 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(newQueryArgHandler(time.Now()))
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(newQueryArgHandler(msg.Clock()))
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -36,76 +36,153 @@ func (op operation) HelpHeaderAndFooter() (string, string) {
 		"Examples\n" +
 		"    tilo query :all :this-week                    # This week's activity across all tasks\n" +
 		"    tilo query foo :between 2019-01-01:2019-06-30 # Logged on task foo in first half of 2019\n" +
-		"    tilo query bar :month=2019-01,2019-02,2019-03 # Activity for bar in three different months"
+		"    tilo query bar :month=2019-01,2019-02,2019-03 # Activity for bar in three different months\n" +
+		"    tilo query foo,bar :this-week :total-only     # Time spent on foo and bar this week, per task"
 	return header, footer
 }
 
 func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if cmd.Flags[paramOffline] && !cl.ServerIsRunning() {
+		if b := cl.OpenBackendReadOnly(); b != nil {
+			defer b.Close()
+			cl.PrintResponse(Respond(b, cmd))
+		}
+		return errors.Wrap(cl.Error(), "Failed to query the backend")
+	}
 	cl.SendReceivePrint(cmd)
 	return errors.Wrap(cl.Error(), "Failed to query the server")
 }
 
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
+	if req.Cmd.Flags[paramTeam] {
+		return srv.Answer(req, respondForTeam(srv, req))
+	}
+	return srv.Answer(req, Respond(srv.Backend, req.Cmd))
+}
+
+// Respond answers the query using the given backend, e.g. one holding entries
+// read from elsewhere.
+func Respond(b backend.Backend, cmd msg.Cmd) msg.Response {
 	resp := msg.Response{}
-	backend := srv.Backend
-Outer:
-	for _, task := range req.Cmd.TaskNames {
-		for _, quant := range req.Cmd.Quantities {
-			if sum, err := queryBackend(backend, task, quant); err != nil {
-				resp.SetError(errors.Wrap(err, "A query failed"))
-				break Outer
-			} else {
-				resp.AddQuerySummaries(sum)
-			}
-		}
+	all, err := summaries(b, cmd)
+	if err != nil {
+		resp.SetError(err)
+		return resp
 	}
-	return srv.Answer(req, resp)
+	return present(all, cmd)
 }
 
-func queryBackend(b backend.Backend, task string, param msg.Quantity) ([]msg.Summary, error) {
-	var sum []msg.Summary
-	if b == nil {
-		return sum, errors.New("No backend present")
+// Answer the query across all users of a shared server, prefixing task names
+// with the user, e.g. alice/foo.
+func respondForTeam(srv *server.Server, req *server.Request) msg.Response {
+	resp := msg.Response{}
+	if !srv.IsAdmin(req) {
+		resp.SetError(errors.New("Only admins may query the team"))
+		return resp
 	}
-	var err error
-	// TODO: Some more length checks required. Might be restructured beforehand.
-	switch param.Type {
-	case quantifier.TimeDay:
-		start, err := time.Parse("2006-01-02", param.Elems[0])
-		if err != nil {
-			return nil, errors.Wrap(err, "Unable to construct query")
+	var all []msg.Summary
+	err := srv.ForEachUser(func(user string, b backend.Backend) error {
+		sum, err := summaries(b, req.Cmd)
+		for _, s := range sum {
+			s.Task = user + "/" + s.Task
+			all = append(all, s)
 		}
-		end := start.AddDate(0, 0, 1)
-		sum, err = b.GetTaskBetween(task, start, end)
-	case quantifier.TimeBetween:
-		if len(param.Elems) < 2 {
-			return nil, errors.Errorf("Invalid query parameter: %v", param)
+		return err
+	})
+	if err != nil {
+		resp.SetError(err)
+		return resp
+	}
+	return present(all, req.Cmd)
+}
+
+// The summaries matching the query in the given backend. Tasks given by name
+// without any activity in a period get a summary with a zero total, so that
+// the output has the same shape regardless.
+func summaries(b backend.Backend, cmd msg.Cmd) ([]msg.Summary, error) {
+	source := msg.Source{Host: cmd.Opts[paramHost], User: cmd.Opts[paramUser]}
+	var all []msg.Summary
+	for _, task := range cmd.TaskNames {
+		for _, quant := range cmd.Quantities {
+			sum, err := queryBackend(b, task, quant, source, cmd.Flags[paramWithNotes])
+			if err != nil {
+				return nil, errors.Wrap(err, "A query failed")
+			}
+			if len(sum) == 0 && task != TskAllTasks {
+				sum = []msg.Summary{{Task: task, Details: quant}}
+			}
+			all = append(all, sum...)
 		}
-		start, err := time.Parse("2006-01-02", param.Elems[0])
+	}
+	if len(cmd.TaskNames) == 1 && cmd.TaskNames[0] == TskAllTasks && !cmd.Flags[paramIncludeArchived] {
+		filtered, err := withoutArchived(b, all)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrap(err, "Error in database query")
 		}
-		end, err := time.Parse("2006-01-02", param.Elems[1])
-		if err != nil {
-			return nil, err
+		all = filtered
+	}
+	return all, nil
+}
+
+// Present the summaries as requested.
+func present(all []msg.Summary, cmd msg.Cmd) msg.Response {
+	resp := msg.Response{}
+	switch {
+	case cmd.Flags[paramCombine]:
+		var total time.Duration
+		for _, sum := range all {
+			total += sum.Total
 		}
-		sum, err = b.GetTaskBetween(task, start, end)
-	case quantifier.TimeMonth:
-		start, err := time.Parse("2006-01", param.Elems[0])
-		if err != nil {
-			return nil, errors.Wrap(err, "Unable to construct query")
+		resp.AddMessage(total.String())
+	case cmd.Flags[paramTotalOnly]:
+		tasks, totals := totalsPerTask(all)
+		for _, task := range tasks {
+			resp.AddKeyValue(task, totals[task].String())
 		}
-		end := start.AddDate(0, 1, 0)
-		sum, err = b.GetTaskBetween(task, start, end)
-	case quantifier.TimeYear:
-		start, err := time.Parse("2006", param.Elems[0])
-		if err != nil {
-			return nil, errors.Wrap(err, "Unable to construct query")
+	default:
+		resp.AddQuerySummaries(all)
+	}
+	return resp
+}
+
+// Leave out the summaries of archived tasks.
+func withoutArchived(b backend.Backend, sum []msg.Summary) ([]msg.Summary, error) {
+	archived, err := backend.ArchivedSet(b)
+	if err != nil {
+		return nil, err
+	}
+	var result []msg.Summary
+	for _, s := range sum {
+		if !archived[s.Task] {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// Sum up the summaries for each task. Tasks are listed in order of appearance.
+func totalsPerTask(sum []msg.Summary) ([]string, map[string]time.Duration) {
+	var tasks []string
+	totals := make(map[string]time.Duration)
+	for _, s := range sum {
+		if _, ok := totals[s.Task]; !ok {
+			tasks = append(tasks, s.Task)
 		}
-		end := start.AddDate(1, 0, 0)
-		sum, err = b.GetTaskBetween(task, start, end)
+		totals[s.Task] += s.Total
+	}
+	return tasks, totals
+}
+
+func queryBackend(b backend.Backend, task string, param msg.Quantity, source msg.Source, withNotes bool) ([]msg.Summary, error) {
+	if b == nil {
+		return nil, errors.New("No backend present")
 	}
+	start, end, err := quantifier.Range(param)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to construct query")
+	}
+	sum, err := b.GetTaskBetween(task, start, end, source)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error in database query")
 	}
@@ -113,10 +190,20 @@ func queryBackend(b backend.Backend, task string, param msg.Quantity) ([]msg.Sum
 	// Setting the details allows to give better output.
 	for i, _ := range sum {
 		sum[i].Details = param
+		if withNotes {
+			if sum[i].Notes, err = b.GetNotesBetween(sum[i].Task, start, end); err != nil {
+				return nil, errors.Wrap(err, "Error in database query")
+			}
+		}
 	}
 	return sum, nil
 }
 
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
 func init() {
 	command.RegisterOperation(operation{})
 }
@@ -0,0 +1,121 @@
+package query_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/query"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+// Log an hour on foo and half an hour on bar, early today.
+func logSomeTasks(srv *tilotest.Server) {
+	y, m, d := time.Now().Date()
+	base := time.Date(y, m, d, 0, 1, 0, 0, time.Local)
+	srv.At(base)
+	srv.MustRun("start", "foo")
+	srv.At(base.Add(time.Hour))
+	srv.MustRun("start", "bar")
+	srv.At(base.Add(90 * time.Minute))
+	srv.MustRun("stop")
+}
+
+func TestQueryToday(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+	logSomeTasks(srv)
+
+	out := srv.MustRun("query", ":all", ":today")
+	for _, line := range []string{"Total time   1h0m0s", "Total time   30m0s"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in output:\n%s", line, out)
+		}
+	}
+}
+
+func TestQueryTotalOnly(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+	logSomeTasks(srv)
+
+	out := srv.MustRun("query", "foo,bar", ":today", ":total-only")
+	if expected := "foo 1h0m0s\nbar 30m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestQueryUnknownTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+	logSomeTasks(srv)
+
+	out := srv.MustRun("query", "baz", ":today")
+	if !strings.Contains(out, "Nothing found") || !strings.Contains(out, "Total time 0s") {
+		t.Errorf("expected no activity for baz, got:\n%s", out)
+	}
+}
+
+func TestQueryClipsOvernightEntry(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(4 * time.Hour))
+	srv.MustRun("stop")
+
+	for _, day := range []string{"2020-03-01", "2020-03-02"} {
+		out := srv.MustRun("query", "foo", ":day="+day, ":total-only")
+		if expected := "foo 2h0m0s\n"; out != expected {
+			t.Errorf("expected for %s:\n%s\ngot:\n%s", day, expected, out)
+		}
+	}
+}
+
+func TestQuerySeparatesSummaries(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+	logSomeTasks(srv)
+
+	out := srv.MustRun("query", "foo,bar", ":today")
+	if parts := strings.Split(strings.TrimSpace(out), "\n\n"); len(parts) != 2 || !strings.HasPrefix(parts[1], "bar ") {
+		t.Errorf("expected two summaries separated by an empty line, got:\n%s", out)
+	}
+}
+
+func TestQueryAtFixedTime(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	// A Sunday evening, the end of the week.
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.Now.Value = "2020-03-01T23:59"
+	out := srv.MustRun("query", "foo", ":this-week", ":total-only")
+	if expected := "foo 1h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	srv.Conf.Now.Value = "2020-03-02T00:01"
+	out = srv.MustRun("query", "foo", ":this-week", ":total-only")
+	if expected := "foo 0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if _, err := srv.Run("start", "bar"); err == nil {
+		t.Error("expected an error when changing data at a fixed time")
+	}
+
+	srv.Conf.Now.Value = "sunday"
+	if _, err := srv.Run("query", "foo", ":this-week"); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+	srv.Conf.Now.Value = ""
+}
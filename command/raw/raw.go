@@ -0,0 +1,79 @@
+package raw
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "raw"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Send a JSON command read from stdin")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Read a single command as a JSON object from stdin, send it to the server\n" +
+		"and print everything received in reply, one JSON object per line"
+	footer := "Meant for editor plugins and other programs not wanting to implement the socket protocol\n" +
+		"The first line printed is the response; some commands, e.g. `export`, stream more data\n" +
+		"Exits with non-zero status if the response indicates an error\n\n" +
+		"Example\n" +
+		"    echo '{\"operation\": \"start\", \"tasks\": [\"foo\"]}' | tilo raw"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	raw := msg.Cmd{}
+	if err := json.NewDecoder(os.Stdin).Decode(&raw); err != nil {
+		return errors.Wrap(err, "Failed to read command from stdin")
+	}
+	if raw.Op == "" {
+		return errors.New("No operation given")
+	} else if raw.Op == op.Command() {
+		return errors.New("Not a valid server operation: " + op.Command())
+	}
+
+	cl.EstablishConnection()
+	cl.SendToServer(raw)
+	resp := cl.ReceiveFromServer()
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Failed to communicate with the server")
+	}
+	if err := json.NewEncoder(cl.Output()).Encode(resp); err != nil {
+		return err
+	}
+	// Anything following the response, e.g. streamed entries or notifications.
+	if _, err := io.Copy(cl.Output(), cl); err != nil {
+		return err
+	}
+	return resp.Err()
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
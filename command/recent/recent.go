@@ -1,6 +1,8 @@
 package recent
 
 import (
+	"strconv"
+
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/client"
 	"github.com/fgahr/tilo/command"
@@ -9,6 +11,12 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	paramCount = "count"
+	// Tasks shown by default
+	defaultCount = 5
+)
+
 type operation struct {
 	// No state required
 }
@@ -18,7 +26,10 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+	params := []argparse.Param{
+		argparse.Option(paramCount, "<n>", "The number of tasks to show, 5 by default"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -40,7 +51,15 @@ func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
 
-	fetchNum := 5
+	fetchNum := defaultCount
+	if count, ok := req.Cmd.Opts[paramCount]; ok {
+		n, err := strconv.Atoi(count)
+		if err != nil || n <= 0 {
+			resp.SetError(errors.Errorf("Invalid count: %s", count))
+			return srv.Answer(req, resp)
+		}
+		fetchNum = n
+	}
 	if srv.CurrentTask.IsRunning() {
 		fetchNum--
 		resp.AddCurrentTask(srv.CurrentTask)
@@ -55,6 +74,11 @@ func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	return srv.Answer(req, resp)
 }
 
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
 func init() {
 	command.RegisterOperation(operation{})
 }
@@ -0,0 +1,121 @@
+package rename
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTo = "to"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "rename"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramTo, "<task>", "The new name of the task"),
+	}
+	return argparse.CommandParser(op.Command()).WithSingleTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Rename a task throughout its history")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Rename a task in all recorded entries, its history and plans, e.g. to fix a typo\n" +
+		"If the task is active, it continues under the new name"
+	footer := "Entries of a task already named so are merged with the renamed ones\n" +
+		"Renaming is synchronized to other devices, see the README\n\n" +
+		"Examples\n" +
+		"    tilo rename porject-x :to=project-x"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	task := cmd.TaskNames[0]
+	to, ok := cmd.Opts[paramTo]
+	if !ok {
+		return errors.New("Require the new name via :to")
+	}
+	if names, err := argparse.GetTaskNames(to); err != nil || len(names) != 1 || names[0] == argparse.AllTasks {
+		return errors.Errorf("Invalid task name: %s", to)
+	}
+	if to == task {
+		return errors.Errorf("The task is named %s already", task)
+	}
+	cl.SendConfirmed(cmd, fmt.Sprintf("Rename %s to %s?", task, to))
+	return errors.Wrapf(cl.Error(), "Failed to rename task '%s'", task)
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	task, to := req.Cmd.TaskNames[0], req.Cmd.Opts[paramTo]
+	running := srv.CurrentTask.IsRunning() && srv.CurrentTask.Name == task
+	recorded, err := recordedTime(srv, task)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if recorded == nil && !running {
+		resp.SetError(errors.Errorf("No entries of %s", task))
+		return srv.Answer(req, resp)
+	}
+	if req.Cmd.DryRun {
+		resp.AddKeyValue("Rename", task+" to "+to)
+		if recorded != nil {
+			resp.AddKeyValue("Recorded", describe(*recorded))
+		}
+		if running {
+			resp.AddKeyValue("Active since", srv.CurrentTask.Started.Format("2006-01-02 15:04"))
+		}
+		if existing, err := recordedTime(srv, to); err != nil {
+			resp.SetError(err)
+		} else if existing != nil {
+			resp.AddKeyValue("Merge with", to+" "+describe(*existing))
+		}
+		return srv.Answer(req, resp)
+	}
+	if count, err := srv.RenameTask(task, to); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+	} else {
+		resp.AddMessage(fmt.Sprintf("Renamed %s to %s", task, to))
+		resp.AddKeyValue("Entries", fmt.Sprint(count))
+	}
+	return srv.Answer(req, resp)
+}
+
+// The time recorded on the task, nil if there are no entries.
+func recordedTime(srv *server.Server, task string) (*msg.Summary, error) {
+	sum, err := srv.Backend.GetTaskBetween(task, time.Unix(0, 0), time.Now().Add(time.Second), msg.Source{})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error in database query")
+	}
+	if len(sum) == 0 {
+		return nil, nil
+	}
+	return &sum[0], nil
+}
+
+// The total time and the period of the summary.
+func describe(sum msg.Summary) string {
+	return fmt.Sprintf("%v (%s - %s)", sum.Total, sum.Start.Format("2006-01-02"), sum.End.Format("2006-01-02"))
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,82 @@
+package rename_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/current"
+	_ "github.com/fgahr/tilo/command/query"
+	_ "github.com/fgahr/tilo/command/rename"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/sync"
+	_ "github.com/fgahr/tilo/server/backend/jsonfile"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestRenameActiveTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 2, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "porject-x")
+	srv.At(started.Add(time.Hour))
+	srv.MustRun("stop")
+	srv.At(started.Add(2 * time.Hour))
+	srv.MustRun("start", "porject-x")
+
+	srv.Conf.AssumeYes.Value = "true"
+	out := srv.MustRun("rename", "porject-x", ":to=project-x")
+	if !strings.Contains(out, "Renamed porject-x to project-x") || !strings.Contains(out, "Entries") {
+		t.Errorf("expected the entry to be renamed, got:\n%s", out)
+	}
+	if out := srv.MustRun("current"); !strings.Contains(out, "project-x") {
+		t.Errorf("expected the active task to be renamed, got:\n%s", out)
+	}
+	out = srv.MustRun("query", "project-x", ":day=2020-03-02", ":total-only")
+	if expected := "project-x 1h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+
+	if _, err := srv.Run("rename", "porject-x", ":to=project-y"); err == nil {
+		t.Error("expected an error for a task without entries")
+	}
+	if _, err := srv.Run("rename", "project-x", ":to=:all"); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+}
+
+func TestRenameThenSync(t *testing.T) {
+	remote := "file:" + filepath.Join(t.TempDir(), "tilo.log")
+	laptop := tilotest.StartServer(t, "--device-id=laptop")
+	defer laptop.Stop()
+	// Backends are shared within the process, the other device needs its own.
+	desktop := tilotest.StartServer(t, "--device-id=desktop", "--backend=jsonfile", "--json-dir="+t.TempDir())
+	defer desktop.Stop()
+	laptop.Conf.AssumeYes.Value = "true"
+	desktop.Conf.AssumeYes.Value = "true"
+
+	started := time.Date(2020, 3, 2, 9, 0, 0, 0, time.Local)
+	laptop.At(started)
+	laptop.MustRun("start", "porject-x")
+	laptop.At(started.Add(time.Hour))
+	laptop.MustRun("stop")
+	laptop.MustRun("sync", remote)
+	desktop.MustRun("sync", remote)
+
+	laptop.MustRun("rename", "porject-x", ":to=project-x")
+	laptop.MustRun("sync", remote)
+	desktop.MustRun("sync", remote)
+
+	out := desktop.MustRun("query", "project-x", ":day=2020-03-02", ":total-only")
+	if expected := "project-x 1h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	out = desktop.MustRun("query", "porject-x", ":day=2020-03-02", ":total-only")
+	if strings.Contains(out, "1h0m0s") {
+		t.Errorf("expected no time left on the old name, got:\n%s", out)
+	}
+}
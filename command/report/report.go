@@ -0,0 +1,182 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTeam = "team"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "report"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Flag(paramTeam, "Report on all users of a shared server"),
+	)
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Sum up the time spent per task")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Sum up the time spent on each task, or by each user of a shared server"
+	footer := "Without time parameters, this week's activity is reported\n" +
+		"What team reports show of other users is configured via\n" +
+		"team_visibility_admins and team_visibility_members\n\n" +
+		"Examples\n" +
+		"    tilo report                  # Time spent on each task this week\n" +
+		"    tilo report :team :last-week # Time spent by each user last week"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to create report")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	periods, err := periods(req.Cmd)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if !req.Cmd.Flags[paramTeam] {
+		totals, err := taskTotals(srv.Backend, periods)
+		if err != nil {
+			resp.SetError(err)
+		} else {
+			addTotals(&resp, "", totals)
+		}
+		return srv.Answer(req, resp)
+	}
+
+	visibility, err := srv.TeamVisibility(req)
+	if err == nil && visibility == config.VISIBILITY_NONE {
+		err = errors.New("Team reports are not available to you")
+	}
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	var total time.Duration
+	err = srv.ForEachUser(func(user string, b backend.Backend) error {
+		totals, err := taskTotals(b, periods)
+		if err != nil {
+			return err
+		}
+		// Everybody may see their own tasks.
+		if visibility == config.VISIBILITY_TASKS || user == req.User {
+			total += addTotals(&resp, user, totals)
+		} else {
+			total += addUserTotal(&resp, user, totals)
+		}
+		return nil
+	})
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Total", total.String())
+	return srv.Answer(req, resp)
+}
+
+// The periods to report on, this week by default.
+func periods(cmd msg.Cmd) ([]msg.Quantity, error) {
+	if len(cmd.Quantities) > 0 {
+		return cmd.Quantities, nil
+	}
+	return quantifier.FixedWeekOffset(cmd.CurrentTime(), 0).Parse("")
+}
+
+// The time spent on each task in the given periods. Periods overlapping each
+// other are counted repeatedly.
+func taskTotals(b backend.Backend, periods []msg.Quantity) (map[string]time.Duration, error) {
+	totals := make(map[string]time.Duration)
+	for _, period := range periods {
+		start, end, err := quantifier.Range(period)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to construct query")
+		}
+		sum, err := b.GetAllTasksBetween(start, end, msg.Source{})
+		if err != nil {
+			return nil, errors.Wrap(err, "Error in database query")
+		}
+		for _, s := range sum {
+			totals[s.Task] += s.Total
+		}
+	}
+	return totals, nil
+}
+
+// Add one line per task, longest first, preceded by the user's total for
+// team reports and followed by the overall total otherwise. Returns the total.
+func addTotals(resp *msg.Response, user string, totals map[string]time.Duration) time.Duration {
+	var tasks []string
+	var total time.Duration
+	for task, d := range totals {
+		tasks = append(tasks, task)
+		total += d
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if totals[tasks[i]] != totals[tasks[j]] {
+			return totals[tasks[i]] > totals[tasks[j]]
+		}
+		return tasks[i] < tasks[j]
+	})
+	if user != "" {
+		resp.AddKeyValue(user, total.String())
+	}
+	for _, task := range tasks {
+		key := task
+		if user != "" {
+			key = user + "/" + task
+		}
+		resp.AddKeyValue(key, totals[task].String())
+	}
+	if user == "" {
+		resp.AddKeyValue("Total", total.String())
+	}
+	return total
+}
+
+// Add a single line with the user's total. Returns the total.
+func addUserTotal(resp *msg.Response, user string, totals map[string]time.Duration) time.Duration {
+	var total time.Duration
+	for _, d := range totals {
+		total += d
+	}
+	resp.AddKeyValue(user, total.String())
+	return total
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -42,13 +42,13 @@ func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	if srv.CurrentTask.IsRunning() {
 		resp.SetError(errors.New("a task is already active"))
 	} else {
-		if summary, err := srv.Backend.RecentTasks(1); err != nil {
+		if recent, err := srv.Backend.RecentEntries(nil, 1); err != nil {
 			resp.SetError(errors.Wrap(err, "failed to determine latest task"))
-		} else if len(summary) == 0 {
+		} else if len(recent) == 0 {
 			resp.SetError(errors.New("no recent activity to continue"))
 		} else {
-			tName := summary[0].Task
-			srv.SetActiveTask(tName)
+			tName := recent[0].Name
+			srv.SetActiveTask(tName, req.Cmd.Source)
 			resp.AddCurrentTask(srv.CurrentTask)
 		}
 	}
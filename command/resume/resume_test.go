@@ -0,0 +1,36 @@
+package resume_test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/fgahr/tilo/command/resume"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestResumeWithoutActivity(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	if _, err := srv.Run("resume"); err == nil {
+		t.Error("expected an error without prior activity")
+	}
+}
+
+func TestResumeLastTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.MustRun("start", "foo")
+	srv.MustRun("stop")
+	out := srv.MustRun("resume")
+	if !strings.Contains(out, "foo ") {
+		t.Errorf("expected foo to be resumed, got:\n%s", out)
+	}
+
+	if _, err := srv.Run("resume"); err == nil {
+		t.Error("expected an error while a task is active")
+	}
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+// Takes all arguments as the search term.
+type termHandler struct{}
+
+func (h termHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, errors.New("Require a search term but none is given")
+	}
+	cmd.Body = [][]string{args}
+	return nil, nil
+}
+
+func (h termHandler) TakesParameters() bool {
+	return true
+}
+
+func (h termHandler) DescribeParameters() []argparse.ParamDescription {
+	return []argparse.ParamDescription{
+		argparse.ParamDescription{
+			ParamName:        "",
+			ParamValues:      "<term>",
+			ParamExplanation: "The text to search for",
+		},
+	}
+}
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "search"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(termHandler{})
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return argparse.Description{
+		Cmd:   op.Command(),
+		First: "<term>",
+		What:  "Search task names and notes",
+	}
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "List all entries whose task name or notes contain the search term"
+	footer := "Several words are searched for as a single phrase"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Search failed")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	if len(req.Cmd.Body) == 0 {
+		resp.SetError(errors.New("No search term given"))
+	} else if found, err := srv.Backend.Search(strings.Join(req.Cmd.Body[0], " ")); err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+	} else if len(found) == 0 {
+		resp.AddMessage("Nothing found")
+	} else {
+		resp.AddEntries(found)
+	}
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,180 @@
+package setup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/srvcmd"
+	"github.com/fgahr/tilo/completion"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/format"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/transport"
+	"github.com/pkg/errors"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "init"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Set up the configuration interactively")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Create the configuration file, asking for the most important settings"
+	footer := "Optionally installs bash completion and a systemd user unit running the server\n" +
+		"Empty answers choose the default shown in brackets"
+	return header, footer
+}
+
+// The choices made by the user.
+type settings struct {
+	backend  string
+	protocol string
+	socket   string
+	output   string
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	file := cl.ConfigFile()
+	if _, err := os.Stat(file); err == nil {
+		if !cl.AskYesNo(fmt.Sprintf("%s exists. Replace it?", file)) {
+			return errors.New("Aborted")
+		}
+	}
+	s := settings{}
+	s.backend = choose(cl, "Backend", config.BackendNames(), "sqlite3")
+	s.protocol = choose(cl, "Protocol", []string{transport.Unix, transport.TCP, transport.TLS}, transport.Unix)
+	if s.protocol != transport.Unix {
+		s.socket = cl.Ask("Server address, e.g. localhost:7070:")
+		if s.socket == "" {
+			return errors.New("An address is required for protocol " + s.protocol)
+		}
+	}
+	s.output = choose(cl, "Output format", format.Names(), format.Text)
+	if err := writeConfig(file, s); err != nil {
+		return err
+	}
+	cl.PrintMessage("Configuration written to " + file)
+
+	if cl.AskYesNo("Install bash completion?") {
+		if err := installCompletion(cl); err != nil {
+			return err
+		}
+	}
+	if cl.AskYesNo("Install a systemd user unit running the server?") {
+		if err := srvcmd.InstallUnit(cl, false); err != nil {
+			return err
+		}
+		cl.PrintMessage(srvcmd.EnableHint(false))
+	}
+	return nil
+}
+
+// Ask the user to choose one of the options until a valid one is given.
+func choose(cl *client.Client, what string, options []string, def string) string {
+	for {
+		answer := cl.Ask(fmt.Sprintf("%s (%s) [%s]:", what, strings.Join(options, ", "), def))
+		if answer == "" {
+			return def
+		}
+		for _, o := range options {
+			if answer == o {
+				return answer
+			}
+		}
+		cl.PrintMessage("Please choose one of: " + strings.Join(options, ", "))
+	}
+}
+
+// Write the configuration file, explaining each setting.
+func writeConfig(file string, s settings) error {
+	var b strings.Builder
+	b.WriteString("# Configuration for tilo, created by `tilo init`.\n" +
+		"# Settings are given as `key = value`, anything following # is ignored.\n" +
+		"# Environment variables like __TILO_BACKEND and command line arguments\n" +
+		"# like --backend take precedence.\n\n")
+
+	b.WriteString("# Where data is kept: " + strings.Join(config.BackendNames(), ", ") + ".\n")
+	fmt.Fprintf(&b, "backend = %s\n", s.backend)
+	for _, item := range config.BackendItems(s.backend) {
+		if item.Value == "" {
+			fmt.Fprintf(&b, "# %s =\n", item.InFile)
+		} else {
+			fmt.Fprintf(&b, "# %s = %s\n", item.InFile, item.Value)
+		}
+	}
+
+	b.WriteString("\n# How client and server communicate: unix for a local socket, tcp or tls.\n")
+	fmt.Fprintf(&b, "protocol = %s\n", s.protocol)
+	if s.socket != "" {
+		fmt.Fprintf(&b, "socket = %s\n", s.socket)
+	}
+	if s.protocol == transport.TLS {
+		b.WriteString("# Certificates, see the README on remote servers.\n" +
+			"# tls_cert =\n# tls_key =\n# tls_ca =\n")
+	}
+
+	b.WriteString("\n# How responses are shown: " + strings.Join(format.Names(), ", ") +
+		", or exec:<command>.\n")
+	fmt.Fprintf(&b, "output = %s\n", s.output)
+
+	b.WriteString("\n# Whether to start a server when required: always, ask or never.\n" +
+		"# spawn = always\n" +
+		"# Duration after which an idle server shuts down, e.g. 4h.\n" +
+		"# idle_timeout = 0\n")
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return errors.Wrap(err, "Unable to create the configuration directory")
+	}
+	return errors.Wrap(ioutil.WriteFile(file, []byte(b.String()), 0600), "Unable to write the configuration")
+}
+
+// Install the bash completion script where bash-completion picks it up.
+func installCompletion(cl *client.Client) error {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return errors.Wrap(err, "Unable to install completion")
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	dir = filepath.Join(dir, "bash-completion", "completions")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "Unable to install completion")
+	}
+	file := filepath.Join(dir, "tilo")
+	if err := ioutil.WriteFile(file, []byte(completion.Bash), 0644); err != nil {
+		return errors.Wrap(err, "Unable to install completion")
+	}
+	cl.PrintMessage("Completion installed to " + file)
+	return nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
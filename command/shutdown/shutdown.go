@@ -40,13 +40,19 @@ func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
 }
 
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
-	defer srv.InitiateShutdown()
 	defer req.Close()
 	resp := msg.Response{}
+	if !srv.IsAdmin(req) {
+		resp.SetError(errors.New("Only admins may shut down a shared server"))
+		return srv.Answer(req, resp)
+	}
+	defer srv.InitiateShutdown()
 	task, stopped := srv.StopCurrentTask()
 	if stopped {
-		if err := srv.SaveTask(task); err != nil {
+		if notice, err := srv.SaveSession(task); err != nil {
 			resp.SetError(err)
+		} else if notice != "" {
+			resp.AddMessage(notice)
 		}
 		resp.AddStoppedTask(task)
 	}
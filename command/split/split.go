@@ -0,0 +1,124 @@
+package split
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramAt     = "at"
+	paramDryRun = "dry-run"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "split"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramAt, "HH:MM", "The time of day at which to split; split_at or midnight by default"),
+		argparse.Flag(paramDryRun, "Only list the entries resulting from splitting"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Split entries spanning the start of a day")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Split recorded entries at the start of each day they span\n" +
+		"Notes are kept with the first part"
+	footer := "With split_at set, entries are split when saved; this command splits those recorded before\n" +
+		"The split entries are listed for confirmation first, see the README\n" +
+		"Splits are synchronized to other devices, see the README\n\n" +
+		"Examples\n" +
+		"    tilo split :dry-run   # List what would be split\n" +
+		"    tilo split :at=04:00  # Split at a virtual midnight"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if at, ok := cmd.Opts[paramAt]; ok {
+		if _, err := server.ParseDayStart(at); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags[paramDryRun] {
+		cmd.DryRun = true
+		cl.SendReceivePrint(cmd)
+	} else {
+		cl.SendConfirmed(cmd, "Split these entries?")
+	}
+	return errors.Wrap(cl.Error(), "Failed to split entries")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	if srv.AppendOnly() {
+		resp.SetError(errors.Errorf("The %s backend only appends entries, they cannot be split", srv.Backend.Name()))
+		return srv.Answer(req, resp)
+	}
+	d, ok := srv.SplitAt()
+	if at, given := req.Cmd.Opts[paramAt]; given {
+		var err error
+		if d, err = server.ParseDayStart(at); err != nil {
+			resp.SetError(err)
+			return srv.Answer(req, resp)
+		}
+	} else if !ok {
+		d, _ = server.ParseDayStart("00:00")
+	}
+	var spanning [][]msg.Task
+	err := srv.Backend.ForEachTaskBetween(nil, time.Unix(0, 0), time.Now().AddDate(1, 0, 0), func(task msg.Task) error {
+		if parts := d.Split(task); len(parts) > 1 {
+			spanning = append(spanning, append([]msg.Task{task}, parts...))
+		}
+		return nil
+	})
+	if err != nil {
+		resp.SetError(errors.Wrap(err, "Error in database query"))
+		return srv.Answer(req, resp)
+	}
+	if len(spanning) == 0 {
+		resp.AddMessage("Nothing to split")
+		return srv.Answer(req, resp)
+	}
+	var parts []msg.Task
+	var dups []backend.Duplicate
+	for _, entry := range spanning {
+		parts = append(parts, entry[1:]...)
+		dups = append(dups, backend.Duplicate{Entry: entry[0], Of: entry[1]})
+	}
+	if req.Cmd.DryRun {
+		resp.AddEntries(parts)
+		resp.AddKeyValue("Entries to split", fmt.Sprint(len(spanning)))
+		resp.AddKeyValue("Resulting entries", fmt.Sprint(len(parts)))
+		return srv.Answer(req, resp)
+	}
+	// The first part keeps the notes of the entry.
+	if err := srv.ReplaceEntries(parts, dups); err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Split entries", fmt.Sprint(len(spanning)))
+	resp.AddKeyValue("Resulting entries", fmt.Sprint(len(parts)))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,114 @@
+package split_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/split"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/sync"
+	"github.com/fgahr/tilo/msg"
+	_ "github.com/fgahr/tilo/server/backend/csvfile"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestSplitRecordedEntries(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(4 * time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	out := strings.Join(strings.Fields(srv.MustRun("split")), " ")
+	if !strings.Contains(out, "Split entries 1") || !strings.Contains(out, "Resulting entries 2") {
+		t.Errorf("expected the entry to be split in two, got:\n%s", out)
+	}
+	out = srv.MustRun("split")
+	if !strings.Contains(out, "Nothing to split") {
+		t.Errorf("expected nothing left to split, got:\n%s", out)
+	}
+}
+
+func TestSplitRecordsChanges(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(4 * time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	srv.MustRun("split", ":at=00:00")
+	out := srv.MustRun("sync", ":export")
+	midnight := time.Date(2020, 3, 2, 0, 0, 0, 0, time.Local)
+	var parts, removed []msg.Task
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var change msg.Change
+		if err := json.Unmarshal([]byte(line), &change); err != nil {
+			t.Fatalf("invalid change %q: %v", line, err)
+		}
+		switch {
+		case change.Kind == msg.ChangeRemoval:
+			removed = append(removed, change.Task)
+		case change.Task.Started.Equal(midnight) || change.Task.Ended.Equal(midnight):
+			parts = append(parts, change.Task)
+		}
+	}
+	if len(parts) != 2 {
+		t.Errorf("expected both parts in the change log, got:\n%s", out)
+	}
+	if len(removed) != 1 || !removed[0].Ended.Equal(started.Add(4*time.Hour)) || !removed[0].Started.Equal(started) {
+		t.Errorf("expected the removal of the split entry in the change log, got:\n%s", out)
+	}
+}
+
+func TestSplitRefusedWhenAppendOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tilo.csv")
+	srv := tilotest.StartServer(t, "--backend=csv", "--csv-file="+file)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(4 * time.Hour))
+	srv.MustRun("stop")
+
+	srv.Conf.AssumeYes.Value = "true"
+	_, err := srv.Run("split")
+	if err == nil || !strings.Contains(err.Error(), "only appends entries") {
+		t.Errorf("expected splitting to be refused, got: %v", err)
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != 2 {
+		t.Errorf("expected the entry to be kept as it is, got:\n%s", content)
+	}
+}
+
+func TestSplitWhenSaving(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.Conf.SplitAt.Value = "04:00"
+	started := time.Date(2020, 3, 1, 22, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(8 * time.Hour))
+	out := srv.MustRun("stop")
+	if !strings.Contains(out, "Split session into 2 entries at 04:00") {
+		t.Errorf("expected the session to be split, got:\n%s", out)
+	}
+}
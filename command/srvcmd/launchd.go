@@ -0,0 +1,81 @@
+package srvcmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fgahr/tilo/client"
+	"github.com/pkg/errors"
+)
+
+// The label of the launchd agent on macOS.
+const agentLabel = "com.github.fgahr.tilo"
+
+// The property list describing the launchd agent.
+func launchAgentFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", agentLabel+".plist"), nil
+}
+
+// Install a launchd agent running the server, the counterpart of the systemd
+// service. It is restarted unless it exits successfully, e.g. when idle.
+func installLaunchAgent(cl *client.Client, withSocket bool) error {
+	if withSocket {
+		return errors.New("Socket activation is not available with launchd")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "Unable to determine the tilo binary")
+	}
+	file, err := launchAgentFile()
+	if err != nil {
+		return errors.Wrap(err, "Unable to install the agent")
+	}
+	logFile := filepath.Join(filepath.Dir(filepath.Dir(file)), "Logs", "tilo.log")
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + agentLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>` + escape(exe) + `</string>
+		<string>` + escape("--conf-file="+cl.ConfigFile()) + `</string>
+		<string>server</string>
+		<string>run</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardErrorPath</key>
+	<string>` + escape(logFile) + `</string>
+</dict>
+</plist>
+`
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.Wrap(err, "Unable to install the agent")
+	}
+	if err := ioutil.WriteFile(file, []byte(plist), 0644); err != nil {
+		return errors.Wrap(err, "Unable to install the agent")
+	}
+	cl.PrintMessage("Agent installed to " + file)
+	return nil
+}
+
+// Escape special characters for use in XML.
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -1,6 +1,9 @@
 package srvcmd
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/client"
 	"github.com/fgahr/tilo/command"
@@ -10,16 +13,43 @@ import (
 )
 
 const (
-	RUN   = "run"
-	START = "start"
-	STOP  = "stop"
+	RUN       = "run"
+	START     = "start"
+	STOP      = "stop"
+	REPLAY    = "replay"
+	LISTENERS = "listeners"
+	UNIT      = "install-unit"
 )
 
+const (
+	paramDisconnect = "disconnect"
+	paramSocket     = "socket"
+	paramEnable     = "enable"
+	paramEphemeral  = "ephemeral"
+)
+
+// Parameters of the run command.
+var runParams = []argparse.Param{
+	argparse.Flag(paramEphemeral, "Run on sample data held in memory, e.g. for demos"),
+}
+
+// Parameters of the listeners command.
+var listenerParams = []argparse.Param{
+	argparse.Option(paramDisconnect, "<id>", "Disconnect the listener with the given ID"),
+}
+
+// Parameters of the install-unit command.
+var unitParams = []argparse.Param{
+	argparse.Flag(paramSocket, "Also install a socket unit starting the server on demand"),
+	argparse.Flag(paramEnable, "Enable and start the units via systemctl"),
+}
+
 type cmdHandler struct {
 	command string
+	file    string // The recording to replay
 }
 
-func (h *cmdHandler) HandleArgs(_ *msg.Cmd, args []string) ([]string, error) {
+func (h *cmdHandler) HandleArgs(cmd *msg.Cmd, args []string) ([]string, error) {
 	if len(args) == 0 {
 		return args, errors.New("Require a command but none was given")
 	}
@@ -28,6 +58,24 @@ func (h *cmdHandler) HandleArgs(_ *msg.Cmd, args []string) ([]string, error) {
 	} else {
 		return args, errors.New("Not a known server command: " + args[0])
 	}
+	if h.command == REPLAY {
+		if len(args) < 2 {
+			return args, errors.New("Require a recording to replay but none was given")
+		}
+		h.file = args[1]
+		return args[2:], nil
+	}
+	if h.command == LISTENERS {
+		// The server needs to know what to do.
+		cmd.Body = [][]string{{LISTENERS}}
+		return argparse.HandlerForParams(listenerParams).HandleArgs(cmd, args[1:])
+	}
+	if h.command == RUN {
+		return argparse.HandlerForParams(runParams).HandleArgs(cmd, args[1:])
+	}
+	if h.command == UNIT {
+		return argparse.HandlerForParams(unitParams).HandleArgs(cmd, args[1:])
+	}
 	return args[1:], nil
 }
 
@@ -47,8 +95,24 @@ func (h *cmdHandler) DescribeParameters() []argparse.ParamDescription {
 		},
 		argparse.ParamDescription{
 			ParamName:        "run",
+			ParamValues:      "[:ephemeral]",
 			ParamExplanation: "Start a server in the foreground, printing log messages",
 		},
+		argparse.ParamDescription{
+			ParamName:        "replay",
+			ParamValues:      "<file>",
+			ParamExplanation: "Execute the commands recorded via record_file against an empty backend",
+		},
+		argparse.ParamDescription{
+			ParamName:        "listeners",
+			ParamValues:      "[:disconnect=<id>]",
+			ParamExplanation: "List the connected listeners or disconnect one of them",
+		},
+		argparse.ParamDescription{
+			ParamName:        "install-unit",
+			ParamValues:      "[:socket] [:enable]",
+			ParamExplanation: "Install a systemd user unit, or a launchd agent on macOS, running the server",
+		},
 	}
 }
 
@@ -60,6 +124,12 @@ func isKnownCommand(str string) bool {
 		return true
 	case STOP:
 		return true
+	case REPLAY:
+		return true
+	case LISTENERS:
+		return true
+	case UNIT:
+		return true
 	default:
 		return false
 	}
@@ -80,14 +150,21 @@ func (op operation) Parser() *argparse.Parser {
 func (op operation) DescribeShort() argparse.Description {
 	return argparse.Description{
 		Cmd:   op.Command(),
-		First: "[start|stop|run]",
+		First: "[start|stop|run|replay|listeners|install-unit]",
 		What:  "Start or stop a server process or run in the foreground",
 	}
 }
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
-	header := "Start or stop a server process"
-	footer := "Several other commands may spawn a server process if it is not yet running"
+	header := "Start or stop a server process, or inspect a running one"
+	footer := "Several other commands may spawn a server process if it is not yet running\n" +
+		"Units run the server with the current configuration file; for socket units,\n" +
+		"the socket has to be a path or an IP address with port, e.g. 127.0.0.1:7070\n\n" +
+		"Examples\n" +
+		"    tilo server listeners                # List listeners, e.g. status bars, with their IDs\n" +
+		"    tilo server listeners :disconnect=3  # Disconnect a stale one\n" +
+		"    tilo server install-unit :enable     # Run the server as a systemd user service\n" +
+		"    tilo server run :ephemeral           # Run on sample data, leaving recorded data alone"
 	return header, footer
 }
 
@@ -98,11 +175,39 @@ func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
 	case STOP:
 		op.requestShutdown(cl, cmd)
 	case RUN:
-		cl.RunServer()
+		if cmd.Flags[paramEphemeral] {
+			cl.RunEphemeralServer()
+		} else {
+			cl.RunServer()
+		}
+	case REPLAY:
+		cl.ReplayServerSession(op.ch.file)
+	case LISTENERS:
+		if id, ok := cmd.Opts[paramDisconnect]; ok {
+			if _, err := strconv.Atoi(id); err != nil {
+				return errors.Errorf("Invalid listener ID: %s", id)
+			}
+		}
+		cl.SendReceivePrint(cmd)
+	case UNIT:
+		return installUnit(cl, cmd)
 	}
 	return cl.Error()
 }
 
+// Install the systemd units and enable them if requested.
+func installUnit(cl *client.Client, cmd msg.Cmd) error {
+	withSocket := cmd.Flags[paramSocket]
+	if err := InstallUnit(cl, withSocket); err != nil {
+		return err
+	}
+	if !cmd.Flags[paramEnable] {
+		cl.PrintMessage(EnableHint(withSocket))
+		return nil
+	}
+	return EnableUnit(withSocket)
+}
+
 func (op operation) requestShutdown(cl *client.Client, cmd msg.Cmd) error {
 	// FIXME: This is a bit of a hack for now. With more server commands added
 	// (such as `reload`, `restart`, etc.) it will make sense to enable
@@ -119,7 +224,45 @@ func (op operation) requestShutdown(cl *client.Client, cmd msg.Cmd) error {
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
-	resp.SetError(errors.New("Not a valid server operation:" + op.Command()))
+	if len(req.Cmd.Body) == 0 || len(req.Cmd.Body[0]) == 0 || req.Cmd.Body[0][0] != LISTENERS {
+		resp.SetError(errors.New("Not a valid server operation:" + op.Command()))
+		return srv.Answer(req, resp)
+	}
+	if !srv.IsAdmin(req) {
+		resp.SetError(errors.New("Only admins may inspect a shared server"))
+		return srv.Answer(req, resp)
+	}
+	if id, ok := req.Cmd.Opts[paramDisconnect]; ok {
+		n, err := strconv.Atoi(id)
+		if err == nil {
+			err = srv.DisconnectListener(n)
+		}
+		if err != nil {
+			resp.SetError(err)
+		} else {
+			resp.AddMessage("Disconnected listener " + id)
+		}
+		return srv.Answer(req, resp)
+	}
+	listeners := srv.Listeners()
+	if len(listeners) == 0 {
+		resp.AddMessage("No listeners connected")
+	}
+	for _, lst := range listeners {
+		name := lst.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		if lst.User != "" {
+			name = lst.User + ":" + name
+		}
+		delivered := "never"
+		if !lst.Delivered.IsZero() {
+			delivered = lst.Delivered.Format("2006-01-02 15:04:05")
+		}
+		resp.AddKeyValue(fmt.Sprintf("%d %s", lst.ID, name),
+			"connected "+lst.Connected.Format("2006-01-02 15:04:05")+", last delivery "+delivered)
+	}
 	return srv.Answer(req, resp)
 }
 
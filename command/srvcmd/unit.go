@@ -0,0 +1,126 @@
+package srvcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/transport"
+	"github.com/pkg/errors"
+)
+
+// The name of the systemd units.
+const unitName = "tilo"
+
+// InstallUnit writes a systemd user service running the server with the
+// client's configuration file and, if requested, a socket unit starting the
+// server on the first connection. On macOS, a launchd agent is written instead.
+func InstallUnit(cl *client.Client, withSocket bool) error {
+	if runtime.GOOS == "darwin" {
+		return installLaunchAgent(cl, withSocket)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "Unable to determine the tilo binary")
+	}
+	dir, err := unitDir()
+	if err != nil {
+		return errors.Wrap(err, "Unable to install the unit")
+	}
+	service := "[Unit]\n" +
+		"Description=tilo time logging server\n\n" +
+		"[Service]\n" +
+		fmt.Sprintf("ExecStart=%s --conf-file=%s server run\n", exe, cl.ConfigFile()) +
+		"Restart=on-failure\n" +
+		// The server notifies the watchdog while the backend is healthy.
+		"WatchdogSec=2min\n\n" +
+		"[Install]\n" +
+		"WantedBy=default.target\n"
+	if err := writeUnit(cl, dir, unitName+".service", service); err != nil {
+		return err
+	}
+	if !withSocket {
+		return nil
+	}
+	protocol, address := cl.ServerAddress()
+	if protocol != transport.Unix && protocol != transport.TCP && protocol != transport.TLS {
+		return errors.Errorf("Socket activation is not available for protocol %s", protocol)
+	}
+	socket := "[Unit]\n" +
+		"Description=tilo time logging server socket\n\n" +
+		"[Socket]\n" +
+		"ListenStream=" + address + "\n" +
+		"SocketMode=0600\n" +
+		"DirectoryMode=0700\n\n" +
+		"[Install]\n" +
+		"WantedBy=sockets.target\n"
+	return writeUnit(cl, dir, unitName+".socket", socket)
+}
+
+// The directory holding the user's systemd units.
+func unitDir() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	dir = filepath.Join(dir, "systemd", "user")
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+func writeUnit(cl *client.Client, dir string, name string, content string) error {
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		return errors.Wrap(err, "Unable to install the unit")
+	}
+	cl.PrintMessage("Unit installed to " + file)
+	return nil
+}
+
+// The commands enabling and starting the installed units.
+func enableCommands(withSocket bool) [][]string {
+	if runtime.GOOS == "darwin" {
+		// The error is reported when installing the agent.
+		file, _ := launchAgentFile()
+		return [][]string{{"launchctl", "load", "-w", file}}
+	}
+	unit := unitName + ".service"
+	if withSocket {
+		// The socket starts the service when required.
+		unit = unitName + ".socket"
+	}
+	return [][]string{
+		{"systemctl", "--user", "daemon-reload"},
+		{"systemctl", "--user", "enable", "--now", unit},
+	}
+}
+
+// EnableUnit enables and starts the installed units via systemctl.
+func EnableUnit(withSocket bool) error {
+	for _, args := range enableCommands(withSocket) {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(err, "Failed to run %s", strings.Join(args, " "))
+		}
+	}
+	return nil
+}
+
+// EnableHint explains how to enable the installed units.
+func EnableHint(withSocket bool) string {
+	var cmds []string
+	for _, args := range enableCommands(withSocket) {
+		cmds = append(cmds, strings.Join(args, " "))
+	}
+	return "Enable it with: " + strings.Join(cmds, " && ")
+}
@@ -1,6 +1,8 @@
 package start
 
 import (
+	"fmt"
+
 	"github.com/fgahr/tilo/argparse"
 	"github.com/fgahr/tilo/client"
 	"github.com/fgahr/tilo/command"
@@ -9,6 +11,8 @@ import (
 	"github.com/pkg/errors"
 )
 
+const paramRestart = "restart"
+
 type operation struct {
 	// No state required
 }
@@ -18,7 +22,9 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithSingleTask().WithoutParams()
+	return argparse.CommandParser(op.Command()).WithSingleTask().WithArgHandler(argparse.HandlerForParams([]argparse.Param{
+		argparse.Flag(paramRestart, "Save and restart the task if it is already active"),
+	}))
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -27,9 +33,11 @@ func (op operation) DescribeShort() argparse.Description {
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
 	header := "Set the currently active task, i.e. start logging time. If a task is active, save it first"
-	footer := "To avoid saving the previous task, use the `abort` command first\n\n" +
-		"This command can also be used from time to time to avoid losing activity accidentally\n" +
-		"In this case the `current` command will only show elapsed time since the last 'save'"
+	footer := "To avoid saving the previous task, use the `abort` command first\n" +
+		"Starting the active task again keeps it running unless :restart is given\n\n" +
+		"With :restart, this command can also be used from time to time to avoid losing activity accidentally\n" +
+		"In this case the `current` command will only show elapsed time since the last 'save'\n" +
+		"With merge_gap set, such save points are merged into a single entry, see also `compact`"
 	return header, footer
 }
 
@@ -42,14 +50,22 @@ func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
 	taskName := req.Cmd.TaskNames[0]
+	if current := srv.CurrentTask; current.IsRunning() && current.Name == taskName && !req.Cmd.Flags[paramRestart] {
+		resp.AddMessage(fmt.Sprintf("%s already active since %s, use :restart to save and restart it",
+			taskName, current.Started.Local().Format("2006-01-02 15:04:05")))
+		resp.AddCurrentTask(current)
+		return srv.Answer(req, resp)
+	}
 	task, stopped := srv.StopCurrentTask()
 	if stopped {
-		if err := srv.SaveTask(task); err != nil {
+		if notice, err := srv.SaveSession(task); err != nil {
 			resp.SetError(err)
+		} else if notice != "" {
+			resp.AddMessage(notice)
 		}
 		resp.AddStoppedTask(task)
 	}
-	srv.SetActiveTask(taskName)
+	srv.SetActiveTask(taskName, req.Cmd.Source)
 	resp.AddCurrentTask(srv.CurrentTask)
 	return srv.Answer(req, resp)
 }
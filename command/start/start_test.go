@@ -0,0 +1,72 @@
+package start_test
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/fgahr/tilo/command/current"
+	_ "github.com/fgahr/tilo/command/start"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestStartSetsCurrentTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.MustRun("start", "foo")
+	out := srv.MustRun("current")
+	if !strings.HasPrefix(strings.SplitN(out, "\n", 3)[1], "foo ") {
+		t.Errorf("expected foo to be current, got:\n%s", out)
+	}
+}
+
+func TestStartStopsPreviousTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.MustRun("start", "foo")
+	out := srv.MustRun("start", "bar")
+	lines := strings.Split(out, "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[1], "foo ") || !strings.HasPrefix(lines[3], "bar ") {
+		t.Errorf("expected foo to be stopped and bar started, got:\n%s", out)
+	}
+}
+
+func TestStartRejectsInvalidName(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	if _, err := srv.Run("start", ":foo"); err == nil {
+		t.Error("expected an error for an invalid task name")
+	}
+}
+
+func TestStartKeepsActiveTaskRunning(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.MustRun("start", "foo")
+	out := srv.MustRun("start", "foo")
+	if !strings.Contains(out, "foo already active since") || strings.HasPrefix(out, "Stopped") {
+		t.Errorf("expected foo to keep running, got:\n%s", out)
+	}
+	out = srv.MustRun("start", "foo", ":restart")
+	lines := strings.Split(out, "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[1], "foo ") || !strings.HasPrefix(lines[3], "foo ") {
+		t.Errorf("expected foo to be stopped and started again, got:\n%s", out)
+	}
+}
+
+func TestStartIfIdle(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.Conf.Condition.Value = config.IF_IDLE
+	srv.MustRun("start", "foo")
+	out := srv.MustRun("start", "bar")
+	srv.Conf.Condition.Value = ""
+	if !strings.HasPrefix(out, "Skipped, foo is active") {
+		t.Errorf("expected bar not to be started, got:\n%s", out)
+	}
+}
@@ -0,0 +1,142 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramByWeekday = "by-weekday"
+	paramByHour    = "by-hour"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "stats"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Flag(paramByWeekday, "Time spent on each day of the week (default)"),
+		argparse.Flag(paramByHour, "Time spent in each hour of the day"),
+	)
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Show when work happens")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Break down the time spent on tasks by day of the week or hour of the day"
+	footer := "Without time parameters, the entire history is considered\n" +
+		"Entries spanning several hours are split at the hour boundaries\n\n" +
+		"Examples\n" +
+		"    tilo stats :all                       # Time spent on each day of the week\n" +
+		"    tilo stats foo :this-year :by-hour    # Hours of the day spent on foo this year"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	cl.SendReceivePrint(cmd)
+	return errors.Wrap(cl.Error(), "Failed to gather statistics")
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	cmd := req.Cmd
+	hours, err := weekHours(srv.Backend, cmd)
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	byHour := cmd.Flags[paramByHour]
+	if cmd.Flags[paramByWeekday] || !byHour {
+		byWeekday := hours.ByWeekday()
+		// Weeks start on Monday.
+		days := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+		durations := make([]time.Duration, len(days))
+		for i, day := range days {
+			durations[i] = byWeekday[day]
+		}
+		addBreakdown(&resp, durations, func(i int) string { return days[i].String() })
+	}
+	if byHour {
+		byHourOfDay := hours.ByHour()
+		addBreakdown(&resp, byHourOfDay[:], func(i int) string { return fmt.Sprintf("%02d:00", i) })
+	}
+	return srv.Answer(req, resp)
+}
+
+// The time spent on the requested tasks in the requested periods. Periods
+// overlapping each other are counted repeatedly.
+func weekHours(b backend.Backend, cmd msg.Cmd) (backend.WeekHours, error) {
+	var tasks []string
+	if len(cmd.TaskNames) > 0 && cmd.TaskNames[0] != query.TskAllTasks {
+		tasks = cmd.TaskNames
+	}
+	quantities := cmd.Quantities
+	if len(quantities) == 0 {
+		return b.GetWeekHours(tasks, time.Unix(0, 0), cmd.CurrentTime().Add(time.Second))
+	}
+	var result backend.WeekHours
+	for _, quant := range quantities {
+		start, end, err := quantifier.Range(quant)
+		if err != nil {
+			return result, errors.Wrap(err, "Unable to construct query")
+		}
+		hours, err := b.GetWeekHours(tasks, start, end)
+		if err != nil {
+			return result, errors.Wrap(err, "Error in database query")
+		}
+		for day := range hours {
+			for hour := range hours[day] {
+				result[day][hour] += hours[day][hour]
+			}
+		}
+	}
+	return result, nil
+}
+
+// Add one line per bucket with its duration, share and a bar for comparison.
+func addBreakdown(resp *msg.Response, durations []time.Duration, label func(int) string) {
+	var total, max time.Duration
+	for _, d := range durations {
+		total += d
+		if d > max {
+			max = d
+		}
+	}
+	for i, d := range durations {
+		share, bar := 0.0, ""
+		if total > 0 {
+			share = 100 * float64(d) / float64(total)
+			bar = strings.Repeat("#", int(20*d/max))
+		}
+		resp.AddKeyValue(label(i), fmt.Sprintf("%-12v %5.1f%%  %s", d.Truncate(time.Minute), share, bar))
+	}
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -18,7 +18,7 @@ func (op operation) Command() string {
 }
 
 func (op operation) Parser() *argparse.Parser {
-	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+	return argparse.CommandParser(op.Command()).WithOptionalTask().WithoutParams()
 }
 
 func (op operation) DescribeShort() argparse.Description {
@@ -27,7 +27,8 @@ func (op operation) DescribeShort() argparse.Description {
 
 func (op operation) HelpHeaderAndFooter() (string, string) {
 	header := "Stop the currently active task, logging the activity"
-	footer := "To stop a task without logging, use the `abort` command"
+	footer := "To stop a task without logging, use the `abort` command\n" +
+		"With a task given, fails unless it is the active one, e.g. in scripts"
 	return header, footer
 }
 
@@ -39,10 +40,16 @@ func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
 func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
 	defer req.Close()
 	resp := msg.Response{}
+	if err := srv.ExpectActiveTask(req.Cmd.TaskNames); err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
 	task, stopped := srv.StopCurrentTask()
 	if stopped {
-		if err := srv.SaveTask(task); err != nil {
+		if notice, err := srv.SaveSession(task); err != nil {
 			resp.SetError(err)
+		} else if notice != "" {
+			resp.AddMessage(notice)
 		}
 		resp.AddStoppedTask(task)
 	} else {
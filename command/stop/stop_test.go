@@ -0,0 +1,53 @@
+package stop_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestStopWithoutTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	if _, err := srv.Run("stop"); err == nil {
+		t.Error("expected an error when no task is active")
+	}
+}
+
+func TestStopReportsTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	started := time.Date(2020, 3, 1, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(time.Hour))
+	out := srv.MustRun("stop")
+	expected := "foo     2020-03-01 09:00:00 2020-03-01 10:00:00"
+	if !strings.Contains(out, expected) {
+		t.Errorf("expected %q in output:\n%s", expected, out)
+	}
+
+	if _, err := srv.Run("stop"); err == nil {
+		t.Error("expected an error when stopping twice")
+	}
+}
+
+func TestStopExpectedTask(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	srv.MustRun("start", "foo")
+	if _, err := srv.Run("stop", "bar"); err == nil {
+		t.Error("expected an error when another task is active")
+	}
+	out := srv.MustRun("stop", "foo")
+	if !strings.Contains(out, "foo ") {
+		t.Errorf("expected foo to be stopped, got:\n%s", out)
+	}
+}
@@ -0,0 +1,100 @@
+package summary
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// The CSV columns required of exported entries.
+var requiredColumns = []string{"task", "start", "end"}
+
+// Read entries exported as JSON lines or CSV, telling them apart by the first
+// character. Exports may be concatenated, e.g. those of several machines.
+func readEntries(in io.Reader) ([]msg.Task, error) {
+	r := bufio.NewReader(in)
+	for {
+		c, _, err := r.ReadRune()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if !strings.ContainsRune(" \t\r\n", c) {
+			r.UnreadRune()
+			if c == '{' {
+				return readJSON(r)
+			}
+			return readCSV(r)
+		}
+	}
+}
+
+func readJSON(in io.Reader) ([]msg.Task, error) {
+	var tasks []msg.Task
+	dec := json.NewDecoder(in)
+	for dec.More() {
+		var task msg.Task
+		if err := dec.Decode(&task); err != nil {
+			return nil, errors.Wrap(err, "Invalid JSON entry")
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func readCSV(in io.Reader) ([]msg.Task, error) {
+	r := csv.NewReader(in)
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid CSV header")
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, errors.Errorf("Missing CSV column: %s", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := index[name]; ok {
+			return record[i]
+		}
+		return ""
+	}
+
+	var tasks []msg.Task
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "Invalid CSV entry")
+		}
+		// The header of a further export
+		if strings.Join(record, ",") == strings.Join(header, ",") {
+			continue
+		}
+		task := msg.Task{Name: field(record, "task"), HasEnded: true}
+		if task.Started, err = time.Parse(time.RFC3339, field(record, "start")); err != nil {
+			return nil, errors.Wrap(err, "Invalid start of an entry")
+		}
+		if task.Ended, err = time.Parse(time.RFC3339, field(record, "end")); err != nil {
+			return nil, errors.Wrap(err, "Invalid end of an entry")
+		}
+		if notes := field(record, "notes"); notes != "" {
+			task.Notes = strings.Split(notes, "\n")
+		}
+		task.Source = msg.Source{Host: field(record, "host"), User: field(record, "user")}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,105 @@
+package summary
+
+import (
+	"os"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/fgahr/tilo/server/backend/memory"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramStdin = "stdin"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "summary"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()), query.SummaryParams()...)
+	params = append(params, argparse.Flag(paramStdin, "Summarize exported entries read from standard input"))
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Summarize recorded or exported entries")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Sum up the time spent on tasks like query, either in the recorded entries or,\n" +
+		"with :stdin, in entries written by tilo export, e.g. on several machines"
+	footer := "Exported entries are read as CSV with the columns task, start and end or as\n" +
+		"JSON lines; notes, host and user are used if present\n" +
+		"Without time parameters, all entries read from standard input are summarized\n\n" +
+		"Examples\n" +
+		"    tilo summary :all :this-week                          # Like tilo query\n" +
+		"    cat laptop.csv desktop.csv | tilo summary :all :stdin # Both machines together\n" +
+		"    tilo export foo :format=json | tilo summary foo :stdin :total-only"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if !cmd.Flags[paramStdin] {
+		if len(cmd.Quantities) == 0 {
+			return errors.New("Require a time period, e.g. :this-week")
+		}
+		cl.SendReceivePrint(cmd)
+		return errors.Wrap(cl.Error(), "Failed to query the server")
+	}
+	tasks, err := readEntries(os.Stdin)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read entries")
+	}
+	if len(cmd.Quantities) == 0 {
+		cmd.Quantities = spanned(tasks)
+	}
+	b := memory.New()
+	b.Load(memory.Data{Tasks: tasks})
+	cl.PrintResponse(query.Respond(b, cmd))
+	return errors.Wrap(cl.Error(), "Failed to summarize entries")
+}
+
+// The days from the first start to the last end of the entries, in UTC like
+// all periods. None without entries.
+func spanned(tasks []msg.Task) []msg.Quantity {
+	if len(tasks) == 0 {
+		return nil
+	}
+	first, last := tasks[0].Started, tasks[0].Ended
+	for _, task := range tasks {
+		if task.Started.Before(first) {
+			first = task.Started
+		}
+		if task.Ended.After(last) {
+			last = task.Ended
+		}
+	}
+	// The end of a period is exclusive.
+	return argparse.SingleQuantity(quantifier.TimeBetween,
+		first.UTC().Format("2006-01-02"), last.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	return srv.Answer(req, query.Respond(srv.Backend, req.Cmd))
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,85 @@
+package summary_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/summary"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+// Run the command with the input on standard input.
+func runWithInput(t *testing.T, srv *tilotest.Server, input string, args ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(path, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+	return srv.MustRun(args...)
+}
+
+func TestSummaryOfConcatenatedExports(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	laptop := "task,start,end,duration_seconds,notes,host,user\n" +
+		"foo,2020-03-02T09:00:00Z,2020-03-02T10:00:00Z,3600,,laptop,me\n" +
+		"bar,2020-03-03T09:00:00Z,2020-03-03T09:30:00Z,1800,,laptop,me\n"
+	desktop := "task,start,end,duration_seconds,notes,host,user\n" +
+		"foo,2020-03-04T09:00:00Z,2020-03-04T11:00:00Z,7200,,desktop,me\n"
+
+	out := runWithInput(t, srv, laptop+desktop, "summary", ":all", ":stdin", ":total-only")
+	if expected := "bar 30m0s\nfoo 3h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	out = runWithInput(t, srv, laptop+desktop, "summary", "foo", ":stdin", ":host=desktop", ":total-only")
+	if expected := "foo 2h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+	out = runWithInput(t, srv, laptop, "summary", ":all", ":stdin", ":day=2020-03-03", ":combine")
+	if expected := "30m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestSummaryOfJSONExport(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	input := `{"Name":"foo","Started":"2020-03-02T09:00:00Z","Ended":"2020-03-02T10:00:00Z","HasEnded":true}
+{"Name":"foo","Started":"2020-03-02T11:00:00Z","Ended":"2020-03-02T11:15:00Z","HasEnded":true}
+`
+	out := runWithInput(t, srv, input, "summary", "foo", ":stdin", ":total-only")
+	if expected := "foo 1h15m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestSummaryOfRecordedEntries(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	y, m, d := time.Now().Date()
+	base := time.Date(y, m, d, 0, 1, 0, 0, time.Local)
+	srv.At(base)
+	srv.MustRun("start", "foo")
+	srv.At(base.Add(time.Hour))
+	srv.MustRun("stop")
+
+	out := srv.MustRun("summary", "foo", ":today", ":total-only")
+	if expected := "foo 1h0m0s\n"; out != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// The other side of a synchronization.
+type remote interface {
+	// Fetch the remote change log.
+	fetch() ([]msg.Change, error)
+	// Push the local change log, which includes all remote changes.
+	push(log *bytes.Buffer) error
+}
+
+func remoteFor(spec string) (remote, error) {
+	switch {
+	case strings.HasPrefix(spec, "ssh:"):
+		return sshRemote{host: strings.TrimPrefix(spec, "ssh:")}, nil
+	case strings.HasPrefix(spec, "file:"):
+		return fileRemote{path: expandHome(strings.TrimPrefix(spec, "file:"))}, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return httpRemote{url: spec}, nil
+	default:
+		return nil, errors.Errorf("Unknown kind of remote: %s", spec)
+	}
+}
+
+// Another device running tilo, reachable via ssh.
+type sshRemote struct {
+	host string
+}
+
+func (r sshRemote) fetch() ([]msg.Change, error) {
+	out, err := exec.Command("ssh", r.host, "tilo", "sync", ":"+paramExport).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "Remote export failed")
+	}
+	return readChanges(bytes.NewReader(out))
+}
+
+func (r sshRemote) push(log *bytes.Buffer) error {
+	cmd := exec.Command("ssh", r.host, "tilo", "sync", ":"+paramImport)
+	cmd.Stdin = log
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "Remote import failed: %s", out)
+	}
+	return nil
+}
+
+// A change log file shared between devices, e.g. in a synchronized folder.
+type fileRemote struct {
+	path string
+}
+
+func (r fileRemote) fetch() ([]msg.Change, error) {
+	f, err := os.Open(r.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readChanges(f)
+}
+
+func (r fileRemote) push(log *bytes.Buffer) error {
+	tmp := r.path + ".part"
+	if err := ioutil.WriteFile(tmp, log.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// A change log file on a web server supporting PUT, e.g. via WebDAV.
+type httpRemote struct {
+	url string
+}
+
+func (r httpRemote) fetch() ([]msg.Change, error) {
+	resp, err := http.Get(r.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("Unexpected response: %s", resp.Status)
+	}
+	return readChanges(resp.Body)
+}
+
+func (r httpRemote) push(log *bytes.Buffer) error {
+	req, err := http.NewRequest(http.MethodPut, r.url, log)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("Unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// Expand a leading ~ to the home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,50 @@
+package sync_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/sync"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestExportRecordsMergedEntryAsRemoved(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+	srv.Conf.MergeGap.Value = "5m"
+
+	started := time.Date(2020, 3, 1, 9, 0, 0, 0, time.Local)
+	srv.At(started)
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(time.Hour))
+	srv.MustRun("stop")
+	srv.At(started.Add(62 * time.Minute))
+	srv.MustRun("start", "foo")
+	srv.At(started.Add(2 * time.Hour))
+	srv.MustRun("stop")
+
+	out := srv.MustRun("sync", ":export")
+	kinds := make(map[string]msg.Task)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var change msg.Change
+		if err := json.Unmarshal([]byte(line), &change); err != nil {
+			t.Fatalf("invalid change %q: %v", line, err)
+		}
+		if change.Kind == msg.ChangeEntry && change.Task.Ended.Equal(started.Add(time.Hour)) {
+			continue
+		}
+		kinds[change.Kind] = change.Task
+	}
+	merged, removed := kinds[msg.ChangeEntry], kinds[msg.ChangeRemoval]
+	if !merged.Started.Equal(started) || !merged.Ended.Equal(started.Add(2*time.Hour)) {
+		t.Errorf("expected the merged entry in the change log, got:\n%s", out)
+	}
+	if !removed.Started.Equal(started) || !removed.Ended.Equal(started.Add(time.Hour)) {
+		t.Errorf("expected the removal of the preceding entry in the change log, got:\n%s", out)
+	}
+}
@@ -0,0 +1,105 @@
+package target
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramTarget = "target"
+	// Key of the response value deciding the exit status
+	keyRemaining = "Remaining"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "target-check"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramTarget, "<duration>", "The daily target, daily_target from the configuration by default"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Check whether today's target is met")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Compare the time tracked today, including the active task, with the daily target"
+	footer := "Exits with non-zero status while the target is not met, e.g. for reminders via cron\n\n" +
+		"Examples\n" +
+		"    tilo target-check || notify-send 'Not done yet'  # Using daily_target from the configuration\n" +
+		"    tilo target-check :target=6h                      # Using a different target"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	if t, ok := cmd.Opts[paramTarget]; ok {
+		if _, err := time.ParseDuration(t); err != nil {
+			return errors.Errorf("Invalid target: %s", t)
+		}
+	}
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	cl.PrintResponse(resp)
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Failed to check the daily target")
+	}
+	for _, elem := range resp.Body {
+		if elem.Kind == msg.KindKeyValue && elem.Key == keyRemaining {
+			return errors.New("Daily target not met")
+		}
+	}
+	return nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	target, err := srv.DailyTarget()
+	if t, ok := req.Cmd.Opts[paramTarget]; ok {
+		target, err = time.ParseDuration(t)
+	}
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	} else if target <= 0 {
+		resp.SetError(errors.New("No daily target set, use daily_target or :target"))
+		return srv.Answer(req, resp)
+	}
+	_, total, err := srv.TodaysSessions(req.Cmd.CurrentTime())
+	if err != nil {
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	resp.AddKeyValue("Target", target.String())
+	resp.AddKeyValue("Tracked", total.Truncate(time.Second).String())
+	if remaining := target - total; remaining > 0 {
+		resp.AddKeyValue(keyRemaining, remaining.Truncate(time.Second).String())
+	} else {
+		resp.AddMessage("Target met")
+	}
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,98 @@
+package version
+
+import (
+	"runtime"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	keyVersion = "Version"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "version"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	return argparse.CommandParser(op.Command()).WithoutTask().WithoutParams()
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Show client and server versions")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Show the version of the client and, if one is running, of the server"
+	footer := "A server is never started by this command\n" +
+		"Client and server of different versions may not understand each other;\n" +
+		"in this case, a warning is shown"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	resp := msg.Response{}
+	resp.AddKeyValue("Client", msg.CurrentBuild().String())
+	resp.AddKeyValue("Go", runtime.Version())
+	resp.AddKeyValue("Platform", runtime.GOOS+"/"+runtime.GOARCH)
+	if !cl.ServerIsRunning() {
+		resp.AddKeyValue("Server", "not running")
+		cl.PrintResponse(resp)
+		return cl.Error()
+	}
+	srvResp, err := cl.Request(cmd)
+	if err != nil && !srvResp.Failed() {
+		return errors.Wrap(err, "Failed to query the server version")
+	}
+	// Servers predating this command reject it.
+	srvVersion := valueOf(srvResp, keyVersion)
+	if srvVersion == "" {
+		srvVersion = "unknown"
+	}
+	if srvResp.Server != nil {
+		resp.AddKeyValue("Server", srvResp.Server.String())
+	} else {
+		resp.AddKeyValue("Server", srvVersion)
+	}
+	cl.PrintResponse(resp)
+	if srvVersion != msg.Version {
+		cl.PrintMessage("Warning: client and server versions differ, consider restarting the server")
+	}
+	return cl.Error()
+}
+
+// The value of the first element with the given key, empty if there is none.
+func valueOf(resp msg.Response, key string) string {
+	for _, elem := range resp.Body {
+		if elem.Kind == msg.KindKeyValue && elem.Key == key {
+			return elem.Value
+		}
+	}
+	return ""
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.AddKeyValue(keyVersion, msg.Version)
+	resp.AddKeyValue("Go", runtime.Version())
+	return srv.Answer(req, resp)
+}
+
+// Permitted with read-only API tokens.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,305 @@
+package watch
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramRefresh = "refresh"
+	paramTarget  = "target"
+	paramOnce    = "once"
+)
+
+const (
+	// Time between queries unless given by :refresh
+	defaultRefresh = 30 * time.Second
+	// Days per week the daily target applies to
+	workdays = 5
+	// The width of the bars
+	barWidth = 20
+	// Moves the cursor home and clears the terminal
+	clearScreen = "\033[H\033[2J"
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "watch"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramRefresh, "<duration>", fmt.Sprintf("The time between queries, %v by default", defaultRefresh)),
+		argparse.Option(paramTarget, "<duration>", fmt.Sprintf("The weekly target, %d times daily_target by default", workdays)),
+		argparse.Flag(paramOnce, "Print the dashboard once instead of updating it"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Show a continuously updated dashboard")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Show the active task, today's time per task and this week's total compared\n" +
+		"with the weekly target, updated on task changes and periodically"
+	footer := "Runs until interrupted or the server shuts down\n\n" +
+		"Examples\n" +
+		"    tilo watch                            # In a terminal of its own\n" +
+		"    tilo watch :target=32h :refresh=1m"
+	return header, footer
+}
+
+// The state shown by the dashboard.
+type dashboard struct {
+	active string    // The running task, empty if idle
+	since  time.Time // When the running task was started
+	today  []msg.Summary
+	week   time.Duration
+	target time.Duration // The weekly target, zero if none
+	err    error         // Why the latest update failed, if it did
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	refresh := defaultRefresh
+	if r, ok := cmd.Opts[paramRefresh]; ok {
+		d, err := time.ParseDuration(r)
+		if err != nil || d <= 0 {
+			return errors.Errorf("Invalid refresh interval: %s", r)
+		}
+		refresh = d
+	}
+	target, err := weeklyTarget(cl, cmd)
+	if err != nil {
+		return err
+	}
+	d := dashboard{target: target}
+
+	if cmd.Flags[paramOnce] {
+		task, running, err := cl.CurrentTask()
+		if err != nil {
+			return err
+		}
+		if running {
+			d.active, d.since = task.Name, task.Started
+		}
+		if err := d.update(cl, time.Now()); err != nil {
+			return err
+		}
+		d.render(cl.Output(), time.Now())
+		return nil
+	}
+
+	// Notifications arrive on this client's connection, queries are sent via
+	// another one.
+	queries := cl.Fork()
+	cl.EstablishConnection()
+	cl.SendToServer(msg.Cmd{Op: "listen", Opts: map[string]string{"name": op.Command()}})
+	if resp := cl.ReceiveFromServer(); resp.Err() != nil {
+		return resp.Err()
+	} else if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Failed to establish listener connection")
+	}
+	notifications := make(chan server.Notification)
+	go func() {
+		defer close(notifications)
+		for {
+			var ntf server.Notification
+			if !cl.ReceiveNext(&ntf) {
+				return
+			}
+			notifications <- ntf
+		}
+	}()
+
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case ntf, ok := <-notifications:
+			if !ok {
+				return errors.Wrap(cl.Error(), "Lost the connection to the server")
+			}
+			if ntf.Event == server.EventShutdown {
+				fmt.Fprintln(cl.Output(), "The server shut down")
+				return nil
+			}
+			d.active, d.since = ntf.Task, ntf.Since
+		case <-ticker.C:
+		}
+		// Failed queries are retried with the next update, e.g. if the
+		// server shuts down in between.
+		now := time.Now()
+		d.err = d.update(queries, now)
+		fmt.Fprint(cl.Output(), clearScreen)
+		d.render(cl.Output(), now)
+	}
+}
+
+// The weekly target given or derived from the daily target; zero if none.
+func weeklyTarget(cl *client.Client, cmd msg.Cmd) (time.Duration, error) {
+	if t, ok := cmd.Opts[paramTarget]; ok {
+		target, err := time.ParseDuration(t)
+		if err != nil || target <= 0 {
+			return 0, errors.Errorf("Invalid target: %s", t)
+		}
+		return target, nil
+	}
+	if cl.DailyTarget() == "" {
+		return 0, nil
+	}
+	daily, err := time.ParseDuration(cl.DailyTarget())
+	if err != nil {
+		return 0, errors.Wrap(err, "Invalid daily target")
+	}
+	return workdays * daily, nil
+}
+
+// Query the time recorded today per task and this week in total.
+func (d *dashboard) update(cl *client.Client, now time.Time) error {
+	today, err := recorded(cl, quantifier.FixedDayOffset(now, 0))
+	if err != nil {
+		return err
+	}
+	week, err := recorded(cl, quantifier.FixedWeekOffset(now, 0))
+	if err != nil {
+		return err
+	}
+	d.today, d.week = today, 0
+	for _, sum := range week {
+		d.week += sum.Total
+	}
+	return nil
+}
+
+// The summaries of all tasks in the period.
+func recorded(cl *client.Client, period argparse.Quantifier) ([]msg.Summary, error) {
+	quantities, err := period.Parse("")
+	if err != nil {
+		return nil, err
+	}
+	cmd := msg.Cmd{Op: "query", TaskNames: []string{query.TskAllTasks}, Quantities: quantities}
+	resp, err := cl.Request(cmd)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to query the server")
+	}
+	var result []msg.Summary
+	for _, elem := range resp.Body {
+		if elem.Kind == msg.KindSummaryRow && elem.Summary.Total > 0 {
+			result = append(result, *elem.Summary)
+		}
+	}
+	return result, nil
+}
+
+// Print the dashboard, including the time on the running task up to now.
+func (d dashboard) render(w io.Writer, now time.Time) {
+	today := make(map[string]time.Duration)
+	for _, sum := range d.today {
+		today[sum.Task] += sum.Total
+	}
+	week := d.week
+	if d.active != "" {
+		today[d.active] += now.Sub(later(d.since, startOfDay(now)))
+		week += now.Sub(later(d.since, startOfWeek(now)))
+	}
+	tasks := make([]string, 0, len(today))
+	var max, total time.Duration
+	for task, spent := range today {
+		tasks = append(tasks, task)
+		total += spent
+		if spent > max {
+			max = spent
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if today[tasks[i]] != today[tasks[j]] {
+			return today[tasks[i]] > today[tasks[j]]
+		}
+		return tasks[i] < tasks[j]
+	})
+
+	fmt.Fprintln(w, "tilo", now.Format("2006-01-02 15:04"))
+	if d.active == "" {
+		fmt.Fprintln(w, "Active  none")
+	} else {
+		fmt.Fprintf(w, "Active  %s since %s (%v)\n", d.active, d.since.Format("15:04"), round(now.Sub(d.since)))
+	}
+	fmt.Fprintln(w, "Today  ", round(total))
+	width := 0
+	for _, task := range tasks {
+		if len(task) > width {
+			width = len(task)
+		}
+	}
+	for _, task := range tasks {
+		fmt.Fprintf(w, "  %-*s %s %v\n", width, task, bar(today[task], max), round(today[task]))
+	}
+	if d.target > 0 {
+		fmt.Fprintf(w, "Week    %v / %v %s %d%%\n", round(week), d.target, bar(week, d.target), int(100*week/d.target))
+	} else {
+		fmt.Fprintln(w, "Week   ", round(week))
+	}
+	if d.err != nil {
+		fmt.Fprintln(w, "Error  ", d.err)
+	}
+}
+
+// A bar filled according to the share of the whole, e.g. [#####-----].
+func bar(part time.Duration, whole time.Duration) string {
+	filled := 0
+	if part >= whole && whole > 0 {
+		filled = barWidth
+	} else if whole > 0 {
+		filled = int(barWidth * part / whole)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]"
+}
+
+// The duration in whole minutes.
+func round(d time.Duration) time.Duration {
+	return d.Truncate(time.Minute)
+}
+
+func later(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// The start of the week (Monday) containing the given time.
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
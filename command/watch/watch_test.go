@@ -0,0 +1,43 @@
+package watch_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/fgahr/tilo/command/current"
+	_ "github.com/fgahr/tilo/command/query"
+	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/watch"
+	"github.com/fgahr/tilo/tilotest"
+)
+
+func TestWatchOnce(t *testing.T) {
+	srv := tilotest.StartServer(t)
+	defer srv.Stop()
+
+	y, m, d := time.Now().Date()
+	base := time.Date(y, m, d, 0, 1, 0, 0, time.Local)
+	srv.At(base)
+	srv.MustRun("start", "foo")
+	srv.At(base.Add(time.Hour))
+	srv.MustRun("start", "bar")
+	srv.At(base.Add(90 * time.Minute))
+	srv.MustRun("stop")
+
+	out := srv.MustRun("watch", ":once", ":target=10h")
+	for _, line := range []string{
+		"Active  none",
+		"Today   1h30m0s",
+		"  foo [####################] 1h0m0s",
+		"  bar [##########----------] 30m0s",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected %q in output:\n%s", line, out)
+		}
+	}
+	if !strings.Contains(out, "/ 10h0m0s") {
+		t.Errorf("expected the weekly target in output:\n%s", out)
+	}
+}
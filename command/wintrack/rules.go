@@ -0,0 +1,70 @@
+//go:build window
+// +build window
+
+package wintrack
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/pkg/errors"
+)
+
+// A rule assigning windows to a task by their title.
+type rule struct {
+	task  string
+	title *regexp.Regexp
+}
+
+// Read rules from a file, one per line: the task name, whitespace, and a
+// regular expression matching window titles. Empty lines and lines starting
+// with # are ignored.
+func readRules(file string) ([]rule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read rules")
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.IndexAny(line, " \t")
+		if sep < 0 {
+			return nil, errors.Errorf("%s:%d: require a task and a regular expression", file, n)
+		}
+		task, expr := line[:sep], strings.TrimSpace(line[sep:])
+		if tasks, err := argparse.GetTaskNames(task); err != nil || len(tasks) != 1 || tasks[0] == argparse.AllTasks {
+			return nil, errors.Errorf("%s:%d: invalid task name: %s", file, n, task)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", file, n)
+		}
+		rules = append(rules, rule{task: task, title: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "Unable to read rules")
+	}
+	if len(rules) == 0 {
+		return nil, errors.Errorf("No rules found in %s", file)
+	}
+	return rules, nil
+}
+
+// The task of the first rule matching the title, empty if there is none.
+func match(rules []rule, title string) string {
+	for _, r := range rules {
+		if r.title.MatchString(title) {
+			return r.task
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+//go:build window
+// +build window
+
+package wintrack
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// An automatic task switch, or the reversal of one.
+type switchEntry struct {
+	Time  time.Time `json:"time"`
+	Title string    `json:"title,omitempty"` // The title of the window causing the switch
+	From  string    `json:"from"`            // The task active before, empty if none
+	To    string    `json:"to"`
+	Undo  bool      `json:"undo,omitempty"` // Whether the latest switch not yet undone was reverted
+}
+
+// Append an entry to the log of switches.
+func logSwitch(file string, entry switchEntry) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Unable to log task switch")
+	}
+	defer f.Close()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		panic(err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	return errors.Wrap(err, "Unable to log task switch")
+}
+
+// The latest switch not yet undone.
+func lastSwitch(file string) (switchEntry, bool, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return switchEntry{}, false, nil
+	} else if err != nil {
+		return switchEntry{}, false, errors.Wrap(err, "Unable to read switch log")
+	}
+	defer f.Close()
+
+	var switches []switchEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := switchEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return switchEntry{}, false, errors.Wrap(err, "Invalid entry in switch log")
+		}
+		if !entry.Undo {
+			switches = append(switches, entry)
+		} else if len(switches) > 0 {
+			switches = switches[:len(switches)-1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return switchEntry{}, false, errors.Wrap(err, "Unable to read switch log")
+	}
+	if len(switches) == 0 {
+		return switchEntry{}, false, nil
+	}
+	return switches[len(switches)-1], true, nil
+}
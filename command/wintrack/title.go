@@ -0,0 +1,93 @@
+//go:build window
+// +build window
+
+package wintrack
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The title of the focused window. Supports sway and X11, the latter with
+// the xprop utility. Other Wayland compositors provide no standard way to
+// determine the focused window.
+func activeWindowTitle() (string, error) {
+	if os.Getenv("SWAYSOCK") != "" {
+		return swayTitle()
+	} else if os.Getenv("DISPLAY") != "" {
+		return x11Title()
+	}
+	return "", errors.New("No supported window system found, requires X11 or sway")
+}
+
+// A node in sway's tree of outputs, workspaces and windows.
+type swayNode struct {
+	Name     string     `json:"name"`
+	Focused  bool       `json:"focused"`
+	Nodes    []swayNode `json:"nodes"`
+	Floating []swayNode `json:"floating_nodes"`
+}
+
+func (n swayNode) focused() (swayNode, bool) {
+	if n.Focused {
+		return n, true
+	}
+	for _, children := range [][]swayNode{n.Nodes, n.Floating} {
+		for _, child := range children {
+			if f, ok := child.focused(); ok {
+				return f, true
+			}
+		}
+	}
+	return swayNode{}, false
+}
+
+func swayTitle() (string, error) {
+	out, err := exec.Command("swaymsg", "-t", "get_tree").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to query sway")
+	}
+	root := swayNode{}
+	if err := json.Unmarshal(out, &root); err != nil {
+		return "", errors.Wrap(err, "Failed to read sway's window tree")
+	}
+	node, _ := root.focused()
+	return node.Name, nil
+}
+
+func x11Title() (string, error) {
+	// Output is e.g. "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x3a00007"
+	out, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to determine the active window")
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", errors.New("Failed to determine the active window")
+	}
+	id := fields[len(fields)-1]
+	if id == "0x0" {
+		return "", nil
+	}
+
+	// Output is e.g. `_NET_WM_NAME(UTF8_STRING) = "title"`
+	out, err = exec.Command("xprop", "-id", id, "_NET_WM_NAME", "WM_NAME").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to determine the window title")
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		kv := strings.SplitN(line, " = ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if title, err := strconv.Unquote(kv[1]); err == nil {
+			return title, nil
+		}
+	}
+	return "", nil
+}
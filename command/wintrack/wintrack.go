@@ -0,0 +1,193 @@
+//go:build window
+// +build window
+
+// Package wintrack tracks tasks based on the title of the focused window.
+// Build with `-tags window` to include it.
+package wintrack
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const (
+	paramRules    = "rules"
+	paramInterval = "interval"
+	paramAuto     = "auto"
+	paramUndo     = "undo"
+)
+
+const (
+	defaultRules    = "window-rules"
+	switchLog       = "window-switches"
+	defaultInterval = 5 * time.Second
+)
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "window-track"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := []argparse.Param{
+		argparse.Option(paramRules, "<file>", "The file containing the rules; window-rules in the configuration directory by default"),
+		argparse.Option(paramInterval, "<duration>", "Time between checks of the focused window; 5s by default"),
+		argparse.Flag(paramAuto, "Switch tasks automatically instead of suggesting them"),
+		argparse.Flag(paramUndo, "Revert the latest automatic switch"),
+	}
+	return argparse.CommandParser(op.Command()).WithoutTask().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Suggest or switch tasks based on the focused window")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Watch the title of the focused window and suggest the task of the first matching rule\n" +
+		"Each line of the rules file holds a task name and a regular expression, e.g.\n" +
+		"    tilo   (?i)tilo.*(vim|emacs)\n" +
+		"    review ^Pull Request"
+	footer := "A window has to stay focused for one interval before its task is suggested\n" +
+		"With :auto, tasks are switched and each switch is logged to window-switches in the\n" +
+		"configuration directory; :undo aborts the task switched to and restarts the previous one\n" +
+		"Requires X11 with xprop or sway\n\n" +
+		"Examples\n" +
+		"    tilo window-track :auto  # Switch tasks automatically\n" +
+		"    tilo window-track :undo  # Revert the latest switch"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	logFile := filepath.Join(cl.ConfigDir(), switchLog)
+	if cmd.Flags[paramUndo] {
+		return undo(cl, logFile)
+	}
+
+	rulesFile := filepath.Join(cl.ConfigDir(), defaultRules)
+	if f, ok := cmd.Opts[paramRules]; ok {
+		rulesFile = f
+	}
+	rules, err := readRules(rulesFile)
+	if err != nil {
+		return err
+	}
+	interval := defaultInterval
+	if i, ok := cmd.Opts[paramInterval]; ok {
+		if interval, err = time.ParseDuration(i); err != nil || interval <= 0 {
+			return errors.Errorf("Invalid interval: %s", i)
+		}
+	}
+	// Fail early rather than on every tick.
+	if _, err := activeWindowTitle(); err != nil {
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending, handled string
+	for {
+		select {
+		case <-ticker.C:
+		case <-sigChan:
+			return nil
+		}
+		title, err := activeWindowTitle()
+		if err != nil {
+			cl.PrintError(err)
+			continue
+		}
+		task := match(rules, title)
+		if task != pending {
+			// Wait for the window to stay focused.
+			pending = task
+			continue
+		}
+		if task == "" || task == handled {
+			continue
+		}
+		handled = task
+
+		if !cmd.Flags[paramAuto] {
+			fmt.Fprintf(cl.Output(), "%s Window %q suggests task %s\n", time.Now().Format("15:04:05"), title, task)
+			continue
+		}
+		if err := switchTo(cl, logFile, task, title); err != nil {
+			cl.PrintError(err)
+		}
+	}
+}
+
+// Start the task unless it is active already, logging the switch.
+func switchTo(cl *client.Client, logFile string, task string, title string) error {
+	current, running, err := cl.CurrentTask()
+	if err != nil {
+		return err
+	} else if running && current.Name == task {
+		return nil
+	}
+	if _, err := cl.Request(msg.Cmd{Op: "start", TaskNames: []string{task}}); err != nil {
+		return errors.Wrap(err, "Failed to switch to "+task)
+	}
+	entry := switchEntry{Time: time.Now(), Title: title, From: current.Name, To: task}
+	fmt.Fprintf(cl.Output(), "%s Switched to %s; use :undo to revert\n", entry.Time.Format("15:04:05"), task)
+	return logSwitch(logFile, entry)
+}
+
+// Revert the latest switch: abort the task switched to and restart the
+// previous one. The time in between is not recorded.
+func undo(cl *client.Client, logFile string) error {
+	last, ok, err := lastSwitch(logFile)
+	if err != nil {
+		return err
+	} else if !ok {
+		return errors.New("No switch to undo")
+	}
+	current, running, err := cl.CurrentTask()
+	if err != nil {
+		return err
+	} else if !running || current.Name != last.To {
+		return errors.Errorf("Cannot undo the switch to %s: no longer active", last.To)
+	}
+
+	if _, err := cl.Request(msg.Cmd{Op: "abort"}); err != nil {
+		return errors.Wrap(err, "Failed to abort "+last.To)
+	}
+	if last.From != "" {
+		if _, err := cl.Request(msg.Cmd{Op: "start", TaskNames: []string{last.From}}); err != nil {
+			return errors.Wrap(err, "Failed to restart "+last.From)
+		}
+		fmt.Fprintln(cl.Output(), "Aborted", last.To, "and restarted", last.From)
+	} else {
+		fmt.Fprintln(cl.Output(), "Aborted", last.To)
+	}
+	return logSwitch(logFile, switchEntry{Time: time.Now(), From: last.To, To: last.From, Undo: true})
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	resp := msg.Response{}
+	resp.SetError(errors.New("Not a valid server operation: " + op.Command()))
+	return srv.Answer(req, resp)
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
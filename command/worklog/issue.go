@@ -0,0 +1,44 @@
+package worklog
+
+import (
+	"regexp"
+)
+
+// Kinds of issue references.
+const (
+	kindJira   = "jira"
+	kindGitHub = "github"
+)
+
+var (
+	// E.g. PROJ-123, optionally followed by a description like PROJ-123-login.
+	jiraPattern = regexp.MustCompile(`^([A-Z][A-Z0-9_]+-[0-9]+)(?:[^0-9]|$)`)
+	// E.g. fgahr/tilo#456.
+	githubPattern = regexp.MustCompile(`^([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)#([0-9]+)$`)
+)
+
+// An issue referenced by a task name.
+type reference struct {
+	kind  string
+	key   string // The Jira issue key, e.g. PROJ-123
+	repo  string // The GitHub repository, e.g. fgahr/tilo
+	issue string // The GitHub issue number
+}
+
+// The issue referenced by the task name, if any.
+func referenceIn(task string) (reference, bool) {
+	if m := githubPattern.FindStringSubmatch(task); m != nil {
+		return reference{kind: kindGitHub, repo: m[1] + "/" + m[2], issue: m[3]}, true
+	}
+	if m := jiraPattern.FindStringSubmatch(task); m != nil {
+		return reference{kind: kindJira, key: m[1]}, true
+	}
+	return reference{}, false
+}
+
+func (r reference) String() string {
+	if r.kind == kindGitHub {
+		return r.repo + "#" + r.issue
+	}
+	return r.key
+}
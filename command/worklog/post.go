@@ -0,0 +1,129 @@
+package worklog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// The API used for GitHub issues.
+const githubAPI = "https://api.github.com"
+
+// Posts the time spent on issues.
+type poster struct {
+	jiraURL     string
+	jiraToken   string
+	githubToken string
+	client      *http.Client
+}
+
+func newPoster(jiraURL string, jiraToken string, githubToken string) poster {
+	return poster{
+		jiraURL:     strings.TrimRight(jiraURL, "/"),
+		jiraToken:   jiraToken,
+		githubToken: githubToken,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Whether the tracker of the reference is configured.
+func (p poster) canPost(ref reference) bool {
+	if ref.kind == kindGitHub {
+		return p.githubToken != ""
+	}
+	return p.jiraURL != "" && p.jiraToken != ""
+}
+
+// Post the entry to the referenced issue, giving the ID of the resulting
+// worklog or comment.
+func (p poster) post(ref reference, task msg.Task) (string, error) {
+	if ref.kind == kindGitHub {
+		return p.postGitHub(ref, task)
+	}
+	return p.postJira(ref, task)
+}
+
+// Add a worklog to a Jira issue.
+func (p poster) postJira(ref reference, task msg.Task) (string, error) {
+	body := map[string]interface{}{
+		// Jira insists on this particular format.
+		"started":          task.Started.Format("2006-01-02T15:04:05.000-0700"),
+		"timeSpentSeconds": int64(task.Ended.Sub(task.Started) / time.Second),
+	}
+	if len(task.Notes) > 0 {
+		body["comment"] = strings.Join(task.Notes, "\n")
+	}
+	url := fmt.Sprintf("%s/rest/api/2/issue/%s/worklog", p.jiraURL, ref.key)
+	req, err := p.request(url, body)
+	if err != nil {
+		return "", err
+	}
+	if user := strings.SplitN(p.jiraToken, ":", 2); len(user) == 2 {
+		// Jira Cloud: e-mail address and API token
+		req.SetBasicAuth(user[0], user[1])
+	} else {
+		// Jira Server and Data Center: personal access token
+		req.Header.Set("Authorization", "Bearer "+p.jiraToken)
+	}
+	return p.send(req)
+}
+
+// Comment on a GitHub issue.
+func (p poster) postGitHub(ref reference, task msg.Task) (string, error) {
+	text := fmt.Sprintf("Tracked %v on %s (%s-%s)",
+		task.Ended.Sub(task.Started).Truncate(time.Minute),
+		task.Started.Format("2006-01-02"), task.Started.Format("15:04"), task.Ended.Format("15:04"))
+	if len(task.Notes) > 0 {
+		text += "\n\n" + strings.Join(task.Notes, "\n")
+	}
+	url := fmt.Sprintf("%s/repos/%s/issues/%s/comments", githubAPI, ref.repo, ref.issue)
+	req, err := p.request(url, map[string]interface{}{"body": text})
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.githubToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	return p.send(req)
+}
+
+func (p poster) request(url string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// Send the request, giving the ID of the created item.
+func (p poster) send(req *http.Request) (string, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", errors.Errorf("Unexpected response: %s %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	// Both APIs give the ID, as a string for Jira and a number for GitHub.
+	var created struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", errors.Wrap(err, "Malformed response")
+	}
+	return strings.Trim(string(created.ID), `"`), nil
+}
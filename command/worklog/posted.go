@@ -0,0 +1,75 @@
+package worklog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Entries posted so far are kept in this file in the configuration directory,
+// so that they are never posted twice.
+const postedFile = "worklogs.json"
+
+// An entry posted to an issue.
+type postedEntry struct {
+	Task    string    `json:"task"`
+	Started time.Time `json:"started"`
+	Issue   string    `json:"issue"` // The reference, e.g. PROJ-123
+	ID      string    `json:"id"`    // The worklog or comment created
+}
+
+// The entries posted so far.
+type postedLog struct {
+	file    string
+	entries []postedEntry
+	known   map[string]bool
+}
+
+// Entries are identified by their recorded start, not the start of the part
+// posted for a period.
+func entryKey(task string, started time.Time) string {
+	return task + "|" + strconv.FormatInt(started.Unix(), 10)
+}
+
+func loadPosted(dir string) (*postedLog, error) {
+	log := &postedLog{file: filepath.Join(dir, postedFile), known: make(map[string]bool)}
+	data, err := ioutil.ReadFile(log.file)
+	if os.IsNotExist(err) {
+		return log, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Unable to read posted worklogs")
+	}
+	if err := json.Unmarshal(data, &log.entries); err != nil {
+		return nil, errors.Wrap(err, "Unable to read posted worklogs")
+	}
+	for _, e := range log.entries {
+		log.known[entryKey(e.Task, e.Started)] = true
+	}
+	return log, nil
+}
+
+func (log *postedLog) contains(task msg.Task) bool {
+	return log.known[entryKey(task.Name, task.Started)]
+}
+
+// Remember the posted entry, saving the log right away so that nothing is
+// posted twice should a later entry fail.
+func (log *postedLog) add(task msg.Task, ref reference, id string) error {
+	log.entries = append(log.entries, postedEntry{Task: task.Name, Started: task.Started, Issue: ref.String(), ID: id})
+	log.known[entryKey(task.Name, task.Started)] = true
+	data, err := json.MarshalIndent(log.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := log.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "Unable to record posted worklog")
+	}
+	return errors.Wrap(os.Rename(tmp, log.file), "Unable to record posted worklog")
+}
@@ -0,0 +1,179 @@
+package worklog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/argparse"
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/client"
+	"github.com/fgahr/tilo/command"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	"github.com/pkg/errors"
+)
+
+const paramDryRun = "dry-run"
+
+type operation struct {
+	// No state required
+}
+
+func (op operation) Command() string {
+	return "worklog"
+}
+
+func (op operation) Parser() *argparse.Parser {
+	params := append(query.TimeParams(msg.Clock()),
+		argparse.Flag(paramDryRun, "Only list what would be posted"),
+	)
+	return argparse.CommandParser(op.Command()).WithMultipleTasks().WithArgHandler(argparse.HandlerForParams(params))
+}
+
+func (op operation) DescribeShort() argparse.Description {
+	return op.Parser().Describe("Post time spent on issues to Jira or GitHub")
+}
+
+func (op operation) HelpHeaderAndFooter() (string, string) {
+	header := "Post entries of tasks named after issues as Jira worklogs or GitHub comments"
+	footer := "Task names like PROJ-123 or PROJ-123-login refer to Jira issues, names like\n" +
+		"fgahr/tilo#456 to GitHub issues; other tasks are left out\n" +
+		"Without time parameters, this week's entries are posted; entries posted\n" +
+		"before, also in part for another period, and entries shorter than a minute\n" +
+		"are skipped\n\n" +
+		"Examples\n" +
+		"    tilo worklog :all :dry-run        # See what would be posted\n" +
+		"    tilo worklog PROJ-123 :last-week  # Last week's entries for one issue"
+	return header, footer
+}
+
+func (op operation) ClientExec(cl *client.Client, cmd msg.Cmd) error {
+	posted, err := loadPosted(cl.ConfigDir())
+	if err != nil {
+		return err
+	}
+	// The server sends entries crossing the start of the period as they are,
+	// they are clipped to the same period here.
+	now := cmd.CurrentTime()
+	cmd.Now = &now
+	start, _, err := postRange(cmd.Quantities, now)
+	if err != nil {
+		return err
+	}
+	cl.EstablishConnection()
+	cl.SendToServer(cmd)
+	resp := cl.ReceiveFromServer()
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Unable to read entries")
+	}
+	if resp.Failed() {
+		return resp.Err()
+	}
+	var entries []msg.Task
+	task := msg.Task{}
+	for cl.ReceiveNext(&task) {
+		entries = append(entries, task)
+		task = msg.Task{}
+	}
+	if cl.Failed() {
+		return errors.Wrap(cl.Error(), "Unable to read entries")
+	}
+
+	jiraURL, jiraToken := cl.Jira()
+	p := newPoster(jiraURL, jiraToken, cl.GitHubToken())
+	dryRun := cmd.Flags[paramDryRun] || cl.DryRun()
+	result := msg.Response{}
+	unconfigured := make(map[string]bool)
+	for _, entry := range entries {
+		// Only the time within the period is posted, the entry is remembered
+		// as a whole.
+		part := entry
+		if part.Started.Before(start) {
+			part.Started = start
+		}
+		ref, ok := referenceIn(entry.Name)
+		if !ok || posted.contains(entry) || part.Ended.Sub(part.Started) < time.Minute {
+			continue
+		}
+		if !p.canPost(ref) {
+			unconfigured[ref.kind] = true
+			continue
+		}
+		what := fmt.Sprintf("%v on %s", part.Ended.Sub(part.Started).Truncate(time.Minute), part.Started.Format("2006-01-02 15:04"))
+		if dryRun {
+			result.AddKeyValue(ref.String(), what+" (not posted)")
+			continue
+		}
+		id, err := p.post(ref, part)
+		if err != nil {
+			cl.PrintResponse(result)
+			return errors.Wrapf(err, "Failed to post to %s", ref)
+		}
+		if err := posted.add(entry, ref, id); err != nil {
+			cl.PrintResponse(result)
+			return err
+		}
+		result.AddKeyValue(ref.String(), what)
+	}
+	if unconfigured[kindJira] {
+		result.AddMessage("Skipped Jira issues, see jira_url and jira_token")
+	}
+	if unconfigured[kindGitHub] {
+		result.AddMessage("Skipped GitHub issues, see github_token")
+	}
+	if len(result.Body) == 0 {
+		result.AddMessage("Nothing to post")
+	}
+	cl.PrintResponse(result)
+	return nil
+}
+
+func (op operation) ServerExec(srv *server.Server, req *server.Request) error {
+	defer req.Close()
+	tasks := req.Cmd.TaskNames
+	if len(tasks) == 1 && tasks[0] == query.TskAllTasks {
+		tasks = nil
+	}
+	start, end, err := postRange(req.Cmd.Quantities, req.Cmd.CurrentTime())
+	if err != nil {
+		resp := msg.Response{}
+		resp.SetError(err)
+		return srv.Answer(req, resp)
+	}
+	if err := srv.Answer(req, msg.Response{Status: msg.RespSuccess}); err != nil {
+		return err
+	}
+	// Entries crossing the start of the period keep their start, by which
+	// the client recognizes those posted before.
+	return srv.Backend.ForEachTaskBetween(tasks, time.Unix(0, 0), end, func(task msg.Task) error {
+		if _, ok := referenceIn(task.Name); !ok || !task.Ended.After(start) {
+			return nil
+		}
+		return srv.Stream(req, task)
+	})
+}
+
+// The period whose entries are posted, the current week unless given.
+func postRange(quantities []msg.Quantity, now time.Time) (time.Time, time.Time, error) {
+	switch len(quantities) {
+	case 0:
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		start := time.Date(now.Year(), now.Month(), now.Day()-daysSinceMonday, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(0, 0, 7), nil
+	case 1:
+		start, end, err := quantifier.Range(quantities[0])
+		return start, end, errors.Wrap(err, "Unable to construct query")
+	default:
+		return time.Time{}, time.Time{}, errors.New("Require at most one period")
+	}
+}
+
+// Permitted with read-only API tokens, as only the client posts.
+func (op operation) ReadOnly() bool {
+	return true
+}
+
+func init() {
+	command.RegisterOperation(operation{})
+}
@@ -0,0 +1,10 @@
+// Package completion provides the shell completion scripts, e.g. for
+// installation by `tilo init`.
+package completion
+
+import _ "embed"
+
+// Bash is the completion script for bash.
+//
+//go:embed tilo.bash
+var Bash string
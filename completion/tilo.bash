@@ -0,0 +1,16 @@
+# Bash completion for tilo. Source this file, e.g. from ~/.bashrc.
+_tilo() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    local IFS=$'\n'
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(tilo __complete "$cur" 2>/dev/null))
+    elif [ "$COMP_CWORD" -eq 2 ] && [[ $cur != :* ]]; then
+        # Task lists are comma-separated, only the last one is completed.
+        local done=""
+        if [[ $cur == *,* ]]; then
+            done=${cur%,*},
+        fi
+        COMPREPLY=($(tilo __complete "${COMP_WORDS[1]}" "${cur##*,}" 2>/dev/null | sed "s/^/$done/"))
+    fi
+}
+complete -F _tilo tilo
@@ -10,7 +10,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +24,11 @@ const (
 	LOG_TRACE = "trace"
 )
 
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
 func logLevel(description string) int {
 	switch description {
 	case LOG_OFF:
@@ -39,11 +46,66 @@ func logLevel(description string) int {
 	}
 }
 
+const (
+	SPAWN_ALWAYS = "always"
+	SPAWN_NEVER  = "never"
+	SPAWN_ASK    = "ask"
+)
+
+const (
+	ORPHAN_ASK   = "ask"
+	ORPHAN_ADOPT = "adopt"
+	ORPHAN_SAVE  = "save"
+)
+
+const (
+	IF_IDLE   = "idle"
+	IF_ACTIVE = "active"
+)
+
+const (
+	PRECISION_SECONDS = "s"
+	PRECISION_MILLIS  = "ms"
+)
+
+const (
+	SHORT_SESSIONS_DISCARD = "discard"
+	SHORT_SESSIONS_MERGE   = "merge"
+)
+
+const (
+	SCOPE_READ = "read"
+	SCOPE_FULL = "full"
+)
+
+const (
+	VISIBILITY_NONE   = "none"
+	VISIBILITY_TOTALS = "totals"
+	VISIBILITY_TASKS  = "tasks"
+)
+
 const (
 	ENV_VAR_PREFIX = "__TILO_"
 	CLI_VAR_PREFIX = "--"
 )
 
+// A command line flag, i.e. a parameter without value. It stands in for a
+// configuration parameter with a fixed value.
+type cliFlag struct {
+	key   string
+	value string
+}
+
+var cliFlags = map[string]cliFlag{
+	"no-spawn":  cliFlag{key: "spawn", value: SPAWN_NEVER},
+	"yes":       cliFlag{key: "yes", value: "true"},
+	"dry-run":   cliFlag{key: "dry-run", value: "true"},
+	"trace":     cliFlag{key: "trace", value: "true"},
+	"strict":    cliFlag{key: "strict", value: "true"},
+	"if-idle":   cliFlag{key: "if", value: IF_IDLE},
+	"if-active": cliFlag{key: "if", value: IF_ACTIVE},
+}
+
 type taggedString struct {
 	inUse bool
 	value string
@@ -86,14 +148,160 @@ type Opts struct {
 	ConfFile Item
 	// The protocol to use for server communication.
 	Protocol Item
-	// The name of the request socket file.
+	// The server's address: the socket file, or host:port for tcp and tls.
+	// Like the backend's options, it may contain {uid}, {hostname} and
+	// {profile}, see expandPlaceholders.
 	Socket Item
+	// Distinguishes setups sharing a config file, e.g. work and private.
+	Profile Item
 	// The server's backend
 	Backend Item
 	// Determines the amount of additional log output.
 	LogLevel Item
+	// Server logs as text on stderr or as JSON lines on stdout.
+	LogFormat Item
+	// Whether the client prints all messages exchanged with the server.
+	Trace Item
+	// The format in which to present responses.
+	Output Item
+	// Whether to start a server automatically when required.
+	Spawn Item
+	// Whether to alter recorded data without asking for confirmation.
+	AssumeYes Item
+	// Whether to only preview changes to recorded data.
+	DryRun Item
+	// Whether unused command arguments are an error rather than a warning.
+	Strict Item
+	// Run the command only if no task or some task is active, e.g. in cron
+	// jobs; empty to run it unconditionally. Only given on the command line.
+	Condition Item
+	// The time to assume as the current one, e.g. to reproduce the result of
+	// :this-week at another time; empty for the actual time. Only given on
+	// the command line and not documented.
+	Now Item
+	// What to do with a task left running by a server which stopped
+	// unexpectedly: continue it, save it, or ask when starting a server.
+	OrphanedTask Item
+	// Duration after which an idle server shuts down; 0 to keep running.
+	IdleTimeout Item
+	// Time granted to pending requests when the server shuts down.
+	ShutdownGrace Item
+	// Backend calls taking longer than this are logged; 0 to disable.
+	SlowQuery Item
+	// Further attempts after a backend call failed with a transient error,
+	// e.g. as the database was locked.
+	BackendRetries Item
+	// The granularity of task times, whole seconds or milliseconds.
+	TimestampPrecision Item
+	// Entries of the same task separated by at most this are merged; 0 to
+	// keep them apart.
+	MergeGap Item
+	// Sessions shorter than this are not saved as is; 0 to keep all.
+	MinSession Item
+	// What to do with short sessions: discard them or merge them into the
+	// preceding entry of the same task.
+	ShortSessions Item
+	// Time of day at which saved entries are split, e.g. 00:00, or 04:00 as a
+	// virtual midnight; empty to keep entries whole.
+	SplitAt Item
+	// Time without heartbeats after which the user is asked whether to keep
+	// the time on the running task; 0 to disable.
+	AwayThreshold Item
+	// Time to be tracked per day, e.g. 8h; empty if there is none.
+	DailyTarget Item
+	// Time planned per task, e.g. foo=80h,bar=20h; listeners are warned when
+	// a task approaches its budget.
+	Budgets Item
+	// Time between notifications that the active task is still running; 0 to
+	// disable.
+	RunningInterval Item
+	// Time to be spent per task and week, e.g. foo=12h,bar=4h.
+	WeeklyGoals Item
+	// Where to push database snapshots, e.g. dir:/path/to/backups.
+	BackupTarget Item
+	// Number of snapshots to retain at the target; 0 to keep all.
+	BackupKeep Item
+	// Time between automatic backups by the server; 0 to disable.
+	BackupInterval Item
+	// Identifies this device when synchronizing with others.
+	DeviceID Item
+	// Commands run by the server on task changes and shutdown.
+	HookOnStart    Item
+	HookOnStop     Item
+	HookOnAbort    Item
+	HookOnShutdown Item
+	// When to mail a weekly summary, e.g. "fri 17:00"; empty to disable.
+	ReportSchedule Item
+	// Recipients of summaries, separated by comma.
+	ReportTo Item
+	// Mail server settings, the host given as host:port.
+	SMTPHost     Item
+	SMTPUser     Item
+	SMTPPassword Item
+	SMTPFrom     Item
+	// Incoming webhook of a chat service to post task changes to.
+	WebhookURL Item
+	// Message templates, see server/webhooks.go for the available fields.
+	WebhookStart   Item
+	WebhookStop    Item
+	WebhookAbort   Item
+	WebhookSummary Item
+	// Time of day to post a daily summary, e.g. 18:00; empty to disable.
+	WebhookSummaryTime Item
+	// MQTT broker (host:port) and topic to publish the current task to.
+	MQTTBroker   Item
+	MQTTTopic    Item
+	MQTTUser     Item
+	MQTTPassword Item
+	// Address (host:port) of a read-only HTTP endpoint reporting the current
+	// state; empty to disable.
+	StateAddress Item
+	// Weeks of entries served as a calendar feed by the state endpoint; 0 to
+	// disable.
+	CalendarWeeks Item
+	// Calendar from which `plan import :caldav` takes events, with
+	// credentials given in the URL.
+	CalDAVURL Item
+	// Task for imported events not naming one, see `plan import`.
+	PlanTask Item
+	// Jira site and token (<email>:<API token>, or a personal access token)
+	// to which `worklog` posts worklogs.
+	JiraURL   Item
+	JiraToken Item
+	// Token with which `worklog` comments on GitHub issues.
+	GitHubToken Item
+	// File to which the server appends all incoming commands; empty to disable.
+	RecordFile Item
+	// Compression of server messages: gzip or none.
+	Compression Item
+	// Certificate, key and CA certificate for the tls protocol.
+	TLSCert Item
+	TLSKey  Item
+	TLSCA   Item
+	// Whether a shared server keeps separate data per user, identified by
+	// their client certificates.
+	MultiUser Item
+	// Users allowed to query all users' data, separated by comma.
+	Admins Item
+	// What admins and other users see of others in team reports: nothing,
+	// totals per user, or totals per user and task.
+	TeamVisibilityAdmins  Item
+	TeamVisibilityMembers Item
+	// Tokens accepted by the server with their scope, e.g. abc=read,def=full.
+	APITokens Item
+	// The token sent along with each command, if any.
+	APIToken Item
+	// Requests permitted per client and per API token in a period, e.g.
+	// 60/1m; 0 for no limit.
+	RateLimit      Item
+	RateLimitToken Item
+	// Default parameters per command, given as e.g. query_defaults.
+	defaults map[string]string
 }
 
+// Suffix of keys giving a command's default parameters, e.g. query_defaults.
+const DEFAULTS_SUFFIX = "_defaults"
+
 type BackendConfig interface {
 	// The name of the corresponding backend.
 	BackendName() string
@@ -110,6 +318,25 @@ func RegisterBackend(bcp BackendConfig) {
 	backendConfigs[bcp.BackendName()] = bcp
 }
 
+// BackendNames lists the registered backends in alphabetical order.
+func BackendNames() []string {
+	var names []string
+	for name := range backendConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackendItems lists the items accepted by the named backend, nil if there is
+// no such backend.
+func BackendItems(name string) []*Item {
+	if bc := backendConfigs[name]; bc != nil {
+		return bc.AcceptedItems()
+	}
+	return nil
+}
+
 func GetConfig(args []string, env []string) (*Opts, []string, error) {
 	conf := defaultConfig()
 
@@ -139,13 +366,37 @@ func GetConfig(args []string, env []string) (*Opts, []string, error) {
 		apply(bc.AcceptedItems(), fromFile, nameInFile)
 		apply(bc.AcceptedItems(), fromEnv, nameInEnv)
 		apply(bc.AcceptedItems(), fromArgs, nameInArgs)
+		expandPlaceholders(append([]*Item{&conf.Socket}, bc.AcceptedItems()...), conf.Profile.Value)
 	}
 
+	// Defaults for commands, from the environment taking precedence.
+	conf.defaults = make(map[string]string)
+	collectDefaults(conf.defaults, fromFile, DEFAULTS_SUFFIX)
+	collectDefaults(conf.defaults, fromEnv, strings.ToUpper(DEFAULTS_SUFFIX))
+
 	warnUnused(fromFile, fromEnv, fromArgs)
 
 	return conf, unused, nil
 }
 
+// Gather the keys with the given suffix as defaults for the command named by
+// the rest of the key.
+func collectDefaults(defaults map[string]string, conf rawConf, suffix string) {
+	for key, value := range conf.values {
+		if conf.inUse[key] || !strings.HasSuffix(key, suffix) {
+			continue
+		}
+		command := strings.ReplaceAll(strings.ToLower(strings.TrimSuffix(key, suffix)), "_", "-")
+		defaults[command] = value
+		conf.inUse[key] = true
+	}
+}
+
+// CommandDefaults gives the parameters configured as defaults for the command.
+func (c *Opts) CommandDefaults(command string) []string {
+	return strings.Fields(c.defaults[command])
+}
+
 func apply(items []*Item, conf rawConf, namer func(*Item) string) {
 	for _, item := range items {
 		key := namer(item)
@@ -156,6 +407,21 @@ func apply(items []*Item, conf rawConf, namer func(*Item) string) {
 	}
 }
 
+// Replace {uid}, {hostname} and {profile} in the items' values, so that a
+// single config file can be shared across machines and profiles.
+func expandPlaceholders(items []*Item, profile string) {
+	// There's nothing we can do with an error here so we ignore it.
+	hostname, _ := os.Hostname()
+	replacer := strings.NewReplacer(
+		"{uid}", strconv.Itoa(os.Getuid()),
+		"{hostname}", hostname,
+		"{profile}", profile,
+	)
+	for _, item := range items {
+		item.Value = replacer.Replace(item.Value)
+	}
+}
+
 func warnUnused(confs ...rawConf) {
 	for _, conf := range confs {
 		for key, value := range conf.values {
@@ -170,18 +436,98 @@ func warn(message ...interface{}) {
 	fmt.Fprintln(os.Stderr, message...)
 }
 
+// The directory holding the default socket's directory. On macOS, the
+// temporary directory differs between terminal sessions and launchd agents,
+// hence /tmp is used directly.
+func socketBaseDir() string {
+	if runtime.GOOS == "darwin" {
+		return "/tmp"
+	}
+	return os.TempDir()
+}
+
 // Create a set of default parameters.
 func defaultConfig() *Opts {
-	socket := filepath.Join(os.TempDir(), fmt.Sprintf("%s%d", "tilo", os.Getuid()), "server")
+	socket := filepath.Join(socketBaseDir(), fmt.Sprintf("%s%d", "tilo", os.Getuid()), "server")
 	// There's nothing we can do with an error here so we ignore it.
 	homeDir, _ := os.UserHomeDir()
 	confFile := filepath.Join(homeDir, ".config", "tilo", "config")
+	hostname, _ := os.Hostname()
 	return &Opts{
-		ConfFile: Item{InFile: "", InArgs: "conf-file", InEnv: "CONF_FILE", Value: confFile},
-		Socket:   Item{InFile: "socket", InArgs: "socket", InEnv: "SOCKET", Value: socket},
-		Protocol: Item{InFile: "protocol", InArgs: "protocol", InEnv: "PROTOCOL", Value: "unix"},
-		Backend:  Item{InFile: "backend", InArgs: "backend", InEnv: "BACKEND", Value: "sqlite3"},
-		LogLevel: Item{InFile: "log_level", InArgs: "log-level", InEnv: "LOG_LEVEL", Value: LOG_INFO},
+		ConfFile:              Item{InFile: "", InArgs: "conf-file", InEnv: "CONF_FILE", Value: confFile},
+		Socket:                Item{InFile: "socket", InArgs: "socket", InEnv: "SOCKET", Value: socket},
+		Profile:               Item{InFile: "profile", InArgs: "profile", InEnv: "PROFILE", Value: "default"},
+		Protocol:              Item{InFile: "protocol", InArgs: "protocol", InEnv: "PROTOCOL", Value: "unix"},
+		Backend:               Item{InFile: "backend", InArgs: "backend", InEnv: "BACKEND", Value: "sqlite3"},
+		LogLevel:              Item{InFile: "log_level", InArgs: "log-level", InEnv: "LOG_LEVEL", Value: LOG_INFO},
+		LogFormat:             Item{InFile: "log_format", InArgs: "log-format", InEnv: "LOG_FORMAT", Value: LOG_FORMAT_TEXT},
+		Trace:                 Item{InFile: "trace", InArgs: "trace", InEnv: "TRACE", Value: "false"},
+		Output:                Item{InFile: "output", InArgs: "output", InEnv: "OUTPUT", Value: "text"},
+		Spawn:                 Item{InFile: "spawn", InArgs: "spawn", InEnv: "SPAWN", Value: SPAWN_ALWAYS},
+		AssumeYes:             Item{InFile: "assume_yes", InArgs: "yes", InEnv: "ASSUME_YES", Value: "false"},
+		DryRun:                Item{InFile: "dry_run", InArgs: "dry-run", InEnv: "DRY_RUN", Value: "false"},
+		Strict:                Item{InFile: "strict", InArgs: "strict", InEnv: "STRICT", Value: "false"},
+		Condition:             Item{InFile: "", InArgs: "if", InEnv: "", Value: ""},
+		Now:                   Item{InFile: "", InArgs: "now", InEnv: "", Value: ""},
+		OrphanedTask:          Item{InFile: "orphaned_task", InArgs: "orphaned-task", InEnv: "ORPHANED_TASK", Value: ORPHAN_ASK},
+		IdleTimeout:           Item{InFile: "idle_timeout", InArgs: "idle-timeout", InEnv: "IDLE_TIMEOUT", Value: "0"},
+		ShutdownGrace:         Item{InFile: "shutdown_grace", InArgs: "shutdown-grace", InEnv: "SHUTDOWN_GRACE", Value: "5s"},
+		SlowQuery:             Item{InFile: "slow_query", InArgs: "slow-query", InEnv: "SLOW_QUERY", Value: "0"},
+		BackendRetries:        Item{InFile: "backend_retries", InArgs: "backend-retries", InEnv: "BACKEND_RETRIES", Value: "3"},
+		TimestampPrecision:    Item{InFile: "timestamp_precision", InArgs: "timestamp-precision", InEnv: "TIMESTAMP_PRECISION", Value: PRECISION_SECONDS},
+		MergeGap:              Item{InFile: "merge_gap", InArgs: "merge-gap", InEnv: "MERGE_GAP", Value: "0"},
+		MinSession:            Item{InFile: "min_session", InArgs: "min-session", InEnv: "MIN_SESSION", Value: "0"},
+		ShortSessions:         Item{InFile: "short_sessions", InArgs: "short-sessions", InEnv: "SHORT_SESSIONS", Value: SHORT_SESSIONS_DISCARD},
+		SplitAt:               Item{InFile: "split_at", InArgs: "split-at", InEnv: "SPLIT_AT", Value: ""},
+		AwayThreshold:         Item{InFile: "away_threshold", InArgs: "away-threshold", InEnv: "AWAY_THRESHOLD", Value: "15m"},
+		DailyTarget:           Item{InFile: "daily_target", InArgs: "daily-target", InEnv: "DAILY_TARGET", Value: ""},
+		Budgets:               Item{InFile: "budgets", InArgs: "budgets", InEnv: "BUDGETS", Value: ""},
+		RunningInterval:       Item{InFile: "running_interval", InArgs: "running-interval", InEnv: "RUNNING_INTERVAL", Value: "0"},
+		WeeklyGoals:           Item{InFile: "weekly_goals", InArgs: "weekly-goals", InEnv: "WEEKLY_GOALS", Value: ""},
+		BackupTarget:          Item{InFile: "backup_target", InArgs: "backup-target", InEnv: "BACKUP_TARGET", Value: ""},
+		BackupKeep:            Item{InFile: "backup_keep", InArgs: "backup-keep", InEnv: "BACKUP_KEEP", Value: "0"},
+		BackupInterval:        Item{InFile: "backup_interval", InArgs: "backup-interval", InEnv: "BACKUP_INTERVAL", Value: "0"},
+		DeviceID:              Item{InFile: "device_id", InArgs: "device-id", InEnv: "DEVICE_ID", Value: hostname},
+		HookOnStart:           Item{InFile: "hook_on_start", InArgs: "hook-on-start", InEnv: "HOOK_ON_START", Value: ""},
+		HookOnStop:            Item{InFile: "hook_on_stop", InArgs: "hook-on-stop", InEnv: "HOOK_ON_STOP", Value: ""},
+		HookOnAbort:           Item{InFile: "hook_on_abort", InArgs: "hook-on-abort", InEnv: "HOOK_ON_ABORT", Value: ""},
+		HookOnShutdown:        Item{InFile: "hook_on_shutdown", InArgs: "hook-on-shutdown", InEnv: "HOOK_ON_SHUTDOWN", Value: ""},
+		ReportSchedule:        Item{InFile: "report_schedule", InArgs: "report-schedule", InEnv: "REPORT_SCHEDULE", Value: ""},
+		ReportTo:              Item{InFile: "report_to", InArgs: "report-to", InEnv: "REPORT_TO", Value: ""},
+		SMTPHost:              Item{InFile: "smtp_host", InArgs: "smtp-host", InEnv: "SMTP_HOST", Value: ""},
+		SMTPUser:              Item{InFile: "smtp_user", InArgs: "smtp-user", InEnv: "SMTP_USER", Value: ""},
+		SMTPPassword:          Item{InFile: "smtp_password", InArgs: "smtp-password", InEnv: "SMTP_PASSWORD", Value: ""},
+		SMTPFrom:              Item{InFile: "smtp_from", InArgs: "smtp-from", InEnv: "SMTP_FROM", Value: ""},
+		WebhookURL:            Item{InFile: "webhook_url", InArgs: "webhook-url", InEnv: "WEBHOOK_URL", Value: ""},
+		WebhookStart:          Item{InFile: "webhook_start", InArgs: "webhook-start", InEnv: "WEBHOOK_START", Value: "Started {{.Task}}"},
+		WebhookStop:           Item{InFile: "webhook_stop", InArgs: "webhook-stop", InEnv: "WEBHOOK_STOP", Value: "Stopped {{.Task}} after {{.Duration}}"},
+		WebhookAbort:          Item{InFile: "webhook_abort", InArgs: "webhook-abort", InEnv: "WEBHOOK_ABORT", Value: "Aborted {{.Task}}"},
+		WebhookSummary:        Item{InFile: "webhook_summary", InArgs: "webhook-summary", InEnv: "WEBHOOK_SUMMARY", Value: "Summary for {{.Date}}: {{.Total}}{{range .Tasks}}\n- {{.Task}}: {{.Total}}{{end}}"},
+		WebhookSummaryTime:    Item{InFile: "webhook_summary_time", InArgs: "webhook-summary-time", InEnv: "WEBHOOK_SUMMARY_TIME", Value: ""},
+		MQTTBroker:            Item{InFile: "mqtt_broker", InArgs: "mqtt-broker", InEnv: "MQTT_BROKER", Value: ""},
+		MQTTTopic:             Item{InFile: "mqtt_topic", InArgs: "mqtt-topic", InEnv: "MQTT_TOPIC", Value: "tilo/state"},
+		MQTTUser:              Item{InFile: "mqtt_user", InArgs: "mqtt-user", InEnv: "MQTT_USER", Value: ""},
+		MQTTPassword:          Item{InFile: "mqtt_password", InArgs: "mqtt-password", InEnv: "MQTT_PASSWORD", Value: ""},
+		StateAddress:          Item{InFile: "state_address", InArgs: "state-address", InEnv: "STATE_ADDRESS", Value: ""},
+		CalendarWeeks:         Item{InFile: "calendar_weeks", InArgs: "calendar-weeks", InEnv: "CALENDAR_WEEKS", Value: "0"},
+		CalDAVURL:             Item{InFile: "caldav_url", InArgs: "caldav-url", InEnv: "CALDAV_URL", Value: ""},
+		PlanTask:              Item{InFile: "plan_task", InArgs: "plan-task", InEnv: "PLAN_TASK", Value: "meeting"},
+		JiraURL:               Item{InFile: "jira_url", InArgs: "jira-url", InEnv: "JIRA_URL", Value: ""},
+		JiraToken:             Item{InFile: "jira_token", InArgs: "jira-token", InEnv: "JIRA_TOKEN", Value: ""},
+		GitHubToken:           Item{InFile: "github_token", InArgs: "github-token", InEnv: "GITHUB_TOKEN", Value: ""},
+		RecordFile:            Item{InFile: "record_file", InArgs: "record-file", InEnv: "RECORD_FILE", Value: ""},
+		Compression:           Item{InFile: "compression", InArgs: "compression", InEnv: "COMPRESSION", Value: "gzip"},
+		TLSCert:               Item{InFile: "tls_cert", InArgs: "tls-cert", InEnv: "TLS_CERT", Value: ""},
+		TLSKey:                Item{InFile: "tls_key", InArgs: "tls-key", InEnv: "TLS_KEY", Value: ""},
+		TLSCA:                 Item{InFile: "tls_ca", InArgs: "tls-ca", InEnv: "TLS_CA", Value: ""},
+		MultiUser:             Item{InFile: "multi_user", InArgs: "multi-user", InEnv: "MULTI_USER", Value: "false"},
+		Admins:                Item{InFile: "admins", InArgs: "admins", InEnv: "ADMINS", Value: ""},
+		TeamVisibilityAdmins:  Item{InFile: "team_visibility_admins", InArgs: "team-visibility-admins", InEnv: "TEAM_VISIBILITY_ADMINS", Value: VISIBILITY_TASKS},
+		TeamVisibilityMembers: Item{InFile: "team_visibility_members", InArgs: "team-visibility-members", InEnv: "TEAM_VISIBILITY_MEMBERS", Value: VISIBILITY_NONE},
+		APITokens:             Item{InFile: "api_tokens", InArgs: "api-tokens", InEnv: "API_TOKENS", Value: ""},
+		APIToken:              Item{InFile: "api_token", InArgs: "api-token", InEnv: "API_TOKEN", Value: ""},
+		RateLimit:             Item{InFile: "rate_limit", InArgs: "rate-limit", InEnv: "RATE_LIMIT", Value: "0"},
+		RateLimitToken:        Item{InFile: "rate_limit_token", InArgs: "rate-limit-token", InEnv: "RATE_LIMIT_TOKEN", Value: "0"},
 	}
 }
 
@@ -189,9 +535,78 @@ func (c *Opts) AcceptedItems() []*Item {
 	return []*Item{
 		&c.ConfFile,
 		&c.Socket,
+		&c.Profile,
 		&c.Protocol,
 		&c.Backend,
 		&c.LogLevel,
+		&c.LogFormat,
+		&c.Trace,
+		&c.Output,
+		&c.Spawn,
+		&c.AssumeYes,
+		&c.DryRun,
+		&c.Strict,
+		&c.Condition,
+		&c.Now,
+		&c.OrphanedTask,
+		&c.IdleTimeout,
+		&c.ShutdownGrace,
+		&c.SlowQuery,
+		&c.BackendRetries,
+		&c.TimestampPrecision,
+		&c.MergeGap,
+		&c.MinSession,
+		&c.ShortSessions,
+		&c.SplitAt,
+		&c.AwayThreshold,
+		&c.DailyTarget,
+		&c.Budgets,
+		&c.RunningInterval,
+		&c.WeeklyGoals,
+		&c.BackupTarget,
+		&c.BackupKeep,
+		&c.BackupInterval,
+		&c.DeviceID,
+		&c.HookOnStart,
+		&c.HookOnStop,
+		&c.HookOnAbort,
+		&c.HookOnShutdown,
+		&c.ReportSchedule,
+		&c.ReportTo,
+		&c.SMTPHost,
+		&c.SMTPUser,
+		&c.SMTPPassword,
+		&c.SMTPFrom,
+		&c.WebhookURL,
+		&c.WebhookStart,
+		&c.WebhookStop,
+		&c.WebhookAbort,
+		&c.WebhookSummary,
+		&c.WebhookSummaryTime,
+		&c.MQTTBroker,
+		&c.MQTTTopic,
+		&c.MQTTUser,
+		&c.MQTTPassword,
+		&c.StateAddress,
+		&c.CalendarWeeks,
+		&c.CalDAVURL,
+		&c.PlanTask,
+		&c.JiraURL,
+		&c.JiraToken,
+		&c.GitHubToken,
+		&c.RecordFile,
+		&c.Compression,
+		&c.TLSCert,
+		&c.TLSKey,
+		&c.TLSCA,
+		&c.MultiUser,
+		&c.Admins,
+		&c.TeamVisibilityAdmins,
+		&c.TeamVisibilityMembers,
+		&c.APITokens,
+		&c.APIToken,
+		&c.RateLimit,
+		&c.RateLimitToken,
 	}
 }
 
@@ -227,16 +642,19 @@ func (c *Opts) logLevel() int {
 	return logLevel(c.LogLevel.Value)
 }
 
-// Emit the configuration in a format suitable as environment variables.
+// Emit the configuration in a format suitable as environment variables,
+// including the options of the selected backend.
 func (c *Opts) AsEnvKeyValue() []string {
-	v := reflect.ValueOf(*c)
-	result := make([]string, v.NumField())
-	for i := 0; i < v.NumField(); i++ {
-		fieldInfo := v.Type().Field(i)
-		tag := fieldInfo.Tag
-		name := tag.Get("env")
-		result[i] = fmt.Sprintf("%s%s=%v", ENV_VAR_PREFIX, name, v.Field(i))
-
+	items := c.AcceptedItems()
+	if bc := backendConfigs[c.Backend.Value]; bc != nil {
+		items = append(items, bc.AcceptedItems()...)
+	}
+	var result []string
+	for _, item := range items {
+		if item.InEnv == "" || item.Value == "" {
+			continue
+		}
+		result = append(result, ENV_VAR_PREFIX+item.InEnv+"="+item.Value)
 	}
 	return result
 }
@@ -289,7 +707,10 @@ func FromCommandLineParams(args []string) (rawConf, []string, error) {
 	var unused []string
 	for i := 0; i < len(args); i++ {
 		param := args[i]
-		if strings.HasPrefix(param, CLI_VAR_PREFIX) {
+		if flag, ok := cliFlags[strings.TrimPrefix(param, CLI_VAR_PREFIX)]; ok && strings.HasPrefix(param, CLI_VAR_PREFIX) {
+			result.values[flag.key] = flag.value
+			result.inUse[flag.key] = false
+		} else if strings.HasPrefix(param, CLI_VAR_PREFIX) {
 			var rawKey, value string
 			// Value in the same arg?
 			if strings.Contains(param, "=") {
@@ -340,6 +761,7 @@ func splitKeyValue(str string) (string, string) {
 		return "", ""
 	}
 
-	pair := strings.Split(str, "=")
+	// Values may contain '=' themselves, e.g. in hook commands.
+	pair := strings.SplitN(str, "=", 2)
 	return pair[0], pair[1]
 }
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -126,3 +128,97 @@ func TestParametersFromFile(t *testing.T) {
 	expect(t, "foo", backendConf.foo.Value, "fooValue")
 	expect(t, "bar", backendConf.bar.Value, "bar")
 }
+
+func TestFlagFromArgs(t *testing.T) {
+	backendName := "backendFlagFromArgs"
+	RegisterBackend(newTestBackendConfig(backendName))
+	defer unsetBackendConfig(backendName)
+
+	args := []string{"query", cliVar("no-spawn"), "foo", cliVal("backend", backendName)}
+	conf, unused, err := GetConfig(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "spawn", conf.Spawn.Value, SPAWN_NEVER)
+	if len(unused) != 2 || unused[0] != "query" || unused[1] != "foo" {
+		t.Errorf("Unexpected remaining arguments: %v", unused)
+	}
+}
+
+func TestEnvRoundTrip(t *testing.T) {
+	backend := "backendEnvRoundTrip"
+	RegisterBackend(newTestBackendConfig(backend))
+	defer unsetBackendConfig(backend)
+
+	args := []string{"--backend=" + backend, cliVal("socket", "/tmp/socket"), cliVal("foo", "baz")}
+	conf, _, err := GetConfig(args, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := conf.MergeIntoEnv(nil)
+	// Backend items are shared, reset to make sure they are restored.
+	backendConfigs[backend].AcceptedItems()[0].Value = "foo"
+	restored, _, err := GetConfig(nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "backend", restored.Backend.Value, backend)
+	expect(t, "socket", restored.Socket.Value, "/tmp/socket")
+	expect(t, "foo", backendConfigs[backend].AcceptedItems()[0].Value, "baz")
+}
+
+func TestCommandDefaults(t *testing.T) {
+	backendName := "backendCommandDefaults"
+	RegisterBackend(newTestBackendConfig(backendName))
+	defer unsetBackendConfig(backendName)
+
+	file, err := ioutil.TempFile(os.TempDir(), "tilo_config")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.WriteString("query_defaults = :this-week :with-notes\ntarget_check_defaults = :today\nrecent_defaults = :count=3"); err != nil {
+		t.Error(err)
+	}
+
+	args := []string{cliVal("conf-file", file.Name()), cliVal("backend", backendName)}
+	env := []string{envVal("RECENT_DEFAULTS", ":count=10")}
+	conf, _, err := GetConfig(args, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, "query defaults", strings.Join(conf.CommandDefaults("query"), " "), ":this-week :with-notes")
+	expect(t, "target-check defaults", strings.Join(conf.CommandDefaults("target-check"), " "), ":today")
+	expect(t, "recent defaults", strings.Join(conf.CommandDefaults("recent"), " "), ":count=10")
+	expect(t, "stats defaults", strings.Join(conf.CommandDefaults("stats"), " "), "")
+}
+
+func TestPlaceholders(t *testing.T) {
+	backendName := "backendPlaceholders"
+	RegisterBackend(newTestBackendConfig(backendName))
+	defer unsetBackendConfig(backendName)
+
+	file, err := ioutil.TempFile(os.TempDir(), "tilo_config")
+	if err != nil {
+		t.Error(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.WriteString("socket = /run/user/{uid}/tilo-{profile}\nfoo = /data/{hostname}/{profile}.db"); err != nil {
+		t.Error(err)
+	}
+
+	args := []string{cliVal("conf-file", file.Name()), cliVal("backend", backendName), cliVal("profile", "work")}
+	conf, _, err := GetConfig(args, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostname, _ := os.Hostname()
+	expect(t, "socket", conf.Socket.Value, fmt.Sprintf("/run/user/%d/tilo-work", os.Getuid()))
+	expect(t, "foo", BackendItems(backendName)[0].Value, "/data/"+hostname+"/work.db")
+}
@@ -0,0 +1,30 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Output rendered by an external command. The response is passed to the
+// command's standard input as a single JSON object.
+type execFormatter struct {
+	command string
+}
+
+func (f execFormatter) Format(w io.Writer, resp msg.Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", f.command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	return errors.Wrapf(cmd.Run(), "Output command failed: %s", f.command)
+}
@@ -0,0 +1,59 @@
+// Package format renders server responses for the user.
+package format
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+const (
+	Text = "text"
+	JSON = "json"
+	// Prefix of a command rendering responses, see execFormatter
+	ExecPrefix = "exec:"
+)
+
+var formatters = make(map[string]Formatter)
+
+// Formatter renders the body of a response.
+type Formatter interface {
+	Format(w io.Writer, resp msg.Response) error
+}
+
+// RegisterFormatter makes a formatter available under the given name.
+func RegisterFormatter(name string, f Formatter) {
+	if _, ok := formatters[name]; ok {
+		panic("Double registration of formatter with name " + name)
+	}
+	formatters[name] = f
+}
+
+// Get determines the formatter registered under the given name.
+func Get(name string) (Formatter, error) {
+	if f, ok := formatters[name]; ok {
+		return f, nil
+	}
+	if strings.HasPrefix(name, ExecPrefix) && len(name) > len(ExecPrefix) {
+		return execFormatter{command: strings.TrimPrefix(name, ExecPrefix)}, nil
+	}
+	return nil, errors.Errorf("No such output format: %s (available: %v)", name, Names())
+}
+
+// Names lists all available formatters in alphabetical order.
+func Names() []string {
+	var names []string
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterFormatter(Text, textFormatter{})
+	RegisterFormatter(JSON, jsonFormatter{})
+}
@@ -0,0 +1,98 @@
+package format
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// Machine-readable output: one JSON object per body element and line.
+type jsonFormatter struct{}
+
+func (f jsonFormatter) Format(w io.Writer, resp msg.Response) error {
+	enc := json.NewEncoder(w)
+	for _, elem := range resp.Body {
+		if err := enc.Encode(jsonObject(elem)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonTaskEvent struct {
+	Event   string     `json:"event"`
+	Task    string     `json:"task"`
+	Started time.Time  `json:"started"`
+	Ended   *time.Time `json:"ended,omitempty"`
+}
+
+type jsonSummary struct {
+	Task         string     `json:"task"`
+	Period       string     `json:"period,omitempty"`
+	TotalSeconds int64      `json:"total_seconds"`
+	FirstLogged  *time.Time `json:"first_logged,omitempty"` // Left out if nothing was logged
+	LastLogged   *time.Time `json:"last_logged,omitempty"`
+	Notes        []msg.Note `json:"notes,omitempty"`
+}
+
+type jsonEntry struct {
+	Task            string    `json:"task"`
+	Started         time.Time `json:"started"`
+	Ended           time.Time `json:"ended"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	Notes           []string  `json:"notes,omitempty"`
+}
+
+type jsonMessage struct {
+	Message string `json:"message"`
+}
+
+type jsonKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// The object representing a body element in JSON output.
+func jsonObject(elem msg.Elem) interface{} {
+	switch elem.Kind {
+	case msg.KindTaskEvent:
+		task := elem.Event.Task
+		obj := jsonTaskEvent{Event: elem.Event.Type, Task: task.Name, Started: task.Started}
+		if task.HasEnded {
+			obj.Ended = &task.Ended
+		}
+		return obj
+	case msg.KindSummaryRow:
+		s := elem.Summary
+		obj := jsonSummary{
+			Task:         s.Task,
+			Period:       strings.TrimSpace(s.Details.Type + " " + strings.Join(s.Details.Elems, " ")),
+			TotalSeconds: int64(s.Total / time.Second),
+			Notes:        s.Notes,
+		}
+		if !s.IsEmpty() {
+			obj.FirstLogged, obj.LastLogged = &s.Start, &s.End
+		}
+		return obj
+	case msg.KindMessage:
+		return jsonMessage{Message: elem.Message}
+	case msg.KindKeyValue:
+		return jsonKeyValue{Key: elem.Key, Value: elem.Value}
+	case msg.KindLogEntry:
+		return elem.Entry
+	case msg.KindEntry:
+		task := elem.Task
+		return jsonEntry{
+			Task:            task.Name,
+			Started:         task.Started,
+			Ended:           task.Ended,
+			DurationSeconds: int64(task.Ended.Sub(task.Started) / time.Second),
+			Notes:           task.Notes,
+		}
+	default:
+		return elem
+	}
+}
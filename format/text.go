@@ -0,0 +1,136 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// Human-readable tabular output.
+type textFormatter struct{}
+
+func (f textFormatter) Format(w io.Writer, resp msg.Response) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for i, elem := range resp.Body {
+		if i > 0 && separated(resp.Body[i-1], elem) {
+			fmt.Fprintln(tw)
+		}
+		for _, line := range textLines(elem) {
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+	}
+	return tw.Flush()
+}
+
+// Whether an empty line goes between two elements: summaries stand apart from
+// each other, and groups from whatever surrounds them.
+func separated(prev msg.Elem, next msg.Elem) bool {
+	if prev.Kind == msg.KindSummaryRow && next.Kind == msg.KindSummaryRow {
+		return true
+	}
+	return prev.Group != next.Group
+}
+
+// Render a single body element as lines of tab-separated words.
+func textLines(elem msg.Elem) [][]string {
+	switch elem.Kind {
+	case msg.KindTaskEvent:
+		return taskEventLines(*elem.Event)
+	case msg.KindSummaryRow:
+		return summaryLines(*elem.Summary)
+	case msg.KindMessage:
+		return lines(line(elem.Message))
+	case msg.KindKeyValue:
+		return lines(line(elem.Key, elem.Value))
+	case msg.KindLogEntry:
+		entry := elem.Entry
+		return lines(line(FormatTime(entry.Time), entry.Type, entry.Task))
+	case msg.KindEntry:
+		return entryLines(*elem.Task)
+	default:
+		return lines(line("Unknown element:", elem.Kind))
+	}
+}
+
+func taskEventLines(event msg.TaskEvent) [][]string {
+	description := eventDescription(event.Type)
+	task := event.Task
+	if task.HasEnded {
+		return lines(
+			line(description, "Since", "Until"),
+			line(task.Name, FormatTime(task.Started), FormatTime(task.Ended)),
+		)
+	}
+	return lines(
+		line(description, "Since"),
+		line(task.Name, FormatTime(task.Started)),
+	)
+}
+
+func eventDescription(eventType string) string {
+	switch eventType {
+	case msg.RespCurrentTask:
+		return "Currently"
+	case msg.RespStartTask:
+		return "Now"
+	case msg.RespStopTask:
+		return "Stopped"
+	case msg.RespAbortTask:
+		return "Aborted"
+	case msg.RespRunningTask:
+		return "Still running"
+	default:
+		return eventType
+	}
+}
+
+func summaryLines(s msg.Summary) [][]string {
+	header := []string{s.Task}
+	header = append(header, s.Details.Type)
+	header = append(header, s.Details.Elems...)
+	if s.IsEmpty() {
+		return lines(
+			line(strings.Join(header, " ")),
+			line("Nothing found"),
+			line("Total time", s.Total.String()),
+		)
+	}
+	result := lines(
+		line(strings.Join(header, " ")),
+		line("First logged", FormatTime(s.Start)),
+		line("Last logged", FormatTime(s.End)),
+		line("Total time", s.Total.String()),
+	)
+	for _, note := range s.Notes {
+		result = append(result, line("Note", FormatTime(note.Time), note.Text))
+	}
+	return result
+}
+
+func entryLines(task msg.Task) [][]string {
+	duration := task.Ended.Sub(task.Started)
+	result := lines(line(FormatTime(task.Started), FormatTime(task.Ended), duration.String(), task.Name))
+	for _, note := range task.Notes {
+		result = append(result, line("", "", "Note", note))
+	}
+	return result
+}
+
+// FormatTime formats a time instance for display.
+func FormatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// Convenience function to gather lines.
+func lines(ls ...[]string) [][]string {
+	return ls
+}
+
+// Convenience function to build a line.
+func line(words ...string) []string {
+	return words
+}
@@ -7,18 +7,56 @@ import (
 
 	"github.com/fgahr/tilo/client"
 	_ "github.com/fgahr/tilo/command/abort"
+	_ "github.com/fgahr/tilo/command/archive"
+	_ "github.com/fgahr/tilo/command/auto"
+	_ "github.com/fgahr/tilo/command/away"
+	_ "github.com/fgahr/tilo/command/backfill"
+	_ "github.com/fgahr/tilo/command/backup"
+	_ "github.com/fgahr/tilo/command/calendar"
+	_ "github.com/fgahr/tilo/command/commands"
+	_ "github.com/fgahr/tilo/command/compact"
+	_ "github.com/fgahr/tilo/command/complete"
 	_ "github.com/fgahr/tilo/command/current"
+	_ "github.com/fgahr/tilo/command/dedupe"
+	_ "github.com/fgahr/tilo/command/devel"
+	_ "github.com/fgahr/tilo/command/edit"
+	_ "github.com/fgahr/tilo/command/export"
+	_ "github.com/fgahr/tilo/command/forecast"
+	_ "github.com/fgahr/tilo/command/goals"
+	_ "github.com/fgahr/tilo/command/healthz"
+	_ "github.com/fgahr/tilo/command/heartbeat"
 	_ "github.com/fgahr/tilo/command/help"
+	_ "github.com/fgahr/tilo/command/history"
+	_ "github.com/fgahr/tilo/command/invoice"
 	_ "github.com/fgahr/tilo/command/listen"
+	_ "github.com/fgahr/tilo/command/note"
 	_ "github.com/fgahr/tilo/command/ping"
+	_ "github.com/fgahr/tilo/command/plan"
 	_ "github.com/fgahr/tilo/command/query"
+	_ "github.com/fgahr/tilo/command/raw"
 	_ "github.com/fgahr/tilo/command/recent"
+	_ "github.com/fgahr/tilo/command/rename"
+	_ "github.com/fgahr/tilo/command/report"
 	_ "github.com/fgahr/tilo/command/resume"
+	_ "github.com/fgahr/tilo/command/search"
+	_ "github.com/fgahr/tilo/command/setup"
 	_ "github.com/fgahr/tilo/command/shutdown"
+	_ "github.com/fgahr/tilo/command/split"
 	_ "github.com/fgahr/tilo/command/srvcmd"
 	_ "github.com/fgahr/tilo/command/start"
+	_ "github.com/fgahr/tilo/command/stats"
 	_ "github.com/fgahr/tilo/command/stop"
+	_ "github.com/fgahr/tilo/command/summary"
+	_ "github.com/fgahr/tilo/command/sync"
+	_ "github.com/fgahr/tilo/command/target"
+	_ "github.com/fgahr/tilo/command/version"
+	_ "github.com/fgahr/tilo/command/watch"
+	_ "github.com/fgahr/tilo/command/worklog"
 	"github.com/fgahr/tilo/config"
+	_ "github.com/fgahr/tilo/server/backend/csvfile"
+	_ "github.com/fgahr/tilo/server/backend/external"
+	_ "github.com/fgahr/tilo/server/backend/jsonfile"
+	_ "github.com/fgahr/tilo/server/backend/memory"
 	_ "github.com/fgahr/tilo/server/backend/sqlite3"
 )
 
@@ -0,0 +1,7 @@
+//go:build window
+// +build window
+
+package main
+
+// Window-title tracking is optional, build with `-tags window` to include it.
+import _ "github.com/fgahr/tilo/command/wintrack"
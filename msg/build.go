@@ -0,0 +1,61 @@
+package msg
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata besides the version. Release builds set them via
+// -ldflags "-X github.com/fgahr/tilo/msg.Commit=<commit> -X github.com/fgahr/tilo/msg.BuildDate=<date>".
+// Otherwise, they are taken from the version control information embedded by
+// the go tool, if any.
+var (
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Build identifies the exact build of a client or server.
+type Build struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+	Date    string `json:"date,omitempty"`
+}
+
+// CurrentBuild describes the running binary.
+func CurrentBuild() *Build {
+	b := Build{Version: Version, Commit: Commit, Date: BuildDate}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && b.Commit == "":
+				b.Commit = s.Value
+			case s.Key == "vcs.time" && b.Date == "":
+				b.Date = s.Value
+			}
+		}
+	}
+	return &b
+}
+
+// String gives the version followed by commit and build date, if known,
+// e.g. "1.2.0 (commit 3bf9753, built 2026-10-15T09:00:00Z)".
+func (b *Build) String() string {
+	if b == nil {
+		return "unknown"
+	}
+	var details []string
+	if b.Commit != "" {
+		commit := b.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		details = append(details, "commit "+commit)
+	}
+	if b.Date != "" {
+		details = append(details, "built "+b.Date)
+	}
+	if len(details) == 0 {
+		return b.Version
+	}
+	return b.Version + " (" + strings.Join(details, ", ") + ")"
+}
@@ -0,0 +1,57 @@
+package msg
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of changes.
+const (
+	ChangeEntry   = "entry"   // A recorded entry, including its notes
+	ChangeNote    = "note"    // A note added to a previously recorded entry
+	ChangeRemoval = "removal" // A recorded entry removed, e.g. when merged
+)
+
+// Change is a single modification of the recorded data. Changes are kept in
+// an append-only log to synchronize several devices.
+type Change struct {
+	ID     string    `json:"id"`     // Unique across all devices
+	Device string    `json:"device"` // The device on which the change was made
+	Time   time.Time `json:"time"`   // When the change was made
+	Kind   string    `json:"kind"`   // One of the Change* constants
+	Task   Task      `json:"task"`   // The entry; only the name for ChangeNote
+	Note   string    `json:"note,omitempty"`
+}
+
+// NewChange creates a change of the given kind, made just now.
+func NewChange(device string, kind string, task Task) Change {
+	return Change{ID: newUUID(), Device: device, Time: rightNow(), Kind: kind, Task: task}
+}
+
+// SortChanges brings changes into the order in which they are applied. The
+// order is the same on all devices.
+func SortChanges(changes []Change) {
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Device != b.Device {
+			return a.Device < b.Device
+		}
+		return a.ID < b.ID
+	})
+}
+
+// A random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
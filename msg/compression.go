@@ -0,0 +1,58 @@
+package msg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+)
+
+// Compression supported for server messages. Clients offer it with their
+// command, the server may then compress everything it sends.
+const CompressionGzip = "gzip"
+
+// Compressor wraps w to compress everything written if the command offers
+// gzip compression. Every write is flushed immediately so that messages can
+// be decoded as they arrive. The result needs to be closed after use.
+func Compressor(w io.Writer, cmd Cmd) io.WriteCloser {
+	if cmd.Compression != CompressionGzip {
+		return nopCloser{w}
+	}
+	return &flushingGzipWriter{gzip.NewWriter(w)}
+}
+
+// Decompressor wraps r to decompress server messages if necessary. Compressed
+// data is recognized by the gzip header, which never starts a JSON message.
+func Decompressor(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	start, err := buffered.Peek(2)
+	if err == io.EOF || (err == nil && !(start[0] == 0x1f && start[1] == 0x8b)) {
+		return buffered, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return gzip.NewReader(buffered)
+}
+
+type flushingGzipWriter struct {
+	zw *gzip.Writer
+}
+
+func (w *flushingGzipWriter) Write(p []byte) (int, error) {
+	n, err := w.zw.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, w.zw.Flush()
+}
+
+func (w *flushingGzipWriter) Close() error {
+	return w.zw.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -2,7 +2,6 @@
 package msg
 
 import (
-	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +14,8 @@ const (
 	// Type
 	RespStartTask   = "start"
 	RespStopTask    = "stop"
+	RespAbortTask   = "abort"
+	RespRunningTask = "running"
 	RespCurrentTask = "current"
 )
 
@@ -27,13 +28,29 @@ type Quantity struct {
 type QueryParam []string
 
 type Cmd struct {
-	Op          string            `json:"operation"`    // The operation to perform
-	Flags       map[string]bool   `json:"flags"`        // Possible flags
-	Opts        map[string]string `json:"options"`      // Possible options
-	TaskNames   []string          `json:"tasks"`        // The tasks for any related requests
-	Body        [][]string        `json:"body"`         // The body containing the command information
-	Quantities  []Quantity        `json:"quantifiers"`  // Quantifiers, e.g. for queries
-	QueryParams []QueryParam      `json:"query_params"` // The parameters for a query
+	Op          string            `json:"operation"`        // The operation to perform
+	Flags       map[string]bool   `json:"flags"`            // Possible flags
+	Opts        map[string]string `json:"options"`          // Possible options
+	TaskNames   []string          `json:"tasks"`            // The tasks for any related requests
+	Body        [][]string        `json:"body"`             // The body containing the command information
+	Quantities  []Quantity        `json:"quantifiers"`      // Quantifiers, e.g. for queries
+	QueryParams []QueryParam      `json:"query_params"`     // The parameters for a query
+	Source      Source            `json:"source"`           // Where the command was issued
+	Compression string            `json:"compression"`      // Compression accepted by the client, if any
+	DryRun      bool              `json:"dry_run"`          // Preview the effect without changing any data
+	Condition   string            `json:"condition"`        // Run only if idle or active; always if empty
+	Token       string            `json:"token,omitempty"`  // API token restricting what the client may do
+	Client      *Build            `json:"client,omitempty"` // The build of the issuing client
+	Now         *time.Time        `json:"now,omitempty"`    // The time assumed as the current one, see --now
+}
+
+// CurrentTime is the time assumed by the command, the actual time unless
+// fixed by the client.
+func (c Cmd) CurrentTime() time.Time {
+	if c.Now != nil {
+		return *c.Now
+	}
+	return Clock()
 }
 
 // Type representing a named task with start and end times.
@@ -42,6 +59,21 @@ type Task struct {
 	Started  time.Time
 	Ended    time.Time
 	HasEnded bool
+	Notes    []string `json:",omitempty"`
+	Source   Source   // Where the task was started
+}
+
+// Entry is a recorded task together with the ID the backend knows it by.
+type Entry struct {
+	ID string `json:"id"` // Only meaningful to the backend giving it
+	Task
+}
+
+// Note is a comment attached to a recorded task.
+type Note struct {
+	Task string    `json:"task"`
+	Time time.Time `json:"time"` // The start of the annotated task
+	Text string    `json:"text"`
 }
 
 // Initiate a new task, started just now.
@@ -71,16 +103,75 @@ func (t *Task) IsRunning() bool {
 	return !t.HasEnded
 }
 
-// The current local time, truncated to seconds.
+// Validate checks whether a stopped task can be recorded. It needs a name
+// and must not end before it started.
+func (t Task) Validate() error {
+	if t.Name == "" {
+		return errors.New("Task has no name")
+	}
+	if t.Ended.Before(t.Started) {
+		return errors.Errorf("Task %s ends at %s, before it started at %s",
+			t.Name, t.Ended.Format("2006-01-02 15:04:05"), t.Started.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// Clock determines the current time for tasks. It can be replaced to
+// reproduce prior sessions.
+var Clock = time.Now
+
+// Precision is the granularity of task times, whole seconds by default.
+var Precision = time.Second
+
+// The current local time, truncated to the precision.
 func rightNow() time.Time {
-	return time.Now().Truncate(time.Second)
+	return Clock().Truncate(Precision)
 }
 
 // Response represents a server's answer to a client's request.
 type Response struct {
-	Status string     `json:"status"`
-	Error  string     `json:"error"`
-	Body   [][]string `json:"body"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Body   []Elem `json:"body"`
+	Server *Build `json:"server,omitempty"` // The build of the answering server
+}
+
+// Kinds of elements in a response body.
+const (
+	KindTaskEvent  = "task_event"
+	KindSummaryRow = "summary_row"
+	KindMessage    = "message"
+	KindKeyValue   = "key_value"
+	KindLogEntry   = "log_entry"
+	KindEntry      = "entry"
+)
+
+// Elem is a single element of a response body. Its kind determines which of
+// the remaining fields are set. Formatters switch on the kind to render the
+// element appropriately.
+type Elem struct {
+	Kind    string     `json:"kind"`
+	Event   *TaskEvent `json:"event,omitempty"`   // Set for KindTaskEvent
+	Summary *Summary   `json:"summary,omitempty"` // Set for KindSummaryRow
+	Message string     `json:"message,omitempty"` // Set for KindMessage
+	Key     string     `json:"key,omitempty"`     // Set for KindKeyValue
+	Value   string     `json:"value,omitempty"`   // Set for KindKeyValue
+	Entry   *LogEntry  `json:"entry,omitempty"`   // Set for KindLogEntry
+	Task    *Task      `json:"task,omitempty"`    // Set for KindEntry
+	Group   string     `json:"group,omitempty"`   // Shared by elements belonging together
+}
+
+// TaskEvent describes what happened to a task, e.g. that it was started.
+type TaskEvent struct {
+	Type string `json:"type"` // One of the Resp*Task constants
+	Task Task   `json:"task"`
+}
+
+// LogEntry is a single event in the history of task changes.
+type LogEntry struct {
+	Type string    `json:"type"` // One of RespStartTask, RespStopTask, RespAbortTask, RespRunningTask
+	Task string    `json:"task"`
+	Time time.Time `json:"time"`
 }
 
 // Summary represents all relevant information concerning a single request
@@ -90,6 +181,12 @@ type Summary struct {
 	Total   time.Duration
 	Start   time.Time
 	End     time.Time
+	Notes   []Note `json:",omitempty"`
+}
+
+// IsEmpty tells whether nothing was logged for the summary.
+func (s Summary) IsEmpty() bool {
+	return s.Start.IsZero()
 }
 
 func (r *Response) SetError(err error) {
@@ -111,12 +208,11 @@ func (r *Response) SetListening() {
 	if !r.Failed() {
 		r.Status = RespSuccess
 	}
-	r.addToBody(line("Listening"))
+	r.AddMessage("Listening")
 }
 
 func (r *Response) AddPong() {
-	pongTime := time.Now().Format(time.RFC3339)
-	r.addToBody(line(pongTime))
+	r.AddKeyValue("Pong", time.Now().Format(time.RFC3339))
 }
 
 func (r *Response) statusIsSet() bool {
@@ -127,91 +223,111 @@ func (r *Response) AddCurrentTask(task Task) {
 	if task.HasEnded {
 		panic("Task not running but should be reported as started!")
 	}
-	r.addTaskWithDescription("Currently", task)
+	r.addTaskEvent(RespCurrentTask, task)
 }
 
 func (r *Response) AddStartedTask(task Task) {
 	if task.HasEnded {
 		panic("Task not running but should be reported as started!")
 	}
-	r.addTaskWithDescription("Now", task)
+	r.addTaskEvent(RespStartTask, task)
 }
 
 func (r *Response) AddStoppedTask(task Task) {
 	if !task.HasEnded {
 		panic("Task needs to end before responding to stop!")
 	}
-	r.addTaskWithDescription("Stopped", task)
+	r.addTaskEvent(RespStopTask, task)
 }
 
 func (r *Response) AddAbortedTask(task Task) {
 	if !task.HasEnded {
 		panic("Task needs to end before responding to abort!")
 	}
-	r.addTaskWithDescription("Aborted", task)
+	r.addTaskEvent(RespAbortTask, task)
 }
 
-func (r *Response) addTaskWithDescription(description string, task Task) {
+func (r *Response) addTaskEvent(eventType string, task Task) {
 	if !r.statusIsSet() {
 		r.Status = RespSuccess
 	}
-	if task.HasEnded {
-		r.addToBody(
-			line(description, "Since", "Until"),
-			line(task.Name, formatTime(task.Started), formatTime(task.Ended)),
-		)
-	} else {
-		r.addToBody(
-			line(description, "Since"),
-			line(task.Name, formatTime(task.Started)),
-		)
-	}
+	r.addToBody(Elem{Kind: KindTaskEvent, Event: &TaskEvent{Type: eventType, Task: task}})
 }
 
 func (r *Response) AddShutdownMessage() {
 	if !r.statusIsSet() {
 		r.Status = RespSuccess
 	}
-	r.addToBody(line("Server shutting down: " + formatTime(time.Now())))
+	r.AddMessage("Server shutting down: " + time.Now().Format("2006-01-02 15:04:05"))
 }
 
 // Create a response containing the given query summaries.
+// AddQuerySummaries adds the summaries, grouped by task in order of
+// appearance. Formatters set groups and the summaries within apart.
 func (r *Response) AddQuerySummaries(sum []Summary) {
 	if !r.statusIsSet() {
 		r.Status = RespSuccess
 	}
-	for _, s := range sum {
-		header := []string{s.Task}
-		header = append(header, s.Details.Type)
-		header = append(header, s.Details.Elems...)
-		r.addToBody(line(strings.Join(header, " ")))
-		r.addToBody(line("First logged", formatTime(s.Start)))
-		r.addToBody(line("Last logged", formatTime(s.End)))
-		r.addToBody(line("Total time", s.Total.String()))
+	var tasks []string
+	byTask := make(map[string][]*Summary)
+	for i := range sum {
+		if _, ok := byTask[sum[i].Task]; !ok {
+			tasks = append(tasks, sum[i].Task)
+		}
+		byTask[sum[i].Task] = append(byTask[sum[i].Task], &sum[i])
+	}
+	for _, task := range tasks {
+		for _, s := range byTask[task] {
+			r.addToBody(Elem{Kind: KindSummaryRow, Summary: s, Group: task})
+		}
 	}
 }
 
-// The error encapsulated in the response, if any.
-func (r *Response) Err() error {
-	if r.Status == RespError {
-		return errors.New(r.Error)
+// AddLogEntries adds the given history events.
+func (r *Response) AddLogEntries(entries []LogEntry) {
+	if !r.statusIsSet() {
+		r.Status = RespSuccess
+	}
+	for i := range entries {
+		r.addToBody(Elem{Kind: KindLogEntry, Entry: &entries[i]})
 	}
-	return nil
 }
 
-// Add the given lines to the response body.
-func (r *Response) addToBody(lines ...[]string) {
-	for _, line := range lines {
-		r.Body = append(r.Body, line)
+// AddEntries adds the given recorded tasks.
+func (r *Response) AddEntries(tasks []Task) {
+	if !r.statusIsSet() {
+		r.Status = RespSuccess
+	}
+	for i := range tasks {
+		r.addToBody(Elem{Kind: KindEntry, Task: &tasks[i]})
 	}
 }
 
-// Convenience function to fill the response body.
-func line(words ...string) []string {
-	return words
+// AddMessage adds a free-form message for the user.
+func (r *Response) AddMessage(message string) {
+	if !r.statusIsSet() {
+		r.Status = RespSuccess
+	}
+	r.addToBody(Elem{Kind: KindMessage, Message: message})
+}
+
+// AddKeyValue adds a single named value.
+func (r *Response) AddKeyValue(key string, value string) {
+	if !r.statusIsSet() {
+		r.Status = RespSuccess
+	}
+	r.addToBody(Elem{Kind: KindKeyValue, Key: key, Value: value})
+}
+
+// The error encapsulated in the response, if any.
+func (r *Response) Err() error {
+	if r.Status == RespError {
+		return errors.New(r.Error)
+	}
+	return nil
 }
 
-// Format a time instance as a string.
-func formatTime(t time.Time) string {
-	return t.Format("2006-01-02 15:04:05")
+// Add the given elements to the response body.
+func (r *Response) addToBody(elems ...Elem) {
+	r.Body = append(r.Body, elems...)
 }
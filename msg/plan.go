@@ -0,0 +1,35 @@
+package msg
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+)
+
+// Plan is an entry planned ahead, e.g. a meeting imported from a calendar. It
+// becomes a recorded entry once confirmed, but is kept to compare plans with
+// the time actually spent.
+type Plan struct {
+	ID        string    `json:"id"`    // Derived from the origin, see PlanID
+	Task      string    `json:"task"`  // The task to record the time for
+	Title     string    `json:"title"` // The title of the calendar event, if any
+	Started   time.Time `json:"started"`
+	Ended     time.Time `json:"ended"`
+	Confirmed bool      `json:"confirmed,omitempty"` // Whether it was recorded
+}
+
+// PlanID gives a short identifier for a planned entry, stable across repeated
+// imports of the same occurrence of an event.
+func PlanID(origin string, started time.Time) string {
+	sum := sha1.Sum([]byte(origin + "|" + started.UTC().Format(time.RFC3339)))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// Entry gives the recorded entry resulting from confirming the plan.
+func (p Plan) Entry() Task {
+	task := Task{Name: p.Task, Started: p.Started, Ended: p.Ended, HasEnded: true}
+	if p.Title != "" {
+		task.Notes = []string{p.Title}
+	}
+	return task
+}
@@ -0,0 +1,32 @@
+package msg
+
+import (
+	"os"
+	"os/user"
+)
+
+// Version of tilo. Release builds set it via
+// -ldflags "-X github.com/fgahr/tilo/msg.Version=<version>".
+var Version = "dev"
+
+// Source describes where a record originates from.
+type Source struct {
+	Host    string `json:"host,omitempty"`
+	User    string `json:"user,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// LocalSource describes the current process.
+func LocalSource() Source {
+	host, _ := os.Hostname()
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return Source{Host: host, User: name, Version: Version}
+}
+
+// IsEmpty returns whether no information about the source is present.
+func (s Source) IsEmpty() bool {
+	return s == Source{}
+}
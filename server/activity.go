@@ -0,0 +1,30 @@
+package server
+
+import (
+	"time"
+)
+
+// Note that the given task has been worked on at the given time, e.g. when a
+// heartbeat is received. Earlier times than those recorded are ignored. A
+// long gap since the previous activity is reported as an away period.
+func (s *Server) RecordActivity(task string, at time.Time) {
+	if s.foreign() {
+		// Away detection is up to the server owner.
+		return
+	}
+	if s.lastActivity == nil {
+		s.lastActivity = make(map[string]time.Time)
+	}
+	if prev := s.lastActivity[task]; at.After(prev) {
+		s.checkAway(task, prev, at)
+		s.lastActivity[task] = at
+	}
+}
+
+// The time the task was last seen being worked on, i.e. the latest heartbeat
+// or its start, whichever is later. This is more reliable than the time of
+// the last request when determining how long a task was actually worked on.
+func (s *Server) LastActivity(task string) (time.Time, bool) {
+	at, ok := s.lastActivity[task]
+	return at, ok
+}
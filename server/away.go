@@ -0,0 +1,101 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// A period without activity on the running task, e.g. while the screen was
+// locked, awaiting the user's decision whether to keep it.
+type Away struct {
+	Task  string    `json:"task"`
+	Since time.Time `json:"since"` // The latest activity before
+	Until time.Time `json:"until"` // The first activity after
+}
+
+// The length of the away period.
+func (a Away) Duration() time.Duration {
+	return a.Until.Sub(a.Since)
+}
+
+// The time without activity after which the user is considered to have been
+// away. Zero if disabled.
+func (s *Server) awayThreshold() time.Duration {
+	threshold, err := time.ParseDuration(s.conf.AwayThreshold.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid away threshold:", err)
+		return 0
+	}
+	return threshold
+}
+
+// Check whether activity on a task follows a period long enough to be
+// considered away. If so, listeners are notified and the period is kept
+// for the user to decide on.
+func (s *Server) checkAway(task string, prev time.Time, at time.Time) {
+	threshold := s.awayThreshold()
+	if threshold <= 0 || prev.IsZero() {
+		return
+	}
+	current := s.CurrentTask
+	if !current.IsRunning() || current.Name != task || at.Sub(prev) < threshold {
+		return
+	}
+	away := Away{Task: task, Since: prev, Until: at}
+	s.away = &away
+	s.logInfo("Away from", task, "for", away.Duration())
+	ntf := TaskNotification(current)
+	ntf.Event = EventAway
+	ntf.Away = &away
+	s.notify(ntf)
+}
+
+// The away period awaiting a decision, if any. Away periods are only kept
+// while the task they occurred in is running.
+func (s *Server) PendingAway() (Away, bool) {
+	if s.away == nil {
+		return Away{}, false
+	}
+	current := s.CurrentTask
+	if !current.IsRunning() || current.Name != s.away.Task || current.Started.After(s.away.Since) {
+		s.away = nil
+		return Away{}, false
+	}
+	return *s.away, true
+}
+
+// Keep the pending away period on the running task.
+func (s *Server) KeepAway() error {
+	if _, ok := s.PendingAway(); !ok {
+		return errors.New("No away period pending")
+	}
+	s.away = nil
+	return nil
+}
+
+// Remove the pending away period from the running task: the part before is
+// saved, and the task continues from the end of the period. The period is
+// saved on the given task, or discarded if it is empty.
+func (s *Server) ReassignAway(task string) error {
+	away, ok := s.PendingAway()
+	if !ok {
+		return errors.New("No away period pending")
+	}
+	current := s.CurrentTask
+	before := msg.Task{Name: current.Name, Started: current.Started, Ended: away.Since, HasEnded: true, Source: current.Source}
+	if err := s.SaveTask(before); err != nil {
+		return errors.Wrap(err, "Failed to save the time before the away period")
+	}
+	if task != "" {
+		during := msg.Task{Name: task, Started: away.Since, Ended: away.Until, HasEnded: true, Source: current.Source}
+		if err := s.SaveTask(during); err != nil {
+			return errors.Wrap(err, "Failed to save the away period")
+		}
+	}
+	s.away = nil
+	s.CurrentTask.Started = away.Until
+	s.notifyListeners()
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/fgahr/tilo/config"
 	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
 )
 
 // Backend represents storage of task information, typically a database.
@@ -13,14 +14,130 @@ import (
 type Backend interface {
 	Name() string
 	Init() error
+	// InitReadOnly prepares the backend for queries only. Existing data is
+	// not modified in any way.
+	InitReadOnly() error
 	Close() error
+	// Snapshot writes a consistent copy of all data to a new file at path.
+	Snapshot(path string) error
+	// Save a stopped task, including its notes.
 	Save(task msg.Task) error
 	Config() config.BackendConfig
 	// RecentTasks gives a summary of the latest activity, limited to the `maxNumber` most recent tasks
 	RecentTasks(maxNumber int) ([]msg.Summary, error)
+	// RecentEntries gives at most maxNumber individual entries, including
+	// notes, the most recently ended first. If no tasks are given, entries of
+	// all tasks are included.
+	RecentEntries(tasks []string, maxNumber int) ([]msg.Entry, error)
+	// GetRecord gives the entry with the ID, including notes.
+	GetRecord(id string) (msg.Entry, error)
+	// UpdateRecord changes the start and end of the entry with the ID.
+	UpdateRecord(id string, started time.Time, ended time.Time) error
 	// TODO: Split into several meaningful methods?
-	GetTaskBetween(task string, start time.Time, end time.Time) ([]msg.Summary, error)
-	GetAllTasksBetween(start time.Time, end time.Time) ([]msg.Summary, error)
+	// Entries are restricted to the source, where empty fields match any value.
+	// Entries overlapping either end of the period are clipped to it, here as
+	// in GetDailyTotals, CountEntriesBetween and GetWeekHours.
+	GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error)
+	GetAllTasksBetween(start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error)
+	// GetDailyTotals gives one summary per day with activity on the task
+	// between start and end, in chronological order. The details hold the day
+	// as a date quantity. Entries count towards the day they were started, or
+	// to the first day if started before.
+	GetDailyTotals(task string, start time.Time, end time.Time) ([]msg.Summary, error)
+	// CountEntriesBetween gives the number of entries between start and end
+	// across all tasks and the total time recorded in them.
+	CountEntriesBetween(start time.Time, end time.Time) (int, time.Duration, error)
+	// GetWeekHours gives the time spent on the tasks between start and end in
+	// each hour of the week. If no tasks are given, all tasks are included.
+	GetWeekHours(tasks []string, start time.Time, end time.Time) (WeekHours, error)
+	// TaskNames lists at most limit task names starting with prefix, most
+	// recently used first.
+	TaskNames(prefix string, limit int) ([]string, error)
+	// SetArchived marks a task as archived or, with archived false, restores
+	// it. Archived tasks are left out of completion and :all queries.
+	SetArchived(task string, archived bool) error
+	// ArchivedTasks lists the names of all archived tasks.
+	ArchivedTasks() ([]string, error)
+	// RenameTask gives the task's entries, events and plans the new name, as
+	// well as its archived state. They are merged with those of a task named
+	// so already. Returns the number of entries renamed.
+	RenameTask(task string, newName string) (int, error)
+	// ForEachTaskBetween calls fn for every recorded task overlapping the
+	// period between start and end, including notes, in chronological order.
+	// Entries crossing either end are clipped to the period. If no tasks are
+	// given, all tasks are included. Iteration stops at the first error.
+	ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error
+	// RemoveDuplicates removes each duplicate entry and attaches its notes to
+	// the entry it duplicates, unless already present, in a single
+	// transaction.
+	RemoveDuplicates(dups []Duplicate) error
+	// ReplaceEntries saves the entries and removes the duplicates, usually of
+	// the new entries, in a single transaction. Notes move as with
+	// RemoveDuplicates.
+	ReplaceEntries(entries []msg.Task, dups []Duplicate) error
+	// AddNote attaches a note to the most recently saved entry of the task.
+	AddNote(task string, note string) error
+	// GetNotesBetween lists the notes attached to entries of the task
+	// overlapping the period between start and end in chronological order.
+	GetNotesBetween(task string, start time.Time, end time.Time) ([]msg.Note, error)
+	// Search lists the entries whose task name or notes contain the term.
+	Search(term string) ([]msg.Task, error)
+	// SaveEvent records a single event in the history of task changes.
+	SaveEvent(entry msg.LogEntry) error
+	// GetEventsBetween lists the recorded events between start and end in
+	// chronological order. If no tasks are given, events for all tasks are listed.
+	GetEventsBetween(tasks []string, start time.Time, end time.Time) ([]msg.LogEntry, error)
+	// RecordChange appends a change made on this device to the change log.
+	RecordChange(change msg.Change) error
+	// ApplyChange records a change made on another device and applies it.
+	// Returns false if the change was known before.
+	ApplyChange(change msg.Change) (bool, error)
+	// ForEachChange calls fn for every change in the log in the order in
+	// which they were recorded. Iteration stops at the first error.
+	ForEachChange(fn func(msg.Change) error) error
+}
+
+// Duplicate is a recorded entry identical to or contained in another entry of
+// the same task.
+type Duplicate struct {
+	Entry msg.Task // The entry to remove
+	Of    msg.Task // The entry it duplicates
+}
+
+// PerUser is implemented by backends able to keep the data of each user of a
+// shared server apart, see multi_user.
+type PerUser interface {
+	// ForUser gives a backend holding the data of the user. It still needs to
+	// be initialized before use.
+	ForUser(user string) (Backend, error)
+	// Users lists the users with data stored.
+	Users() ([]string, error)
+}
+
+// Pinger is implemented by backends able to check their connection more
+// cheaply than by running a query on recorded data.
+type Pinger interface {
+	Ping() error
+}
+
+// AppendOnly is implemented by backends unable to change or remove entries
+// once saved. Sessions are not merged into preceding entries with them.
+type AppendOnly interface {
+	// AppendOnly reports whether entries are only ever appended.
+	AppendOnly() bool
+}
+
+// Planner is implemented by backends able to keep planned entries apart from
+// recorded ones, see msg.Plan.
+type Planner interface {
+	// SavePlans adds planned entries, replacing those with the same ID.
+	SavePlans(plans []msg.Plan) error
+	// GetPlansBetween lists the planned entries starting between start and
+	// end in chronological order.
+	GetPlansBetween(start time.Time, end time.Time) ([]msg.Plan, error)
+	// RemovePlan removes the planned entry with the ID. Returns false if there
+	// is no such entry.
+	RemovePlan(id string) (bool, error)
 }
 
 var backends = make(map[string]Backend)
@@ -34,8 +151,35 @@ func RegisterBackend(b Backend) {
 	config.RegisterBackend(b.Config())
 }
 
-// From determines and sets up a backend based on configuration options.
-func From(conf *config.Opts) Backend {
-	// TODO: Adjust to conf
-	return backends["sqlite3"]
+// From determines the backend to use based on configuration options.
+// The backend still needs to be initialized before use.
+func From(conf *config.Opts) (Backend, error) {
+	name := conf.Backend.Value
+	if b, ok := backends[name]; ok {
+		return b, nil
+	}
+	return nil, errors.New("No such backend: " + name)
+}
+
+// ArchivedSet gives the archived tasks of the backend as a set.
+func ArchivedSet(b Backend) (map[string]bool, error) {
+	names, err := b.ArchivedTasks()
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set, nil
+}
+
+// OpenReadOnly determines the configured backend and prepares it for queries,
+// bypassing the server.
+func OpenReadOnly(conf *config.Opts) (Backend, error) {
+	b, err := From(conf)
+	if err != nil {
+		return nil, err
+	}
+	return b, b.InitReadOnly()
 }
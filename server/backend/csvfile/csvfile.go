@@ -0,0 +1,343 @@
+// Backend appending each completed task as a line to a CSV file, named csv in
+// the configuration. The file starts with the header
+//
+//	name,start,end
+//
+// followed by one line per entry with the times in RFC 3339 format, e.g. to
+// open it in a spreadsheet or keep it for audits. Queries are answered by
+// scanning the whole file.
+//
+// Only entries are kept: notes, the history of events and the change log are
+// not stored, and entries cannot be edited, renamed or archived.
+package csvfile
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/server/backend/memory"
+	"github.com/pkg/errors"
+)
+
+const (
+	backendName = "csv"
+	timeLayout  = time.RFC3339Nano
+)
+
+var header = []string{"name", "start", "end"}
+
+func init() {
+	c := CSV{conf: defaultConf()}
+	backend.RegisterBackend(&c)
+}
+
+type csvConf struct {
+	file config.Item
+}
+
+func defaultConf() csvConf {
+	// TODO: Log warning on error?
+	home, _ := os.UserHomeDir()
+	file := config.Item{
+		InFile: "csv_file",
+		InArgs: "csv-file",
+		InEnv:  "CSV_FILE",
+		Value:  filepath.Join(home, ".config", "tilo", "tilo.csv"),
+	}
+	return csvConf{file: file}
+}
+
+func (c *csvConf) BackendName() string {
+	return backendName
+}
+
+func (c *csvConf) AcceptedItems() []*config.Item {
+	return []*config.Item{&c.file}
+}
+
+type CSV struct {
+	conf csvConf
+	mu   sync.Mutex // Held while reading or writing the file
+}
+
+func (c *CSV) Config() config.BackendConfig {
+	return &c.conf
+}
+
+func (c *CSV) Name() string {
+	return backendName
+}
+
+// Create the file with its header unless it exists.
+func (c *CSV) Init() error {
+	path := c.conf.file.Value
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "Unable to create the directory of the CSV file")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create the CSV file")
+	}
+	w := csv.NewWriter(f)
+	w.Write(header)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Unable to write the CSV file")
+	}
+	return f.Close()
+}
+
+func (c *CSV) InitReadOnly() error {
+	_, err := os.Stat(c.conf.file.Value)
+	return errors.Wrap(err, "Unable to open the CSV file")
+}
+
+func (c *CSV) Close() error {
+	return nil
+}
+
+// Copy the file as it is.
+func (c *CSV) Snapshot(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	src, err := os.Open(c.conf.file.Value)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Append the task to the file. Its notes are not kept.
+func (c *CSV) Save(task msg.Task) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.OpenFile(c.conf.file.Value, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Unable to open the CSV file")
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{task.Name, task.Started.Format(timeLayout), task.Ended.Format(timeLayout)})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return errors.Wrap(err, "Unable to write the CSV file")
+	}
+	return f.Close()
+}
+
+// Read all entries from the file into a backend answering queries about them.
+func (c *CSV) scan() (*memory.Memory, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := os.Open(c.conf.file.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to open the CSV file")
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(header)
+	r.ReuseRecord = true
+	var tasks []msg.Task
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "Invalid CSV file")
+		}
+		if line == 1 && record[0] == header[0] {
+			continue
+		}
+		task, err := parseRecord(record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid entry in line %d of the CSV file", line)
+		}
+		tasks = append(tasks, task)
+	}
+	m := memory.New()
+	m.Load(memory.Data{Tasks: tasks})
+	return m, nil
+}
+
+func parseRecord(record []string) (msg.Task, error) {
+	started, err := time.Parse(timeLayout, record[1])
+	if err != nil {
+		return msg.Task{}, err
+	}
+	ended, err := time.Parse(timeLayout, record[2])
+	if err != nil {
+		return msg.Task{}, err
+	}
+	return msg.Task{Name: record[0], Started: started, Ended: ended, HasEnded: true}, nil
+}
+
+func (c *CSV) RecentTasks(maxNumber int) ([]msg.Summary, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.RecentTasks(maxNumber)
+}
+
+// Entries are identified by their position in the file, counting from 0
+// after the header.
+func (c *CSV) RecentEntries(tasks []string, maxNumber int) ([]msg.Entry, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.RecentEntries(tasks, maxNumber)
+}
+
+func (c *CSV) GetRecord(id string) (msg.Entry, error) {
+	m, err := c.scan()
+	if err != nil {
+		return msg.Entry{}, err
+	}
+	return m.GetRecord(id)
+}
+
+func (c *CSV) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	return errAppendOnly
+}
+
+func (c *CSV) GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetTaskBetween(task, start, end, source)
+}
+
+func (c *CSV) GetAllTasksBetween(start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetAllTasksBetween(start, end, source)
+}
+
+func (c *CSV) GetDailyTotals(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.GetDailyTotals(task, start, end)
+}
+
+func (c *CSV) CountEntriesBetween(start time.Time, end time.Time) (int, time.Duration, error) {
+	m, err := c.scan()
+	if err != nil {
+		return 0, 0, err
+	}
+	return m.CountEntriesBetween(start, end)
+}
+
+func (c *CSV) GetWeekHours(tasks []string, start time.Time, end time.Time) (backend.WeekHours, error) {
+	m, err := c.scan()
+	if err != nil {
+		return backend.WeekHours{}, err
+	}
+	return m.GetWeekHours(tasks, start, end)
+}
+
+func (c *CSV) TaskNames(prefix string, limit int) ([]string, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.TaskNames(prefix, limit)
+}
+
+func (c *CSV) ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error {
+	m, err := c.scan()
+	if err != nil {
+		return err
+	}
+	return m.ForEachTaskBetween(tasks, start, end, fn)
+}
+
+func (c *CSV) Search(term string) ([]msg.Task, error) {
+	m, err := c.scan()
+	if err != nil {
+		return nil, err
+	}
+	return m.Search(term)
+}
+
+// The file is only ever appended to.
+var errAppendOnly = errors.New("Entries of the csv backend cannot be changed")
+
+func (c *CSV) AppendOnly() bool {
+	return true
+}
+
+func (c *CSV) SetArchived(task string, archived bool) error {
+	return errAppendOnly
+}
+
+func (c *CSV) ArchivedTasks() ([]string, error) {
+	return nil, nil
+}
+
+func (c *CSV) RenameTask(task string, newName string) (int, error) {
+	return 0, errAppendOnly
+}
+
+func (c *CSV) RemoveDuplicates(dups []backend.Duplicate) error {
+	return errAppendOnly
+}
+
+func (c *CSV) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	return errAppendOnly
+}
+
+func (c *CSV) AddNote(task string, note string) error {
+	return errAppendOnly
+}
+
+func (c *CSV) GetNotesBetween(task string, start time.Time, end time.Time) ([]msg.Note, error) {
+	return nil, nil
+}
+
+// Events are not kept.
+func (c *CSV) SaveEvent(entry msg.LogEntry) error {
+	return nil
+}
+
+func (c *CSV) GetEventsBetween(tasks []string, start time.Time, end time.Time) ([]msg.LogEntry, error) {
+	return nil, nil
+}
+
+// Changes are not kept, the csv backend cannot be synchronized.
+func (c *CSV) RecordChange(change msg.Change) error {
+	return nil
+}
+
+func (c *CSV) ApplyChange(change msg.Change) (bool, error) {
+	return false, errors.New("The csv backend cannot be synchronized")
+}
+
+func (c *CSV) ForEachChange(fn func(msg.Change) error) error {
+	return nil
+}
@@ -0,0 +1,105 @@
+package csvfile
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+func newTestBackend(t *testing.T) *CSV {
+	c := &CSV{conf: defaultConf()}
+	c.conf.file.Value = filepath.Join(t.TempDir(), "tilo.csv")
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func at(hour, min int) time.Time {
+	return time.Date(2020, time.March, 4, hour, min, 0, 0, time.UTC)
+}
+
+func saveTask(t *testing.T, c *CSV, name string, started, ended time.Time) {
+	task := msg.Task{Name: name, Started: started, Ended: ended, HasEnded: true}
+	if err := c.Save(task); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveAppendsLines(t *testing.T) {
+	c := newTestBackend(t)
+	saveTask(t, c, "foo", at(9, 0), at(10, 0))
+	saveTask(t, c, "bar, baz", at(10, 0), at(10, 30))
+	// An existing file is kept as it is.
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(c.conf.file.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,start,end\n" +
+		"foo,2020-03-04T09:00:00Z,2020-03-04T10:00:00Z\n" +
+		"\"bar, baz\",2020-03-04T10:00:00Z,2020-03-04T10:30:00Z\n"
+	if string(content) != expected {
+		t.Errorf("Expected file content:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+func TestQueriesClipEntries(t *testing.T) {
+	c := newTestBackend(t)
+	saveTask(t, c, "foo", at(8, 0), at(10, 0))
+	saveTask(t, c, "foo", at(11, 0), at(11, 45))
+
+	sums, err := c.GetTaskBetween("foo", at(9, 0), at(12, 0), msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 1 || sums[0].Total != 105*time.Minute {
+		t.Errorf("Expected a total of 1h45m0s, got %v", sums)
+	}
+
+	var got []msg.Task
+	err = c.ForEachTaskBetween(nil, at(9, 0), at(11, 30), func(task msg.Task) error {
+		got = append(got, task)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !got[0].Started.Equal(at(9, 0)) || !got[1].Ended.Equal(at(11, 30)) {
+		t.Errorf("Expected two entries clipped to 09:00 and 11:30, got %v", got)
+	}
+}
+
+func TestEntriesIdentifiedByPosition(t *testing.T) {
+	c := newTestBackend(t)
+	saveTask(t, c, "foo", at(9, 0), at(10, 0))
+	saveTask(t, c, "bar", at(10, 0), at(11, 0))
+
+	entry, err := c.GetRecord("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Name != "foo" {
+		t.Errorf("Expected the first entry after the header, got %v", entry)
+	}
+	entries, err := c.RecentEntries(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != "1" || entries[0].Name != "bar" {
+		t.Errorf("Expected the second entry as the most recent one, got %v", entries)
+	}
+
+	if err := c.UpdateRecord("0", at(8, 0), at(10, 0)); err == nil {
+		t.Error("Expected an error when changing an entry")
+	}
+	if !c.AppendOnly() {
+		t.Error("Expected the backend to be append-only")
+	}
+}
@@ -0,0 +1,350 @@
+// Backend delegating to an external program, allowing to store data anywhere
+// without changes to tilo itself.
+//
+// The program is started by the server and receives one JSON request per line
+// on its standard input:
+//
+//	{"method": "save", "params": {"task": {...}}}
+//
+// It answers each request with one JSON response per line on its standard
+// output:
+//
+//	{"result": ..., "error": ""}
+//
+// A non-empty error marks a failure. Methods iterating over data
+// (for_each_task_between, for_each_change) answer with one response per item,
+// all but the last one with "more": true; the last one carries no result.
+//
+// The methods and their parameters correspond to the backend.Backend
+// interface, with names in snake case, e.g. get_task_between takes "name",
+// "start", "end" and "source".
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	backendName = "external"
+)
+
+func init() {
+	e := External{conf: defaultConf()}
+	backend.RegisterBackend(&e)
+}
+
+type externalConf struct {
+	command config.Item
+}
+
+func defaultConf() externalConf {
+	command := config.Item{
+		InFile: "backend_command",
+		InArgs: "backend-command",
+		InEnv:  "BACKEND_COMMAND",
+		Value:  "",
+	}
+	return externalConf{command: command}
+}
+
+func (c *externalConf) BackendName() string {
+	return backendName
+}
+
+func (c *externalConf) AcceptedItems() []*config.Item {
+	return []*config.Item{&c.command}
+}
+
+type request struct {
+	Method string `json:"method"`
+	Params params `json:"params"`
+}
+
+// Parameters of all methods, only the relevant ones are set.
+type params struct {
+	Path       string              `json:"path,omitempty"`
+	ID         string              `json:"id,omitempty"`
+	Task       *msg.Task           `json:"task,omitempty"`
+	Entries    []msg.Task          `json:"entries,omitempty"`
+	Name       string              `json:"name,omitempty"`
+	NewName    string              `json:"new_name,omitempty"`
+	Tasks      []string            `json:"tasks,omitempty"`
+	Start      *time.Time          `json:"start,omitempty"`
+	End        *time.Time          `json:"end,omitempty"`
+	Source     *msg.Source         `json:"source,omitempty"`
+	Max        int                 `json:"max,omitempty"`
+	Note       string              `json:"note,omitempty"`
+	Term       string              `json:"term,omitempty"`
+	Event      *msg.LogEntry       `json:"event,omitempty"`
+	Change     *msg.Change         `json:"change,omitempty"`
+	Archived   bool                `json:"archived,omitempty"`
+	Duplicates []backend.Duplicate `json:"duplicates,omitempty"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+	More   bool            `json:"more"`
+}
+
+type External struct {
+	conf externalConf
+	mux  sync.Mutex
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	out  *json.Decoder
+}
+
+func (e *External) Config() config.BackendConfig {
+	return &e.conf
+}
+
+func (e *External) Name() string {
+	return backendName
+}
+
+func (e *External) Init() error {
+	return e.start("init")
+}
+
+func (e *External) InitReadOnly() error {
+	return e.start("init_read_only")
+}
+
+// Start the external program and initialize it.
+func (e *External) start(method string) error {
+	if e.conf.command.Value == "" {
+		return errors.New("No backend command configured")
+	}
+	cmd := exec.Command("sh", "-c", e.conf.command.Value)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return errors.Wrap(err, "Unable to start backend command")
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "Unable to start backend command")
+	}
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "Unable to start backend command")
+	}
+	e.cmd, e.in, e.out = cmd, in, json.NewDecoder(bufio.NewReader(out))
+	return e.call(method, params{}, nil)
+}
+
+func (e *External) Close() error {
+	if e.cmd == nil {
+		return errors.New("No backend present")
+	}
+	err := e.call("close", params{}, nil)
+	e.in.Close()
+	if waitErr := e.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	e.cmd = nil
+	return err
+}
+
+// Send a request and decode the result into v, unless nil.
+func (e *External) call(method string, p params, v interface{}) error {
+	return e.iterate(method, p, func(result json.RawMessage) error {
+		if v == nil || len(result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(result, v)
+	})
+}
+
+// Send a request and pass each result to fn. All responses are read even if
+// fn fails, to stay in sync with the external program.
+func (e *External) iterate(method string, p params, fn func(json.RawMessage) error) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.cmd == nil {
+		return errors.New("Backend command not running")
+	}
+	data, err := json.Marshal(request{Method: method, Params: p})
+	if err != nil {
+		return err
+	}
+	if _, err := e.in.Write(append(data, '\n')); err != nil {
+		return errors.Wrap(err, "Failed to send request to backend command")
+	}
+	var fnErr error
+	for {
+		resp := response{}
+		if err := e.out.Decode(&resp); err != nil {
+			return errors.Wrap(err, "Failed to read response from backend command")
+		}
+		if resp.Error != "" {
+			return errors.Errorf("Backend command failed on %s: %s", method, resp.Error)
+		}
+		if fnErr == nil && (resp.More || len(resp.Result) > 0) {
+			fnErr = fn(resp.Result)
+		}
+		if !resp.More {
+			return fnErr
+		}
+	}
+}
+
+func (e *External) Snapshot(path string) error {
+	return e.call("snapshot", params{Path: path}, nil)
+}
+
+func (e *External) Save(task msg.Task) error {
+	return e.call("save", params{Task: &task}, nil)
+}
+
+func (e *External) RecentTasks(maxNumber int) ([]msg.Summary, error) {
+	var result []msg.Summary
+	err := e.call("recent_tasks", params{Max: maxNumber}, &result)
+	return result, err
+}
+
+func (e *External) RecentEntries(tasks []string, maxNumber int) ([]msg.Entry, error) {
+	var result []msg.Entry
+	err := e.call("recent_entries", params{Tasks: tasks, Max: maxNumber}, &result)
+	return result, err
+}
+
+func (e *External) GetRecord(id string) (msg.Entry, error) {
+	var result msg.Entry
+	err := e.call("get_record", params{ID: id}, &result)
+	return result, err
+}
+
+func (e *External) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	return e.call("update_record", params{ID: id, Start: &started, End: &ended}, nil)
+}
+
+func (e *External) GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	var result []msg.Summary
+	err := e.call("get_task_between", params{Name: task, Start: &start, End: &end, Source: &source}, &result)
+	return result, err
+}
+
+func (e *External) GetAllTasksBetween(start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	var result []msg.Summary
+	err := e.call("get_all_tasks_between", params{Start: &start, End: &end, Source: &source}, &result)
+	return result, err
+}
+
+func (e *External) GetDailyTotals(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+	var result []msg.Summary
+	err := e.call("get_daily_totals", params{Name: task, Start: &start, End: &end}, &result)
+	return result, err
+}
+
+func (e *External) CountEntriesBetween(start time.Time, end time.Time) (int, time.Duration, error) {
+	var result struct {
+		Count int           `json:"count"`
+		Total time.Duration `json:"total"`
+	}
+	err := e.call("count_entries_between", params{Start: &start, End: &end}, &result)
+	return result.Count, result.Total, err
+}
+
+func (e *External) GetWeekHours(tasks []string, start time.Time, end time.Time) (backend.WeekHours, error) {
+	var result backend.WeekHours
+	err := e.call("get_week_hours", params{Tasks: tasks, Start: &start, End: &end}, &result)
+	return result, err
+}
+
+func (e *External) TaskNames(prefix string, limit int) ([]string, error) {
+	var result []string
+	err := e.call("task_names", params{Name: prefix, Max: limit}, &result)
+	return result, err
+}
+
+func (e *External) SetArchived(task string, archived bool) error {
+	return e.call("set_archived", params{Name: task, Archived: archived}, nil)
+}
+
+func (e *External) ArchivedTasks() ([]string, error) {
+	var result []string
+	err := e.call("archived_tasks", params{}, &result)
+	return result, err
+}
+
+func (e *External) RenameTask(task string, newName string) (int, error) {
+	var result int
+	err := e.call("rename_task", params{Name: task, NewName: newName}, &result)
+	return result, err
+}
+
+func (e *External) ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error {
+	return e.iterate("for_each_task_between", params{Tasks: tasks, Start: &start, End: &end}, func(result json.RawMessage) error {
+		task := msg.Task{}
+		if err := json.Unmarshal(result, &task); err != nil {
+			return err
+		}
+		return fn(task)
+	})
+}
+
+func (e *External) RemoveDuplicates(dups []backend.Duplicate) error {
+	return e.call("remove_duplicates", params{Duplicates: dups}, nil)
+}
+
+func (e *External) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	return e.call("replace_entries", params{Entries: entries, Duplicates: dups}, nil)
+}
+
+func (e *External) AddNote(task string, note string) error {
+	return e.call("add_note", params{Name: task, Note: note}, nil)
+}
+
+func (e *External) GetNotesBetween(task string, start time.Time, end time.Time) ([]msg.Note, error) {
+	var result []msg.Note
+	err := e.call("get_notes_between", params{Name: task, Start: &start, End: &end}, &result)
+	return result, err
+}
+
+func (e *External) Search(term string) ([]msg.Task, error) {
+	var result []msg.Task
+	err := e.call("search", params{Term: term}, &result)
+	return result, err
+}
+
+func (e *External) SaveEvent(entry msg.LogEntry) error {
+	return e.call("save_event", params{Event: &entry}, nil)
+}
+
+func (e *External) GetEventsBetween(tasks []string, start time.Time, end time.Time) ([]msg.LogEntry, error) {
+	var result []msg.LogEntry
+	err := e.call("get_events_between", params{Tasks: tasks, Start: &start, End: &end}, &result)
+	return result, err
+}
+
+func (e *External) RecordChange(change msg.Change) error {
+	return e.call("record_change", params{Change: &change}, nil)
+}
+
+func (e *External) ApplyChange(change msg.Change) (bool, error) {
+	var isNew bool
+	err := e.call("apply_change", params{Change: &change}, &isNew)
+	return isNew, err
+}
+
+func (e *External) ForEachChange(fn func(msg.Change) error) error {
+	return e.iterate("for_each_change", params{}, func(result json.RawMessage) error {
+		change := msg.Change{}
+		if err := json.Unmarshal(result, &change); err != nil {
+			return err
+		}
+		return fn(change)
+	})
+}
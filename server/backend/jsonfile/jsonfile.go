@@ -0,0 +1,344 @@
+// Backend storing all data as JSON lines in plain text files, e.g. to keep
+// them under version control. Each kind of record has a file of its own in
+// the configured directory:
+//
+//	entries.jsonl   recorded tasks, including their notes
+//	events.jsonl    the history of task changes
+//	changes.jsonl   the change log for synchronization
+//	archived.jsonl  the names of archived tasks
+//
+// All data is read when the server starts and queried in memory, see package
+// memory. New entries and events are appended to their files; other changes,
+// e.g. edits, rewrite the affected file.
+package jsonfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/server/backend/memory"
+	"github.com/pkg/errors"
+)
+
+const (
+	backendName = "jsonfile"
+)
+
+// The files in the data directory.
+const (
+	entriesFile  = "entries.jsonl"
+	eventsFile   = "events.jsonl"
+	changesFile  = "changes.jsonl"
+	archivedFile = "archived.jsonl"
+)
+
+func init() {
+	j := JSONFile{conf: defaultConf()}
+	backend.RegisterBackend(&j)
+}
+
+type jsonConf struct {
+	dir config.Item
+}
+
+func defaultConf() jsonConf {
+	// TODO: Log warning on error?
+	home, _ := os.UserHomeDir()
+	dir := config.Item{
+		InFile: "json_dir",
+		InArgs: "json-dir",
+		InEnv:  "JSON_DIR",
+		Value:  filepath.Join(home, ".config", "tilo", "data"),
+	}
+	return jsonConf{dir: dir}
+}
+
+func (c *jsonConf) BackendName() string {
+	return backendName
+}
+
+func (c *jsonConf) AcceptedItems() []*config.Item {
+	return []*config.Item{&c.dir}
+}
+
+// JSONFile answers queries from the data held in memory. Only the methods of
+// backend.Backend are taken over from it, leaving out optional interfaces like
+// PerUser and Planner whose data would not be stored.
+type JSONFile struct {
+	backend.Backend
+	conf jsonConf
+	mem  *memory.Memory
+	mu   sync.Mutex // Held while changing data, until written
+}
+
+func (j *JSONFile) Config() config.BackendConfig {
+	return &j.conf
+}
+
+func (j *JSONFile) Name() string {
+	return backendName
+}
+
+func (j *JSONFile) Init() error {
+	if err := os.MkdirAll(j.conf.dir.Value, 0700); err != nil {
+		return errors.Wrap(err, "Unable to create the data directory")
+	}
+	return j.load()
+}
+
+func (j *JSONFile) InitReadOnly() error {
+	if _, err := os.Stat(j.conf.dir.Value); err != nil {
+		return errors.Wrap(err, "Unable to open the data directory")
+	}
+	return j.load()
+}
+
+func (j *JSONFile) Close() error {
+	return nil
+}
+
+// Read all files into memory.
+func (j *JSONFile) load() error {
+	data := memory.Data{}
+	err := j.read(entriesFile, func(dec *json.Decoder) error {
+		var task msg.Task
+		err := dec.Decode(&task)
+		data.Tasks = append(data.Tasks, task)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = j.read(eventsFile, func(dec *json.Decoder) error {
+		var event msg.LogEntry
+		err := dec.Decode(&event)
+		data.Events = append(data.Events, event)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	// Changes are appended without checking for duplicates, see RecordChange.
+	known := make(map[string]bool)
+	err = j.read(changesFile, func(dec *json.Decoder) error {
+		var change msg.Change
+		err := dec.Decode(&change)
+		if !known[change.ID] {
+			known[change.ID] = true
+			data.Changes = append(data.Changes, change)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = j.read(archivedFile, func(dec *json.Decoder) error {
+		var name string
+		err := dec.Decode(&name)
+		data.Archived = append(data.Archived, name)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	j.mem = memory.New()
+	j.mem.Load(data)
+	j.Backend = j.mem
+	return nil
+}
+
+// Decode the records of the file, if it exists, one at a time.
+func (j *JSONFile) read(name string, decode func(dec *json.Decoder) error) error {
+	f, err := os.Open(filepath.Join(j.conf.dir.Value, name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "Unable to read %s", name)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		if err := decode(dec); err != nil {
+			return errors.Wrapf(err, "Invalid record in %s", name)
+		}
+	}
+	return nil
+}
+
+// Append a record to the file.
+func (j *JSONFile) append(name string, record interface{}) error {
+	f, err := os.OpenFile(filepath.Join(j.conf.dir.Value, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write %s", name)
+	}
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Unable to write %s", name)
+	}
+	return errors.Wrapf(f.Close(), "Unable to write %s", name)
+}
+
+// Replace the file by one holding the records. The file is replaced at once,
+// so that it is never left incomplete.
+func (j *JSONFile) rewrite(name string, records []interface{}) error {
+	path := filepath.Join(j.conf.dir.Value, name)
+	tmp := path + ".tmp"
+	if err := writeRecords(tmp, records); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "Unable to write %s", name)
+	}
+	return errors.Wrapf(os.Rename(tmp, path), "Unable to write %s", name)
+}
+
+func writeRecords(path string, records []interface{}) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Rewrite the files holding the given kinds of records from memory.
+func (j *JSONFile) persist(names ...string) error {
+	data := j.mem.Dump()
+	for _, name := range names {
+		var records []interface{}
+		switch name {
+		case entriesFile:
+			for _, task := range data.Tasks {
+				records = append(records, task)
+			}
+		case eventsFile:
+			for _, event := range data.Events {
+				records = append(records, event)
+			}
+		case changesFile:
+			for _, change := range data.Changes {
+				records = append(records, change)
+			}
+		case archivedFile:
+			for _, name := range data.Archived {
+				records = append(records, name)
+			}
+		}
+		if err := j.rewrite(name, records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *JSONFile) Save(task msg.Task) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.Save(task); err != nil {
+		return err
+	}
+	return j.append(entriesFile, task)
+}
+
+func (j *JSONFile) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.UpdateRecord(id, started, ended); err != nil {
+		return err
+	}
+	return j.persist(entriesFile)
+}
+
+func (j *JSONFile) SetArchived(task string, archived bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.SetArchived(task, archived); err != nil {
+		return err
+	}
+	return j.persist(archivedFile)
+}
+
+func (j *JSONFile) RenameTask(task string, newName string) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	count, err := j.mem.RenameTask(task, newName)
+	if err != nil {
+		return count, err
+	}
+	return count, j.persist(entriesFile, eventsFile, archivedFile)
+}
+
+func (j *JSONFile) RemoveDuplicates(dups []backend.Duplicate) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.RemoveDuplicates(dups); err != nil {
+		return err
+	}
+	return j.persist(entriesFile)
+}
+
+func (j *JSONFile) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.ReplaceEntries(entries, dups); err != nil {
+		return err
+	}
+	return j.persist(entriesFile)
+}
+
+func (j *JSONFile) AddNote(task string, note string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.AddNote(task, note); err != nil {
+		return err
+	}
+	return j.persist(entriesFile)
+}
+
+func (j *JSONFile) SaveEvent(entry msg.LogEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.SaveEvent(entry); err != nil {
+		return err
+	}
+	return j.append(eventsFile, entry)
+}
+
+// Changes known already are appended nonetheless and left out when reading.
+func (j *JSONFile) RecordChange(change msg.Change) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.mem.RecordChange(change); err != nil {
+		return err
+	}
+	return j.append(changesFile, change)
+}
+
+func (j *JSONFile) ApplyChange(change msg.Change) (bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	isNew, err := j.mem.ApplyChange(change)
+	if err != nil || !isNew {
+		return isNew, err
+	}
+	if err := j.persist(entriesFile); err != nil {
+		return isNew, err
+	}
+	return isNew, j.append(changesFile, change)
+}
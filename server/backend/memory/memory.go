@@ -0,0 +1,675 @@
+// Backend keeping all data in memory, lost when the server stops. It is
+// meant for tests, see package tilotest, and as a base for backends storing
+// their data in simple files.
+package memory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/command/query"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	backendName = "memory"
+)
+
+func init() {
+	backend.RegisterBackend(New())
+}
+
+type memoryConf struct{}
+
+func (c *memoryConf) BackendName() string {
+	return backendName
+}
+
+func (c *memoryConf) AcceptedItems() []*config.Item {
+	return nil
+}
+
+// Data is everything stored by the backend.
+type Data struct {
+	Tasks    []msg.Task     `json:"tasks"`
+	Events   []msg.LogEntry `json:"events"`
+	Changes  []msg.Change   `json:"changes"`
+	Archived []string       `json:"archived,omitempty"`
+	Plans    []msg.Plan     `json:"plans,omitempty"`
+}
+
+// Memory implements all queries on data held in memory.
+type Memory struct {
+	mu    sync.Mutex
+	data  Data
+	users map[string]*Memory // The data of each user of a shared server
+}
+
+// New creates an empty backend.
+func New() *Memory {
+	return &Memory{}
+}
+
+// ForUser gives the backend holding the data of the user, creating it when
+// first asked for.
+func (m *Memory) ForUser(user string) (backend.Backend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.users == nil {
+		m.users = make(map[string]*Memory)
+	}
+	if m.users[user] == nil {
+		m.users[user] = New()
+	}
+	return m.users[user], nil
+}
+
+// Users lists the users asked for so far.
+func (m *Memory) Users() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []string
+	for user := range m.users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// Load replaces all data.
+func (m *Memory) Load(data Data) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data = data
+}
+
+// Dump gives a copy of all data.
+func (m *Memory) Dump() Data {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Data{
+		Tasks:    copyTasks(m.data.Tasks),
+		Events:   append([]msg.LogEntry(nil), m.data.Events...),
+		Changes:  append([]msg.Change(nil), m.data.Changes...),
+		Archived: append([]string(nil), m.data.Archived...),
+		Plans:    append([]msg.Plan(nil), m.data.Plans...),
+	}
+}
+
+func (m *Memory) Name() string {
+	return backendName
+}
+
+func (m *Memory) Config() config.BackendConfig {
+	return &memoryConf{}
+}
+
+// Init discards all data.
+func (m *Memory) Init() error {
+	m.Load(Data{})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users = nil
+	return nil
+}
+
+func (m *Memory) InitReadOnly() error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Write all data as JSON.
+func (m *Memory) Snapshot(path string) error {
+	data, err := json.Marshal(m.Dump())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func (m *Memory) Save(task msg.Task) error {
+	if task.IsRunning() {
+		panic("Cannot save an active task.")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.Tasks = append(m.data.Tasks, copyTask(task))
+	return nil
+}
+
+// Whether the entry overlaps the period between start and end.
+func overlaps(task msg.Task, start time.Time, end time.Time) bool {
+	return task.Started.Before(end) && task.Ended.After(start)
+}
+
+// The entries clipped to the period between start and end.
+func clip(tasks []msg.Task, start time.Time, end time.Time) []msg.Task {
+	for i := range tasks {
+		if tasks[i].Started.Before(start) {
+			tasks[i].Started = start
+		}
+		if tasks[i].Ended.After(end) {
+			tasks[i].Ended = end
+		}
+	}
+	return tasks
+}
+
+func matchesSource(task msg.Task, source msg.Source) bool {
+	return (source.Host == "" || task.Source.Host == source.Host) &&
+		(source.User == "" || task.Source.User == source.User)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The entries matching the filter, copied to allow calling back without
+// holding the lock.
+func (m *Memory) filter(keep func(msg.Task) bool) []msg.Task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []msg.Task
+	for _, task := range m.data.Tasks {
+		if keep(task) {
+			result = append(result, copyTask(task))
+		}
+	}
+	return result
+}
+
+// Sum up the entries per task, in alphabetical order.
+func summarize(tasks []msg.Task) []msg.Summary {
+	byName := make(map[string]*msg.Summary)
+	var names []string
+	for _, task := range tasks {
+		sum, ok := byName[task.Name]
+		if !ok {
+			sum = &msg.Summary{Task: task.Name, Start: task.Started, End: task.Ended}
+			byName[task.Name] = sum
+			names = append(names, task.Name)
+		}
+		sum.Total += task.Ended.Sub(task.Started)
+		if task.Started.Before(sum.Start) {
+			sum.Start = task.Started
+		}
+		if task.Ended.After(sum.End) {
+			sum.End = task.Ended
+		}
+	}
+	sort.Strings(names)
+	var result []msg.Summary
+	for _, name := range names {
+		result = append(result, *byName[name])
+	}
+	return result
+}
+
+func (m *Memory) RecentTasks(maxNumber int) ([]msg.Summary, error) {
+	tasks := m.filter(func(msg.Task) bool { return true })
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].Ended.After(tasks[j].Ended) })
+	var result []msg.Summary
+	for i := 0; i < len(tasks) && i < maxNumber; i++ {
+		result = append(result, summarize(tasks[i:i+1])...)
+	}
+	return result, nil
+}
+
+// Entries are identified by their position among all recorded ones.
+func (m *Memory) RecentEntries(tasks []string, maxNumber int) ([]msg.Entry, error) {
+	m.mu.Lock()
+	var entries []msg.Entry
+	for i, task := range m.data.Tasks {
+		if len(tasks) == 0 || contains(tasks, task.Name) {
+			entries = append(entries, msg.Entry{ID: strconv.Itoa(i), Task: copyTask(task)})
+		}
+	}
+	m.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Ended.After(entries[j].Ended) })
+	if len(entries) > maxNumber {
+		entries = entries[:maxNumber]
+	}
+	return entries, nil
+}
+
+func (m *Memory) GetRecord(id string) (msg.Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.index(id)
+	if err != nil {
+		return msg.Entry{}, err
+	}
+	return msg.Entry{ID: id, Task: copyTask(m.data.Tasks[i])}, nil
+}
+
+func (m *Memory) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, err := m.index(id)
+	if err != nil {
+		return err
+	}
+	m.data.Tasks[i].Started = started
+	m.data.Tasks[i].Ended = ended
+	return nil
+}
+
+// The position of the entry with the ID, see RecentEntries.
+func (m *Memory) index(id string) (int, error) {
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 0 || i >= len(m.data.Tasks) {
+		return 0, errors.Errorf("No entry with ID %s", id)
+	}
+	return i, nil
+}
+
+func (m *Memory) GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	return summarize(clip(m.filter(func(t msg.Task) bool {
+		return (task == query.TskAllTasks || t.Name == task) && overlaps(t, start, end) && matchesSource(t, source)
+	}), start, end)), nil
+}
+
+func (m *Memory) GetAllTasksBetween(start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	return m.GetTaskBetween(query.TskAllTasks, start, end, source)
+}
+
+func (m *Memory) GetDailyTotals(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+	tasks := clip(m.filter(func(t msg.Task) bool {
+		return (task == query.TskAllTasks || t.Name == task) && overlaps(t, start, end)
+	}), start, end)
+	byDay := make(map[string][]msg.Task)
+	var days []string
+	for _, t := range tasks {
+		day := t.Started.Format("2006-01-02")
+		if _, ok := byDay[day]; !ok {
+			days = append(days, day)
+		}
+		// Summed up under the requested name.
+		t.Name = task
+		byDay[day] = append(byDay[day], t)
+	}
+	sort.Strings(days)
+	var result []msg.Summary
+	for _, day := range days {
+		sum := summarize(byDay[day])[0]
+		sum.Details = msg.Quantity{Type: quantifier.TimeDay, Elems: []string{day}}
+		result = append(result, sum)
+	}
+	return result, nil
+}
+
+func (m *Memory) CountEntriesBetween(start time.Time, end time.Time) (int, time.Duration, error) {
+	tasks := clip(m.filter(func(t msg.Task) bool { return overlaps(t, start, end) }), start, end)
+	var total time.Duration
+	for _, t := range tasks {
+		total += t.Ended.Sub(t.Started)
+	}
+	return len(tasks), total, nil
+}
+
+func (m *Memory) GetWeekHours(tasks []string, start time.Time, end time.Time) (backend.WeekHours, error) {
+	var result backend.WeekHours
+	for _, t := range clip(m.filter(func(t msg.Task) bool {
+		return (len(tasks) == 0 || contains(tasks, t.Name)) && overlaps(t, start, end)
+	}), start, end) {
+		result.Add(t.Started, t.Ended)
+	}
+	return result, nil
+}
+
+func (m *Memory) TaskNames(prefix string, limit int) ([]string, error) {
+	prefix = strings.ToLower(prefix)
+	lastUsed := make(map[string]time.Time)
+	for _, t := range m.filter(func(t msg.Task) bool { return strings.HasPrefix(strings.ToLower(t.Name), prefix) }) {
+		if t.Ended.After(lastUsed[t.Name]) {
+			lastUsed[t.Name] = t.Ended
+		}
+	}
+	var names []string
+	for name := range lastUsed {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return lastUsed[names[i]].After(lastUsed[names[j]]) })
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	return names, nil
+}
+
+func (m *Memory) SetArchived(task string, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, name := range m.data.Archived {
+		if name != task {
+			names = append(names, name)
+		}
+	}
+	if archived {
+		names = append(names, task)
+		sort.Strings(names)
+	}
+	m.data.Archived = names
+	return nil
+}
+
+func (m *Memory) ArchivedTasks() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.data.Archived...), nil
+}
+
+func (m *Memory) RenameTask(task string, newName string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for i := range m.data.Tasks {
+		if m.data.Tasks[i].Name == task {
+			m.data.Tasks[i].Name = newName
+			count++
+		}
+	}
+	for i := range m.data.Events {
+		if m.data.Events[i].Task == task {
+			m.data.Events[i].Task = newName
+		}
+	}
+	for i := range m.data.Plans {
+		if m.data.Plans[i].Task == task {
+			m.data.Plans[i].Task = newName
+		}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range m.data.Archived {
+		if name == task {
+			name = newName
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	m.data.Archived = names
+	return count, nil
+}
+
+func (m *Memory) SavePlans(plans []msg.Plan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range plans {
+		replaced := false
+		for i := range m.data.Plans {
+			if m.data.Plans[i].ID == p.ID {
+				m.data.Plans[i] = p
+				replaced = true
+			}
+		}
+		if !replaced {
+			m.data.Plans = append(m.data.Plans, p)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetPlansBetween(start time.Time, end time.Time) ([]msg.Plan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found []msg.Plan
+	for _, p := range m.data.Plans {
+		if !p.Started.Before(start) && p.Started.Before(end) {
+			found = append(found, p)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Started.Before(found[j].Started) })
+	return found, nil
+}
+
+func (m *Memory) RemovePlan(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.data.Plans {
+		if p.ID == id {
+			m.data.Plans = append(m.data.Plans[:i], m.data.Plans[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error {
+	found := clip(m.filter(func(t msg.Task) bool {
+		return (len(tasks) == 0 || contains(tasks, t.Name)) && overlaps(t, start, end)
+	}), start, end)
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Started.Before(found[j].Started) })
+	for _, t := range found {
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RemoveDuplicates(dups []backend.Duplicate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tasks, err := removeDuplicates(copyTasks(m.data.Tasks), dups)
+	if err != nil {
+		return err
+	}
+	m.data.Tasks = tasks
+	return nil
+}
+
+func (m *Memory) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tasks := append(copyTasks(m.data.Tasks), copyTasks(entries)...)
+	tasks, err := removeDuplicates(tasks, dups)
+	if err != nil {
+		return err
+	}
+	m.data.Tasks = tasks
+	return nil
+}
+
+// Remove the duplicates from the tasks, which are changed in place.
+func removeDuplicates(tasks []msg.Task, dups []backend.Duplicate) ([]msg.Task, error) {
+	same := func(a msg.Task, b msg.Task) bool {
+		return a.Name == b.Name && a.Started.Equal(b.Started) && a.Ended.Equal(b.Ended)
+	}
+	for _, dup := range dups {
+		// The first entry matching is kept, the last other one removed.
+		keep, remove := -1, -1
+		for i, t := range tasks {
+			if keep < 0 && same(t, dup.Of) {
+				keep = i
+			} else if same(t, dup.Entry) {
+				remove = i
+			}
+		}
+		if keep < 0 || remove < 0 {
+			return nil, errors.Errorf("No such entry: %v", dup.Entry)
+		}
+		for _, note := range tasks[remove].Notes {
+			if !contains(tasks[keep].Notes, note) {
+				tasks[keep].Notes = append(tasks[keep].Notes, note)
+			}
+		}
+		tasks = append(tasks[:remove], tasks[remove+1:]...)
+	}
+	return tasks, nil
+}
+
+func (m *Memory) AddNote(task string, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	latest := -1
+	for i, t := range m.data.Tasks {
+		if t.Name == task && (latest < 0 || t.Ended.After(m.data.Tasks[latest].Ended)) {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return errors.Errorf("No recorded entry for task %s", task)
+	}
+	m.data.Tasks[latest].Notes = append(m.data.Tasks[latest].Notes, note)
+	return nil
+}
+
+func (m *Memory) GetNotesBetween(task string, start time.Time, end time.Time) ([]msg.Note, error) {
+	found := m.filter(func(t msg.Task) bool {
+		return (task == query.TskAllTasks || t.Name == task) && overlaps(t, start, end)
+	})
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Started.Before(found[j].Started) })
+	var result []msg.Note
+	for _, t := range found {
+		for _, note := range t.Notes {
+			result = append(result, msg.Note{Task: t.Name, Time: t.Started, Text: note})
+		}
+	}
+	return result, nil
+}
+
+func (m *Memory) Search(term string) ([]msg.Task, error) {
+	term = strings.ToLower(term)
+	found := m.filter(func(t msg.Task) bool {
+		if strings.Contains(strings.ToLower(t.Name), term) {
+			return true
+		}
+		for _, note := range t.Notes {
+			if strings.Contains(strings.ToLower(note), term) {
+				return true
+			}
+		}
+		return false
+	})
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Started.Before(found[j].Started) })
+	return found, nil
+}
+
+func (m *Memory) SaveEvent(entry msg.LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.Events = append(m.data.Events, entry)
+	return nil
+}
+
+func (m *Memory) GetEventsBetween(tasks []string, start time.Time, end time.Time) ([]msg.LogEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []msg.LogEntry
+	for _, e := range m.data.Events {
+		if !e.Time.Before(start) && e.Time.Before(end) && (len(tasks) == 0 || contains(tasks, e.Task)) {
+			result = append(result, e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result, nil
+}
+
+// Whether the change is in the log already. Requires the lock to be held.
+func (m *Memory) knows(change msg.Change) bool {
+	for _, c := range m.data.Changes {
+		if c.ID == change.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) RecordChange(change msg.Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.knows(change) {
+		m.data.Changes = append(m.data.Changes, change)
+	}
+	return nil
+}
+
+// Apply a change the same way as the sqlite3 backend does.
+func (m *Memory) ApplyChange(change msg.Change) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.knows(change) {
+		return false, nil
+	}
+	task := change.Task
+	switch change.Kind {
+	case msg.ChangeEntry:
+		for _, t := range m.data.Tasks {
+			if t.Name == task.Name && t.Started.Equal(task.Started) && t.Ended.Equal(task.Ended) {
+				m.data.Changes = append(m.data.Changes, change)
+				return true, nil
+			}
+		}
+		m.data.Tasks = append(m.data.Tasks, copyTask(task))
+	case msg.ChangeNote:
+		latest := -1
+		for i, t := range m.data.Tasks {
+			if t.Name == task.Name && !t.Started.After(change.Time) &&
+				(latest < 0 || t.Started.After(m.data.Tasks[latest].Started)) {
+				latest = i
+			}
+		}
+		if latest >= 0 {
+			m.data.Tasks[latest].Notes = append(m.data.Tasks[latest].Notes, change.Note)
+		}
+	case msg.ChangeRemoval:
+		for i := len(m.data.Tasks) - 1; i >= 0; i-- {
+			t := m.data.Tasks[i]
+			if t.Name == task.Name && t.Started.Equal(task.Started) && t.Ended.Equal(task.Ended) {
+				m.data.Tasks = append(m.data.Tasks[:i:i], m.data.Tasks[i+1:]...)
+				break
+			}
+		}
+	default:
+		return false, errors.Errorf("Unknown kind of change: %s", change.Kind)
+	}
+	m.data.Changes = append(m.data.Changes, change)
+	return true, nil
+}
+
+func (m *Memory) ForEachChange(fn func(msg.Change) error) error {
+	m.mu.Lock()
+	changes := append([]msg.Change(nil), m.data.Changes...)
+	m.mu.Unlock()
+	for _, c := range changes {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A copy of the task not sharing its notes.
+func copyTask(task msg.Task) msg.Task {
+	task.Notes = append([]string(nil), task.Notes...)
+	return task
+}
+
+func copyTasks(tasks []msg.Task) []msg.Task {
+	var result []msg.Task
+	for _, t := range tasks {
+		result = append(result, copyTask(t))
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package sqlite3
+
+import (
+	"github.com/pkg/errors"
+)
+
+func (s *SQLite) SetArchived(task string, archived bool) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	var err error
+	if archived {
+		_, err = s.db.Exec("INSERT OR IGNORE INTO archived (name) VALUES (?);", task)
+	} else {
+		_, err = s.db.Exec("DELETE FROM archived WHERE name = ?;", task)
+	}
+	return errors.Wrapf(err, "Error while archiving %s", task)
+}
+
+func (s *SQLite) ArchivedTasks() ([]string, error) {
+	if s == nil {
+		return nil, errors.New("No backend present")
+	}
+	// Databases opened read-only may predate archival.
+	if exists, err := s.hasTable("archived"); err != nil || !exists {
+		return nil, err
+	}
+	rows, err := s.db.Query("SELECT name FROM archived ORDER BY name;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (s *SQLite) RenameTask(task string, newName string) (int, error) {
+	if s == nil {
+		return 0, errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error while renaming %s", task)
+	}
+	result, err := tx.Exec("UPDATE task SET name = ? WHERE name = ?;", newName, task)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.Wrapf(err, "Error while renaming %s", task)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for _, stmt := range []string{
+		"UPDATE event SET task = ? WHERE task = ?;",
+		"UPDATE plan SET task = ? WHERE task = ?;",
+		"UPDATE OR IGNORE archived SET name = ? WHERE name = ?;",
+	} {
+		if _, err := tx.Exec(stmt, newName, task); err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "Error while renaming %s", task)
+		}
+	}
+	// Left over if the new name was archived already.
+	if _, err := tx.Exec("DELETE FROM archived WHERE name = ?;", task); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrapf(err, "Error while renaming %s", task)
+	}
+	return int(count), errors.Wrapf(tx.Commit(), "Error while renaming %s", task)
+}
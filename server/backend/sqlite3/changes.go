@@ -0,0 +1,186 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// The change log used for synchronization. Notes attached to entries are
+// stored as a JSON array.
+func (s *SQLite) setupChangeLog() error {
+	_, err := s.db.Exec(`
+CREATE TABLE IF NOT EXISTS change (
+	id TEXT PRIMARY KEY,
+	device TEXT NOT NULL,
+	time INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	task TEXT NOT NULL,
+	started INTEGER NOT NULL,
+	ended INTEGER NOT NULL,
+	notes TEXT NOT NULL);`)
+	return err
+}
+
+func (s *SQLite) RecordChange(change msg.Change) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error while recording change")
+	}
+	if _, err := insertChange(tx, change); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error while recording change")
+	}
+	return errors.Wrap(tx.Commit(), "Error while recording change")
+}
+
+func (s *SQLite) ApplyChange(change msg.Change) (bool, error) {
+	if s == nil {
+		return false, errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, errors.Wrap(err, "Error while applying change")
+	}
+	isNew, err := insertChange(tx, change)
+	if err == nil && isNew {
+		err = s.applyChange(tx, change)
+	}
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error while applying change %s", change.ID)
+	}
+	return isNew, errors.Wrap(tx.Commit(), "Error while applying change")
+}
+
+// Apply a change to the recorded data. Entries identical to an existing one
+// are skipped, notes are attached to the latest entry of the task started
+// before the note was taken. Removals remove the latest identical entry
+// along with its notes.
+func (s *SQLite) applyChange(tx *sql.Tx, change msg.Change) error {
+	task := change.Task
+	switch change.Kind {
+	case msg.ChangeEntry:
+		var exists bool
+		err := tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM task WHERE name = ? AND started = ? AND ended = ?);",
+			task.Name, stamp(task.Started), stamp(task.Ended)).Scan(&exists)
+		if err != nil || exists {
+			return err
+		}
+		return s.insertTask(tx, task)
+	case msg.ChangeNote:
+		var taskID int64
+		err := tx.QueryRow(
+			"SELECT rowid FROM task WHERE name = ? AND started <= ? ORDER BY started DESC LIMIT 1;",
+			task.Name, stamp(change.Time)).Scan(&taskID)
+		if err == sql.ErrNoRows {
+			// Nothing to attach the note to, keep it in the log only.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return s.insertNote(tx, taskID, change.Note)
+	case msg.ChangeRemoval:
+		var taskID int64
+		err := tx.QueryRow(
+			"SELECT rowid FROM task WHERE name = ? AND started = ? AND ended = ? ORDER BY rowid DESC LIMIT 1;",
+			task.Name, stamp(task.Started), stamp(task.Ended)).Scan(&taskID)
+		if err == sql.ErrNoRows {
+			// Removed already or never recorded here.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if s.fts {
+			if _, err := tx.Exec("DELETE FROM note_fts WHERE rowid IN (SELECT rowid FROM note WHERE task_id = ?);", taskID); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("DELETE FROM note WHERE task_id = ?;", taskID); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM task WHERE rowid = ?;", taskID)
+		return err
+	default:
+		return errors.Errorf("Unknown kind of change: %s", change.Kind)
+	}
+}
+
+// Add a change to the log unless it is already present. Returns whether it
+// was added.
+func insertChange(tx *sql.Tx, change msg.Change) (bool, error) {
+	notes := change.Task.Notes
+	if change.Kind == msg.ChangeNote {
+		notes = []string{change.Note}
+	}
+	encoded, err := json.Marshal(notes)
+	if err != nil {
+		return false, err
+	}
+	source, err := json.Marshal(change.Task.Source)
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`
+INSERT OR IGNORE INTO change (id, device, time, kind, task, started, ended, notes, source)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`,
+		change.ID, change.Device, stamp(change.Time), change.Kind,
+		change.Task.Name, stamp(change.Task.Started), stamp(change.Task.Ended),
+		string(encoded), string(source))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *SQLite) ForEachChange(fn func(msg.Change) error) error {
+	rows, err := s.db.Query(`
+SELECT id, device, time, kind, task, started, ended, notes, source FROM change
+ORDER BY rowid;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, device, kind, task, notes, source string
+		var changed, started, ended int64
+		if err := rows.Scan(&id, &device, &changed, &kind, &task, &started, &ended, &notes, &source); err != nil {
+			return err
+		}
+		change := msg.Change{
+			ID:     id,
+			Device: device,
+			Time:   fromStamp(changed),
+			Kind:   kind,
+			Task:   msg.Task{Name: task},
+		}
+		var decoded []string
+		if err := json.Unmarshal([]byte(notes), &decoded); err != nil {
+			return errors.Wrapf(err, "Invalid notes in change %s", id)
+		}
+		if kind == msg.ChangeNote {
+			if len(decoded) > 0 {
+				change.Note = decoded[0]
+			}
+		} else {
+			change.Task.Started = fromStamp(started)
+			change.Task.Ended = fromStamp(ended)
+			change.Task.HasEnded = true
+			change.Task.Notes = decoded
+			if err := json.Unmarshal([]byte(source), &change.Task.Source); err != nil {
+				return errors.Wrapf(err, "Invalid source in change %s", id)
+			}
+		}
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,36 @@
+package sqlite3
+
+import (
+	"database/sql/driver"
+	"strconv"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// Limit the connections to the database as configured. Connections dropped
+// by the driver are replaced by database/sql on the next query.
+func (s *SQLite) setupPool() error {
+	max, err := strconv.Atoi(s.conf.maxConnections.Value)
+	if err != nil || max < 0 {
+		return errors.Errorf("Invalid %s: %s", s.conf.maxConnections.InFile, s.conf.maxConnections.Value)
+	}
+	s.db.SetMaxOpenConns(max)
+	if max > 0 {
+		s.db.SetMaxIdleConns(max)
+	}
+	return nil
+}
+
+// IsTransient tells whether the error may go away when retrying: the database
+// was locked by another connection or process, or the connection dropped.
+func (s *SQLite) IsTransient(err error) bool {
+	err = errors.Cause(err)
+	if err == driver.ErrBadConn {
+		return true
+	}
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
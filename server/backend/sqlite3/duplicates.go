@@ -0,0 +1,113 @@
+package sqlite3
+
+import (
+	"database/sql"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+func (s *SQLite) RemoveDuplicates(dups []backend.Duplicate) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error while removing duplicates")
+	}
+	for _, dup := range dups {
+		if err := s.removeDuplicate(tx, dup); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error while removing %v", dup.Entry)
+		}
+	}
+	return errors.Wrap(tx.Commit(), "Error while removing duplicates")
+}
+
+func (s *SQLite) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error while replacing entries")
+	}
+	for _, task := range entries {
+		if err := s.insertTask(tx, task); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error while saving %v", task)
+		}
+	}
+	for _, dup := range dups {
+		if err := s.removeDuplicate(tx, dup); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error while removing %v", dup.Entry)
+		}
+	}
+	return errors.Wrap(tx.Commit(), "Error while replacing entries")
+}
+
+// Remove a single duplicate as part of a transaction. Of identical entries,
+// the first one recorded is kept.
+func (s *SQLite) removeDuplicate(tx *sql.Tx, dup backend.Duplicate) error {
+	keepID, err := entryID(tx, dup.Of, "ASC", -1)
+	if err != nil {
+		return err
+	}
+	removeID, err := entryID(tx, dup.Entry, "DESC", keepID)
+	if err != nil {
+		return err
+	}
+	// Notes already present on the kept entry are dropped, the others move.
+	rows, err := tx.Query(`
+SELECT rowid FROM note
+WHERE task_id = ?
+  AND text IN (SELECT text FROM note WHERE task_id = ?);`, removeID, keepID)
+	if err != nil {
+		return err
+	}
+	var dropped []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		dropped = append(dropped, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range dropped {
+		if _, err := tx.Exec("DELETE FROM note WHERE rowid = ?;", id); err != nil {
+			return err
+		}
+		if s.fts {
+			if _, err := tx.Exec("DELETE FROM note_fts WHERE rowid = ?;", id); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec("UPDATE note SET task_id = ? WHERE task_id = ?;", keepID, removeID); err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM task WHERE rowid = ?;", removeID)
+	return err
+}
+
+// The rowid of the first or last entry matching the task in the given order,
+// other than the excluded one.
+func entryID(tx *sql.Tx, task msg.Task, order string, exclude int64) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`
+SELECT rowid FROM task
+WHERE name = ? AND started = ? AND ended = ? AND rowid != ?
+ORDER BY rowid `+order+` LIMIT 1;`,
+		task.Name, stamp(task.Started), stamp(task.Ended), exclude).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.Errorf("No such entry: %v", task)
+	}
+	return id, err
+}
@@ -0,0 +1,59 @@
+package sqlite3
+
+// Encryption at rest relies on SQLCipher. The regular go-sqlite3 build links
+// a bundled SQLite without encryption support. To use an encrypted database,
+// link against SQLCipher instead, e.g.
+//
+//     CGO_CFLAGS="-I/usr/include/sqlcipher" CGO_LDFLAGS="-lsqlcipher" \
+//         go build -tags libsqlite3
+//
+// The key is taken from the db_key item or, preferably, from the output of
+// db_key_command, e.g. `secret-tool lookup tilo database` for a keyring.
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Determine the database key, if any.
+func (c *sqliteConf) key() (string, error) {
+	if c.dbKey.Value != "" {
+		return c.dbKey.Value, nil
+	}
+	if c.dbKeyCommand.Value == "" {
+		return "", nil
+	}
+	out, err := exec.Command("sh", "-c", c.dbKeyCommand.Value).Output()
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to obtain database key")
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", errors.New("Unable to obtain database key: command gave no output")
+	}
+	return key, nil
+}
+
+// Unlock an encrypted database. Does nothing if no key is configured.
+func (s *SQLite) unlock() error {
+	key, err := s.conf.key()
+	if err != nil || key == "" {
+		return err
+	}
+	// The key applies to a single connection only.
+	s.db.SetMaxOpenConns(1)
+	if _, err := s.db.Exec("PRAGMA key = '" + strings.Replace(key, "'", "''", -1) + "';"); err != nil {
+		return errors.Wrap(err, "Unable to unlock database")
+	}
+	// Without SQLCipher the key pragma is silently ignored.
+	var version string
+	if err := s.db.QueryRow("PRAGMA cipher_version;").Scan(&version); err != nil || version == "" {
+		return errors.New("Database key given but SQLite was built without SQLCipher support")
+	}
+	if _, err := s.db.Exec("SELECT count(*) FROM sqlite_master;"); err != nil {
+		return errors.Wrap(err, "Unable to unlock database, wrong key?")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package sqlite3
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Schema changes applied to existing databases, in order. The number of
+// applied migrations is kept in the user_version pragma.
+var migrations = []string{
+	// Origin of entries, see msg.Source
+	`ALTER TABLE task ADD COLUMN host TEXT NOT NULL DEFAULT '';
+ALTER TABLE task ADD COLUMN user TEXT NOT NULL DEFAULT '';
+ALTER TABLE task ADD COLUMN version TEXT NOT NULL DEFAULT '';
+ALTER TABLE change ADD COLUMN source TEXT NOT NULL DEFAULT '{}';`,
+	// Tasks hidden from completion and :all queries
+	`CREATE TABLE archived (name TEXT PRIMARY KEY);`,
+	// Integrity constraints on entries. SQLite cannot add them to an existing
+	// table, so it is rebuilt, keeping rowids as notes refer to them. Rows
+	// violating the constraints are kept in task_invalid for inspection.
+	`CREATE TABLE task_invalid AS
+	SELECT rowid AS id, name, started, ended, host, user, version FROM task
+	WHERE name IS NULL OR name = '' OR started IS NULL OR ended IS NULL OR ended < started;
+CREATE TABLE task_checked (
+	name TEXT NOT NULL CHECK (name != ''),
+	started INTEGER NOT NULL,
+	ended INTEGER NOT NULL CHECK (ended >= started),
+	host TEXT NOT NULL DEFAULT '',
+	user TEXT NOT NULL DEFAULT '',
+	version TEXT NOT NULL DEFAULT '');
+INSERT INTO task_checked (rowid, name, started, ended, host, user, version)
+	SELECT rowid, name, started, ended, host, user, version FROM task
+	WHERE rowid NOT IN (SELECT id FROM task_invalid);
+DROP TABLE task;
+ALTER TABLE task_checked RENAME TO task;
+CREATE INDEX task_name ON task (name);`,
+	// Times in milliseconds instead of seconds
+	`UPDATE task SET started = started * 1000, ended = ended * 1000;
+UPDATE task_invalid SET started = started * 1000, ended = ended * 1000;
+UPDATE event SET time = time * 1000;
+UPDATE change SET time = time * 1000, started = started * 1000, ended = ended * 1000;`,
+	// Planned entries, see msg.Plan
+	`CREATE TABLE plan (
+	id TEXT PRIMARY KEY,
+	task TEXT NOT NULL CHECK (task != ''),
+	title TEXT NOT NULL DEFAULT '',
+	started INTEGER NOT NULL,
+	ended INTEGER NOT NULL CHECK (ended >= started),
+	confirmed INTEGER NOT NULL DEFAULT 0);
+CREATE INDEX plan_started ON plan (started);`,
+}
+
+// Make sure a database opened without migrating it has an up to date schema,
+// as data would be misread otherwise.
+func (s *SQLite) checkVersion() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version;").Scan(&version); err != nil {
+		return errors.Wrap(err, "Unable to open database")
+	}
+	if version < len(migrations) {
+		return errors.New("The database needs to be upgraded, start the server once to do so")
+	}
+	return nil
+}
+
+// Bring the schema up to date.
+func (s *SQLite) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version;").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version]); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Migration %d failed", version+1)
+		}
+		// Pragmas do not accept parameters.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d;", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package sqlite3
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+func (s *SQLite) SavePlans(plans []msg.Plan) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Unable to save planned entries")
+	}
+	for _, p := range plans {
+		_, err := tx.Exec("INSERT OR REPLACE INTO plan (id, task, title, started, ended, confirmed) VALUES (?, ?, ?, ?, ?, ?);",
+			p.ID, p.Task, p.Title, stamp(p.Started), stamp(p.Ended), p.Confirmed)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Unable to save planned entry %s", p.ID)
+		}
+	}
+	return errors.Wrap(tx.Commit(), "Unable to save planned entries")
+}
+
+func (s *SQLite) GetPlansBetween(start time.Time, end time.Time) ([]msg.Plan, error) {
+	if s == nil {
+		return nil, errors.New("No backend present")
+	}
+	// Databases opened read-only may predate planning.
+	if exists, err := s.hasTable("plan"); err != nil || !exists {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+SELECT id, task, title, started, ended, confirmed FROM plan
+WHERE started >= ? AND started < ?
+ORDER BY started, id;`, stamp(start), stamp(end))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var plans []msg.Plan
+	for rows.Next() {
+		var p msg.Plan
+		var started, ended int64
+		if err := rows.Scan(&p.ID, &p.Task, &p.Title, &started, &ended, &p.Confirmed); err != nil {
+			return nil, err
+		}
+		p.Started, p.Ended = fromStamp(started), fromStamp(ended)
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+func (s *SQLite) RemovePlan(id string) (bool, error) {
+	if s == nil {
+		return false, errors.New("No backend present")
+	}
+	res, err := s.db.Exec("DELETE FROM plan WHERE id = ?;", id)
+	if err != nil {
+		return false, errors.Wrapf(err, "Unable to remove planned entry %s", id)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
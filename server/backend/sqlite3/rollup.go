@@ -0,0 +1,166 @@
+package sqlite3
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// With db_rollup set, the time spent per task and (UTC) day is kept in the
+// task_day table, allowing queries for whole days, months and years to be
+// answered without scanning all entries. Entries are clipped to each day they
+// overlap, as in GetTaskBetween. Triggers keep the table up to date however
+// entries are saved, changed or removed; without db_rollup, both are dropped
+// so as not to slow down writes.
+
+// The length of a day in the stored representation.
+const dayMillis = 24 * 60 * 60 * 1000
+
+// The same, spelled out as triggers take no parameters.
+const dayExpr = "86400000"
+
+// Entries spanning more days than this are only rolled up partly.
+const rollupMaxDays = 3660
+
+const rollupSchema = `
+CREATE TABLE task_day (
+	name TEXT NOT NULL,
+	day INTEGER NOT NULL,
+	total INTEGER NOT NULL,
+	first INTEGER NOT NULL,
+	last INTEGER NOT NULL,
+	PRIMARY KEY (name, day));
+CREATE INDEX task_day_day ON task_day (day);
+CREATE TABLE day_offset (n INTEGER PRIMARY KEY);`
+
+// Fill day_offset with the numbers up to the number given, then the rollup.
+const rollupFill = `
+WITH RECURSIVE seq(n) AS (SELECT 0 UNION ALL SELECT n + 1 FROM seq WHERE n + 1 < ?)
+INSERT INTO day_offset (n) SELECT n FROM seq;
+INSERT INTO task_day (name, day, total, first, last)
+SELECT name, day, total(min(ended, day + ` + dayExpr + `) - max(started, day)), max(min(started), day), min(max(ended), day + ` + dayExpr + `)
+FROM (
+	SELECT t.name, t.started, t.ended, t.started - t.started % ` + dayExpr + ` + o.n * ` + dayExpr + ` AS day
+	FROM task AS t JOIN day_offset AS o
+	ON o.n <= (t.ended - t.started + t.started % ` + dayExpr + ` - 1) / ` + dayExpr + `)
+WHERE day < ended
+GROUP BY name, day;`
+
+// Recompute the days overlapped by the entry, given as NEW or OLD.
+const rollupRefresh = `
+	DELETE FROM task_day WHERE name = ROW.name AND day >= ROW.started - ROW.started % ` + dayExpr + ` AND day < ROW.ended;
+	INSERT INTO task_day (name, day, total, first, last)
+	SELECT ROW.name, d.day, total(min(t.ended, d.day + ` + dayExpr + `) - max(t.started, d.day)), max(min(t.started), d.day), min(max(t.ended), d.day + ` + dayExpr + `)
+	FROM (
+		SELECT ROW.started - ROW.started % ` + dayExpr + ` + n * ` + dayExpr + ` AS day FROM day_offset
+		WHERE n <= (ROW.ended - ROW.started + ROW.started % ` + dayExpr + ` - 1) / ` + dayExpr + `) AS d
+	JOIN task AS t ON t.name = ROW.name AND t.started < d.day + ` + dayExpr + ` AND t.ended > d.day
+	WHERE d.day < ROW.ended
+	GROUP BY d.day;`
+
+var rollupTriggers = map[string]string{
+	"task_day_insert": "CREATE TRIGGER task_day_insert AFTER INSERT ON task BEGIN" +
+		strings.Replace(rollupRefresh, "ROW.", "NEW.", -1) + "\nEND;",
+	"task_day_delete": "CREATE TRIGGER task_day_delete AFTER DELETE ON task BEGIN" +
+		strings.Replace(rollupRefresh, "ROW.", "OLD.", -1) + "\nEND;",
+	"task_day_update": "CREATE TRIGGER task_day_update AFTER UPDATE OF name, started, ended ON task BEGIN" +
+		strings.Replace(rollupRefresh, "ROW.", "OLD.", -1) +
+		strings.Replace(rollupRefresh, "ROW.", "NEW.", -1) + "\nEND;",
+}
+
+// Whether the rollup is configured.
+func (c *sqliteConf) withRollup() bool {
+	return c.rollup.Value == "true"
+}
+
+// Build or drop the rollup as configured. It is rebuilt if incomplete, e.g.
+// after a migration rebuilt the task table, dropping its triggers.
+func (s *SQLite) setupRollup() error {
+	var present int
+	err := s.db.QueryRow(`
+SELECT count(*) FROM sqlite_master
+WHERE (type = 'table' AND name IN ('task_day', 'day_offset'))
+   OR (type = 'trigger' AND name LIKE 'task_day_%');`).Scan(&present)
+	if err != nil {
+		return err
+	}
+	complete := present == 2+len(rollupTriggers)
+	if complete == s.conf.withRollup() && (complete || present == 0) {
+		s.useRollup = complete
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	statements := []string{"DROP TABLE IF EXISTS task_day;", "DROP TABLE IF EXISTS day_offset;"}
+	for name := range rollupTriggers {
+		statements = append(statements, "DROP TRIGGER IF EXISTS "+name+";")
+	}
+	if s.conf.withRollup() {
+		statements = append(statements, rollupSchema)
+		for _, trigger := range rollupTriggers {
+			statements = append(statements, trigger)
+		}
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Unable to set up the rollup")
+		}
+	}
+	if s.conf.withRollup() {
+		if _, err := tx.Exec(rollupFill, rollupMaxDays); err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Unable to set up the rollup")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.useRollup = s.conf.withRollup()
+	return nil
+}
+
+// Whether the period can be answered from the rollup: it consists of whole
+// days and entries are not restricted to a source.
+func (s *SQLite) rollupCovers(start time.Time, end time.Time, source msg.Source) bool {
+	return s.useRollup && source.Host == "" && source.User == "" &&
+		stamp(start)%dayMillis == 0 && stamp(end)%dayMillis == 0
+}
+
+// GetTaskBetween for periods covered by the rollup.
+func (s *SQLite) getTaskFromRollup(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+	var duration, started, ended int64
+	err := s.db.QueryRow(`
+SELECT CAST(total(total) AS INTEGER), min(first), max(last) FROM task_day
+WHERE name = ? AND day >= ? AND day < ?
+GROUP BY name;`, task, stamp(start), stamp(end)).Scan(&duration, &started, &ended)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return []msg.Summary{msg.Summary{
+		Task:  task,
+		Total: time.Duration(duration) * time.Millisecond,
+		Start: fromStamp(started),
+		End:   fromStamp(ended),
+	}}, nil
+}
+
+// GetAllTasksBetween for periods covered by the rollup.
+func (s *SQLite) getAllTasksFromRollup(start time.Time, end time.Time) ([]msg.Summary, error) {
+	rows, err := s.db.Query(`
+SELECT name, CAST(total(total) AS INTEGER), min(first), max(last) FROM task_day
+WHERE day >= ? AND day < ?
+GROUP BY name;`, stamp(start), stamp(end))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return allTasksFromQuery(rows)
+}
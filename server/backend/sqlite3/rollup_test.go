@@ -0,0 +1,95 @@
+package sqlite3
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+)
+
+// Apply the same changes to backends with and without the rollup, expecting
+// the same answers for whole days.
+func TestRollupMatchesEntries(t *testing.T) {
+	plain, rolled := newTestBackend(t, false), newTestBackend(t, true)
+	if !rolled.useRollup {
+		t.Fatal("Rollup not set up")
+	}
+	dup := msg.Task{Name: "bar", Started: at(11, 0).AddDate(0, 0, 1), Ended: at(11, 40).AddDate(0, 0, 1), HasEnded: true}
+	for _, s := range []*SQLite{plain, rolled} {
+		// Crossing midnight UTC
+		saveTask(t, s, "foo", at(22, 0), at(2, 0).AddDate(0, 0, 1))
+		saveTask(t, s, "foo", at(9, 0).AddDate(0, 0, 1), at(10, 30).AddDate(0, 0, 1))
+		saveTask(t, s, "baz", at(8, 0).AddDate(0, 0, 2), at(9, 0).AddDate(0, 0, 2))
+		saveTask(t, s, dup.Name, dup.Started, dup.Ended)
+		saveTask(t, s, dup.Name, dup.Started, dup.Ended)
+
+		entries, err := s.RecentEntries([]string{"foo"}, 1)
+		if err != nil || len(entries) != 1 {
+			t.Fatal("No entry to update:", err)
+		}
+		if err := s.UpdateRecord(entries[0].ID, at(8, 0).AddDate(0, 0, 1), entries[0].Ended); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.RenameTask("baz", "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RemoveDuplicates([]backend.Duplicate{{Entry: dup, Of: dup}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := at(0, 0)
+	periods := [][2]time.Time{
+		{day, day.AddDate(0, 0, 1)},
+		{day.AddDate(0, 0, 1), day.AddDate(0, 0, 2)},
+		{day, day.AddDate(0, 0, 3)},
+	}
+	for _, p := range periods {
+		if !rolled.rollupCovers(p[0], p[1], msg.Source{}) {
+			t.Fatalf("Rollup does not cover %v to %v", p[0], p[1])
+		}
+		want, err := plain.GetAllTasksBetween(p[0], p[1], msg.Source{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := rolled.GetAllTasksBetween(p[0], p[1], msg.Source{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sortSummaries(want)
+		sortSummaries(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("From %v to %v: expected %v from the rollup, got %v", p[0], p[1], want, got)
+		}
+
+		want, err = plain.GetTaskBetween("foo", p[0], p[1], msg.Source{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = rolled.GetTaskBetween("foo", p[0], p[1], msg.Source{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("From %v to %v: expected %v from the rollup, got %v", p[0], p[1], want, got)
+		}
+	}
+
+	sums, err := rolled.GetTaskBetween("foo", periods[1][0], periods[1][1], msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "Rollup", sums, 270*time.Minute)
+	sums, err = rolled.GetTaskBetween("bar", periods[1][0], periods[1][1], msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "Rollup without duplicates", sums, 40*time.Minute)
+}
+
+func sortSummaries(sums []msg.Summary) {
+	sort.Slice(sums, func(i, j int) bool { return sums[i].Task < sums[j].Task })
+}
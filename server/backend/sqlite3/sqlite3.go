@@ -1,16 +1,20 @@
 // SQLite3 backend for the tilo server.
 //
 // Each record has two timestamps, "started" and "ended". They are saved as
-// Unix time stamps because some arithmetic is performed on them which is
-// cumbersome when storing timestamps as strings.
+// milliseconds since the Unix epoch because some arithmetic is performed on
+// them which is cumbersome when storing timestamps as strings. The same holds
+// for all other points in time stored.
 package sqlite3
 
 import (
 	"database/sql"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/fgahr/tilo/argparse/quantifier"
 	"github.com/fgahr/tilo/command/query"
 	"github.com/fgahr/tilo/config"
 	"github.com/fgahr/tilo/msg"
@@ -29,7 +33,11 @@ func init() {
 }
 
 type sqliteConf struct {
-	dbFile config.Item
+	dbFile         config.Item
+	dbKey          config.Item
+	dbKeyCommand   config.Item
+	maxConnections config.Item
+	rollup         config.Item
 }
 
 func defaultConf() sqliteConf {
@@ -42,7 +50,34 @@ func defaultConf() sqliteConf {
 		InEnv:  "DB_FILE",
 		Value:  fileDefault,
 	}
-	return sqliteConf{dbFile: dbFile}
+	// Encryption requires SQLite built with SQLCipher, see encryption.go
+	dbKey := config.Item{
+		InFile: "db_key",
+		InArgs: "db-key",
+		InEnv:  "DB_KEY",
+		Value:  "",
+	}
+	dbKeyCommand := config.Item{
+		InFile: "db_key_command",
+		InArgs: "db-key-command",
+		InEnv:  "DB_KEY_COMMAND",
+		Value:  "",
+	}
+	// At most this many connections are opened at once; 0 for no limit.
+	maxConnections := config.Item{
+		InFile: "db_max_connections",
+		InArgs: "db-max-connections",
+		InEnv:  "DB_MAX_CONNECTIONS",
+		Value:  "0",
+	}
+	// Keep daily totals per task for large histories, see rollup.go
+	rollup := config.Item{
+		InFile: "db_rollup",
+		InArgs: "db-rollup",
+		InEnv:  "DB_ROLLUP",
+		Value:  "false",
+	}
+	return sqliteConf{dbFile: dbFile, dbKey: dbKey, dbKeyCommand: dbKeyCommand, maxConnections: maxConnections, rollup: rollup}
 }
 
 func (c *sqliteConf) BackendName() string {
@@ -50,12 +85,25 @@ func (c *sqliteConf) BackendName() string {
 }
 
 func (c *sqliteConf) AcceptedItems() []*config.Item {
-	return []*config.Item{&c.dbFile}
+	return []*config.Item{&c.dbFile, &c.dbKey, &c.dbKeyCommand, &c.maxConnections, &c.rollup}
 }
 
 type SQLite struct {
 	conf sqliteConf
 	db   *sql.DB
+	fts  bool // Whether full-text search is available
+	// Whether totals of whole days are taken from the rollup
+	useRollup bool
+}
+
+// The stored representation of a point in time.
+func stamp(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+// The point in time with the stored representation.
+func fromStamp(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
 }
 
 func (s *SQLite) Config() config.BackendConfig {
@@ -75,6 +123,12 @@ func (s *SQLite) Init() error {
 		return errors.Wrap(err, "Unable to establish database connection")
 	}
 	s.db = db
+	if err := s.setupPool(); err != nil {
+		return err
+	}
+	if err := s.unlock(); err != nil {
+		return err
+	}
 	// Setup schema
 	_, err = s.db.Exec(`
 CREATE TABLE IF NOT EXISTS task (
@@ -87,7 +141,114 @@ CREATE TABLE IF NOT EXISTS task (
 
 	_, err = s.db.Exec(
 		"CREATE INDEX IF NOT EXISTS task_name ON task (name);")
-	return errors.Wrap(err, "Unable to setup database")
+	if err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	_, err = s.db.Exec(`
+CREATE TABLE IF NOT EXISTS event (
+	type TEXT NOT NULL,
+	task TEXT NOT NULL,
+	time INTEGER NOT NULL);`)
+	if err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	_, err = s.db.Exec(
+		"CREATE INDEX IF NOT EXISTS event_time ON event (time);")
+	if err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	// Notes refer to the rowid of the annotated task.
+	_, err = s.db.Exec(`
+CREATE TABLE IF NOT EXISTS note (
+	task_id INTEGER NOT NULL,
+	text TEXT NOT NULL);`)
+	if err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	_, err = s.db.Exec(
+		"CREATE INDEX IF NOT EXISTS note_task ON note (task_id);")
+	if err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	if err := s.setupChangeLog(); err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	if err := s.migrate(); err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	if err := s.setupRollup(); err != nil {
+		return errors.Wrap(err, "Unable to setup database")
+	}
+
+	return errors.Wrap(s.setupFullTextSearch(), "Unable to setup database")
+}
+
+// Set up the full-text index for notes. Not all SQLite builds include the
+// FTS5 extension; without it, searches fall back to scanning the notes.
+func (s *SQLite) setupFullTextSearch() error {
+	exists, err := s.hasTable("note_fts")
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.fts = true
+		return nil
+	}
+	if _, err := s.db.Exec("CREATE VIRTUAL TABLE note_fts USING fts5(text);"); err != nil {
+		// FTS5 not compiled in
+		s.fts = false
+		return nil
+	}
+	s.fts = true
+	// Index notes created before the index existed.
+	_, err = s.db.Exec("INSERT INTO note_fts (rowid, text) SELECT rowid, text FROM note;")
+	return err
+}
+
+// Whether a table with the given name exists.
+func (s *SQLite) hasTable(name string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(
+		"SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?;", name).Scan(&count)
+	return count > 0, err
+}
+
+// Open an existing database without setting up or modifying anything.
+func (s *SQLite) InitReadOnly() error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	if _, err := os.Stat(s.conf.dbFile.Value); err != nil {
+		return errors.Wrap(err, "Unable to open database")
+	}
+	db, err := sql.Open("sqlite3", "file:"+s.conf.dbFile.Value+"?mode=ro")
+	if err != nil {
+		return errors.Wrap(err, "Unable to establish database connection")
+	}
+	s.db = db
+	if err := s.setupPool(); err != nil {
+		return err
+	}
+	if err := s.unlock(); err != nil {
+		return err
+	}
+	if err := s.checkVersion(); err != nil {
+		return err
+	}
+	s.fts, err = s.hasTable("note_fts")
+	if err != nil {
+		return errors.Wrap(err, "Unable to open database")
+	}
+	rollup, err := s.hasTable("task_day")
+	s.useRollup = rollup && s.conf.withRollup()
+	return errors.Wrap(err, "Unable to open database")
 }
 
 func (s *SQLite) Close() error {
@@ -97,6 +258,18 @@ func (s *SQLite) Close() error {
 	return s.db.Close()
 }
 
+// Ping checks that the database answers queries.
+func (s *SQLite) Ping() error {
+	var one int
+	return s.db.QueryRow("SELECT 1;").Scan(&one)
+}
+
+// Copy the database using VACUUM INTO, requiring SQLite 3.27 or newer.
+func (s *SQLite) Snapshot(path string) error {
+	_, err := s.db.Exec("VACUUM INTO ?;", path)
+	return err
+}
+
 func (s *SQLite) Save(task msg.Task) error {
 	if s == nil {
 		return errors.New("No backend present")
@@ -104,10 +277,103 @@ func (s *SQLite) Save(task msg.Task) error {
 	if task.IsRunning() {
 		panic("Cannot save an active task.")
 	}
-	_, err := s.db.Exec(
-		"INSERT INTO task (name, started, ended) VALUES (?, ?, ?);",
-		task.Name, task.Started.Unix(), task.Ended.Unix())
-	return errors.Wrapf(err, "Error while saving %v", task)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "Error while saving %v", task)
+	}
+	if err := s.insertTask(tx, task); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error while saving %v", task)
+	}
+	return errors.Wrapf(tx.Commit(), "Error while saving %v", task)
+}
+
+// Insert the task and its notes as part of a transaction.
+func (s *SQLite) insertTask(tx *sql.Tx, task msg.Task) error {
+	res, err := tx.Exec(
+		"INSERT INTO task (name, started, ended, host, user, version) VALUES (?, ?, ?, ?, ?, ?);",
+		task.Name, stamp(task.Started), stamp(task.Ended),
+		task.Source.Host, task.Source.User, task.Source.Version)
+	if err != nil {
+		return err
+	}
+	if len(task.Notes) == 0 {
+		return nil
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, note := range task.Notes {
+		if err := s.insertNote(tx, id, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert a note for the task with the given id, updating the search index.
+func (s *SQLite) insertNote(tx *sql.Tx, taskID int64, note string) error {
+	res, err := tx.Exec("INSERT INTO note (task_id, text) VALUES (?, ?);", taskID, note)
+	if err != nil || !s.fts {
+		return err
+	}
+	noteID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO note_fts (rowid, text) VALUES (?, ?);", noteID, note)
+	return err
+}
+
+func (s *SQLite) AddNote(task string, note string) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	var taskID int64
+	err := s.db.QueryRow(
+		"SELECT rowid FROM task WHERE name = ? ORDER BY ended DESC LIMIT 1;", task).Scan(&taskID)
+	if err == sql.ErrNoRows {
+		return errors.Errorf("No recorded entry for task %s", task)
+	} else if err != nil {
+		return errors.Wrapf(err, "Error while adding note to %s", task)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "Error while adding note to %s", task)
+	}
+	if err := s.insertNote(tx, taskID, note); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error while adding note to %s", task)
+	}
+	return errors.Wrapf(tx.Commit(), "Error while adding note to %s", task)
+}
+
+// List the notes attached to entries of a task between start and end.
+func (s *SQLite) GetNotesBetween(task string, start time.Time, end time.Time) ([]msg.Note, error) {
+	rows, err := s.db.Query(`
+SELECT task.name, task.started, note.text FROM note
+JOIN task ON note.task_id = task.rowid
+WHERE (task.name = ? OR ? = ?)
+  AND task.started < ?
+  AND task.ended > ?
+ORDER BY task.started, note.rowid;`,
+		task, task, query.TskAllTasks, stamp(end), stamp(start))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []msg.Note
+	for rows.Next() {
+		var note msg.Note
+		var started int64
+		if err := rows.Scan(&note.Task, &started, &note.Text); err != nil {
+			return result, err
+		}
+		note.Time = fromStamp(started)
+		result = append(result, note)
+	}
+	return result, rows.Err()
 }
 
 func allTasksFromQuery(rows *sql.Rows) ([]msg.Summary, error) {
@@ -121,9 +387,9 @@ func allTasksFromQuery(rows *sql.Rows) ([]msg.Summary, error) {
 		}
 		taskSummary := msg.Summary{
 			Task:  taskName,
-			Total: time.Duration(duration * int64(time.Second/time.Nanosecond)),
-			Start: time.Unix(started, 0),
-			End:   time.Unix(ended, 0),
+			Total: time.Duration(duration) * time.Millisecond,
+			Start: fromStamp(started),
+			End:   fromStamp(ended),
 		}
 		result = append(result, taskSummary)
 	}
@@ -144,20 +410,126 @@ LIMIT ?;
 	return allTasksFromQuery(rows)
 }
 
-// Query the total time spent on a task between start and end.
-func (s *SQLite) GetTaskBetween(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+// Entries are identified by their rowid.
+func (s *SQLite) RecentEntries(tasks []string, maxNumber int) ([]msg.Entry, error) {
+	filter := ""
+	var args []interface{}
+	if len(tasks) > 0 {
+		filter = "\nWHERE name IN (?" + strings.Repeat(", ?", len(tasks)-1) + ")"
+		for _, task := range tasks {
+			args = append(args, task)
+		}
+	}
+	rows, err := s.db.Query(`
+SELECT recent.id, recent.name, recent.started, recent.ended, recent.host, recent.user, recent.version, note.text FROM (
+  SELECT rowid AS id, name, started, ended, host, user, version FROM task`+filter+`
+  ORDER BY ended DESC, rowid DESC
+  LIMIT ?
+) AS recent
+LEFT JOIN note ON note.task_id = recent.id
+ORDER BY recent.ended DESC, recent.id DESC, note.rowid;`, append(args, maxNumber)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []msg.Entry
+	// Notes result in several rows per entry.
+	for rows.Next() {
+		var id, started, ended int64
+		var name string
+		var source msg.Source
+		var note sql.NullString
+		if err := rows.Scan(&id, &name, &started, &ended, &source.Host, &source.User, &source.Version, &note); err != nil {
+			return nil, err
+		}
+		entryID := strconv.FormatInt(id, 10)
+		if len(result) == 0 || result[len(result)-1].ID != entryID {
+			result = append(result, msg.Entry{ID: entryID, Task: msg.Task{
+				Name:     name,
+				Started:  fromStamp(started),
+				Ended:    fromStamp(ended),
+				HasEnded: true,
+				Source:   source,
+			}})
+		}
+		if note.Valid {
+			last := &result[len(result)-1]
+			last.Notes = append(last.Notes, note.String)
+		}
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLite) GetRecord(id string) (msg.Entry, error) {
+	rowid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return msg.Entry{}, errors.Errorf("No entry with ID %s", id)
+	}
+	rows, err := s.db.Query(`
+SELECT task.name, task.started, task.ended, task.host, task.user, task.version, note.text FROM task
+LEFT JOIN note ON note.task_id = task.rowid
+WHERE task.rowid = ?
+ORDER BY note.rowid;`, rowid)
+	if err != nil {
+		return msg.Entry{}, err
+	}
+	defer rows.Close()
+	entry := msg.Entry{ID: id}
+	found := false
+	for rows.Next() {
+		var started, ended int64
+		var note sql.NullString
+		if err := rows.Scan(&entry.Name, &started, &ended, &entry.Source.Host, &entry.Source.User, &entry.Source.Version, &note); err != nil {
+			return msg.Entry{}, err
+		}
+		entry.Started, entry.Ended, entry.HasEnded = fromStamp(started), fromStamp(ended), true
+		if note.Valid {
+			entry.Notes = append(entry.Notes, note.String)
+		}
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return msg.Entry{}, err
+	}
+	if !found {
+		return msg.Entry{}, errors.Errorf("No entry with ID %s", id)
+	}
+	return entry, nil
+}
+
+func (s *SQLite) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	rowid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return errors.Errorf("No entry with ID %s", id)
+	}
+	result, err := s.db.Exec("UPDATE task SET started = ?, ended = ? WHERE rowid = ?;", stamp(started), stamp(ended), rowid)
+	if err != nil {
+		return errors.Wrapf(err, "Error while updating entry %s", id)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errors.Errorf("No entry with ID %s", id)
+	}
+	return nil
+}
+
+// Query the total time spent on a task between start and end. Entries
+// overlapping either end of the period are clipped to it.
+func (s *SQLite) GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) ([]msg.Summary, error) {
 	if task == query.TskAllTasks {
-		return s.GetAllTasksBetween(start, end)
+		return s.GetAllTasksBetween(start, end, source)
+	}
+	if s.rollupCovers(start, end, source) {
+		return s.getTaskFromRollup(task, start, end)
 	}
 	// NOTE: total() is a non-standard function present in SQLite which is
 	// superior to sum() in terms of NULL-handling
 	rows, err := s.db.Query(`
-SELECT total(ended - started), min(started), max(ended) FROM task
-WHERE name = ?
-  AND started >= ?
-  AND ended < ?
+SELECT `+clippedColumns+` FROM task
+WHERE name = ?`+overlapCondition+sourceCondition+`
 GROUP BY name;`,
-		task, start.Unix(), end.Unix())
+		append(append(clippedArgs(start, end), task), append(overlapArgs(start, end), sourceArgs(source)...)...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,26 +542,308 @@ GROUP BY name;`,
 		}
 		return []msg.Summary{msg.Summary{
 			Task:  task,
-			Total: time.Duration(duration * int64(time.Second/time.Nanosecond)),
-			Start: time.Unix(started, 0),
-			End:   time.Unix(ended, 0),
+			Total: time.Duration(duration) * time.Millisecond,
+			Start: fromStamp(started),
+			End:   fromStamp(ended),
 		}}, nil
 	}
 
 	return nil, rows.Err()
 }
 
-// Query the total time spent on all tasks between start and end.
-func (s *SQLite) GetAllTasksBetween(start, end time.Time) ([]msg.Summary, error) {
+// Query the total time spent on all tasks between start and end, clipping
+// entries like GetTaskBetween.
+func (s *SQLite) GetAllTasksBetween(start, end time.Time, source msg.Source) ([]msg.Summary, error) {
+	if s.rollupCovers(start, end, source) {
+		return s.getAllTasksFromRollup(start, end)
+	}
 	rows, err := s.db.Query(`
-SELECT name, total(ended-started), min(started), max(ended) FROM task
-WHERE started >= ?
-  AND ended < ?
+SELECT name, `+clippedColumns+` FROM task
+WHERE 1`+overlapCondition+sourceCondition+`
 GROUP BY name;`,
-		start.Unix(), end.Unix())
+		append(append(clippedArgs(start, end), overlapArgs(start, end)...), sourceArgs(source)...)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	return allTasksFromQuery(rows)
 }
+
+// Query the time spent on a task per day between start and end, clipping
+// entries like GetTaskBetween.
+func (s *SQLite) GetDailyTotals(task string, start time.Time, end time.Time) ([]msg.Summary, error) {
+	args := append([]interface{}{stamp(start)}, clippedArgs(start, end)...)
+	args = append(append(args, task, task, query.TskAllTasks), overlapArgs(start, end)...)
+	rows, err := s.db.Query(`
+SELECT date(max(started, ?) / 1000, 'unixepoch', 'localtime') AS day, `+clippedColumns+` FROM task
+WHERE (name = ? OR ? = ?)`+overlapCondition+`
+GROUP BY day
+ORDER BY day;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []msg.Summary
+	for rows.Next() {
+		var day string
+		var duration, started, ended int64
+		if err := rows.Scan(&day, &duration, &started, &ended); err != nil {
+			return result, err
+		}
+		result = append(result, msg.Summary{
+			Task:    task,
+			Details: msg.Quantity{Type: quantifier.TimeDay, Elems: []string{day}},
+			Total:   time.Duration(duration) * time.Millisecond,
+			Start:   fromStamp(started),
+			End:     fromStamp(ended),
+		})
+	}
+	return result, rows.Err()
+}
+
+// Count the entries between start and end and sum up their durations,
+// clipping entries like GetTaskBetween.
+func (s *SQLite) CountEntriesBetween(start time.Time, end time.Time) (int, time.Duration, error) {
+	var count int
+	var duration int64
+	err := s.db.QueryRow(`
+SELECT count(*), CAST(total(min(ended, ?) - max(started, ?)) AS INTEGER) FROM task
+WHERE 1`+overlapCondition+`;`,
+		append([]interface{}{stamp(end), stamp(start)}, overlapArgs(start, end)...)...).Scan(&count, &duration)
+	return count, time.Duration(duration) * time.Millisecond, err
+}
+
+// Distribute the time spent on the tasks between start and end over the
+// hours of the week, clipping entries like GetTaskBetween.
+func (s *SQLite) GetWeekHours(tasks []string, start time.Time, end time.Time) (backend.WeekHours, error) {
+	var result backend.WeekHours
+	query := `
+SELECT max(started, ?), min(ended, ?) FROM task
+WHERE 1` + overlapCondition
+	args := append([]interface{}{stamp(start), stamp(end)}, overlapArgs(start, end)...)
+	if len(tasks) > 0 {
+		query += "\n  AND name IN (?" + strings.Repeat(", ?", len(tasks)-1) + ")"
+		for _, task := range tasks {
+			args = append(args, task)
+		}
+	}
+	rows, err := s.db.Query(query+";", args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var started, ended int64
+		if err := rows.Scan(&started, &ended); err != nil {
+			return result, err
+		}
+		result.Add(fromStamp(started), fromStamp(ended))
+	}
+	return result, rows.Err()
+}
+
+// Restricts entries to those overlapping a period, see overlapArgs. Entries
+// merely touching it, e.g. ending at its start, are left out.
+const overlapCondition = `
+  AND started < ?
+  AND ended > ?`
+
+func overlapArgs(start time.Time, end time.Time) []interface{} {
+	return []interface{}{stamp(end), stamp(start)}
+}
+
+// The total time, first start and last end of entries, clipped to a period,
+// see clippedArgs. total() yields a REAL which is cast for scanning.
+const clippedColumns = `CAST(total(min(ended, ?) - max(started, ?)) AS INTEGER), max(min(started), ?), min(max(ended), ?)`
+
+func clippedArgs(start time.Time, end time.Time) []interface{} {
+	return []interface{}{stamp(end), stamp(start), stamp(start), stamp(end)}
+}
+
+// Restricts entries to a source; empty fields match any value.
+const sourceCondition = `
+  AND (? = '' OR host = ?)
+  AND (? = '' OR user = ?)`
+
+func sourceArgs(source msg.Source) []interface{} {
+	return []interface{}{source.Host, source.Host, source.User, source.User}
+}
+
+func (s *SQLite) SaveEvent(entry msg.LogEntry) error {
+	if s == nil {
+		return errors.New("No backend present")
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO event (type, task, time) VALUES (?, ?, ?);",
+		entry.Type, entry.Task, stamp(entry.Time))
+	return errors.Wrapf(err, "Error while saving event %v", entry)
+}
+
+// List the events between start and end, optionally restricted to some tasks.
+func (s *SQLite) GetEventsBetween(tasks []string, start time.Time, end time.Time) ([]msg.LogEntry, error) {
+	query := `
+SELECT type, task, time FROM event
+WHERE time >= ?
+  AND time < ?`
+	args := []interface{}{stamp(start), stamp(end)}
+	if len(tasks) > 0 {
+		query += "\n  AND task IN (?" + strings.Repeat(", ?", len(tasks)-1) + ")"
+		for _, task := range tasks {
+			args = append(args, task)
+		}
+	}
+	rows, err := s.db.Query(query+"\nORDER BY time, rowid;", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []msg.LogEntry
+	for rows.Next() {
+		var entry msg.LogEntry
+		var t int64
+		if err := rows.Scan(&entry.Type, &entry.Task, &t); err != nil {
+			return result, err
+		}
+		entry.Time = fromStamp(t)
+		result = append(result, entry)
+	}
+	return result, rows.Err()
+}
+
+// Find entries whose task name or notes contain the search term.
+func (s *SQLite) Search(term string) ([]msg.Task, error) {
+	noteMatch := "SELECT task_id FROM note WHERE text LIKE ? ESCAPE '\\'"
+	noteArg := likePattern(term)
+	if s.fts {
+		noteMatch = "SELECT note.task_id FROM note_fts JOIN note ON note.rowid = note_fts.rowid WHERE note_fts MATCH ?"
+		// Search for the term as a phrase to avoid interpretation of operators.
+		noteArg = `"` + strings.Replace(term, `"`, `""`, -1) + `"`
+	}
+	rows, err := s.db.Query(`
+SELECT task.rowid, task.name, task.started, task.ended, task.host, task.user, task.version, note.text FROM task
+LEFT JOIN note ON note.task_id = task.rowid
+WHERE task.name LIKE ? ESCAPE '\'
+   OR task.rowid IN (`+noteMatch+`)
+ORDER BY task.started, note.rowid;`, likePattern(term), noteArg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []msg.Task
+	lastID := int64(-1)
+	for rows.Next() {
+		var id, started, ended int64
+		var name string
+		var source msg.Source
+		var note sql.NullString
+		if err := rows.Scan(&id, &name, &started, &ended, &source.Host, &source.User, &source.Version, &note); err != nil {
+			return result, err
+		}
+		if id != lastID {
+			result = append(result, msg.Task{
+				Name:     name,
+				Started:  fromStamp(started),
+				Ended:    fromStamp(ended),
+				HasEnded: true,
+			})
+			lastID = id
+		}
+		if note.Valid {
+			last := &result[len(result)-1]
+			last.Notes = append(last.Notes, note.String)
+		}
+	}
+	return result, rows.Err()
+}
+
+// A LIKE pattern matching strings containing the term.
+func likePattern(term string) string {
+	return "%" + escapeLike(term) + "%"
+}
+
+// Escape wildcards in a LIKE pattern, using backslash as the escape character.
+func escapeLike(term string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return escaper.Replace(term)
+}
+
+// Task names with the prefix, ordered by the latest entry.
+func (s *SQLite) TaskNames(prefix string, limit int) ([]string, error) {
+	rows, err := s.db.Query(`
+SELECT name FROM task
+WHERE name LIKE ? ESCAPE '\'
+GROUP BY name
+ORDER BY max(ended) DESC
+LIMIT ?;`, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Iterate over the entries between start and end without gathering them,
+// clipping entries like GetTaskBetween.
+func (s *SQLite) ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error {
+	query := `
+SELECT task.rowid, task.name, max(task.started, ?), min(task.ended, ?), task.host, task.user, task.version, note.text FROM task
+LEFT JOIN note ON note.task_id = task.rowid
+WHERE 1` + overlapCondition
+	args := append([]interface{}{stamp(start), stamp(end)}, overlapArgs(start, end)...)
+	if len(tasks) > 0 {
+		query += "\n  AND task.name IN (?" + strings.Repeat(", ?", len(tasks)-1) + ")"
+		for _, task := range tasks {
+			args = append(args, task)
+		}
+	}
+	rows, err := s.db.Query(query+"\nORDER BY task.started, task.rowid, note.rowid;", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	// Notes result in several rows per task, so it is passed on once complete.
+	var current msg.Task
+	currentID := int64(-1)
+	for rows.Next() {
+		var id, started, ended int64
+		var name string
+		var source msg.Source
+		var note sql.NullString
+		if err := rows.Scan(&id, &name, &started, &ended, &source.Host, &source.User, &source.Version, &note); err != nil {
+			return err
+		}
+		if id != currentID {
+			if currentID >= 0 {
+				if err := fn(current); err != nil {
+					return err
+				}
+			}
+			current = msg.Task{
+				Name:     name,
+				Started:  fromStamp(started),
+				Ended:    fromStamp(ended),
+				HasEnded: true,
+				Source:   source,
+			}
+			currentID = id
+		}
+		if note.Valid {
+			current.Notes = append(current.Notes, note.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if currentID >= 0 {
+		return fn(current)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package sqlite3
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+)
+
+func newTestBackend(t *testing.T, rollup bool) *SQLite {
+	s := &SQLite{conf: defaultConf()}
+	s.conf.dbFile.Value = filepath.Join(t.TempDir(), "tilo.db")
+	if rollup {
+		s.conf.rollup.Value = "true"
+	}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func at(hour, min int) time.Time {
+	return time.Date(2020, time.March, 4, hour, min, 0, 0, time.UTC)
+}
+
+func saveTask(t *testing.T, s *SQLite, name string, started, ended time.Time) {
+	task := msg.Task{Name: name, Started: started, Ended: ended, HasEnded: true}
+	if err := s.Save(task); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectTotal(t *testing.T, what string, sums []msg.Summary, expected time.Duration) {
+	var total time.Duration
+	for _, sum := range sums {
+		total += sum.Total
+	}
+	if total != expected {
+		t.Errorf("%s: expected a total of %v, got %v", what, expected, total)
+	}
+}
+
+// Totals are summed up as REAL values by SQLite, large ones are written in
+// exponential notation unless cast.
+func TestLongEntries(t *testing.T) {
+	s := newTestBackend(t, false)
+	saveTask(t, s, "foo", at(9, 0), at(10, 0))
+	saveTask(t, s, "foo", at(10, 30), at(10, 50))
+	saveTask(t, s, "bar", at(11, 0), at(13, 30))
+
+	start, end := at(0, 0), at(0, 0).AddDate(0, 0, 1)
+	sums, err := s.GetTaskBetween("foo", start, end, msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "GetTaskBetween", sums, 80*time.Minute)
+
+	sums, err = s.GetAllTasksBetween(start, end, msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "GetAllTasksBetween", sums, 230*time.Minute)
+
+	sums, err = s.GetDailyTotals("foo", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "GetDailyTotals", sums, 80*time.Minute)
+
+	// Clipped to the period
+	sums, err = s.GetTaskBetween("bar", at(12, 0), end, msg.Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTotal(t, "GetTaskBetween, clipped", sums, 90*time.Minute)
+
+	count, total, err := s.CountEntriesBetween(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || total != 230*time.Minute {
+		t.Errorf("CountEntriesBetween: expected 3 entries and 3h50m0s, got %d and %v", count, total)
+	}
+}
+
+func TestForEachTaskBetweenClips(t *testing.T) {
+	s := newTestBackend(t, false)
+	saveTask(t, s, "foo", at(8, 0), at(9, 30))
+	saveTask(t, s, "foo", at(10, 0), at(11, 0))
+	saveTask(t, s, "foo", at(11, 30), at(13, 0))
+	saveTask(t, s, "foo", at(13, 0), at(14, 0))
+
+	var got []msg.Task
+	err := s.ForEachTaskBetween(nil, at(9, 0), at(12, 0), func(task msg.Task) error {
+		got = append(got, task)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]time.Time{{at(9, 0), at(9, 30)}, {at(10, 0), at(11, 0)}, {at(11, 30), at(12, 0)}}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d entries, got %v", len(expected), got)
+	}
+	for i, task := range got {
+		if !task.Started.Equal(expected[i][0]) || !task.Ended.Equal(expected[i][1]) {
+			t.Errorf("Expected entry %d from %v to %v, got %v to %v",
+				i, expected[i][0], expected[i][1], task.Started, task.Ended)
+		}
+	}
+}
+
+func TestApplyRemoval(t *testing.T) {
+	s := newTestBackend(t, false)
+	task := msg.Task{Name: "foo", Started: at(9, 0), Ended: at(10, 0), HasEnded: true, Notes: []string{"bar"}}
+	for _, kind := range []string{msg.ChangeEntry, msg.ChangeRemoval} {
+		if _, err := s.ApplyChange(msg.NewChange("other", kind, task)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, _, err := s.CountEntriesBetween(at(0, 0), at(23, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("Expected the entry to be removed, %d left", count)
+	}
+	notes, err := s.GetNotesBetween("foo", at(0, 0), at(23, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("Expected the notes to be removed, got %v", notes)
+	}
+}
+
+func TestReplaceEntries(t *testing.T) {
+	s := newTestBackend(t, false)
+	first := msg.Task{Name: "foo", Started: at(9, 0), Ended: at(10, 0), HasEnded: true, Notes: []string{"bar"}}
+	second := msg.Task{Name: "foo", Started: at(9, 30), Ended: at(9, 45), HasEnded: true, Notes: []string{"baz"}}
+	for _, task := range []msg.Task{first, second} {
+		if err := s.Save(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Identical to the first entry
+	merged := msg.Task{Name: "foo", Started: at(9, 0), Ended: at(10, 0), HasEnded: true, Notes: []string{"bar", "baz"}}
+	dups := []backend.Duplicate{{Entry: first, Of: merged}, {Entry: second, Of: merged}}
+	if err := s.ReplaceEntries([]msg.Task{merged}, dups); err != nil {
+		t.Fatal(err)
+	}
+	count, total, err := s.CountEntriesBetween(at(0, 0), at(23, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || total != time.Hour {
+		t.Errorf("Expected a single entry of 1h0m0s, got %d and %v", count, total)
+	}
+	notes, err := s.GetNotesBetween("foo", at(0, 0), at(23, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("Expected both notes kept once, got %v", notes)
+	}
+
+	// Nothing changes if an entry is missing.
+	missing := msg.Task{Name: "foo", Started: at(11, 0), Ended: at(12, 0), HasEnded: true}
+	if err := s.ReplaceEntries([]msg.Task{missing}, []backend.Duplicate{{Entry: missing, Of: missing}}); err == nil {
+		t.Error("Expected an error when removing a missing entry")
+	}
+	if count, _, _ := s.CountEntriesBetween(at(0, 0), at(23, 0)); count != 1 {
+		t.Errorf("Expected the replacement to be rolled back, %d entries present", count)
+	}
+}
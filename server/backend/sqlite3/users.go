@@ -0,0 +1,42 @@
+package sqlite3
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// The directory holding one database per user of a shared server, next to
+// the server owner's database.
+func (s *SQLite) usersDir() string {
+	return filepath.Join(filepath.Dir(s.conf.dbFile.Value), "users")
+}
+
+// ForUser gives a backend using the user's own database file. Encryption
+// settings are shared with the server owner's database.
+func (s *SQLite) ForUser(user string) (backend.Backend, error) {
+	if err := os.MkdirAll(s.usersDir(), 0700); err != nil {
+		return nil, errors.Wrap(err, "Unable to create user database directory")
+	}
+	conf := s.conf
+	conf.dbFile.Value = filepath.Join(s.usersDir(), user+".db")
+	return &SQLite{conf: conf}, nil
+}
+
+// Users lists the users with a database of their own.
+func (s *SQLite) Users() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.usersDir(), "*.db"))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to list user databases")
+	}
+	var users []string
+	for _, f := range files {
+		users = append(users, strings.TrimSuffix(filepath.Base(f), ".db"))
+	}
+	sort.Strings(users)
+	return users, nil
+}
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// CallTiming sums up the calls of one backend method.
+type CallTiming struct {
+	Backend string
+	Method  string // In snake case, as for the external backend
+	Count   int
+	Total   time.Duration
+	Max     time.Duration
+}
+
+// Timings collects the time spent in backend calls, per backend and method.
+// It is safe for concurrent use.
+type Timings struct {
+	mu    sync.Mutex
+	calls map[[2]string]*CallTiming
+}
+
+// NewTimings creates an empty collection of timings.
+func NewTimings() *Timings {
+	return &Timings{calls: make(map[[2]string]*CallTiming)}
+}
+
+func (t *Timings) observe(backend string, method string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := [2]string{backend, method}
+	call, ok := t.calls[key]
+	if !ok {
+		call = &CallTiming{Backend: backend, Method: method}
+		t.calls[key] = call
+	}
+	call.Count++
+	call.Total += d
+	if d > call.Max {
+		call.Max = d
+	}
+}
+
+// All gives the timings of the methods called so far, ordered by backend and
+// method.
+func (t *Timings) All() []CallTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []CallTiming
+	for _, call := range t.calls {
+		result = append(result, *call)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Backend != result[j].Backend {
+			return result[i].Backend < result[j].Backend
+		}
+		return result[i].Method < result[j].Method
+	})
+	return result
+}
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"time"
+)
+
+// WeekHours is the time spent in each hour of the day, for each day of the
+// week. It is indexed by weekday first, starting on Sunday like time.Weekday.
+type WeekHours [7][24]time.Duration
+
+// Add an entry, splitting it at the hour boundaries in local time.
+func (w *WeekHours) Add(start time.Time, end time.Time) {
+	for t := start; t.Before(end); {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		if next.After(end) {
+			next = end
+		}
+		w[t.Weekday()][t.Hour()] += next.Sub(t)
+		t = next
+	}
+}
+
+// ByWeekday sums up the time spent on each day of the week.
+func (w *WeekHours) ByWeekday() [7]time.Duration {
+	var result [7]time.Duration
+	for day := range w {
+		for _, d := range w[day] {
+			result[day] += d
+		}
+	}
+	return result
+}
+
+// ByHour sums up the time spent in each hour of the day.
+func (w *WeekHours) ByHour() [24]time.Duration {
+	var result [24]time.Duration
+	for day := range w {
+		for hour, d := range w[day] {
+			result[hour] += d
+		}
+	}
+	return result
+}
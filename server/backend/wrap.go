@@ -0,0 +1,318 @@
+package backend
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// Retrier is implemented by backends able to tell errors which may go away
+// when retrying, e.g. as the database is locked by another process.
+type Retrier interface {
+	IsTransient(err error) bool
+}
+
+// Error is a transient failure of a backend call persisting through all
+// retries.
+type Error struct {
+	Backend  string
+	Method   string
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v (%s %s, gave up after %d attempts)", e.Err, e.Backend, e.Method, e.Attempts)
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// IsTransient tells whether the error is a transient failure of a backend
+// call, such that trying again later may succeed.
+func IsTransient(err error) bool {
+	for err != nil {
+		if _, ok := err.(*Error); ok {
+			return true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// Policy determines how calls of a wrapped backend are handled.
+type Policy struct {
+	Timings *Timings      // Collects the time spent in calls
+	Slow    time.Duration // Calls taking longer are passed to OnSlow; 0 to disable
+	OnSlow  func(method string, d time.Duration)
+	Retries int           // Further attempts after a transient error
+	Backoff time.Duration // Wait before the first retry, doubled for each one after
+}
+
+// Wrapped records the time spent in each call of the backend and retries calls
+// failing with transient errors, if the backend is a Retrier. Calls iterating
+// over data are only retried if the callback has not been called yet; their
+// time includes that spent in the callback.
+type Wrapped struct {
+	Backend
+	policy Policy
+}
+
+// Wrap the backend to handle its calls according to the policy.
+func Wrap(b Backend, policy Policy) *Wrapped {
+	return &Wrapped{Backend: b, policy: policy}
+}
+
+// Unwrap gives the backend itself, e.g. to check for optional interfaces like
+// Planner.
+func Unwrap(b Backend) Backend {
+	if w, ok := b.(*Wrapped); ok {
+		return w.Backend
+	}
+	return b
+}
+
+// An error not to be retried as the call had an effect already.
+type final struct {
+	err error
+}
+
+func (f final) Error() string {
+	return f.err.Error()
+}
+
+// Perform the call according to the policy.
+func (w *Wrapped) call(method string, fn func() error) error {
+	begin := time.Now()
+	defer func() {
+		d := time.Since(begin)
+		w.policy.Timings.observe(w.Backend.Name(), method, d)
+		if w.policy.Slow > 0 && d > w.policy.Slow && w.policy.OnSlow != nil {
+			w.policy.OnSlow(method, d)
+		}
+	}()
+	r, canRetry := w.Backend.(Retrier)
+	backoff := w.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if f, ok := err.(final); ok {
+			return f.err
+		}
+		if err == nil || !canRetry || !r.IsTransient(err) {
+			return err
+		}
+		if attempt > w.policy.Retries {
+			return &Error{Backend: w.Backend.Name(), Method: method, Attempts: attempt, Err: err}
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *Wrapped) Snapshot(path string) error {
+	return w.call("snapshot", func() error {
+		return w.Backend.Snapshot(path)
+	})
+}
+
+func (w *Wrapped) Save(task msg.Task) error {
+	return w.call("save", func() error {
+		return w.Backend.Save(task)
+	})
+}
+
+func (w *Wrapped) RecentTasks(maxNumber int) (result []msg.Summary, err error) {
+	err = w.call("recent_tasks", func() error {
+		result, err = w.Backend.RecentTasks(maxNumber)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) RecentEntries(tasks []string, maxNumber int) (result []msg.Entry, err error) {
+	err = w.call("recent_entries", func() error {
+		result, err = w.Backend.RecentEntries(tasks, maxNumber)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) GetRecord(id string) (result msg.Entry, err error) {
+	err = w.call("get_record", func() error {
+		result, err = w.Backend.GetRecord(id)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) UpdateRecord(id string, started time.Time, ended time.Time) error {
+	return w.call("update_record", func() error {
+		return w.Backend.UpdateRecord(id, started, ended)
+	})
+}
+
+func (w *Wrapped) GetTaskBetween(task string, start time.Time, end time.Time, source msg.Source) (result []msg.Summary, err error) {
+	err = w.call("get_task_between", func() error {
+		result, err = w.Backend.GetTaskBetween(task, start, end, source)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) GetAllTasksBetween(start time.Time, end time.Time, source msg.Source) (result []msg.Summary, err error) {
+	err = w.call("get_all_tasks_between", func() error {
+		result, err = w.Backend.GetAllTasksBetween(start, end, source)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) GetDailyTotals(task string, start time.Time, end time.Time) (result []msg.Summary, err error) {
+	err = w.call("get_daily_totals", func() error {
+		result, err = w.Backend.GetDailyTotals(task, start, end)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) CountEntriesBetween(start time.Time, end time.Time) (count int, total time.Duration, err error) {
+	err = w.call("count_entries_between", func() error {
+		count, total, err = w.Backend.CountEntriesBetween(start, end)
+		return err
+	})
+	return count, total, err
+}
+
+func (w *Wrapped) GetWeekHours(tasks []string, start time.Time, end time.Time) (result WeekHours, err error) {
+	err = w.call("get_week_hours", func() error {
+		result, err = w.Backend.GetWeekHours(tasks, start, end)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) TaskNames(prefix string, limit int) (result []string, err error) {
+	err = w.call("task_names", func() error {
+		result, err = w.Backend.TaskNames(prefix, limit)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) SetArchived(task string, archived bool) error {
+	return w.call("set_archived", func() error {
+		return w.Backend.SetArchived(task, archived)
+	})
+}
+
+func (w *Wrapped) ArchivedTasks() (result []string, err error) {
+	err = w.call("archived_tasks", func() error {
+		result, err = w.Backend.ArchivedTasks()
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) RenameTask(task string, newName string) (count int, err error) {
+	err = w.call("rename_task", func() error {
+		count, err = w.Backend.RenameTask(task, newName)
+		return err
+	})
+	return count, err
+}
+
+func (w *Wrapped) ForEachTaskBetween(tasks []string, start time.Time, end time.Time, fn func(msg.Task) error) error {
+	called := false
+	return w.call("for_each_task_between", func() error {
+		err := w.Backend.ForEachTaskBetween(tasks, start, end, func(task msg.Task) error {
+			called = true
+			return fn(task)
+		})
+		if err != nil && called {
+			return final{err}
+		}
+		return err
+	})
+}
+
+func (w *Wrapped) RemoveDuplicates(dups []Duplicate) error {
+	return w.call("remove_duplicates", func() error {
+		return w.Backend.RemoveDuplicates(dups)
+	})
+}
+
+func (w *Wrapped) ReplaceEntries(entries []msg.Task, dups []Duplicate) error {
+	return w.call("replace_entries", func() error {
+		return w.Backend.ReplaceEntries(entries, dups)
+	})
+}
+
+func (w *Wrapped) AddNote(task string, note string) error {
+	return w.call("add_note", func() error {
+		return w.Backend.AddNote(task, note)
+	})
+}
+
+func (w *Wrapped) GetNotesBetween(task string, start time.Time, end time.Time) (result []msg.Note, err error) {
+	err = w.call("get_notes_between", func() error {
+		result, err = w.Backend.GetNotesBetween(task, start, end)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) Search(term string) (result []msg.Task, err error) {
+	err = w.call("search", func() error {
+		result, err = w.Backend.Search(term)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) SaveEvent(entry msg.LogEntry) error {
+	return w.call("save_event", func() error {
+		return w.Backend.SaveEvent(entry)
+	})
+}
+
+func (w *Wrapped) GetEventsBetween(tasks []string, start time.Time, end time.Time) (result []msg.LogEntry, err error) {
+	err = w.call("get_events_between", func() error {
+		result, err = w.Backend.GetEventsBetween(tasks, start, end)
+		return err
+	})
+	return result, err
+}
+
+func (w *Wrapped) RecordChange(change msg.Change) error {
+	return w.call("record_change", func() error {
+		return w.Backend.RecordChange(change)
+	})
+}
+
+func (w *Wrapped) ApplyChange(change msg.Change) (isNew bool, err error) {
+	err = w.call("apply_change", func() error {
+		isNew, err = w.Backend.ApplyChange(change)
+		return err
+	})
+	return isNew, err
+}
+
+func (w *Wrapped) ForEachChange(fn func(msg.Change) error) error {
+	called := false
+	return w.call("for_each_change", func() error {
+		err := w.Backend.ForEachChange(func(change msg.Change) error {
+			called = true
+			return fn(change)
+		})
+		if err != nil && called {
+			return final{err}
+		}
+		return err
+	})
+}
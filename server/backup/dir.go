@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Stores snapshots in a local directory, e.g. a mounted network share.
+type dirTarget struct {
+	dir string
+}
+
+func (t dirTarget) Push(snapshot string, name string) error {
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return err
+	}
+	in, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// Write to a temporary file first to avoid leaving partial snapshots.
+	tmp := filepath.Join(t.dir, "."+name+".part")
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, name))
+}
+
+func (t dirTarget) Prune(keep int) error {
+	files, err := ioutil.ReadDir(t.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	for _, name := range obsolete(names, keep) {
+		if err := os.Remove(filepath.Join(t.dir, name)); err != nil {
+			return errors.Wrap(err, "Failed to remove snapshot")
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterTarget("dir", func(location string) (Target, error) {
+		return dirTarget{dir: location}, nil
+	})
+}
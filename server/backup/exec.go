@@ -0,0 +1,27 @@
+package backup
+
+import (
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+// Hands snapshots to a shell command, e.g. rsync or an S3 client. The command
+// receives the path of the snapshot as $1 and its name as $2.
+type execTarget struct {
+	command string
+}
+
+func (t execTarget) Push(snapshot string, name string) error {
+	cmd := exec.Command("sh", "-c", t.command, "sh", snapshot, name)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "Backup command failed: %s", out)
+	}
+	return nil
+}
+
+func init() {
+	RegisterTarget("exec", func(location string) (Target, error) {
+		return execTarget{command: location}, nil
+	})
+}
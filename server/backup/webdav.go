@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Stores snapshots in a WebDAV collection. Credentials can be given as part of
+// the URL.
+type webdavTarget struct {
+	base *url.URL
+}
+
+func (t webdavTarget) Push(snapshot string, name string) error {
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := t.request(http.MethodPut, name, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	return t.do(req)
+}
+
+func (t webdavTarget) Prune(keep int) error {
+	names, err := t.list()
+	if err != nil {
+		return err
+	}
+	for _, name := range obsolete(names, keep) {
+		req, err := t.request(http.MethodDelete, name, nil)
+		if err != nil {
+			return err
+		}
+		if err := t.do(req); err != nil {
+			return errors.Wrap(err, "Failed to remove snapshot")
+		}
+	}
+	return nil
+}
+
+// The names of all files in the collection.
+func (t webdavTarget) list() ([]string, error) {
+	req, err := t.request("PROPFIND", "", strings.NewReader(
+		`<?xml version="1.0"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, errors.Errorf("Unexpected response: %s", resp.Status)
+	}
+	var multistatus struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&multistatus); err != nil {
+		return nil, errors.Wrap(err, "Invalid response")
+	}
+	var names []string
+	for _, r := range multistatus.Responses {
+		if p, err := url.PathUnescape(r.Href); err == nil {
+			names = append(names, path.Base(p))
+		}
+	}
+	return names, nil
+}
+
+func (t webdavTarget) request(method string, name string, body io.Reader) (*http.Request, error) {
+	target := *t.base
+	target.User = nil
+	target.Path = path.Join(t.base.Path, name)
+	if name == "" {
+		target.Path += "/"
+	}
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if user := t.base.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+	return req, nil
+}
+
+func (t webdavTarget) do(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return nil
+}
+
+func init() {
+	RegisterTarget("webdav", func(location string) (Target, error) {
+		base, err := url.Parse(location)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid WebDAV URL")
+		}
+		return webdavTarget{base: base}, nil
+	})
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Percentages of a budget at which listeners are warned, in ascending order.
+var budgetThresholds = []int{80, 100}
+
+// How often the budget of the running task is checked.
+const budgetCheckInterval = time.Minute
+
+// The budget warnings issued for the running task so far.
+type budgetState struct {
+	task    string
+	started time.Time
+	level   int // The highest threshold crossed
+}
+
+// Parse durations per task, given as e.g. foo=80h,bar=20h, as used for
+// budgets and weekly goals.
+func parseTaskDurations(conf string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, entry := range strings.Split(conf, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("Invalid entry: %s", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("Invalid entry: %s", entry)
+		}
+		durations[strings.TrimSpace(kv[0])] = d
+	}
+	return durations, nil
+}
+
+// Load the configured budgets. Invalid configuration disables warnings.
+func (s *Server) loadBudgets() {
+	budgets, err := parseTaskDurations(s.conf.Budgets.Value)
+	if err != nil {
+		s.logWarn("Ignoring budgets:", err)
+		return
+	}
+	s.budgets = budgets
+}
+
+// The time spent on the running task so far, including prior entries.
+func (s *Server) spentOnCurrentTask(now time.Time) (time.Duration, error) {
+	task := s.CurrentTask
+	sum, err := s.Backend.GetTaskBetween(task.Name, time.Unix(0, 0), now.Add(time.Second), msg.Source{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to determine time spent on task")
+	}
+	spent := now.Sub(task.Started)
+	for _, row := range sum {
+		spent += row.Total
+	}
+	return spent, nil
+}
+
+// The highest threshold reached by spent.
+func budgetLevel(spent time.Duration, budget time.Duration) int {
+	level := 0
+	for _, threshold := range budgetThresholds {
+		if spent >= budget*time.Duration(threshold)/100 {
+			level = threshold
+		}
+	}
+	return level
+}
+
+// Check the running task against its budget, warning listeners about newly
+// crossed thresholds. Thresholds crossed before the task was started are not
+// reported again. Budgets concern the server owner only.
+func (s *Server) checkBudget(now time.Time) {
+	if s.foreign() {
+		return
+	}
+	task := s.CurrentTask
+	budget, ok := s.budgets[task.Name]
+	if !ok || !task.IsRunning() {
+		return
+	}
+	spent, err := s.spentOnCurrentTask(now)
+	if err != nil {
+		s.logError(err)
+		return
+	}
+	level := budgetLevel(spent, budget)
+	if s.budgetState.task != task.Name || !s.budgetState.started.Equal(task.Started) {
+		s.budgetState = budgetState{task: task.Name, started: task.Started, level: level}
+		return
+	}
+	if level <= s.budgetState.level {
+		return
+	}
+	s.budgetState.level = level
+	s.logInfo("Task", task.Name, "reached", level, "percent of its budget")
+	ntf := TaskNotification(task)
+	ntf.Event = EventBudget
+	ntf.Warning = &BudgetWarning{Task: task.Name, Percent: level, Budget: budget, Spent: spent.Truncate(time.Second)}
+	s.notify(ntf)
+}
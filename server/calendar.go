@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/ics"
+	"github.com/pkg/errors"
+)
+
+// The entries served as a calendar feed.
+type calendarReply struct {
+	tasks []msg.Task
+	err   error
+}
+
+// Parse the number of weeks served as a calendar feed.
+func (s *Server) loadCalendarWeeks() error {
+	weeks, err := strconv.Atoi(s.conf.CalendarWeeks.Value)
+	if err != nil || weeks < 0 {
+		return errors.Errorf("Invalid number of calendar weeks: %s", s.conf.CalendarWeeks.Value)
+	}
+	if weeks > 0 && len(s.tokens) == 0 {
+		s.logWarn("Not serving a calendar without API tokens")
+		weeks = 0
+	}
+	s.calendarWeeks = weeks
+	return nil
+}
+
+// Serve the entries of the recent weeks as an iCalendar feed. Calendar
+// applications cannot send headers, so the API token is given as a query
+// parameter, e.g. /calendar.ics?token=abc.
+func (s *Server) serveCalendar(w http.ResponseWriter, r *http.Request) {
+	if s.calendarWeeks == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.tokenScope(r.URL.Query().Get("token")); !ok {
+		http.Error(w, "Invalid API token", http.StatusUnauthorized)
+		return
+	}
+	reply := make(chan calendarReply, 1)
+	select {
+	case s.icsRequests <- reply:
+	case <-time.After(5 * time.Second):
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	result := <-reply
+	if result.err != nil {
+		http.Error(w, "Failed to read entries", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	cal := ics.NewWriter(w)
+	cal.Begin()
+	for _, task := range result.tasks {
+		cal.Write(task)
+	}
+	if err := cal.Flush(); err != nil {
+		s.logWarn("Failed to serve the calendar:", err)
+	}
+}
+
+// Gather the entries of the current and the preceding weeks, as configured.
+func (s *Server) calendarEntries(now time.Time) calendarReply {
+	start := startOfWeek(now).AddDate(0, 0, -7*(s.calendarWeeks-1))
+	var tasks []msg.Task
+	err := s.Backend.ForEachTaskBetween(nil, start, now, func(task msg.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		s.logError(errors.Wrap(err, "Failed to gather calendar entries"))
+	}
+	return calendarReply{tasks: tasks, err: err}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/sample"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Days and tasks of the sample data of an ephemeral server.
+	ephemeralDays  = 56
+	ephemeralTasks = 6
+)
+
+// RunEphemeral runs a server on sample data held in memory, e.g. for demos
+// and screenshots. It is reached on the configured socket like any server,
+// but keeps its files in a temporary directory and runs no hooks, posts no
+// notifications, and neither mails reports nor makes backups. The same day
+// gives the same data. This function will block until server shutdown.
+func RunEphemeral(conf *config.Opts) error {
+	dir, err := ioutil.TempDir("", "tilo-ephemeral")
+	if err != nil {
+		return errors.Wrap(err, "Failed to initialize server")
+	}
+	defer os.RemoveAll(dir)
+	demo := *conf
+	demo.ConfFile.Value = filepath.Join(dir, "config")
+	demo.Backend.Value = "memory"
+	demo.HookOnStart.Value = ""
+	demo.HookOnStop.Value = ""
+	demo.HookOnAbort.Value = ""
+	demo.HookOnShutdown.Value = ""
+	demo.RecordFile.Value = ""
+	demo.WebhookURL.Value = ""
+	demo.MQTTBroker.Value = ""
+	demo.ReportSchedule.Value = ""
+	demo.BackupTarget.Value = ""
+
+	s := Server{conf: &demo, build: msg.CurrentBuild()}
+	if err := s.init(); err != nil {
+		return errors.Wrap(err, "Failed to initialize server")
+	}
+	defer s.enforceCleanup()
+
+	now := time.Now()
+	rng := rand.New(rand.NewSource(int64(now.Year()*1000 + now.YearDay())))
+	for _, task := range sample.Entries(rng, now, ephemeralDays, ephemeralTasks) {
+		if err := s.Backend.Save(task); err != nil {
+			return errors.Wrap(err, "Failed to add sample data")
+		}
+	}
+	s.main()
+	return nil
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Load the configured weekly goals. Invalid configuration disables them.
+func (s *Server) loadGoals() {
+	goals, err := parseTaskDurations(s.conf.WeeklyGoals.Value)
+	if err != nil {
+		s.logWarn("Ignoring weekly goals:", err)
+		return
+	}
+	s.goals = goals
+}
+
+// WeeklyGoals gives the time to be spent on tasks per week.
+func (s *Server) WeeklyGoals() map[string]time.Duration {
+	return s.goals
+}
+
+// The start of the week (Monday) containing the given time.
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// SpentThisWeek gives the time spent on a task in the week up to now,
+// including the running task.
+func (s *Server) SpentThisWeek(task string, now time.Time) (time.Duration, error) {
+	weekStart := startOfWeek(now)
+	sum, err := s.Backend.GetTaskBetween(task, weekStart, now.Add(time.Second), msg.Source{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to determine time spent on task")
+	}
+	var spent time.Duration
+	for _, row := range sum {
+		spent += row.Total
+	}
+	if current := s.CurrentTask; current.IsRunning() && current.Name == task {
+		if current.Started.After(weekStart) {
+			spent += now.Sub(current.Started)
+		} else {
+			spent += now.Sub(weekStart)
+		}
+	}
+	return spent, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// HealthReport is the outcome of a health check.
+type HealthReport struct {
+	Status        string  `json:"status"` // One of the Health* constants
+	Version       string  `json:"version"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
+	Backend       string  `json:"backend"`
+	BackendMillis float64 `json:"backend_ms"` // Time taken to check the backend
+	Error         string  `json:"error,omitempty"`
+}
+
+// Health checks whether the backend answers queries, as cheaply as possible.
+func (s *Server) Health(now time.Time) HealthReport {
+	report := HealthReport{
+		Status:        HealthOK,
+		Version:       msg.Version,
+		UptimeSeconds: int64(now.Sub(s.started).Seconds()),
+		Backend:       s.Backend.Name(),
+	}
+	begin := time.Now()
+	var err error
+	if p, ok := backend.Unwrap(s.Backend).(backend.Pinger); ok {
+		err = p.Ping()
+	} else {
+		_, err = s.Backend.RecentTasks(1)
+	}
+	report.BackendMillis = float64(time.Since(begin).Microseconds()) / 1000
+	if err != nil {
+		report.Status = HealthFailing
+		report.Error = errors.Wrap(err, "Backend check failed").Error()
+	}
+	return report
+}
+
+// Answer HTTP health checks, e.g. of container runtimes, with 200 if healthy
+// and 503 otherwise. Like state requests, checks are run in the main loop, so
+// a busy server is reported as failing.
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply := make(chan HealthReport, 1)
+	select {
+	case s.healthRequests <- reply:
+	case <-time.After(5 * time.Second):
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	report := <-reply
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// Notify the systemd watchdog if the server is healthy.
+func (s *Server) feedWatchdog(now time.Time) {
+	if report := s.Health(now); report.Status != HealthOK {
+		s.logWarn("Not notifying the watchdog:", report.Error)
+		return
+	}
+	if err := sdNotify("WATCHDOG=1"); err != nil {
+		s.logWarn("Unable to notify the watchdog:", err)
+	}
+}
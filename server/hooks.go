@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Event name for hooks run on server shutdown.
+const hookShutdown = "shutdown"
+
+// The command configured to run on the event, if any.
+func (s *Server) hookFor(event string) string {
+	switch event {
+	case msg.RespStartTask:
+		return s.conf.HookOnStart.Value
+	case msg.RespStopTask:
+		return s.conf.HookOnStop.Value
+	case msg.RespAbortTask:
+		return s.conf.HookOnAbort.Value
+	case hookShutdown:
+		return s.conf.HookOnShutdown.Value
+	default:
+		return ""
+	}
+}
+
+// Run the hook configured for the event in the background. Details about the
+// task are passed in environment variables.
+func (s *Server) runHook(event string, task msg.Task) {
+	if cmd := s.hookCommand(event, task); cmd != nil {
+		go s.awaitHook(event, cmd)
+	}
+}
+
+// Run the hook configured for the event and wait for it to finish.
+func (s *Server) runHookAndWait(event string, task msg.Task) {
+	if cmd := s.hookCommand(event, task); cmd != nil {
+		s.awaitHook(event, cmd)
+	}
+}
+
+func (s *Server) hookCommand(event string, task msg.Task) *exec.Cmd {
+	hook := s.hookFor(event)
+	if hook == "" {
+		return nil
+	}
+	cmd := exec.Command("sh", "-c", hook)
+	cmd.Env = append(os.Environ(), hookEnv(event, task)...)
+	return cmd
+}
+
+func (s *Server) awaitHook(event string, cmd *exec.Cmd) {
+	s.logDebug("Running hook for", event)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		s.logError(errors.Wrapf(err, "Hook for %s failed: %s", event, out))
+	}
+}
+
+// Environment variables describing the event.
+func hookEnv(event string, task msg.Task) []string {
+	env := []string{"TILO_EVENT=" + event}
+	if task.Name == "" {
+		return env
+	}
+	env = append(env,
+		"TILO_TASK="+task.Name,
+		"TILO_STARTED="+task.Started.Format(time.RFC3339),
+		"TILO_HOST="+task.Source.Host,
+		"TILO_USER="+task.Source.User,
+	)
+	if task.HasEnded {
+		env = append(env,
+			"TILO_ENDED="+task.Ended.Format(time.RFC3339),
+			fmt.Sprintf("TILO_DURATION=%d", int64(task.Ended.Sub(task.Started)/time.Second)),
+		)
+	}
+	return env
+}
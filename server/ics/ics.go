@@ -0,0 +1,98 @@
+// Package ics writes recorded tasks as an iCalendar (RFC 5545) feed, suitable
+// for subscribing to in calendar applications.
+package ics
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// The format of times in UTC.
+const timeFormat = "20060102T150405Z"
+
+// Lines are folded beyond this many octets.
+const maxLineLength = 75
+
+// Writer writes tasks as calendar events.
+type Writer struct {
+	out *bufio.Writer
+	now time.Time // The time stamp of all events
+}
+
+// NewWriter creates a writer for the calendar.
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: bufio.NewWriter(out), now: time.Now()}
+}
+
+// Begin starts the calendar.
+func (w *Writer) Begin() error {
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//fgahr//tilo " + msg.Version + "//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("X-WR-CALNAME:tilo")
+	return nil
+}
+
+// Write adds the task as an event. Its notes become the description.
+func (w *Writer) Write(task msg.Task) error {
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + uid(task))
+	w.line("DTSTAMP:" + w.now.UTC().Format(timeFormat))
+	w.line("DTSTART:" + task.Started.UTC().Format(timeFormat))
+	w.line("DTEND:" + task.Ended.UTC().Format(timeFormat))
+	w.line("SUMMARY:" + escape(task.Name))
+	if len(task.Notes) > 0 {
+		w.line("DESCRIPTION:" + escape(strings.Join(task.Notes, "\n")))
+	}
+	w.line("TRANSP:TRANSPARENT")
+	w.line("END:VEVENT")
+	return nil
+}
+
+// Flush ends the calendar and writes any buffered data.
+func (w *Writer) Flush() error {
+	w.line("END:VCALENDAR")
+	return w.out.Flush()
+}
+
+// Write a content line, folded as required. Errors are reported on flushing.
+func (w *Writer) line(content string) {
+	limit := maxLineLength
+	for len(content) > limit {
+		cut := limit
+		// Never split a multi-byte character.
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.out.WriteString(content[:cut] + "\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space.
+		limit = maxLineLength - 1
+	}
+	w.out.WriteString(content + "\r\n")
+}
+
+// A stable identifier, so that calendars update rather than duplicate events.
+func uid(task msg.Task) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%d", task.Source.User, task.Name, task.Started.Unix())))
+	return hex.EncodeToString(sum[:]) + "@tilo"
+}
+
+// Escape text values.
+func escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
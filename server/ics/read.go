@@ -0,0 +1,173 @@
+package ics
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event is a calendar event with a definite start and end.
+type Event struct {
+	UID     string
+	Summary string
+	Started time.Time
+	Ended   time.Time
+}
+
+// A content line, split into its parts.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ReadEvents gives the events of all calendars in r. All-day events are left
+// out, as are events lacking a start.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	var current *Event
+	var duration time.Duration
+	allDay := false
+	for _, line := range lines {
+		prop, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && prop.value == "VEVENT":
+			current, duration, allDay = &Event{}, 0, false
+		case prop.name == "END" && prop.value == "VEVENT":
+			if current != nil && !allDay && !current.Started.IsZero() {
+				if current.Ended.IsZero() {
+					current.Ended = current.Started.Add(duration)
+				}
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+			// Not part of an event.
+		case prop.name == "UID":
+			current.UID = prop.value
+		case prop.name == "SUMMARY":
+			current.Summary = unescape(prop.value)
+		case prop.name == "DTSTART", prop.name == "DTEND":
+			if prop.params["VALUE"] == "DATE" || len(prop.value) == len("20060102") {
+				allDay = true
+				continue
+			}
+			t, err := parseTime(prop)
+			if err != nil {
+				return nil, err
+			}
+			if prop.name == "DTSTART" {
+				current.Started = t
+			} else {
+				current.Ended = t
+			}
+		case prop.name == "DURATION":
+			if duration, err = parseDuration(prop.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return events, nil
+}
+
+// Read content lines, joining folded ones.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, errors.Wrap(scanner.Err(), "Unable to read calendar")
+}
+
+// Split a line like DTSTART;TZID=Europe/Berlin:20240502T093000.
+func parseProperty(line string) (property, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return property{}, false
+	}
+	parts := strings.Split(line[:colon], ";")
+	prop := property{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+			prop.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return prop, true
+}
+
+// Parse a time in UTC, in the given time zone, or in local time otherwise.
+func parseTime(prop property) (time.Time, error) {
+	if strings.HasSuffix(prop.value, "Z") {
+		t, err := time.Parse(timeFormat, prop.value)
+		return t, errors.Wrapf(err, "Invalid %s", prop.name)
+	}
+	loc := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", prop.value, loc)
+	return t, errors.Wrapf(err, "Invalid %s", prop.name)
+}
+
+// Parse a duration like PT1H30M. Weeks and days are taken as multiples of 24
+// hours.
+func parseDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var d time.Duration
+	inTime := false
+	n := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			n = n*10 + int(c-'0')
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		case c == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, errors.Errorf("Invalid DURATION: %s", value)
+		}
+		n = 0
+	}
+	return d, nil
+}
+
+// Undo the escaping of text values.
+func unescape(text string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(text)
+}
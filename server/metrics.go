@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Serve the time spent in backend calls in the Prometheus text format. The
+// timings are safe for concurrent use, so unlike state requests, these are
+// answered without involving the main loop.
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	calls := s.timings.All()
+	fmt.Fprintln(w, "# HELP tilo_backend_call_seconds Time spent in backend calls.")
+	fmt.Fprintln(w, "# TYPE tilo_backend_call_seconds summary")
+	for _, call := range calls {
+		labels := fmt.Sprintf(`{backend="%s",method="%s"}`, call.Backend, call.Method)
+		fmt.Fprintf(w, "tilo_backend_call_seconds_sum%s %g\n", labels, call.Total.Seconds())
+		fmt.Fprintf(w, "tilo_backend_call_seconds_count%s %d\n", labels, call.Count)
+	}
+	fmt.Fprintln(w, "# HELP tilo_backend_call_max_seconds Longest backend call since the server started.")
+	fmt.Fprintln(w, "# TYPE tilo_backend_call_max_seconds gauge")
+	for _, call := range calls {
+		labels := fmt.Sprintf(`{backend="%s",method="%s"}`, call.Backend, call.Method)
+		fmt.Fprintf(w, "tilo_backend_call_max_seconds%s %g\n", labels, call.Max.Seconds())
+	}
+}
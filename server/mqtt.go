@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/fgahr/tilo/server/mqtt"
+	"github.com/pkg/errors"
+)
+
+// Start publishing to the configured MQTT broker, if any.
+func (s *Server) startMQTT() {
+	if s.conf.MQTTBroker.Value != "" {
+		s.mqtt = mqtt.NewPublisher(s.conf.MQTTBroker.Value, s.conf.MQTTUser.Value, s.conf.MQTTPassword.Value, s.logError)
+		s.publishState(TaskNotification(s.CurrentTask))
+	}
+}
+
+// Publish all pending messages and stop publishing.
+func (s *Server) stopMQTT() {
+	if s.mqtt != nil {
+		s.mqtt.Close()
+		s.mqtt = nil
+	}
+}
+
+// Publish the notification as the retained state of the configured topic.
+func (s *Server) publishState(ntf Notification) {
+	if s.mqtt == nil {
+		return
+	}
+	if payload, err := json.Marshal(ntf); err != nil {
+		s.logError(errors.Wrap(err, "Failed to encode MQTT message"))
+	} else {
+		s.mqtt.Publish(s.conf.MQTTTopic.Value, payload)
+	}
+}
@@ -0,0 +1,158 @@
+// Package mqtt publishes messages to an MQTT broker. Only the small part of
+// MQTT 3.1.1 required to publish retained messages without acknowledgement
+// (QoS 0) is implemented.
+package mqtt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnAck    = 0x20
+	packetPublish    = 0x30
+	packetDisconnect = 0xe0
+	flagRetain       = 0x01
+	// Messages waiting to be published beyond this number are dropped.
+	queueSize = 32
+	timeout   = 10 * time.Second
+)
+
+// Publisher sends messages in the background, in order. A connection is
+// established for each message, which suffices for infrequent updates.
+type Publisher struct {
+	broker   string
+	user     string
+	password string
+	queue    chan message
+	done     chan struct{}
+	onError  func(error)
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// NewPublisher starts publishing to the broker at host:port. Errors are
+// passed to onError.
+func NewPublisher(broker string, user string, password string, onError func(error)) *Publisher {
+	p := &Publisher{
+		broker:   broker,
+		user:     user,
+		password: password,
+		queue:    make(chan message, queueSize),
+		done:     make(chan struct{}),
+		onError:  onError,
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues a retained message for the topic.
+func (p *Publisher) Publish(topic string, payload []byte) {
+	select {
+	case p.queue <- message{topic, payload}:
+	default:
+		p.onError(errors.New("MQTT queue full, dropping message"))
+	}
+}
+
+// Close publishes all queued messages and stops the publisher.
+func (p *Publisher) Close() {
+	close(p.queue)
+	<-p.done
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	for m := range p.queue {
+		if err := p.send(m); err != nil {
+			p.onError(errors.Wrap(err, "Failed to publish to MQTT broker"))
+		}
+	}
+}
+
+func (p *Publisher) send(m message) error {
+	conn, err := net.DialTimeout("tcp", p.broker, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(p.connectPacket()); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return err
+	}
+	if ack[0] != packetConnAck || ack[3] != 0 {
+		return errors.Errorf("Connection refused by broker (code %d)", ack[3])
+	}
+
+	publish := bytes.Buffer{}
+	writeString(&publish, m.topic)
+	publish.Write(m.payload)
+	if _, err := conn.Write(packet(packetPublish|flagRetain, publish.Bytes())); err != nil {
+		return err
+	}
+	_, err = conn.Write(packet(packetDisconnect, nil))
+	return err
+}
+
+func (p *Publisher) connectPacket() []byte {
+	body := bytes.Buffer{}
+	writeString(&body, "MQTT")
+	body.WriteByte(4)   // Protocol level 3.1.1
+	flags := byte(0x02) // Clean session
+	if p.user != "" {
+		flags |= 0x80
+		if p.password != "" {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+	body.Write([]byte{0, 60}) // Keep alive in seconds
+	writeString(&body, fmt.Sprintf("tilo-%d", os.Getpid()))
+	if p.user != "" {
+		writeString(&body, p.user)
+		if p.password != "" {
+			writeString(&body, p.password)
+		}
+	}
+	return packet(packetConnect, body.Bytes())
+}
+
+// A packet consisting of the fixed header and the body.
+func packet(header byte, body []byte) []byte {
+	result := []byte{header}
+	// The remaining length is encoded in 7-bit groups.
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		result = append(result, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(result, body...)
+}
+
+// Strings are prefixed by their length.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.Write([]byte{byte(len(s) >> 8), byte(len(s))})
+	buf.WriteString(s)
+}
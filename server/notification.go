@@ -3,25 +3,59 @@ package server
 import (
 	"github.com/fgahr/tilo/msg"
 	"github.com/pkg/errors"
+	"io"
 	"net"
 	"time"
 )
 
+// Kinds of notifications.
+const (
+	EventStart    = "start"    // A task is running
+	EventIdle     = "idle"     // No task is running
+	EventShutdown = "shutdown" // The server shuts down
+	EventBudget   = "budget"   // The running task approaches its budget
+	EventAway     = "away"     // Activity resumed after a period away
+	EventRunning  = "running"  // The task is still running, sent periodically
+)
+
 // The notification to send to listeners.
 type Notification struct {
-	Task  string    `json:"task"`  // The name of the task; empty if idle
-	Since time.Time `json:"since"` // Time of the last status change, formatted
+	Event   string         `json:"event"`             // One of the Event* constants
+	Seq     uint64         `json:"seq"`               // Increases with each event; restarts with the server
+	Task    string         `json:"task"`              // The name of the task; empty if idle
+	Since   time.Time      `json:"since"`             // Time of the last status change, formatted
+	Warning *BudgetWarning `json:"warning,omitempty"` // Set if the task is running out of budget
+	Away    *Away          `json:"away,omitempty"`    // Set when returning after a period without activity
+	Elapsed time.Duration  `json:"elapsed,omitempty"` // Time since the task started, set when still running
+	user    string         // The user concerned, in multi-user mode
+}
+
+// The number of events kept for listeners catching up on missed ones.
+const eventBufferSize = 100
+
+// A warning that the time spent on a task approaches or exceeds its budget.
+type BudgetWarning struct {
+	Task    string        `json:"task"`
+	Percent int           `json:"percent"` // The threshold crossed, e.g. 80
+	Budget  time.Duration `json:"budget"`
+	Spent   time.Duration `json:"spent"`
 }
 
 // An entity awaiting notifications about task changes.
 type NotificationListener struct {
-	conn net.Conn // The connection to notify
+	ID        int       // Unique while the server runs
+	Name      string    // The purpose given by the listener, if any
+	User      string    // The user listening, in multi-user mode
+	Connected time.Time // When the listener registered
+	Delivered time.Time // When the latest notification was sent successfully
+	conn      net.Conn  // The connection to notify
+	out       io.Writer // The writer for the connection, possibly compressing
 }
 
 // A notification informing listeners about server shutdown.
 func shutdownNotification() Notification {
 	// --shutdown is not a valid task name and hence can be used as a signal.
-	return Notification{"--shutdown", time.Now().Truncate(time.Second)}
+	return Notification{Event: EventShutdown, Task: "--shutdown", Since: time.Now().Truncate(time.Second)}
 }
 
 // A notification about a task, presumed to be the currently set one.
@@ -29,21 +63,78 @@ func shutdownNotification() Notification {
 // idle state.
 func TaskNotification(t msg.Task) Notification {
 	if t.IsRunning() {
-		return Notification{Task: t.Name, Since: t.Started}
+		return Notification{Event: EventStart, Task: t.Name, Since: t.Started}
 	} else {
-		return Notification{Task: "", Since: t.Ended}
+		return Notification{Event: EventIdle, Task: "", Since: t.Ended}
 	}
 }
 
+// Assign the next sequence number to an event and keep it for listeners
+// catching up later.
+func (s *Server) sequence(ntf Notification) Notification {
+	s.eventSeq++
+	ntf.Seq = s.eventSeq
+	ntf.user = s.user
+	s.events = append(s.events, ntf)
+	if len(s.events) > eventBufferSize {
+		s.events = append([]Notification(nil), s.events[len(s.events)-eventBufferSize:]...)
+	}
+	return ntf
+}
+
+// Greet a new listener with the current state, carrying the sequence number
+// of the latest event. With replay set, the events after the given sequence
+// number are sent instead, if all of them are still available.
+func (s *Server) Greet(lst NotificationListener, since uint64, replay bool) error {
+	if replay && since <= s.eventSeq && (len(s.events) == 0 || s.events[0].Seq <= since+1) {
+		for _, ntf := range s.events {
+			if ntf.Seq <= since || (ntf.user != lst.User && ntf.Event != EventShutdown) {
+				continue
+			}
+			if err := lst.Notify(ntf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	state := TaskNotification(s.CurrentTask)
+	state.Seq = s.eventSeq
+	return lst.Notify(state)
+}
+
 // Disconnect this listener.
 func (lst *NotificationListener) disconnect() error {
 	if lst == nil {
 		return nil
 	}
+	if c, ok := lst.out.(io.Closer); ok {
+		c.Close()
+	}
 	return lst.conn.Close()
 }
 
 // Notify this listener.
 func (lst *NotificationListener) Notify(ntf Notification) error {
-	return errors.Wrap(writeJsonLine(ntf, lst.conn), "Failed to send notification")
+	if err := writeJsonLine(ntf, lst.out); err != nil {
+		return errors.Wrap(err, "Failed to send notification")
+	}
+	lst.Delivered = time.Now()
+	return nil
+}
+
+// Listeners lists the registered listeners.
+func (s *Server) Listeners() []NotificationListener {
+	return append([]NotificationListener(nil), s.listeners...)
+}
+
+// DisconnectListener disconnects the listener with the given ID.
+func (s *Server) DisconnectListener(id int) error {
+	for i, lst := range s.listeners {
+		if lst.ID == id {
+			s.listeners = append(s.listeners[:i:i], s.listeners[i+1:]...)
+			s.logInfo("Disconnecting listener", id, lst.Name)
+			return lst.disconnect()
+		}
+	}
+	return errors.Errorf("No listener with ID %d", id)
 }
@@ -5,7 +5,11 @@ package server
 // with explanations.
 
 import (
+	"time"
+
 	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/server/backup"
 	"github.com/pkg/errors"
 )
 
@@ -21,30 +25,190 @@ func (s *Server) logResponse(resp msg.Response) {
 
 // Answer the request with the provided response.
 func (s *Server) Answer(req *Request, resp msg.Response) error {
-	return errors.Wrap(writeJsonLine(resp, req.Conn), "Failed to send response")
+	resp.Server = s.build
+	return errors.Wrap(writeJsonLine(resp, req.out), "Failed to send response")
+}
+
+// Stream a single value to the client following the response.
+func (s *Server) Stream(req *Request, v interface{}) error {
+	return errors.Wrap(writeJsonLine(v, req.out), "Failed to send data")
 }
 
-// Save a task to the backend database.
+// Push a snapshot of the database to the backup target.
+func (s *Server) Backup() (string, error) {
+	s.logInfo("Creating backup..")
+	name, err := backup.Run(s.Backend, s.conf, time.Now())
+	if err != nil {
+		s.logError(err)
+		return name, errors.Wrap(err, "Backup failed")
+	}
+	s.logInfo("Backup complete:", name)
+	return name, nil
+}
+
+// Save a task to the backend database, split at the start of each day it
+// spans if split_at is set.
 func (s *Server) SaveTask(task msg.Task) error {
 	if task.IsRunning() {
 		return errors.New("Cannot save an active task")
 	}
+	if err := task.Validate(); err != nil {
+		s.logWarn("Not saving invalid task:", task)
+		return errors.Wrap(err, "Cannot save task")
+	}
 	s.logFmtInfo("Saving task: %v\n", task)
-	if err := s.Backend.Save(task); err != nil {
-		s.logFmtInfo("%v\n", err)
+	parts := s.savedParts(task)
+	for _, part := range parts {
+		if err := s.Backend.Save(part); err != nil {
+			s.logFmtInfo("%v\n", err)
+			return err
+		}
+	}
+	s.recordEvent(msg.RespStopTask, task.Name, task.Ended)
+	s.announce(msg.RespStopTask, task)
+	for _, part := range parts {
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeEntry, part))
+	}
+	return nil
+}
+
+// Replace entries by others, e.g. when merging or splitting them, recording
+// the changes for synchronization. Each duplicate is removed and its notes
+// move to the entry it duplicates.
+func (s *Server) ReplaceEntries(entries []msg.Task, dups []backend.Duplicate) error {
+	s.logFmtInfo("Replacing entries by: %v\n", entries)
+	if err := s.Backend.ReplaceEntries(entries, dups); err != nil {
+		s.logError(err)
+		return errors.Wrap(err, "Unable to replace entries")
+	}
+	for _, task := range entries {
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeEntry, task))
+	}
+	for _, dup := range dups {
+		// Other devices skip a new entry identical to an existing one, which
+		// must not be removed there.
+		if !containsEntry(entries, dup.Entry) {
+			s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeRemoval, dup.Entry))
+		}
+	}
+	return nil
+}
+
+// Change the times of a recorded entry. The change is recorded for
+// synchronization as the removal of the entry and the changed one.
+func (s *Server) UpdateEntry(entry msg.Entry, changed msg.Task) error {
+	s.logFmtInfo("Changing entry %s to: %v\n", entry.ID, changed)
+	if err := s.Backend.UpdateRecord(entry.ID, changed.Started, changed.Ended); err != nil {
+		s.logError(err)
 		return err
 	}
+	if !containsEntry([]msg.Task{changed}, entry.Task) {
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeRemoval, entry.Task))
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeEntry, changed))
+	}
 	return nil
 }
 
-// Change the server's current task.
-func (s *Server) SetActiveTask(taskName string) {
+// Remove duplicate entries, moving their notes to the entries they duplicate,
+// and record the removals for synchronization.
+func (s *Server) RemoveDuplicates(dups []backend.Duplicate) error {
+	s.logFmtInfo("Removing duplicates: %v\n", dups)
+	if err := s.Backend.RemoveDuplicates(dups); err != nil {
+		s.logError(err)
+		return errors.Wrap(err, "Unable to remove duplicates")
+	}
+	for _, dup := range dups {
+		// Other devices skipped identical entries in the first place.
+		if !containsEntry([]msg.Task{dup.Of}, dup.Entry) {
+			s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeRemoval, dup.Entry))
+		}
+	}
+	return nil
+}
+
+// Whether an entry of the same task with the same times is among the tasks.
+func containsEntry(tasks []msg.Task, entry msg.Task) bool {
+	for _, task := range tasks {
+		if task.Name == entry.Name && task.Started.Equal(entry.Started) && task.Ended.Equal(entry.Ended) {
+			return true
+		}
+	}
+	return false
+}
+
+// Attach a note to the latest recorded entry of a task.
+func (s *Server) AnnotateTask(taskName string, note string) error {
+	if err := s.Backend.AddNote(taskName, note); err != nil {
+		return err
+	}
+	change := msg.NewChange(s.conf.DeviceID.Value, msg.ChangeNote, msg.Task{Name: taskName})
+	change.Note = note
+	s.recordChange(change)
+	return nil
+}
+
+// Rename a task throughout its history, including the running task. Returns
+// the number of entries renamed. For synchronization, each entry is recorded
+// as removed and saved under the new name.
+func (s *Server) RenameTask(task string, newName string) (int, error) {
+	var entries []msg.Task
+	err := s.Backend.ForEachTaskBetween([]string{task}, time.Unix(0, 0), time.Now().AddDate(1, 0, 0), func(t msg.Task) error {
+		entries = append(entries, t)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	count, err := s.Backend.RenameTask(task, newName)
+	if err != nil {
+		return count, err
+	}
+	for _, entry := range entries {
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeRemoval, entry))
+		entry.Name = newName
+		s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeEntry, entry))
+	}
+	if s.away != nil && s.away.Task == task {
+		s.away.Task = newName
+	}
+	if s.CurrentTask.IsRunning() && s.CurrentTask.Name == task {
+		s.CurrentTask.Name = newName
+		s.notifyListeners()
+	}
+	return count, nil
+}
+
+// Record an event in the task history. Failure is logged but not fatal.
+func (s *Server) recordEvent(eventType string, taskName string, t time.Time) {
+	entry := msg.LogEntry{Type: eventType, Task: taskName, Time: t}
+	if err := s.Backend.SaveEvent(entry); err != nil {
+		s.logError(errors.Wrap(err, "Failed to record event"))
+	}
+}
+
+// Inform hooks and the webhook about a task change.
+func (s *Server) announce(event string, task msg.Task) {
+	if s.foreign() {
+		// Hooks and the webhook are configured by the server owner.
+		return
+	}
+	s.runHook(event, task)
+	s.postTaskEvent(event, task)
+}
+
+// Change the server's current task, started from the given source.
+func (s *Server) SetActiveTask(taskName string, source msg.Source) {
 	if s.CurrentTask.IsRunning() {
 		s.logWarn("Task was not stopped before being superseded:", s.CurrentTask)
 		s.CurrentTask.Stop()
 	}
 	s.CurrentTask = msg.FreshTask(taskName)
+	s.CurrentTask.Source = source
+	s.RecordActivity(taskName, s.CurrentTask.Started)
+	s.recordEvent(msg.RespStartTask, taskName, s.CurrentTask.Started)
+	s.announce(msg.RespStartTask, s.CurrentTask)
 	s.notifyListeners()
+	s.checkBudget(time.Now())
 }
 
 // Stop the current task and return it. Returns true if the task was actually
@@ -58,19 +222,92 @@ func (s *Server) StopCurrentTask() (msg.Task, bool) {
 	return s.CurrentTask, false
 }
 
+// ExpectActiveTask fails unless the task given, if any, is the active one, so
+// that scripts don't stop a task started in between by someone else.
+func (s *Server) ExpectActiveTask(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !s.CurrentTask.IsRunning() {
+		return errors.Errorf("Expected %s to be active but no task is", names[0])
+	}
+	if s.CurrentTask.Name != names[0] {
+		return errors.Errorf("Expected %s to be active but %s is", names[0], s.CurrentTask.Name)
+	}
+	return nil
+}
+
+// TodaysSessions gives the number of entries started today and the time
+// spent on them, including the running task.
+func (s *Server) TodaysSessions(now time.Time) (int, time.Duration, error) {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	count, total, err := s.Backend.CountEntriesBetween(startOfDay, now)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Error in database query")
+	}
+	if task := s.CurrentTask; task.IsRunning() {
+		count++
+		if task.Started.After(startOfDay) {
+			total += now.Sub(task.Started)
+		} else {
+			total += now.Sub(startOfDay)
+		}
+	}
+	return count, total, nil
+}
+
+// DailyTarget is the configured time to be tracked per day; zero if unset.
+func (s *Server) DailyTarget() (time.Duration, error) {
+	if s.conf.DailyTarget.Value == "" {
+		return 0, nil
+	}
+	target, err := time.ParseDuration(s.conf.DailyTarget.Value)
+	return target, errors.Wrap(err, "Invalid daily target")
+}
+
+// Attach a note to the current task, to be saved alongside it. Returns false
+// if no task is active.
+func (s *Server) AnnotateCurrentTask(note string) bool {
+	if !s.CurrentTask.IsRunning() {
+		return false
+	}
+	s.CurrentTask.Notes = append(s.CurrentTask.Notes, note)
+	s.persistRunningTask(time.Now())
+	return true
+}
+
+// Abort the current task without saving it and return it. Returns true if the
+// task was actually halted and false if no task was active.
+func (s *Server) AbortCurrentTask() (msg.Task, bool) {
+	task, stopped := s.StopCurrentTask()
+	if stopped {
+		s.recordEvent(msg.RespAbortTask, task.Name, task.Ended)
+		s.announce(msg.RespAbortTask, task)
+	}
+	return task, stopped
+}
+
 // Register the listener with the server. If it cannot be notified immediately,
 // an error is returned.
 func (s *Server) RegisterListener(req *Request) (NotificationListener, error) {
-	lst := NotificationListener{req.Conn}
+	s.listenerSeq++
+	lst := NotificationListener{
+		ID:        s.listenerSeq,
+		Name:      req.Cmd.Opts["name"],
+		User:      req.User,
+		Connected: time.Now(),
+		conn:      req.Conn,
+		out:       req.out,
+	}
 	// FIXME: Make thread-safe
 	s.listeners = append(s.listeners, lst)
 	return lst, nil
 }
 
 // Initiate the server to shut down, accepting no further connections.
+// Requests already accepted are still served. Safe to call repeatedly.
 func (s *Server) InitiateShutdown() {
-	close(s.shutdownChan)
-	if r := recover(); r != nil {
-		s.logWarn(r)
-	}
+	s.shutdownOnce.Do(func() {
+		close(s.shutdownChan)
+	})
 }
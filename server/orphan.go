@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/transport"
+	"github.com/pkg/errors"
+)
+
+// The running task is kept in this file in the configuration directory, so
+// that it survives the server dying unexpectedly.
+const runningTaskFile = "running_task.json"
+
+// The ID of the server process is kept in this file in the socket directory.
+const pidFile = "pid"
+
+// How often the running task is persisted to keep track of the server being
+// alive.
+const aliveInterval = time.Minute
+
+// The running task as persisted by the server.
+type runningTask struct {
+	Task  msg.Task  `json:"task"`
+	Alive time.Time `json:"alive"` // The latest time the server was known to be running
+}
+
+// Remember the server's process ID to tell a stale socket from a live one.
+func (s *Server) writePidFile() {
+	if s.conf.Protocol.Value != transport.Unix {
+		return
+	}
+	file := filepath.Join(s.conf.SocketDir(), pidFile)
+	if err := ioutil.WriteFile(file, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		s.logWarn("Unable to write the process ID:", err)
+	}
+}
+
+// Forget the server's process ID.
+func (s *Server) removePidFile() {
+	if s.conf.Protocol.Value != transport.Unix {
+		return
+	}
+	os.Remove(filepath.Join(s.conf.SocketDir(), pidFile))
+}
+
+// Whether the unix socket was left behind by a server which is no longer
+// running, e.g. after it was killed.
+func socketIsStale(conf *config.Opts) bool {
+	if conf.Protocol.Value != transport.Unix {
+		return false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(conf.SocketDir(), pidFile))
+	if err != nil {
+		// Without a process ID, the server is assumed to be alive.
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return err == nil && !processExists(pid)
+}
+
+// Keep the running task, or remove it once there is none. Nothing is kept for
+// other users of a shared server or when replaying.
+func (s *Server) persistRunningTask(now time.Time) {
+	if s.runningTaskFile == "" || s.foreign() {
+		return
+	}
+	if !s.CurrentTask.IsRunning() {
+		if err := os.Remove(s.runningTaskFile); err != nil && !os.IsNotExist(err) {
+			s.logWarn("Unable to remove the running task:", err)
+		}
+		return
+	}
+	data, err := json.Marshal(runningTask{Task: s.CurrentTask, Alive: now})
+	if err == nil {
+		// Replace the file atomically to never leave it half-written.
+		tmp := s.runningTaskFile + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0600); err == nil {
+			err = os.Rename(tmp, s.runningTaskFile)
+		}
+	}
+	if err != nil {
+		s.logWarn("Unable to persist the running task:", err)
+	}
+}
+
+// OrphanedTask gives the task left running by a server which stopped
+// unexpectedly, along with the latest time that server was known to be alive.
+// Only meaningful while no server is running.
+func OrphanedTask(conf *config.Opts) (msg.Task, time.Time, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(conf.ConfigDir(), runningTaskFile))
+	if err != nil {
+		return msg.Task{}, time.Time{}, false
+	}
+	var rt runningTask
+	if err := json.Unmarshal(data, &rt); err != nil || !rt.Task.IsRunning() || rt.Task.Name == "" {
+		return msg.Task{}, time.Time{}, false
+	}
+	return rt.Task, rt.Alive, true
+}
+
+// Deal with a task left running by a previous server as configured: continue
+// it, or save it as ended when the server was last known to be alive. Unless
+// the client asked the user, the task is saved so as not to count the time in
+// between.
+func (s *Server) adoptOrphanedTask() {
+	task, alive, ok := OrphanedTask(s.conf)
+	if !ok {
+		return
+	}
+	if s.conf.OrphanedTask.Value == config.ORPHAN_ADOPT {
+		s.logInfo("Continuing task left running by the previous server:", task.Name)
+		s.CurrentTask = task
+		return
+	}
+	task.HasEnded = true
+	task.Ended = alive
+	if task.Ended.Before(task.Started) {
+		task.Ended = task.Started
+	}
+	s.logInfo("Saving task left running by the previous server:", task.Name)
+	if err := s.SaveTask(task); err != nil {
+		s.logError(errors.Wrap(err, "Unable to save the orphaned task"))
+		return
+	}
+	s.persistRunningTask(time.Now())
+}
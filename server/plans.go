@@ -0,0 +1,36 @@
+package server
+
+import (
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// Planner gives the backend's store of planned entries, if it has one.
+func (s *Server) Planner() (backend.Planner, error) {
+	if p, ok := backend.Unwrap(s.Backend).(backend.Planner); ok {
+		return p, nil
+	}
+	return nil, errors.Errorf("The %s backend does not support planned entries", s.Backend.Name())
+}
+
+// DefaultPlanTask is the task for imported events not naming one.
+func (s *Server) DefaultPlanTask() string {
+	return s.conf.PlanTask.Value
+}
+
+// ConfirmPlan records the planned entry and marks it as confirmed.
+func (s *Server) ConfirmPlan(plan msg.Plan) error {
+	planner, err := s.Planner()
+	if err != nil {
+		return err
+	}
+	if plan.Confirmed {
+		return errors.Errorf("Already confirmed: %s", plan.ID)
+	}
+	if err := s.SaveTask(plan.Entry()); err != nil {
+		return errors.Wrapf(err, "Unable to confirm %s", plan.ID)
+	}
+	plan.Confirmed = true
+	return planner.SavePlans([]msg.Plan{plan})
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package server
+
+import "syscall"
+
+// Process attributes detaching a background server from the terminal, so that
+// it survives the terminal being closed. This is required on macOS in
+// particular, where closing a terminal window ends its whole session.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// Whether the process with the given ID exists.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,14 @@
+package server
+
+import "syscall"
+
+// Process attributes for a background server, no special treatment required.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{}
+}
+
+// Whether the process with the given ID exists. Not determined on Windows,
+// where unix sockets are not used anyway.
+func processExists(pid int) bool {
+	return true
+}
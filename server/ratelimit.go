@@ -0,0 +1,147 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Clients whose buckets are full are forgotten once there are this many.
+const maxIdleBuckets = 1000
+
+// A number of requests permitted per period.
+type rateLimit struct {
+	requests int
+	per      time.Duration
+}
+
+// Parse a rate limit given as e.g. 60/1m. Zero means no limit.
+func parseRateLimit(str string) (rateLimit, error) {
+	if str == "" || str == "0" {
+		return rateLimit{}, nil
+	}
+	parts := strings.SplitN(str, "/", 2)
+	if len(parts) != 2 {
+		return rateLimit{}, errors.Errorf("Invalid rate limit: %s", str)
+	}
+	n, err := strconv.Atoi(parts[0])
+	if err != nil || n <= 0 {
+		return rateLimit{}, errors.Errorf("Invalid rate limit: %s", str)
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return rateLimit{}, errors.Errorf("Invalid rate limit: %s", str)
+	}
+	return rateLimit{requests: n, per: per}, nil
+}
+
+// A token bucket, refilled continuously up to the permitted requests.
+type bucket struct {
+	available float64
+	updated   time.Time
+}
+
+// Keeps track of the requests made by each client.
+type limiter struct {
+	limit   rateLimit
+	buckets map[string]*bucket
+}
+
+// A limiter for the configured rate; nil if there is no limit.
+func newLimiter(conf string) (*limiter, error) {
+	limit, err := parseRateLimit(conf)
+	if err != nil || limit.requests == 0 {
+		return nil, err
+	}
+	return &limiter{limit: limit, buckets: make(map[string]*bucket)}, nil
+}
+
+// Take a request from the client's bucket. If it is empty, the time until the
+// next request is permitted is returned.
+func (l *limiter) take(client string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	capacity := float64(l.limit.requests)
+	rate := capacity / float64(l.limit.per)
+	b, ok := l.buckets[client]
+	if !ok {
+		l.prune(now)
+		b = &bucket{available: capacity, updated: now}
+		l.buckets[client] = b
+	}
+	b.available += rate * float64(now.Sub(b.updated))
+	if b.available > capacity {
+		b.available = capacity
+	}
+	b.updated = now
+	if b.available < 1 {
+		return false, time.Duration((1 - b.available) / rate)
+	}
+	b.available--
+	return true, 0
+}
+
+// Forget the clients who could make the maximum number of requests anyway.
+func (l *limiter) prune(now time.Time) {
+	if len(l.buckets) < maxIdleBuckets {
+		return
+	}
+	for client, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.per {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// The error for a throttled request.
+func (l *limiter) exceeded(wait time.Duration) error {
+	return errors.Errorf("Rate limit of %d requests per %v exceeded, retry in %v",
+		l.limit.requests, l.limit.per, (wait + time.Second - 1).Truncate(time.Second))
+}
+
+// Set up the configured rate limits.
+func (s *Server) loadRateLimits() error {
+	var err error
+	if s.clientLimit, err = newLimiter(s.conf.RateLimit.Value); err != nil {
+		return err
+	}
+	s.tokenLimit, err = newLimiter(s.conf.RateLimitToken.Value)
+	return err
+}
+
+// The client making a request for the purpose of rate limiting: the user in
+// multi-user mode, else the remote host. Local clients share a single limit.
+func clientOf(req *Request) string {
+	if req.User != "" {
+		return "user:" + req.User
+	}
+	if addr, ok := req.Conn.RemoteAddr().(*net.TCPAddr); ok {
+		return "host:" + addr.IP.String()
+	}
+	return "local"
+}
+
+// Check the request against the rate limit for its client. This happens
+// before authorization, so that failed attempts count as well.
+func (s *Server) throttleClient(req *Request) error {
+	if ok, wait := s.clientLimit.take(clientOf(req), time.Now()); !ok {
+		return s.clientLimit.exceeded(wait)
+	}
+	return nil
+}
+
+// Check the request against the rate limit for its API token, if any. Only
+// valid tokens are checked, so that invented ones take up no buckets.
+func (s *Server) throttleToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	if ok, wait := s.tokenLimit.take(token, time.Now()); !ok {
+		return s.tokenLimit.exceeded(wait)
+	}
+	return nil
+}
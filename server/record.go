@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// A command as received by the server, one per line in the record file.
+type recordedCmd struct {
+	Time time.Time `json:"time"`
+	Cmd  msg.Cmd   `json:"cmd"`
+}
+
+// Start appending incoming commands to the configured file, if any.
+func (s *Server) startRecording() error {
+	if s.conf.RecordFile.Value == "" {
+		return nil
+	}
+	f, err := os.OpenFile(s.conf.RecordFile.Value, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Unable to open record file")
+	}
+	s.recording = f
+	return nil
+}
+
+// Stop recording commands.
+func (s *Server) stopRecording() {
+	if s.recording != nil {
+		s.recording.Close()
+		s.recording = nil
+	}
+}
+
+// Record a command, if enabled. Failure is logged but not fatal.
+func (s *Server) record(cmd msg.Cmd) {
+	if s.recording == nil {
+		return
+	}
+	if err := writeJsonLine(recordedCmd{Time: time.Now(), Cmd: cmd}, s.recording); err != nil {
+		s.logError(errors.Wrap(err, "Failed to record command"))
+	}
+}
+
+// Replay executes the commands recorded in the file against the configured
+// backend, which has to be empty. Task times are taken from the recording.
+// Returns the number of commands executed and the time it took.
+func Replay(conf *config.Opts, file string) (int, time.Duration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Unable to open recording")
+	}
+	defer f.Close()
+
+	s, err := replayServer(conf)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer s.Backend.Close()
+	defer func() { msg.Clock = time.Now }()
+
+	count := 0
+	begin := time.Now()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() && !s.shuttingDown() {
+		rec := recordedCmd{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return count, time.Since(begin), errors.Wrapf(err, "Malformed command in line %d", count+1)
+		}
+		msg.Clock = func() time.Time { return rec.Time }
+		if err := s.replay(rec.Cmd); err != nil {
+			s.logError(err)
+		}
+		count++
+	}
+	if s.CurrentTask.IsRunning() {
+		s.logWarn("Task still active at the end of the recording:", s.CurrentTask.Name)
+	}
+	return count, time.Since(begin), scanner.Err()
+}
+
+// A server without connections or side effects to replay commands with.
+func replayServer(conf *config.Opts) (*Server, error) {
+	quiet := *conf
+	quiet.HookOnStart.Value = ""
+	quiet.HookOnStop.Value = ""
+	quiet.HookOnAbort.Value = ""
+	quiet.HookOnShutdown.Value = ""
+	quiet.RecordFile.Value = ""
+	s := &Server{conf: &quiet}
+	s.shutdownChan = make(chan struct{})
+	s.stateRequests = make(chan chan stateReport)
+	s.healthRequests = make(chan chan HealthReport)
+	s.icsRequests = make(chan chan calendarReply)
+
+	b, err := backend.From(s.conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Init(); err != nil {
+		return nil, err
+	}
+	if recent, err := b.RecentTasks(1); err != nil {
+		b.Close()
+		return nil, err
+	} else if len(recent) > 0 {
+		b.Close()
+		return nil, errors.New("Replay requires an empty backend, e.g. --db-file=/tmp/replay.db")
+	}
+	s.Backend = b
+	s.CurrentTask = msg.IdleTask()
+	return s, nil
+}
+
+// Execute a single command, discarding the answer.
+func (s *Server) replay(cmd msg.Cmd) error {
+	srvConn, cliConn := net.Pipe()
+	go func() {
+		io.Copy(ioutil.Discard, cliConn)
+		cliConn.Close()
+	}()
+	cmd.Compression = ""
+	return s.Dispatch(newRequest(srvConn, cmd))
+}
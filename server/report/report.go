@@ -0,0 +1,120 @@
+// Package report sends weekly summaries of the recorded activity by mail.
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/fgahr/tilo/argparse/quantifier"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/format"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// Schedule is a weekly point in time, e.g. Friday at 17:00.
+type Schedule struct {
+	weekday time.Weekday
+	hour    int
+	minute  int
+}
+
+// ParseSchedule parses a schedule like "fri 17:00".
+func ParseSchedule(str string) (Schedule, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 2 {
+		return Schedule{}, errors.Errorf("Invalid schedule: %s (expected e.g. fri 17:00)", str)
+	}
+	weekday, err := parseWeekday(fields[0])
+	if err != nil {
+		return Schedule{}, err
+	}
+	t, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return Schedule{}, errors.Errorf("Invalid time of day: %s", fields[1])
+	}
+	return Schedule{weekday: weekday, hour: t.Hour(), minute: t.Minute()}, nil
+}
+
+func parseWeekday(str string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if len(str) >= 3 && strings.HasPrefix(name, strings.ToLower(str)) {
+			return d, nil
+		}
+	}
+	return time.Sunday, errors.Errorf("Invalid day of the week: %s", str)
+}
+
+// Next determines the first scheduled time after t.
+func (s Schedule) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.hour, s.minute, 0, 0, t.Location())
+	next = next.AddDate(0, 0, (int(s.weekday)-int(t.Weekday())+7)%7)
+	if !next.After(t) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// Weekly summarizes this week's activity across all tasks.
+func Weekly(b backend.Backend, now time.Time) (msg.Response, error) {
+	resp := msg.Response{}
+	week, err := quantifier.FixedWeekOffset(now, 0).Parse("")
+	if err != nil {
+		return resp, err
+	}
+	start, end, err := quantifier.Range(week[0])
+	if err != nil {
+		return resp, err
+	}
+	sum, err := b.GetAllTasksBetween(start, end, msg.Source{})
+	if err != nil {
+		return resp, errors.Wrap(err, "Error in database query")
+	}
+	for i := range sum {
+		sum[i].Details = week[0]
+	}
+	if len(sum) == 0 {
+		resp.AddMessage("No activity recorded this week")
+	}
+	resp.AddQuerySummaries(sum)
+	return resp, nil
+}
+
+// Send renders the response as text and mails it to the configured recipient.
+func Send(conf *config.Opts, subject string, resp msg.Response) error {
+	if conf.SMTPHost.Value == "" || conf.ReportTo.Value == "" {
+		return errors.New("Mail requires smtp_host and report_to")
+	}
+	f, err := format.Get(format.Text)
+	if err != nil {
+		return err
+	}
+	body := bytes.Buffer{}
+	if err := f.Format(&body, resp); err != nil {
+		return err
+	}
+
+	from := conf.SMTPFrom.Value
+	if from == "" {
+		from = conf.ReportTo.Value
+	}
+	to := strings.Split(conf.ReportTo.Value, ",")
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		from, strings.Join(to, ", "), subject, strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	var auth smtp.Auth
+	if conf.SMTPUser.Value != "" {
+		host, _, err := net.SplitHostPort(conf.SMTPHost.Value)
+		if err != nil {
+			return errors.Wrap(err, "Invalid smtp_host, expected host:port")
+		}
+		auth = smtp.PlainAuth("", conf.SMTPUser.Value, conf.SMTPPassword.Value, host)
+	}
+	return errors.Wrap(smtp.SendMail(conf.SMTPHost.Value, auth, from, to, []byte(message)), "Failed to send mail")
+}
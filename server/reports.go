@@ -0,0 +1,37 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/server/report"
+	"github.com/pkg/errors"
+)
+
+// The schedule for weekly reports. Returns false if none is configured.
+func (s *Server) reportSchedule() (report.Schedule, bool) {
+	if s.conf.ReportSchedule.Value == "" {
+		return report.Schedule{}, false
+	}
+	schedule, err := report.ParseSchedule(s.conf.ReportSchedule.Value)
+	if err != nil {
+		s.logWarn("Ignoring report schedule:", err)
+		return schedule, false
+	}
+	return schedule, true
+}
+
+// Mail a summary of this week's activity.
+func (s *Server) SendWeeklyReport() error {
+	s.logInfo("Sending weekly report..")
+	now := time.Now()
+	resp, err := report.Weekly(s.Backend, now)
+	if err == nil {
+		err = report.Send(s.conf, "Weekly summary "+now.Format("2006-01-02"), resp)
+	}
+	if err != nil {
+		s.logError(err)
+		return errors.Wrap(err, "Failed to send weekly report")
+	}
+	s.logInfo("OK")
+	return nil
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// The time between notifications that the task is still running; zero if
+// disabled.
+func (s *Server) runningInterval() time.Duration {
+	interval, err := time.ParseDuration(s.conf.RunningInterval.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid running interval:", err)
+		return 0
+	}
+	return interval
+}
+
+// Notify listeners that the task is still running, along with the time
+// elapsed, and mark it in the history.
+func (s *Server) notifyRunning(now time.Time) {
+	task := s.CurrentTask
+	if !task.IsRunning() {
+		return
+	}
+	ntf := TaskNotification(task)
+	ntf.Event = EventRunning
+	ntf.Elapsed = now.Sub(task.Started).Truncate(time.Second)
+	s.notify(ntf)
+	s.recordEvent(msg.RespRunningTask, task.Name, now)
+}
@@ -0,0 +1,105 @@
+// Package sample generates a plausible history of recorded entries, e.g. for
+// demonstrations and for testing reports at a realistic scale.
+package sample
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+)
+
+// Names of the generated tasks, the first ones worked on most.
+var names = []string{
+	"project-alpha",
+	"meetings",
+	"project-beta",
+	"email",
+	"review",
+	"docs",
+	"support",
+	"research",
+}
+
+// TaskNames gives the names of the given number of generated tasks.
+func TaskNames(tasks int) []string {
+	var result []string
+	for i := 0; i < tasks; i++ {
+		if i < len(names) {
+			result = append(result, names[i])
+		} else {
+			result = append(result, fmt.Sprintf("task-%d", i+1))
+		}
+	}
+	return result
+}
+
+// Entries gives the entries of a working day on most weekdays among the given
+// number of days up to now, in local time and chronological order. The same
+// source of randomness gives the same entries.
+func Entries(rng *rand.Rand, now time.Time, days int, tasks int) []msg.Task {
+	if tasks <= 0 {
+		return nil
+	}
+	tasknames := TaskNames(tasks)
+	var entries []msg.Task
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	for d := days - 1; d >= 0; d-- {
+		day := today.AddDate(0, 0, -d)
+		weekend := day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+		if (weekend && rng.Intn(10) > 0) || (!weekend && rng.Intn(20) == 0) {
+			// Mostly free on weekends, the odd day off during the week
+			continue
+		}
+		for _, entry := range workingDay(rng, day, weekend, tasknames) {
+			if entry.Ended.After(now) {
+				break
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// The entries of a single day, starting in the morning with a lunch break.
+func workingDay(rng *rand.Rand, day time.Time, short bool, tasknames []string) []msg.Task {
+	t := day.Add(8*time.Hour + minutes(rng, 0, 90))
+	end := day.Add(16*time.Hour + minutes(rng, 30, 120))
+	if short {
+		end = t.Add(minutes(rng, 60, 180))
+	}
+	lunch := false
+	var entries []msg.Task
+	for t.Before(end) {
+		if !lunch && t.Hour() >= 12 {
+			t = t.Add(minutes(rng, 30, 60))
+			lunch = true
+		}
+		ended := t.Add(minutes(rng, 15, 120))
+		if ended.After(end) {
+			ended = end
+		}
+		if ended.Sub(t) >= 5*time.Minute {
+			entries = append(entries, msg.Task{
+				Name:     pick(rng, tasknames),
+				Started:  t,
+				Ended:    ended,
+				HasEnded: true,
+			})
+		}
+		t = ended.Add(minutes(rng, 0, 15))
+	}
+	return entries
+}
+
+// A task, earlier ones more likely.
+func pick(rng *rand.Rand, tasknames []string) string {
+	i := int(rng.ExpFloat64() * float64(len(tasknames)) / 3)
+	return tasknames[i%len(tasknames)]
+}
+
+// A random number of minutes between min and max, whole minutes only.
+func minutes(rng *rand.Rand, min int, max int) time.Duration {
+	return time.Duration(min+rng.Intn(max-min+1)) * time.Minute
+}
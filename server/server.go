@@ -3,17 +3,26 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/fgahr/tilo/config"
 	"github.com/fgahr/tilo/msg"
 	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/server/mqtt"
+	"github.com/fgahr/tilo/server/webhook"
+	"github.com/fgahr/tilo/transport"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 )
@@ -23,9 +32,16 @@ var operations = make(map[string]Operation)
 type Request struct {
 	Conn net.Conn
 	Cmd  msg.Cmd
+	User string         // The user making the request; empty unless in multi-user mode
+	out  io.WriteCloser // Everything sent to the client, possibly compressed
+}
+
+func newRequest(conn net.Conn, cmd msg.Cmd) *Request {
+	return &Request{Conn: conn, Cmd: cmd, out: msg.Compressor(conn, cmd)}
 }
 
 func (req *Request) Close() error {
+	req.out.Close()
 	return req.Conn.Close()
 }
 
@@ -41,25 +57,52 @@ func RegisterOperation(name string, operation Operation) {
 // A tilo Server. When the configuration is provided, the remaining fields
 // are filled by the .init() method.
 type Server struct {
-	shutdownChan   chan struct{}          // Used to communicate shutdown requests
-	conf           *config.Opts           // Configuration parameters for this instance
-	Backend        backend.Backend        // The database backend
-	socketListener net.Listener           // Listener on the client request socket
-	CurrentTask    msg.Task               // The currently active task, if any
-	listeners      []NotificationListener // Listeners for task change notifications
+	shutdownChan    chan struct{}            // Used to communicate shutdown requests
+	shutdownOnce    sync.Once                // Guards closing the shutdown channel
+	conf            *config.Opts             // Configuration parameters for this instance
+	Backend         backend.Backend          // The database backend
+	socketListener  net.Listener             // Listener on the client request socket
+	CurrentTask     msg.Task                 // The currently active task, if any
+	listeners       []NotificationListener   // Listeners for task change notifications
+	listenerSeq     int                      // The ID of the latest listener registered
+	eventSeq        uint64                   // The sequence number of the latest event
+	events          []Notification           // The latest events, oldest first
+	webhook         *webhook.Dispatcher      // Posts task changes to a chat, if configured
+	mqtt            *mqtt.Publisher          // Publishes task changes to a broker, if configured
+	stateServer     *http.Server             // Serves the current state via HTTP, if configured
+	stateRequests   chan chan stateReport    // State requests from the HTTP endpoint
+	healthRequests  chan chan HealthReport   // Health checks from the HTTP endpoint
+	icsRequests     chan chan calendarReply  // Calendar requests from the HTTP endpoint
+	calendarWeeks   int                      // Weeks of entries served as a calendar
+	started         time.Time                // When the server was started
+	timings         *backend.Timings         // Time spent in backend calls
+	budgets         map[string]time.Duration // Configured budgets per task
+	budgetState     budgetState              // Budget warnings issued for the running task
+	goals           map[string]time.Duration // Time to be spent on tasks per week
+	recording       *os.File                 // Incoming commands are appended here, if configured
+	lastActivity    map[string]time.Time     // Latest sign of activity per task
+	away            *Away                    // A period without activity awaiting a decision
+	owner           string                   // The user running the server, in multi-user mode
+	ownBackend      backend.Backend          // The owner's backend, in multi-user mode
+	user            string                   // The user being served, in multi-user mode
+	workspaces      map[string]*workspace    // The data of users other than the owner
+	tokens          []apiToken               // Accepted API tokens, if configured
+	clientLimit     *limiter                 // Requests permitted per client, if limited
+	tokenLimit      *limiter                 // Requests permitted per API token, if limited
+	build           *msg.Build               // The build of this server, sent with each response
+	runningTaskFile string                   // Keeps the running task in case the server dies; empty when replaying
 }
 
 // Start server operation.
 // This function will block until server shutdown.
 func Run(conf *config.Opts) error {
-	s := Server{conf: conf}
+	s := Server{conf: conf, build: msg.CurrentBuild()}
 	if err := s.init(); err != nil {
 		return errors.Wrap(err, "Failed to initialize server")
 	}
 
 	// Ensure clean shutdown if at all possible.
 	defer s.enforceCleanup()
-	defer close(s.shutdownChan)
 
 	s.main()
 	return nil
@@ -67,13 +110,11 @@ func Run(conf *config.Opts) error {
 
 // Check whether the server is running.
 func IsRunning(conf *config.Opts) (bool, error) {
-	_, err := os.Stat(conf.Socket.Value)
-	if os.IsNotExist(err) {
-		return false, nil
-	} else if err != nil {
-		return false, errors.Wrap(err, "Could not determine server status")
+	running, err := transport.IsServerUp(conf)
+	if running && socketIsStale(conf) {
+		running = false
 	}
-	return true, nil
+	return running, errors.Wrap(err, "Could not determine server status")
 }
 
 // Check whether the server is currently in shutdown.
@@ -93,41 +134,88 @@ func ensureDirExists(dir string) error {
 
 // Start the server, initiating required connections.
 func (s *Server) init() error {
-	if running, err := IsRunning(s.conf); err != nil {
-		return err
-	} else if running {
-		return errors.New("Cannot start server: Already running.")
+	// Under socket activation, the socket exists before the server is up.
+	if !transport.Activated() {
+		if running, err := IsRunning(s.conf); err != nil {
+			return err
+		} else if running {
+			return errors.New("Cannot start server: Already running.")
+		} else if socketIsStale(s.conf) {
+			s.logWarn("Removing the socket of a server which stopped unexpectedly")
+			os.Remove(s.conf.Socket.Value)
+		}
 	}
 
 	s.shutdownChan = make(chan struct{})
+	s.stateRequests = make(chan chan stateReport)
+	s.healthRequests = make(chan chan HealthReport)
+	s.icsRequests = make(chan chan calendarReply)
+	s.started = time.Now()
 
 	// Create directories if necessary
 	if err := ensureDirExists(s.conf.ConfigDir()); err != nil {
 		return err
 	}
 
-	if err := ensureDirExists(s.conf.SocketDir()); err != nil {
+	// Establish database connection.
+	b, err := backend.From(s.conf)
+	if err != nil {
 		return err
 	}
-
-	// Establish database connection.
-	backend := backend.From(s.conf)
-	if err := backend.Init(); err != nil {
-		s.socketListener.Close()
-		backend.Close()
+	if err := b.Init(); err != nil {
+		return err
+	}
+	s.timings = backend.NewTimings()
+	s.Backend = s.wrap(b)
+	if err := s.initUsers(); err != nil {
+		s.Backend.Close()
+		return err
+	}
+	if err := s.loadTokens(); err != nil {
+		s.Backend.Close()
+		return err
+	}
+	if err := s.loadCalendarWeeks(); err != nil {
+		s.Backend.Close()
 		return err
-	} else {
-		s.Backend = backend
+	}
+	if err := s.loadRateLimits(); err != nil {
+		s.Backend.Close()
+		return err
+	}
+	if err := s.seedChangeLog(); err != nil {
+		s.Backend.Close()
+		return errors.Wrap(err, "Unable to prepare the change log")
 	}
 
 	// Open request socket.
-	if requestListener, err := net.Listen(s.conf.Protocol.Value, s.conf.Socket.Value); err != nil {
+	if requestListener, err := transport.Listen(s.conf); err != nil {
+		s.Backend.Close()
 		return err
 	} else {
 		s.socketListener = requestListener
 	}
+	s.writePidFile()
 
+	s.setPrecision()
 	s.CurrentTask = msg.IdleTask()
+	s.runningTaskFile = filepath.Join(s.conf.ConfigDir(), runningTaskFile)
+	s.adoptOrphanedTask()
+	s.loadBudgets()
+	s.loadGoals()
+	if err := s.startStateEndpoint(); err != nil {
+		s.socketListener.Close()
+		s.Backend.Close()
+		return err
+	}
+	if err := s.startRecording(); err != nil {
+		s.stopStateEndpoint()
+		s.socketListener.Close()
+		s.Backend.Close()
+		return err
+	}
+	s.startWebhook()
+	s.startMQTT()
 
 	return nil
 }
@@ -145,19 +233,115 @@ func (s *Server) main() {
 	// Signal channel needs to be buffered, see documentation.
 	sigChan := make(chan os.Signal, 1)
 	srvChan := make(chan net.Conn)
-	defer close(srvChan)
 
 	// Enable cleanup on receiving SIGTERM.
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	// Enable connection processing.
 	go s.waitForConnection(s.socketListener, srvChan)
 
+	// Enable shutdown when idle.
+	idleTimeout := s.idleTimeout()
+	idleTimer := time.NewTimer(idleTimeout)
+	defer idleTimer.Stop()
+	var idleChan <-chan time.Time
+	if idleTimeout > 0 {
+		idleChan = idleTimer.C
+	}
+
+	// Enable periodic backups.
+	var backupChan <-chan time.Time
+	if interval := s.backupInterval(); interval > 0 {
+		backupTicker := time.NewTicker(interval)
+		defer backupTicker.Stop()
+		backupChan = backupTicker.C
+	}
+
+	// Enable budget warnings.
+	var budgetChan <-chan time.Time
+	if len(s.budgets) > 0 {
+		budgetTicker := time.NewTicker(budgetCheckInterval)
+		defer budgetTicker.Stop()
+		budgetChan = budgetTicker.C
+	}
+
+	// Enable notifications of the running task.
+	var runningChan <-chan time.Time
+	if interval := s.runningInterval(); interval > 0 {
+		runningTicker := time.NewTicker(interval)
+		defer runningTicker.Stop()
+		runningChan = runningTicker.C
+	}
+
+	// Enable weekly reports.
+	var reportChan <-chan time.Time
+	schedule, scheduled := s.reportSchedule()
+	reportTimer := time.NewTimer(time.Until(schedule.Next(time.Now())))
+	defer reportTimer.Stop()
+	if scheduled {
+		reportChan = reportTimer.C
+	}
+
+	// Enable daily summaries.
+	var summaryChan <-chan time.Time
+	nextSummary, summaries := s.nextWebhookSummary(time.Now())
+	summaryTimer := time.NewTimer(time.Until(nextSummary))
+	defer summaryTimer.Stop()
+	if summaries {
+		summaryChan = summaryTimer.C
+	}
+
+	// Notify the systemd watchdog, if enabled.
+	var watchdogChan <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		watchdogTicker := time.NewTicker(interval / 2)
+		defer watchdogTicker.Stop()
+		watchdogChan = watchdogTicker.C
+	}
+
+	// Keep track of the server being alive while a task is running.
+	aliveTicker := time.NewTicker(aliveInterval)
+	defer aliveTicker.Stop()
+
+	s.logInfo("Running tilo", s.build)
 	s.logDebug("Starting server main loop.")
 MainLoop:
 	for {
 		select {
 		case conn := <-srvChan:
 			s.serveConnection(conn)
+			resetTimer(idleTimer, idleTimeout)
+		case <-idleChan:
+			if s.isIdle() {
+				s.logInfo("Idle for", idleTimeout)
+				break MainLoop
+			}
+			idleTimer.Reset(idleTimeout)
+		case reply := <-s.stateRequests:
+			// Deliberately not resetting the idle timer for polling clients.
+			reply <- s.currentState(time.Now())
+		case reply := <-s.healthRequests:
+			reply <- s.Health(time.Now())
+		case reply := <-s.icsRequests:
+			reply <- s.calendarEntries(time.Now())
+		case now := <-watchdogChan:
+			s.feedWatchdog(now)
+		case now := <-budgetChan:
+			s.checkBudget(now)
+		case now := <-runningChan:
+			s.notifyRunning(now)
+		case now := <-aliveTicker.C:
+			if s.CurrentTask.IsRunning() {
+				s.persistRunningTask(now)
+			}
+		case <-backupChan:
+			s.Backup()
+		case now := <-summaryChan:
+			s.postDailySummary(now)
+			nextSummary, _ = s.nextWebhookSummary(now)
+			summaryTimer.Reset(time.Until(nextSummary))
+		case <-reportChan:
+			s.SendWeeklyReport()
+			reportTimer.Reset(time.Until(schedule.Next(time.Now())))
 		case sig := <-sigChan:
 			s.logDebug("Received signal: ", sig)
 			break MainLoop
@@ -165,10 +349,149 @@ MainLoop:
 			break MainLoop
 		}
 	}
+	s.drain(srvChan, sigChan)
+}
+
+// Stop accepting connections and serve those already accepted. Gives up
+// after the grace period or on another signal.
+func (s *Server) drain(srvChan <-chan net.Conn, sigChan <-chan os.Signal) {
+	s.InitiateShutdown()
+	s.socketListener.Close()
+	s.socketListener = nil
+
+	grace := s.shutdownGrace()
+	deadline := time.Now().Add(grace)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+Drain:
+	for {
+		select {
+		case conn, ok := <-srvChan:
+			if !ok {
+				return
+			}
+			conn.SetDeadline(deadline)
+			s.serveConnection(conn)
+		case <-timer.C:
+			s.logWarn("Grace period expired, dropping pending requests")
+			break Drain
+		case sig := <-sigChan:
+			s.logWarn("Received signal during shutdown, dropping pending requests:", sig)
+			break Drain
+		}
+	}
+	// Release connections the client would otherwise wait on.
+	go func() {
+		for conn := range srvChan {
+			conn.Close()
+		}
+	}()
+}
+
+// The duration after which an idle server shuts down. Zero if it should keep
+// running indefinitely.
+func (s *Server) idleTimeout() time.Duration {
+	timeout, err := time.ParseDuration(s.conf.IdleTimeout.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid idle timeout:", err)
+		return 0
+	}
+	return timeout
+}
+
+// The time granted to pending requests on shutdown.
+func (s *Server) shutdownGrace() time.Duration {
+	grace, err := time.ParseDuration(s.conf.ShutdownGrace.Value)
+	if err != nil || grace < 0 {
+		s.logWarn("Ignoring invalid shutdown grace period:", s.conf.ShutdownGrace.Value)
+		return 0
+	}
+	return grace
+}
+
+// Backend calls taking longer than this are logged. Zero if disabled.
+func (s *Server) slowQuery() time.Duration {
+	slow, err := time.ParseDuration(s.conf.SlowQuery.Value)
+	if err != nil || slow < 0 {
+		s.logWarn("Ignoring invalid slow query threshold:", s.conf.SlowQuery.Value)
+		return 0
+	}
+	return slow
+}
+
+// The wait before retrying a backend call, doubled for each further attempt.
+const retryBackoff = 50 * time.Millisecond
+
+// The number of further attempts after a transient backend error.
+func (s *Server) backendRetries() int {
+	retries, err := strconv.Atoi(s.conf.BackendRetries.Value)
+	if err != nil || retries < 0 {
+		s.logWarn("Ignoring invalid number of backend retries:", s.conf.BackendRetries.Value)
+		return 0
+	}
+	return retries
+}
+
+// Record the time spent in calls of the backend, logging slow ones, and retry
+// calls failing with transient errors.
+func (s *Server) wrap(b backend.Backend) backend.Backend {
+	return backend.Wrap(b, backend.Policy{
+		Timings: s.timings,
+		Slow:    s.slowQuery(),
+		OnSlow: func(method string, d time.Duration) {
+			s.logWarn("Slow backend call:", b.Name(), method, "took", d)
+		},
+		Retries: s.backendRetries(),
+		Backoff: retryBackoff,
+	})
+}
+
+// Set the granularity of task times as configured.
+func (s *Server) setPrecision() {
+	switch s.conf.TimestampPrecision.Value {
+	case config.PRECISION_SECONDS:
+		msg.Precision = time.Second
+	case config.PRECISION_MILLIS:
+		msg.Precision = time.Millisecond
+	default:
+		s.logWarn("Ignoring invalid timestamp precision:", s.conf.TimestampPrecision.Value)
+		msg.Precision = time.Second
+	}
+}
+
+// The time between automatic backups. Zero if disabled.
+func (s *Server) backupInterval() time.Duration {
+	if s.conf.BackupTarget.Value == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(s.conf.BackupInterval.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid backup interval:", err)
+		return 0
+	}
+	return interval
+}
+
+// Whether the server is idle, i.e. there is no active task and no listener.
+func (s *Server) isIdle() bool {
+	return !s.CurrentTask.IsRunning() && !s.othersBusy() && len(s.listeners) == 0
+}
+
+// Reset a timer that may or may not have fired yet.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
 }
 
-// Wait for a client to connect. Send connections to the given channel.
+// Wait for a client to connect. Send connections to the given channel,
+// closing it once the listener is closed on shutdown.
 func (s *Server) waitForConnection(lst net.Listener, srvChan chan<- net.Conn) {
+	defer close(srvChan)
 	for {
 		if conn, err := lst.Accept(); err != nil {
 			if s.shuttingDown() {
@@ -186,11 +509,54 @@ func (s *Server) waitForConnection(lst net.Listener, srvChan chan<- net.Conn) {
 func (s *Server) serveConnection(conn net.Conn) {
 	dec := json.NewDecoder(conn)
 	cmd := msg.Cmd{}
-	if err := dec.Decode(&cmd); err != nil {
+	if err := dec.Decode(&cmd); err == io.EOF {
+		// Clients probing whether the server is up close without a command.
+		conn.Close()
+		return
+	} else if err != nil {
 		s.logError(errors.Wrap(err, "Failed to decode command"))
 	}
-	if err := s.Dispatch(&Request{conn, cmd}); err != nil {
-		s.logError(errors.Wrap(err, "Unable to execute command"))
+	req := newRequest(conn, cmd)
+	user, err := s.identify(conn)
+	if user != "" {
+		// Entries are attributed to the verified user, not the claimed one.
+		req.User = user
+		req.Cmd.Source.User = user
+	}
+	token := req.Cmd.Token
+	// Requests failing identification or authorization count as well.
+	if limitErr := s.throttleClient(req); limitErr != nil {
+		err = limitErr
+	}
+	if err == nil {
+		err = s.authorize(req)
+	}
+	if err == nil {
+		err = s.throttleToken(token)
+	}
+	if err != nil {
+		s.logWarn("Refusing request:", err)
+		defer req.Close()
+		resp := msg.Response{}
+		resp.SetError(err)
+		s.Answer(req, resp)
+		return
+	}
+	if user == s.owner {
+		// Replaying with another user's commands would mix up their data.
+		s.record(req.Cmd)
+	}
+	err = s.serveAs(user, func() {
+		if err := s.Dispatch(req); err != nil {
+			s.logError(errors.Wrap(err, "Unable to execute command"))
+		}
+	})
+	if err != nil {
+		s.logError(err)
+		resp := msg.Response{}
+		resp.SetError(err)
+		s.Answer(req, resp)
+		req.Close()
 	}
 }
 
@@ -199,19 +565,60 @@ func (s *Server) Dispatch(req *Request) error {
 	command := req.Cmd.Op
 	op := operations[command]
 	if op == nil {
-		return errors.New("No such operation: " + command)
+		// Clients not using the command line, see `tilo raw`, await an answer.
+		defer req.Close()
+		err := errors.New("No such operation: " + command)
+		resp := msg.Response{}
+		resp.SetError(err)
+		s.Answer(req, resp)
+		return err
+	}
+	if skip := s.unmetCondition(req.Cmd.Condition); skip != "" {
+		// Not an error, so that scripts can issue the command regardless.
+		defer req.Close()
+		resp := msg.Response{}
+		resp.AddMessage("Skipped, " + skip)
+		return s.Answer(req, resp)
 	}
 	op.ServerExec(s, req)
 	return nil
 }
 
-// Send a notification to all registered listeners.
+// Describes why the command's condition is not met, empty if it is. The
+// condition is checked here, rather than by the client, so that it holds
+// when the command is executed.
+func (s *Server) unmetCondition(condition string) string {
+	switch {
+	case condition == config.IF_IDLE && s.CurrentTask.IsRunning():
+		return s.CurrentTask.Name + " is active"
+	case condition == config.IF_ACTIVE && !s.CurrentTask.IsRunning():
+		return "no task is active"
+	default:
+		return ""
+	}
+}
+
+// Inform all registered listeners about the current task.
 func (s *Server) notifyListeners() {
+	s.persistRunningTask(time.Now())
 	ntf := TaskNotification(s.CurrentTask)
+	if !s.foreign() {
+		s.publishState(ntf)
+	}
+	s.notify(ntf)
+}
+
+// Send a notification to all registered listeners.
+func (s *Server) notify(ntf Notification) {
+	ntf = s.sequence(ntf)
 	s.logDebug("Notifying listeners:", ntf)
 	if len(s.listeners) > 0 {
 		remainingListeners := make([]NotificationListener, 0)
 		for _, lst := range s.listeners {
+			if lst.User != s.user {
+				remainingListeners = append(remainingListeners, lst)
+				continue
+			}
 			if err := lst.Notify(ntf); err != nil {
 				s.logInfo("Could not notify listener, disconnecting:", err)
 				lst.disconnect()
@@ -225,7 +632,7 @@ func (s *Server) notifyListeners() {
 
 // Notify all connected listeners of shutdown and disconnect them.
 func (s *Server) disconnectAllListeners() {
-	ntf := shutdownNotification()
+	ntf := s.sequence(shutdownNotification())
 	for _, lst := range s.listeners {
 		lst.Notify(ntf)
 		if err := lst.disconnect(); err != nil {
@@ -238,26 +645,42 @@ func (s *Server) disconnectAllListeners() {
 func (s *Server) shutdown() {
 	var err error
 	s.logInfo("Shutting down server..")
+	s.InitiateShutdown()
 	// When the shutdown is initiated by a message, the task is stopped prior.
-	// If shutdown is in response to a signal, there is nothing else to do here.
-	s.StopCurrentTask()
+	// Otherwise, save it now to avoid losing it.
+	if task, stopped := s.StopCurrentTask(); stopped {
+		if notice, err := s.SaveSession(task); err != nil {
+			s.logError(err)
+		} else if notice != "" {
+			s.logInfo(notice)
+		}
+	}
+	s.closeWorkspaces()
+	s.runHookAndWait(hookShutdown, msg.Task{})
+	s.stopStateEndpoint()
+	s.stopWebhook()
+	s.publishState(shutdownNotification())
+	s.stopMQTT()
+	s.stopRecording()
 
 	if len(s.listeners) > 0 {
 		s.logInfo("Disconnecting listeners")
 		s.disconnectAllListeners()
 	}
 
-	s.logInfo("Closing socket..")
-	err = s.socketListener.Close()
-	if err != nil {
-		s.logError(err)
-	} else {
-		s.logInfo("OK")
+	// Normally closed while draining, unless the main loop failed.
+	if s.socketListener != nil {
+		s.logInfo("Closing socket..")
+		if err = s.socketListener.Close(); err != nil {
+			s.logError(err)
+		} else {
+			s.logInfo("OK")
+		}
 	}
 
-	// FIXME: Directory should probably not be removed unless in /tmp
-	s.logInfo("Removing temporary directory..")
-	err = os.RemoveAll(s.conf.SocketDir())
+	s.logInfo("Cleaning up..")
+	s.removePidFile()
+	err = transport.Cleanup(s.conf)
 	if err != nil {
 		s.logError(err)
 	} else {
@@ -270,7 +693,6 @@ func (s *Server) shutdown() {
 // TODO: Move to client package?
 // Start a server in a background process.
 func StartInBackground(conf *config.Opts) (int, error) {
-	sysProcAttr := syscall.SysProcAttr{}
 	// Prepare high-level process attributes
 	confDir := filepath.Dir(conf.ConfFile.Value)
 	if err := ensureDirExists(confDir); err != nil {
@@ -280,7 +702,7 @@ func StartInBackground(conf *config.Opts) (int, error) {
 		Dir:   confDir,
 		Env:   conf.MergeIntoEnv(os.Environ()),
 		Files: []*os.File{nil, nil, nil}, // stdin, stdout, stderr
-		Sys:   &sysProcAttr,
+		Sys:   detachedProcAttr(),
 	}
 
 	// No need to keep track of the spawned process
@@ -315,42 +737,62 @@ func (s *Server) logError(err error) {
 		return
 	}
 	if s.conf.ShouldLogAny() {
-		log.Println(err)
+		s.writeLog("error", fmt.Sprint(err))
 	}
 }
 
 func (s *Server) logWarn(msg ...interface{}) {
 	if s.conf.ShouldLogWarnings() {
-		log.Println(msg...)
+		s.writeLog(config.LOG_WARN, fmt.Sprintln(msg...))
 	}
 }
 
 func (s *Server) logFmtWarn(format string, v ...interface{}) {
 	if s.conf.ShouldLogWarnings() {
-		log.Printf(format, v...)
+		s.writeLog(config.LOG_WARN, fmt.Sprintf(format, v...))
 	}
 }
 
 func (s *Server) logInfo(msg ...interface{}) {
 	if s.conf.ShouldLogInfo() {
-		log.Println(msg...)
+		s.writeLog(config.LOG_INFO, fmt.Sprintln(msg...))
 	}
 }
 
 func (s *Server) logFmtInfo(format string, v ...interface{}) {
 	if s.conf.ShouldLogInfo() {
-		log.Printf(format, v...)
+		s.writeLog(config.LOG_INFO, fmt.Sprintf(format, v...))
 	}
 }
 
 func (s *Server) logDebug(msg ...interface{}) {
 	if s.conf.ShouldLogDebug() {
-		log.Println(msg...)
+		s.writeLog(config.LOG_DEBUG, fmt.Sprintln(msg...))
 	}
 }
 
 func (s *Server) logFmtDebug(format string, v ...interface{}) {
 	if s.conf.ShouldLogDebug() {
-		log.Printf(format, v...)
+		s.writeLog(config.LOG_DEBUG, fmt.Sprintf(format, v...))
+	}
+}
+
+// A log line in JSON, e.g. for container platforms collecting logs.
+type logEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+}
+
+// Write a log message in the configured format.
+func (s *Server) writeLog(level string, message string) {
+	message = strings.TrimSuffix(message, "\n")
+	if s.conf.LogFormat.Value != config.LOG_FORMAT_JSON {
+		log.Println(message)
+		return
+	}
+	entry := logEntry{Time: time.Now().Format(time.RFC3339Nano), Level: level, Message: message}
+	if data, err := json.Marshal(entry); err == nil {
+		os.Stdout.Write(append(data, '\n'))
 	}
 }
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/pkg/errors"
+)
+
+// The length below which sessions are discarded or merged. Zero if all
+// sessions are kept.
+func (s *Server) minSession() time.Duration {
+	min, err := time.ParseDuration(s.conf.MinSession.Value)
+	if err != nil || min < 0 {
+		s.logWarn("Ignoring invalid minimum session length:", s.conf.MinSession.Value)
+		return 0
+	}
+	return min
+}
+
+// MergeGap is the longest break between two entries of the same task for
+// which they are merged. Zero if entries are not merged.
+func (s *Server) MergeGap() time.Duration {
+	gap, err := time.ParseDuration(s.conf.MergeGap.Value)
+	if err != nil || gap < 0 {
+		s.logWarn("Ignoring invalid merge gap:", s.conf.MergeGap.Value)
+		return 0
+	}
+	return gap
+}
+
+// SaveSession saves a task stopped by the user. It is merged into the
+// preceding entry of the same task if that ended at most merge_gap before.
+// Otherwise, sessions shorter than min_session are discarded or, with
+// short_sessions set to merge, merged the same way if the preceding entry
+// ended at most min_session before. Sessions with notes are never discarded.
+// Sessions are not merged across the start of a day if split_at is set, nor
+// with an append-only backend.
+// Returns a notice if the session was not saved as is.
+func (s *Server) SaveSession(task msg.Task) (string, error) {
+	min := s.minSession()
+	length := task.Ended.Sub(task.Started)
+	short := length < min && len(task.Notes) == 0
+	gap := s.MergeGap()
+	if short && s.conf.ShortSessions.Value == config.SHORT_SESSIONS_MERGE && min > gap {
+		gap = min
+	}
+	if gap > 0 && !s.AppendOnly() {
+		prev, found, err := s.precedingEntry(task, gap)
+		if err != nil {
+			return "", errors.Wrap(err, "Unable to merge session")
+		}
+		notes := append(append([]string(nil), prev.Notes...), task.Notes...)
+		merged := msg.Task{Name: prev.Name, Started: prev.Started, Ended: task.Ended, HasEnded: true, Notes: notes, Source: prev.Source}
+		// A merged entry would be split again at the start of a day.
+		if found && len(s.savedParts(merged)) == 1 {
+			if err := s.SaveTask(merged); err != nil {
+				return "", err
+			}
+			// The notes of the preceding entry are present on the merged one
+			// already and dropped along with it.
+			dup := backend.Duplicate{Entry: prev, Of: merged}
+			if err := s.Backend.RemoveDuplicates([]backend.Duplicate{dup}); err != nil {
+				return "", errors.Wrap(err, "Unable to merge session")
+			}
+			s.recordChange(msg.NewChange(s.conf.DeviceID.Value, msg.ChangeRemoval, prev))
+			return "Merged session (" + length.String() + ") into the preceding entry", nil
+		}
+	}
+	if short {
+		s.logInfo("Discarding short session:", task)
+		return "Discarded short session (" + length.String() + ")", nil
+	}
+	if err := s.SaveTask(task); err != nil {
+		return "", err
+	}
+	if parts := len(s.savedParts(task)); parts > 1 {
+		return fmt.Sprintf("Split session into %d entries at %s", parts, s.conf.SplitAt.Value), nil
+	}
+	return "", nil
+}
+
+// Whether the backend cannot change or remove entries. Sessions are not merged
+// then, and commands replacing entries refuse to run.
+func (s *Server) AppendOnly() bool {
+	a, ok := backend.Unwrap(s.Backend).(backend.AppendOnly)
+	return ok && a.AppendOnly()
+}
+
+// The latest entry of the same task ending at most gap before the task
+// started.
+func (s *Server) precedingEntry(task msg.Task, gap time.Duration) (msg.Task, bool, error) {
+	var prev msg.Task
+	found := false
+	err := s.Backend.ForEachTaskBetween([]string{task.Name}, time.Unix(0, 0), task.Started.Add(time.Millisecond), func(t msg.Task) error {
+		if t.Ended.After(task.Started) || t.Ended.Before(task.Started.Add(-gap)) {
+			return nil
+		}
+		if !found || t.Ended.After(prev.Ended) {
+			prev = t
+			found = true
+		}
+		return nil
+	})
+	return prev, found, err
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// DayStart is the time of day at which entries are split, midnight or a
+// virtual midnight like 04:00 for those working late.
+type DayStart struct {
+	hour   int
+	minute int
+}
+
+// ParseDayStart reads a time of day given as HH:MM.
+func ParseDayStart(str string) (DayStart, error) {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return DayStart{}, errors.Errorf("Invalid time of day: %s", str)
+	}
+	return DayStart{hour: t.Hour(), minute: t.Minute()}, nil
+}
+
+// Next gives the first start of a day after t, in t's location.
+func (d DayStart) Next(t time.Time) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), d.hour, d.minute, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, d.hour, d.minute, 0, 0, t.Location())
+	}
+	return next
+}
+
+// Split divides the entry at each start of a day it spans. Notes stay with
+// the first part.
+func (d DayStart) Split(task msg.Task) []msg.Task {
+	var parts []msg.Task
+	part := task
+	for {
+		next := d.Next(part.Started.Local())
+		if !next.Before(part.Ended) {
+			return append(parts, part)
+		}
+		before := part
+		before.Ended = next
+		parts = append(parts, before)
+		part.Started = next
+		part.Notes = nil
+	}
+}
+
+// SplitAt gives the time of day at which saved entries are split, see
+// split_at. False if entries are kept whole.
+func (s *Server) SplitAt() (DayStart, bool) {
+	if s.conf.SplitAt.Value == "" {
+		return DayStart{}, false
+	}
+	d, err := ParseDayStart(s.conf.SplitAt.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid split time:", s.conf.SplitAt.Value)
+		return DayStart{}, false
+	}
+	return d, true
+}
+
+// The parts an entry is saved as, the entry itself unless splitting.
+func (s *Server) savedParts(task msg.Task) []msg.Task {
+	if d, ok := s.SplitAt(); ok {
+		return d.Split(task)
+	}
+	return []msg.Task{task}
+}
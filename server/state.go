@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The state reported by the HTTP endpoint. The layout is suitable for a Home
+// Assistant REST sensor, with the task as the state and the rest as
+// attributes.
+type stateReport struct {
+	State          string     `json:"state"` // The current task or "idle"
+	Task           string     `json:"task"`  // The current task; empty if idle
+	Running        bool       `json:"running"`
+	Since          *time.Time `json:"since,omitempty"` // Start of the current task
+	ElapsedSeconds int64      `json:"elapsed_seconds"` // Time spent on the current task
+	TodaySeconds   int64      `json:"today_seconds"`   // Total time tracked today
+}
+
+// Serve the current state via HTTP, if configured. Requests are passed to the
+// main loop to avoid concurrent access to server state.
+func (s *Server) startStateEndpoint() error {
+	if s.conf.StateAddress.Value == "" {
+		return nil
+	}
+	lst, err := net.Listen("tcp", s.conf.StateAddress.Value)
+	if err != nil {
+		return errors.Wrap(err, "Unable to serve state endpoint")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/calendar.ics", s.serveCalendar)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	mux.HandleFunc("/", s.serveState)
+	s.stateServer = &http.Server{Handler: mux}
+	go func() {
+		if err := s.stateServer.Serve(lst); err != http.ErrServerClosed {
+			s.logError(errors.Wrap(err, "State endpoint failed"))
+		}
+	}()
+	return nil
+}
+
+// Stop serving the state endpoint.
+func (s *Server) stopStateEndpoint() {
+	if s.stateServer != nil {
+		s.stateServer.Close()
+		s.stateServer = nil
+	}
+}
+
+func (s *Server) serveState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply := make(chan stateReport, 1)
+	select {
+	case s.stateRequests <- reply:
+	case <-time.After(5 * time.Second):
+		http.Error(w, "Server busy", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(<-reply)
+}
+
+// Gather the current state, including today's total.
+func (s *Server) currentState(now time.Time) stateReport {
+	report := stateReport{State: "idle"}
+	_, today, err := s.TodaysSessions(now)
+	if err != nil {
+		s.logError(errors.Wrap(err, "Failed to determine today's total"))
+	}
+	if task := s.CurrentTask; task.IsRunning() {
+		report.State = task.Name
+		report.Task = task.Name
+		report.Running = true
+		report.Since = &task.Started
+		report.ElapsedSeconds = int64(now.Sub(task.Started).Seconds())
+	}
+	report.TodaySeconds = int64(today.Seconds())
+	return report
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/pkg/errors"
+)
+
+// Record a change for synchronization. Failure is logged but not fatal.
+func (s *Server) recordChange(change msg.Change) {
+	if err := s.Backend.RecordChange(change); err != nil {
+		s.logError(errors.Wrap(err, "Failed to record change"))
+	}
+}
+
+// Fill an empty change log with the existing entries so that data recorded
+// prior to synchronization is included.
+func (s *Server) seedChangeLog() error {
+	empty := true
+	err := s.Backend.ForEachChange(func(msg.Change) error {
+		empty = false
+		return errStopIteration
+	})
+	if err != nil && err != errStopIteration {
+		return err
+	}
+	if !empty {
+		return nil
+	}
+	// Gathered first as the backend may not allow writing while iterating.
+	var changes []msg.Change
+	device := s.conf.DeviceID.Value
+	err = s.Backend.ForEachTaskBetween(nil, time.Unix(0, 0), time.Now().AddDate(1, 0, 0), func(task msg.Task) error {
+		changes = append(changes, msg.NewChange(device, msg.ChangeEntry, task))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := s.Backend.RecordChange(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+// MergeChanges applies the changes made on other devices in a deterministic
+// order. Returns the number of changes not known before.
+func (s *Server) MergeChanges(changes []msg.Change) (int, error) {
+	msg.SortChanges(changes)
+	merged := 0
+	for _, change := range changes {
+		if change.Kind == msg.ChangeEntry || change.Kind == msg.ChangeRemoval {
+			if err := change.Task.Validate(); err != nil {
+				return merged, errors.Wrapf(err, "Invalid change %s", change.ID)
+			}
+		}
+		isNew, err := s.Backend.ApplyChange(change)
+		if err != nil {
+			return merged, errors.Wrap(err, "Failed to merge changes")
+		}
+		if isNew {
+			merged++
+		}
+	}
+	if merged > 0 {
+		s.logInfo("Merged changes:", merged)
+	}
+	return merged, nil
+}
+
+// NewChanges lists the changes not known before in the order in which they
+// would be applied, without applying them.
+func (s *Server) NewChanges(changes []msg.Change) ([]msg.Change, error) {
+	known := make(map[string]bool)
+	err := s.Backend.ForEachChange(func(change msg.Change) error {
+		known[change.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read the change log")
+	}
+	msg.SortChanges(changes)
+	var result []msg.Change
+	for _, change := range changes {
+		if !known[change.ID] {
+			known[change.ID] = true
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
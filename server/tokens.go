@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/transport"
+	"github.com/pkg/errors"
+)
+
+// ReadOnlyOperation is implemented by operations which never alter any data
+// and may hence be run with read-only API tokens or at a fixed time, see
+// --now.
+type ReadOnlyOperation interface {
+	Operation
+	ReadOnly() bool
+}
+
+// ReadOnlySubcommands is implemented by operations of which only some
+// subcommands alter data, e.g. listing and importing entries.
+type ReadOnlySubcommands interface {
+	Operation
+	ReadOnlyCommand(cmd msg.Cmd) bool
+}
+
+// IsReadOnly tells whether the command never alters any data, see
+// ReadOnlyOperation and ReadOnlySubcommands.
+func IsReadOnly(op interface{}, cmd msg.Cmd) bool {
+	switch op := op.(type) {
+	case ReadOnlySubcommands:
+		return op.ReadOnlyCommand(cmd)
+	case ReadOnlyOperation:
+		return op.ReadOnly()
+	}
+	return false
+}
+
+// An API token and what it permits.
+type apiToken struct {
+	token string
+	scope string // One of the config.SCOPE_* constants
+}
+
+// Parse tokens with their scope, given as e.g. abc=read,def=full.
+func parseTokens(conf string) ([]apiToken, error) {
+	var tokens []apiToken
+	for _, entry := range strings.Split(conf, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			// Not quoting the entry, it is meant to be secret.
+			return nil, errors.Errorf("Invalid entry #%d", len(tokens)+1)
+		}
+		switch scope := strings.TrimSpace(kv[1]); scope {
+		case config.SCOPE_READ, config.SCOPE_FULL:
+			tokens = append(tokens, apiToken{token: strings.TrimSpace(kv[0]), scope: scope})
+		default:
+			return nil, errors.Errorf("Invalid scope: %s", scope)
+		}
+	}
+	return tokens, nil
+}
+
+// Load the configured API tokens. Unlike with budgets, invalid configuration
+// is an error as it might leave the server more open than intended.
+func (s *Server) loadTokens() error {
+	tokens, err := parseTokens(s.conf.APITokens.Value)
+	if err != nil {
+		return errors.Wrap(err, "Invalid API tokens")
+	}
+	s.tokens = tokens
+	return nil
+}
+
+// The scope of a known token.
+func (s *Server) tokenScope(token string) (string, bool) {
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(t.token), []byte(token)) == 1 {
+			return t.scope, true
+		}
+	}
+	return "", false
+}
+
+// Check whether the request's token permits the operation. The token is
+// removed from the request to keep it out of logs and recordings.
+//
+// Requests without a token are permitted unless tokens are configured and the
+// server is reachable via the network. The unix socket is protected by file
+// permissions instead.
+func (s *Server) authorize(req *Request) error {
+	token := req.Cmd.Token
+	req.Cmd.Token = ""
+	if token == "" {
+		if len(s.tokens) > 0 && s.conf.Protocol.Value != transport.Unix {
+			return errors.New("API token required")
+		}
+		return nil
+	}
+	scope, ok := s.tokenScope(token)
+	if !ok {
+		return errors.New("Invalid API token")
+	}
+	if scope == config.SCOPE_READ {
+		if !IsReadOnly(operations[req.Cmd.Op], req.Cmd) {
+			return errors.New("Not permitted with a read-only token: " + req.Cmd.Op)
+		}
+	}
+	return nil
+}
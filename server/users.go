@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/backend"
+	"github.com/fgahr/tilo/transport"
+	"github.com/pkg/errors"
+)
+
+// In multi-user mode, each user of a shared server has a workspace of their
+// own: a backend holding their data and their running task. The server owner,
+// i.e. the user running the server, works with the server's own backend.
+//
+// Requests are served one at a time, so a user's workspace is swapped in for
+// the duration of their request. Everything happening outside of requests,
+// e.g. budget warnings, hooks and MQTT, concerns the owner only.
+type workspace struct {
+	backend backend.Backend
+	current msg.Task
+}
+
+// User names double as file names, hence the restrictions.
+var validUser = regexp.MustCompile(`^[a-zA-Z0-9_@-][a-zA-Z0-9_@.-]*$`)
+
+// Whether data is kept separate per user.
+func (s *Server) multiUser() bool {
+	return s.conf.MultiUser.Value == "true"
+}
+
+// Prepare multi-user mode, if enabled.
+func (s *Server) initUsers() error {
+	if !s.multiUser() {
+		return nil
+	}
+	if s.conf.Protocol.Value != transport.TLS || s.conf.TLSCA.Value == "" {
+		return errors.New("Multi-user mode requires the tls protocol with client certificates, see tls_ca")
+	}
+	if _, ok := backend.Unwrap(s.Backend).(backend.PerUser); !ok {
+		return errors.Errorf("Backend %s does not support multi-user mode", s.Backend.Name())
+	}
+	u, err := user.Current()
+	if err != nil {
+		return errors.Wrap(err, "Unable to determine the server owner")
+	}
+	s.owner = u.Username
+	s.user = s.owner
+	s.ownBackend = s.Backend
+	s.workspaces = make(map[string]*workspace)
+	return nil
+}
+
+// Determine the user a connection belongs to from their client certificate.
+// Empty unless in multi-user mode.
+func (s *Server) identify(conn net.Conn) (string, error) {
+	if !s.multiUser() {
+		return "", nil
+	}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", errors.New("Cannot identify user without TLS")
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("Cannot identify user without client certificate")
+	}
+	name := certs[0].Subject.CommonName
+	if !validUser.MatchString(name) {
+		return "", errors.Errorf("Invalid user name in client certificate: %q", name)
+	}
+	return name, nil
+}
+
+// Whether the request was made by the server owner or one of the configured
+// admins. Always true unless in multi-user mode.
+func (s *Server) IsAdmin(req *Request) bool {
+	if !s.multiUser() || req.User == s.owner {
+		return true
+	}
+	for _, admin := range strings.Split(s.conf.Admins.Value, ",") {
+		if strings.TrimSpace(admin) == req.User {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamVisibility gives what the user making the request may see of other
+// users in team reports, one of the config.VISIBILITY_* constants.
+func (s *Server) TeamVisibility(req *Request) (string, error) {
+	item := s.conf.TeamVisibilityMembers
+	if s.IsAdmin(req) {
+		item = s.conf.TeamVisibilityAdmins
+	}
+	switch item.Value {
+	case config.VISIBILITY_NONE, config.VISIBILITY_TOTALS, config.VISIBILITY_TASKS:
+		return item.Value, nil
+	default:
+		return config.VISIBILITY_NONE, errors.Errorf("Invalid %s: %s", item.InFile, item.Value)
+	}
+}
+
+// Whether a request of a user other than the server owner is being served.
+func (s *Server) foreign() bool {
+	return s.user != s.owner
+}
+
+// The user's workspace, opening their backend when first needed.
+func (s *Server) workspace(name string) (*workspace, error) {
+	if ws, ok := s.workspaces[name]; ok {
+		return ws, nil
+	}
+	b, err := backend.Unwrap(s.ownBackend).(backend.PerUser).ForUser(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Init(); err != nil {
+		return nil, errors.Wrap(err, "Unable to open data of "+name)
+	}
+	ws := &workspace{backend: s.wrap(b), current: msg.IdleTask()}
+	s.workspaces[name] = ws
+	return ws, nil
+}
+
+// Run fn on behalf of the user, with their backend and running task in place
+// of the owner's.
+func (s *Server) serveAs(name string, fn func()) error {
+	if name == s.owner {
+		fn()
+		return nil
+	}
+	ws, err := s.workspace(name)
+	if err != nil {
+		return err
+	}
+	ownBackend, ownTask := s.Backend, s.CurrentTask
+	s.Backend, s.CurrentTask, s.user = ws.backend, ws.current, name
+	defer func() {
+		ws.current = s.CurrentTask
+		s.Backend, s.CurrentTask, s.user = ownBackend, ownTask, s.owner
+	}()
+	fn()
+	return nil
+}
+
+// ForEachUser calls fn with the backend of every user with data stored,
+// including the server owner, e.g. for team queries. Iteration stops at the
+// first error.
+func (s *Server) ForEachUser(fn func(user string, b backend.Backend) error) error {
+	if !s.multiUser() {
+		return errors.New("Only available in multi-user mode, see multi_user")
+	}
+	users, err := backend.Unwrap(s.ownBackend).(backend.PerUser).Users()
+	if err != nil {
+		return err
+	}
+	if err := fn(s.owner, s.ownBackend); err != nil {
+		return err
+	}
+	for _, name := range users {
+		if name == s.owner {
+			continue
+		}
+		ws, err := s.workspace(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(name, ws.backend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Whether any user other than the owner has a running task.
+func (s *Server) othersBusy() bool {
+	for _, ws := range s.workspaces {
+		if ws.current.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// Save the running tasks of all users but the owner and close their backends.
+func (s *Server) closeWorkspaces() {
+	for name, ws := range s.workspaces {
+		s.serveAs(name, func() {
+			if task, stopped := s.StopCurrentTask(); stopped {
+				if _, err := s.SaveSession(task); err != nil {
+					s.logError(errors.Wrap(err, "Unable to save task of "+name))
+				}
+			}
+		})
+		if err := ws.backend.Close(); err != nil {
+			s.logWarn("Error closing data of", name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Support for the systemd watchdog, see sd_notify(3) and sd_watchdog_enabled(3).
+
+// The interval within which systemd expects to be notified; zero if the
+// watchdog is not enabled for this process.
+func watchdogInterval() time.Duration {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Send a state change like WATCHDOG=1 to systemd, if it is listening.
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	// Names starting with @ are in the abstract namespace, which is taken care of.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
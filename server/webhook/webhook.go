@@ -0,0 +1,93 @@
+// Package webhook posts messages to chat services like Slack or Mattermost via
+// incoming webhooks.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Messages waiting to be posted beyond this number are dropped.
+const queueSize = 32
+
+// Dispatcher posts messages in the background, in order.
+type Dispatcher struct {
+	url     string
+	client  *http.Client
+	queue   chan string
+	done    chan struct{}
+	onError func(error)
+}
+
+// NewDispatcher starts a dispatcher posting to the webhook at url. Errors are
+// passed to onError.
+func NewDispatcher(url string, onError func(error)) *Dispatcher {
+	d := &Dispatcher{
+		url:     url,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan string, queueSize),
+		done:    make(chan struct{}),
+		onError: onError,
+	}
+	go d.run()
+	return d
+}
+
+// Post queues a message without waiting for it to be sent.
+func (d *Dispatcher) Post(text string) {
+	select {
+	case d.queue <- text:
+	default:
+		d.onError(errors.New("Webhook queue full, dropping message"))
+	}
+}
+
+// Close sends all queued messages and stops the dispatcher.
+func (d *Dispatcher) Close() {
+	close(d.queue)
+	<-d.done
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for text := range d.queue {
+		if err := d.send(text); err != nil {
+			d.onError(err)
+		}
+	}
+}
+
+// Both Slack and Mattermost accept a JSON object with a text field.
+func (d *Dispatcher) send(text string) error {
+	data, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Post(d.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "Failed to post to webhook")
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("Webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Render a message template with the given data.
+func Render(text string, data interface{}) (string, error) {
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err, "Invalid message template")
+	}
+	out := bytes.Buffer{}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", errors.Wrap(err, "Invalid message template")
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server/webhook"
+	"github.com/pkg/errors"
+)
+
+// Fields available in templates for task changes.
+type webhookEvent struct {
+	Event    string
+	Task     string
+	Started  string
+	Ended    string
+	Duration time.Duration
+}
+
+// Fields available in the summary template.
+type webhookSummary struct {
+	Date  string
+	Total time.Duration
+	Tasks []webhookTaskTotal
+}
+
+type webhookTaskTotal struct {
+	Task  string
+	Total time.Duration
+}
+
+// Start posting to the configured webhook, if any.
+func (s *Server) startWebhook() {
+	if s.conf.WebhookURL.Value != "" {
+		s.webhook = webhook.NewDispatcher(s.conf.WebhookURL.Value, s.logError)
+	}
+}
+
+// Post all pending messages and stop posting.
+func (s *Server) stopWebhook() {
+	if s.webhook != nil {
+		s.webhook.Close()
+		s.webhook = nil
+	}
+}
+
+// Post a task change to the webhook.
+func (s *Server) postTaskEvent(event string, task msg.Task) {
+	if s.webhook == nil {
+		return
+	}
+	var tmpl string
+	switch event {
+	case msg.RespStartTask:
+		tmpl = s.conf.WebhookStart.Value
+	case msg.RespStopTask:
+		tmpl = s.conf.WebhookStop.Value
+	case msg.RespAbortTask:
+		tmpl = s.conf.WebhookAbort.Value
+	}
+	if tmpl == "" {
+		return
+	}
+	data := webhookEvent{Event: event, Task: task.Name, Started: task.Started.Format("15:04")}
+	if task.HasEnded {
+		data.Ended = task.Ended.Format("15:04")
+		data.Duration = task.Ended.Sub(task.Started)
+	}
+	s.postTemplate(tmpl, data)
+}
+
+// The next time a daily summary is due. Returns false if disabled.
+func (s *Server) nextWebhookSummary(now time.Time) (time.Time, bool) {
+	if s.webhook == nil || s.conf.WebhookSummaryTime.Value == "" {
+		return now, false
+	}
+	t, err := time.Parse("15:04", s.conf.WebhookSummaryTime.Value)
+	if err != nil {
+		s.logWarn("Ignoring invalid summary time:", s.conf.WebhookSummaryTime.Value)
+		return now, false
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, true
+}
+
+// Post a summary of today's activity to the webhook.
+func (s *Server) postDailySummary(now time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	sum, err := s.Backend.GetAllTasksBetween(start, now, msg.Source{})
+	if err != nil {
+		s.logError(errors.Wrap(err, "Failed to gather daily summary"))
+		return
+	}
+	data := webhookSummary{Date: start.Format("2006-01-02")}
+	for _, row := range sum {
+		data.Tasks = append(data.Tasks, webhookTaskTotal{Task: row.Task, Total: row.Total})
+		data.Total += row.Total
+	}
+	s.postTemplate(s.conf.WebhookSummary.Value, data)
+}
+
+func (s *Server) postTemplate(tmpl string, data interface{}) {
+	if text, err := webhook.Render(tmpl, data); err != nil {
+		s.logError(err)
+	} else {
+		s.webhook.Post(text)
+	}
+}
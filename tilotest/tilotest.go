@@ -0,0 +1,123 @@
+// Package tilotest runs a server with an in-memory backend on a temporary
+// socket so that commands can be tested end to end.
+//
+// Commands register themselves on import, so a test has to import every
+// command it intends to run, e.g.
+//
+//	import _ "github.com/fgahr/tilo/command/start"
+package tilotest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fgahr/tilo/client"
+	_ "github.com/fgahr/tilo/command/shutdown"
+	"github.com/fgahr/tilo/config"
+	"github.com/fgahr/tilo/msg"
+	"github.com/fgahr/tilo/server"
+	_ "github.com/fgahr/tilo/server/backend/memory"
+)
+
+// How long to wait for the server to come up or go down.
+const startupTimeout = 5 * time.Second
+
+// Server is a server running in the background of a test.
+type Server struct {
+	Conf *config.Opts
+	t    *testing.T
+	dir  string
+	done chan error
+}
+
+// StartServer starts a server with an empty backend. Further configuration
+// can be given as arguments, e.g. "--merge-gap=1m", for settings which only
+// take effect on startup. Call Stop when done.
+func StartServer(t *testing.T, extra ...string) *Server {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "tilotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := []string{
+		"--conf-file=" + filepath.Join(dir, "config"),
+		"--socket=" + filepath.Join(dir, "run", "server"),
+		"--backend=memory",
+		"--spawn=" + config.SPAWN_NEVER,
+		"--log-level=" + config.LOG_OFF,
+	}
+	args = append(args, extra...)
+	conf, _, err := config.GetConfig(args, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	s := &Server{Conf: conf, t: t, dir: dir, done: make(chan error, 1)}
+	go func() {
+		s.done <- server.Run(conf)
+	}()
+	deadline := time.Now().Add(startupTimeout)
+	for {
+		if up, _ := server.IsRunning(conf); up {
+			return s
+		}
+		select {
+		case err := <-s.done:
+			os.RemoveAll(dir)
+			t.Fatal("server stopped during startup:", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			os.RemoveAll(dir)
+			t.Fatal("server did not start in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Run executes a command, e.g. Run("start", "foo"), returning its output.
+func (s *Server) Run(args ...string) (string, error) {
+	var out, msgout bytes.Buffer
+	err := client.Execute(s.Conf, args, &out, &msgout)
+	return out.String(), err
+}
+
+// MustRun is like Run but fails the test if the command fails.
+func (s *Server) MustRun(args ...string) string {
+	s.t.Helper()
+	out, err := s.Run(args...)
+	if err != nil {
+		s.t.Fatalf("tilo %v: %v", args, err)
+	}
+	return out
+}
+
+// At fixes the time seen by the server, e.g. to create tasks of known
+// length. Stop restores the real clock.
+func (s *Server) At(t time.Time) {
+	msg.Clock = func() time.Time { return t }
+}
+
+// Stop shuts down the server and removes all temporary files.
+func (s *Server) Stop() {
+	s.t.Helper()
+	defer os.RemoveAll(s.dir)
+	defer func() { msg.Clock = time.Now }()
+	if _, err := s.Run("shutdown"); err != nil {
+		s.t.Error("shutdown failed:", err)
+		return
+	}
+	select {
+	case err := <-s.done:
+		if err != nil {
+			s.t.Error("server failed:", err)
+		}
+	case <-time.After(startupTimeout):
+		s.t.Error("server did not stop in time")
+	}
+}
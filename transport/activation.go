@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Socket activation by systemd, see sd_listen_fds(3). The socket belongs to
+// systemd and outlives the server, which is started on the first connection.
+
+// The first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+// Whether the server's listener was passed by systemd.
+var activated bool
+
+// Activated tells whether the server is started via socket activation.
+func Activated() bool {
+	if activated {
+		return true
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && n > 0 && os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid())
+}
+
+// The listener passed by systemd. The variables describing it are removed so
+// as not to pass them on to hooks.
+func activatedListener() (net.Listener, error) {
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	f := os.NewFile(listenFdsStart, "systemd socket")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to use the socket passed by systemd")
+	}
+	activated = true
+	return l, nil
+}
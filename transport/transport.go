@@ -0,0 +1,179 @@
+// Package transport establishes connections between client and server based
+// on the configured protocol. The configured socket is the address to use,
+// i.e. a file for unix sockets and host:port otherwise.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fgahr/tilo/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	Unix      = "unix"
+	TCP       = "tcp"
+	TLS       = "tls"
+	NamedPipe = "npipe"
+)
+
+// How long to wait when checking whether a network server is up.
+const probeTimeout = time.Second
+
+// Dial connects to the server.
+func Dial(conf *config.Opts) (net.Conn, error) {
+	address := conf.Socket.Value
+	switch conf.Protocol.Value {
+	case Unix, TCP:
+		return net.Dial(conf.Protocol.Value, address)
+	case TLS:
+		tlsConf, err := clientTLSConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		return tls.Dial("tcp", address, tlsConf)
+	default:
+		return nil, unsupported(conf.Protocol.Value)
+	}
+}
+
+// Listen opens the server's listener, creating the socket's directory if
+// required. Under socket activation, the socket passed by systemd is used.
+func Listen(conf *config.Opts) (net.Listener, error) {
+	if Activated() {
+		l, err := activatedListener()
+		if err != nil || conf.Protocol.Value != TLS {
+			return l, err
+		}
+		tlsConf, err := serverTLSConfig(conf)
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		return tls.NewListener(l, tlsConf), nil
+	}
+	address := conf.Socket.Value
+	switch conf.Protocol.Value {
+	case Unix:
+		if err := os.MkdirAll(filepath.Dir(address), 0700); err != nil {
+			return nil, err
+		}
+		return net.Listen(Unix, address)
+	case TCP:
+		return net.Listen(TCP, address)
+	case TLS:
+		tlsConf, err := serverTLSConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		return tls.Listen("tcp", address, tlsConf)
+	default:
+		return nil, unsupported(conf.Protocol.Value)
+	}
+}
+
+// IsServerUp determines whether a server is listening. For unix sockets, the
+// existence of the socket file is taken as an indication.
+func IsServerUp(conf *config.Opts) (bool, error) {
+	switch conf.Protocol.Value {
+	case Unix:
+		_, err := os.Stat(conf.Socket.Value)
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		return true, nil
+	case TCP, TLS:
+		// A plain connection suffices, there is no need for a handshake.
+		conn, err := net.DialTimeout("tcp", conf.Socket.Value, probeTimeout)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	default:
+		return false, unsupported(conf.Protocol.Value)
+	}
+}
+
+// Cleanup removes anything left behind by the listener. Only required for
+// unix sockets.
+func Cleanup(conf *config.Opts) error {
+	if conf.Protocol.Value != Unix || activated {
+		return nil
+	}
+	// FIXME: Directory should probably not be removed unless in /tmp
+	return os.RemoveAll(filepath.Dir(conf.Socket.Value))
+}
+
+func unsupported(protocol string) error {
+	if protocol == NamedPipe {
+		return errors.New("Named pipes are not supported by this build")
+	}
+	return errors.Errorf("Unknown protocol: %s (expected %s, %s, %s)", protocol, Unix, TCP, TLS)
+}
+
+// The server presents its certificate. If a CA is configured, clients must
+// present a certificate signed by it.
+func serverTLSConfig(conf *config.Opts) (*tls.Config, error) {
+	if conf.TLSCert.Value == "" || conf.TLSKey.Value == "" {
+		return nil, errors.New("TLS requires a certificate and key")
+	}
+	cert, err := tls.LoadX509KeyPair(conf.TLSCert.Value, conf.TLSKey.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to load TLS certificate")
+	}
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if conf.TLSCA.Value != "" {
+		pool, err := loadCA(conf.TLSCA.Value)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConf, nil
+}
+
+// The client verifies the server against the configured CA, or the system's
+// if none is given, and presents its own certificate if configured.
+func clientTLSConfig(conf *config.Opts) (*tls.Config, error) {
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if host, _, err := net.SplitHostPort(conf.Socket.Value); err == nil {
+		tlsConf.ServerName = host
+	}
+	if conf.TLSCA.Value != "" {
+		pool, err := loadCA(conf.TLSCA.Value)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.TLSCert.Value != "" && conf.TLSKey.Value != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TLSCert.Value, conf.TLSKey.Value)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to load TLS certificate")
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
+
+func loadCA(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read CA certificate")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Errorf("No certificates found in %s", file)
+	}
+	return pool, nil
+}